@@ -0,0 +1,101 @@
+// Package catalog contains the templates that are built into genny, so that
+// genny get can generate them without fetching anything.
+//
+// The templates are in the directories of the package, and sources.go has
+// their contents. Run go generate after changing them.
+package catalog
+
+//go:generate go run gen.go
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Tag is the build tag of the templates, which keeps them out of builds. It
+// is stripped from the generated code.
+const Tag = "genny"
+
+// Template is a template of the catalog.
+type Template struct {
+	// Name is the path of the template in the catalog, like set/set.go.
+	Name string
+	// Source is the content of the template. The templates of the catalog
+	// are preprocessed, see parse.Options.Preprocess.
+	Source string
+	// Defaults are the parameters of the template that a type set may leave
+	// out, with their default values.
+	Defaults map[string]string
+	// Description tells what the template is for.
+	Description string
+}
+
+// descriptions tell what the templates are for.
+var descriptions = map[string]string{
+	"maps/map.go":            "A map with Get, Set, Delete and Keys, optionally guarded by a mutex.",
+	"option/option.go":       "A value that may be missing, with Map, OrElse and Unwrap.",
+	"pool/pool.go":           "A sync.Pool of the type, without type assertions at the call sites.",
+	"queue/queue.go":         "A first in, first out queue, optionally guarded by a mutex.",
+	"result/result.go":       "A value or an error, with Map, OrElse and Unwrap.",
+	"set/set.go":             "A set of comparable items, optionally guarded by a mutex.",
+	"sortedmap/sortedmap.go": "A map that keeps its ordered keys sorted in a skip list.",
+}
+
+// defaults are the default parameters of the templates. ThreadSafe=true
+// guards the operations of a template with a sync.RWMutex.
+var defaults = map[string]map[string]string{
+	"maps/map.go":            {"ThreadSafe": "false"},
+	"queue/queue.go":         {"ThreadSafe": "false"},
+	"set/set.go":             {"ThreadSafe": "false"},
+	"sortedmap/sortedmap.go": {"ThreadSafe": "false"},
+}
+
+// Lookup gets the template with the name, and false if there is none.
+func Lookup(name string) (Template, bool) {
+	source, ok := sources[name]
+	if !ok {
+		return Template{}, false
+	}
+	return Template{Name: name, Source: source, Defaults: defaults[name], Description: descriptions[name]}, true
+}
+
+// Names gets the names of all the templates, sorted.
+func Names() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reGenericDecl matches the declaration of a generic type, like
+// type Elem generic.Type.
+var reGenericDecl = regexp.MustCompile(`(?m)^type (\w+) generic\.\w+`)
+
+// Generics gets the generic types of the template, which a type set gives
+// the specific types of.
+func (t Template) Generics() []string {
+	var generics []string
+	for _, m := range reGenericDecl.FindAllStringSubmatch(t.Source, -1) {
+		generics = append(generics, m[1])
+	}
+	return generics
+}
+
+// TypeSets gets the type sets with the defaults of the template added to
+// each of them.
+func (t Template) TypeSets(typeSets []map[string]string) []map[string]string {
+	result := make([]map[string]string, 0, len(typeSets))
+	for _, typeSet := range typeSets {
+		withDefaults := make(map[string]string, len(typeSet)+len(t.Defaults))
+		for param, value := range t.Defaults {
+			withDefaults[param] = value
+		}
+		for generic, specific := range typeSet {
+			withDefaults[generic] = specific
+		}
+		result = append(result, withDefaults)
+	}
+	return result
+}
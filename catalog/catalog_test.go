@@ -0,0 +1,104 @@
+package catalog_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/mauricelam/genny/catalog"
+	"github.com/mauricelam/genny/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+// types are type sets for the generic types of each template.
+var types = map[string]map[string]string{
+	"maps/map.go":            {"KeyType": "string", "ValueType": "int"},
+	"option/option.go":       {"Elem": "int"},
+	"pool/pool.go":           {"Elem": "bytes.Buffer"},
+	"queue/queue.go":         {"Elem": "int"},
+	"result/result.go":       {"Elem": "int"},
+	"set/set.go":             {"Elem": "int"},
+	"sortedmap/sortedmap.go": {"KeyType": "string", "ValueType": "int"},
+}
+
+func TestSources(t *testing.T) {
+	for _, name := range catalog.Names() {
+		template, ok := catalog.Lookup(name)
+		assert.True(t, ok, name)
+		src, err := ioutil.ReadFile(name)
+		if assert.NoError(t, err) {
+			assert.Equal(t, string(src), template.Source, "%s changed, run go generate", name)
+		}
+	}
+	_, ok := catalog.Lookup("missing.go")
+	assert.False(t, ok)
+}
+
+func TestThreadSafe(t *testing.T) {
+	for _, name := range catalog.Names() {
+		template, _ := catalog.Lookup(name)
+		threadSafes := []string{""}
+		if _, ok := template.Defaults["ThreadSafe"]; ok {
+			threadSafes = append(threadSafes, "false", "true")
+		}
+		for _, threadSafe := range threadSafes {
+			typeSet := make(map[string]string)
+			for generic, specific := range types[name] {
+				typeSet[generic] = specific
+			}
+			if threadSafe != "" {
+				typeSet["ThreadSafe"] = threadSafe
+			}
+			code, err := parse.GenericsTemplates(
+				[]parse.Template{{Filename: name, Source: strings.NewReader(template.Source)}},
+				template.TypeSets([]map[string]string{typeSet}),
+				parse.Options{StripTags: []string{catalog.Tag}, Preprocess: true},
+			)
+			if !assert.NoError(t, err, name) {
+				continue
+			}
+			if threadSafe == "true" {
+				assert.Contains(t, string(code), "sync.RWMutex", name)
+			} else {
+				assert.NotContains(t, string(code), "sync.RWMutex", name)
+			}
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	builtIn := catalog.BuiltIn()
+	assert.Len(t, builtIn.Templates, len(catalog.Names()))
+	for _, entry := range builtIn.Templates {
+		assert.NotEmpty(t, entry.Description, entry.Name)
+		assert.True(t, entry.BuiltIn)
+		for generic := range types[entry.Name] {
+			assert.Contains(t, entry.Generics, generic, entry.Name)
+		}
+	}
+
+	published, err := catalog.ParseIndex([]byte(`{"templates": [
+		{"name": "maps/concurrentmap.go", "description": "A map safe for concurrent use.", "generics": ["KeyType", "ValueType"]},
+		{"name": "set/set.go", "description": "Another set."},
+		{"name": "https://example.com/ring.go", "description": "A ring buffer.", "generics": ["Elem"]}
+	]}`))
+	assert.NoError(t, err)
+	index := builtIn.Merge(published)
+	assert.Len(t, index.Templates, len(builtIn.Templates)+2)
+
+	var names []string
+	for _, entry := range index.Search("MAP keytype") {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"maps/concurrentmap.go", "maps/map.go", "sortedmap/sortedmap.go"}, names)
+	// the built-in set wins over the published one
+	found := index.Search("set/set.go")
+	if assert.Len(t, found, 1) {
+		assert.True(t, found[0].BuiltIn)
+	}
+	assert.Len(t, index.Search(""), len(index.Templates))
+	assert.Empty(t, index.Search("ring mutex"))
+
+	_, err = catalog.ParseIndex([]byte("not json"))
+	assert.Error(t, err)
+}
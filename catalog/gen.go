@@ -0,0 +1,59 @@
+//go:build ignore
+
+// gen writes sources.go with the contents of the templates of the catalog.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go. DO NOT EDIT.\n\npackage catalog\n\n")
+	buf.WriteString("// sources are the contents of the templates, by their names.\n")
+	buf.WriteString("var sources = map[string]string{\n")
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// the templates are in the directories of the package
+		if info.IsDir() || filepath.Dir(path) == "." || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(filepath.ToSlash(path)), quote(string(src)))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("sources.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quote writes s as a raw string literal if it can, to keep the sources
+// readable.
+func quote(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
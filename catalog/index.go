@@ -0,0 +1,85 @@
+package catalog
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Entry is a published template in an index.
+type Entry struct {
+	// Name is what genny get fetches, a path in the online library or a
+	// URL.
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Generics are the generic types of the template.
+	Generics []string `json:"generics"`
+	// BuiltIn is true for the templates of the catalog.
+	BuiltIn bool `json:"-"`
+}
+
+// Index is an index of published templates, in JSON like
+//
+//	{"templates": [{"name": "maps/concurrentmap.go", "description": "...", "generics": ["KeyType", "ValueType"]}]}
+type Index struct {
+	Templates []Entry `json:"templates"`
+}
+
+// ParseIndex parses the JSON of an index.
+func ParseIndex(data []byte) (Index, error) {
+	var index Index
+	err := json.Unmarshal(data, &index)
+	return index, err
+}
+
+// BuiltIn gets the index of the templates of the catalog.
+func BuiltIn() Index {
+	var index Index
+	for _, name := range Names() {
+		template, _ := Lookup(name)
+		index.Templates = append(index.Templates, Entry{Name: name, Description: template.Description, Generics: template.Generics(), BuiltIn: true})
+	}
+	return index
+}
+
+// Search gets the templates whose name, description or generic types
+// contain every word of the term, ignoring case, sorted by name. All of
+// them match an empty term.
+func (index Index) Search(term string) []Entry {
+	words := strings.Fields(strings.ToLower(term))
+	var found []Entry
+	for _, entry := range index.Templates {
+		text := strings.ToLower(entry.Name + " " + entry.Description + " " + strings.Join(entry.Generics, " "))
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			found = append(found, entry)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+	return found
+}
+
+// Merge gets the templates of both indexes, with those of index first for
+// the names in both.
+func (index Index) Merge(other Index) Index {
+	merged := Index{Templates: append([]Entry(nil), index.Templates...)}
+	seen := make(map[string]bool)
+	for _, entry := range index.Templates {
+		seen[entry.Name] = true
+	}
+	for _, entry := range other.Templates {
+		if !seen[entry.Name] {
+			seen[entry.Name] = true
+			merged.Templates = append(merged.Templates, entry)
+		}
+	}
+	return merged
+}
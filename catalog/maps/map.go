@@ -0,0 +1,78 @@
+//go:build genny
+
+package maps
+
+import (
+	"sync"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// KeyType is the type of the keys of the map.
+type KeyType generic.Type
+
+// ValueType is the type of the values of the map.
+type ValueType generic.Type
+
+// KeyTypeValueTypeMap maps KeyTypes to ValueTypes.
+type KeyTypeValueTypeMap struct {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	mu sync.RWMutex
+	/*{{- end}}*/
+	items map[KeyType]ValueType
+}
+
+// NewKeyTypeValueTypeMap makes an empty map.
+func NewKeyTypeValueTypeMap() *KeyTypeValueTypeMap {
+	return &KeyTypeValueTypeMap{items: make(map[KeyType]ValueType)}
+}
+
+// Get gets the value of k, and whether it is in the map.
+func (m *KeyTypeValueTypeMap) Get(k KeyType) (ValueType, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	v, ok := m.items[k]
+	return v, ok
+}
+
+// Set sets the value of k to v.
+func (m *KeyTypeValueTypeMap) Set(k KeyType, v ValueType) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	/*{{- end}}*/
+	m.items[k] = v
+}
+
+// Delete removes k from the map.
+func (m *KeyTypeValueTypeMap) Delete(k KeyType) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	/*{{- end}}*/
+	delete(m.items, k)
+}
+
+// Len gets the number of keys in the map.
+func (m *KeyTypeValueTypeMap) Len() int {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	return len(m.items)
+}
+
+// Keys gets the keys of the map, in no particular order.
+func (m *KeyTypeValueTypeMap) Keys() []KeyType {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	keys := make([]KeyType, 0, len(m.items))
+	for k := range m.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,58 @@
+//go:build genny
+
+package option
+
+import "github.com/mauricelam/genny/generic"
+
+// Elem is the type of the value of the option.
+type Elem generic.Type
+
+// ElemOption is an Elem that may be missing. The zero value has none.
+type ElemOption struct {
+	value Elem
+	ok    bool
+}
+
+// SomeElem gets an option that has v.
+func SomeElem(v Elem) ElemOption {
+	return ElemOption{value: v, ok: true}
+}
+
+// NoElem gets an option that has none.
+func NoElem() ElemOption {
+	return ElemOption{}
+}
+
+// Get gets the value, and whether there is one.
+func (o ElemOption) Get() (Elem, bool) {
+	return o.value, o.ok
+}
+
+// IsSome tells whether there is a value.
+func (o ElemOption) IsSome() bool {
+	return o.ok
+}
+
+// Unwrap gets the value. It panics if there is none.
+func (o ElemOption) Unwrap() Elem {
+	if !o.ok {
+		panic("Unwrap of an empty ElemOption")
+	}
+	return o.value
+}
+
+// OrElse gets the value, or v if there is none.
+func (o ElemOption) OrElse(v Elem) Elem {
+	if !o.ok {
+		return v
+	}
+	return o.value
+}
+
+// Map gets an option with f of the value, or none if there is none.
+func (o ElemOption) Map(f func(Elem) Elem) ElemOption {
+	if !o.ok {
+		return o
+	}
+	return SomeElem(f(o.value))
+}
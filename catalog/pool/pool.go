@@ -0,0 +1,49 @@
+//go:build genny
+
+package pool
+
+import (
+	"sync"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// Elem is the type of the values in the pool.
+type Elem generic.Type
+
+// ElemPool is a pool of *Elems to reuse, to take work off the garbage
+// collector. It is a sync.Pool that takes and gives *Elems instead of
+// interface{} values, and the values are pointers so that putting them back
+// allocates nothing. The zero value is an empty pool that makes zero
+// values. A pool must not be copied after it is first used.
+type ElemPool struct {
+	pool sync.Pool
+	// New, if set, makes the values when the pool is empty, instead of
+	// zero ones.
+	New func() *Elem
+	// Reset, if set, is called with every value put back into the pool, to
+	// clear it for its next use.
+	Reset func(*Elem)
+}
+
+// Get takes a value from the pool, or makes one if it is empty.
+func (p *ElemPool) Get() *Elem {
+	if v := p.pool.Get(); v != nil {
+		return v.(*Elem)
+	}
+	if p.New != nil {
+		return p.New()
+	}
+	return new(Elem)
+}
+
+// Put puts e back into the pool. e must not be used after this.
+func (p *ElemPool) Put(e *Elem) {
+	if e == nil {
+		return
+	}
+	if p.Reset != nil {
+		p.Reset(e)
+	}
+	p.pool.Put(e)
+}
@@ -0,0 +1,69 @@
+//go:build genny
+
+package queue
+
+import (
+	"sync"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// Elem is the type of the items of the queue.
+type Elem generic.Type
+
+// ElemQueue is a first in, first out queue of Elems. The zero value is an
+// empty queue.
+type ElemQueue struct {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	mu sync.RWMutex
+	/*{{- end}}*/
+	items []Elem
+}
+
+// Push adds e to the back of the queue.
+func (q *ElemQueue) Push(e Elem) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	/*{{- end}}*/
+	q.items = append(q.items, e)
+}
+
+// Pop removes the item at the front of the queue and gets it. It returns
+// false if the queue is empty.
+func (q *ElemQueue) Pop() (Elem, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	/*{{- end}}*/
+	var e Elem
+	if len(q.items) == 0 {
+		return e, false
+	}
+	e, q.items[0] = q.items[0], e
+	q.items = q.items[1:]
+	return e, true
+}
+
+// Peek gets the item at the front of the queue without removing it. It
+// returns false if the queue is empty.
+func (q *ElemQueue) Peek() (Elem, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	/*{{- end}}*/
+	var e Elem
+	if len(q.items) == 0 {
+		return e, false
+	}
+	return q.items[0], true
+}
+
+// Len gets the number of items in the queue.
+func (q *ElemQueue) Len() int {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	/*{{- end}}*/
+	return len(q.items)
+}
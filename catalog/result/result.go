@@ -0,0 +1,80 @@
+//go:build genny
+
+package result
+
+import "github.com/mauricelam/genny/generic"
+
+// Elem is the type of the value of the result.
+type Elem generic.Type
+
+// ElemResult is an Elem, or the error that kept it from being made.
+type ElemResult struct {
+	value Elem
+	err   error
+}
+
+// ElemResultOf gets the result of a call that returns an Elem and an
+// error, which can be given to it as it is.
+func ElemResultOf(v Elem, err error) ElemResult {
+	if err != nil {
+		return FailedElem(err)
+	}
+	return OkElem(v)
+}
+
+// OkElem gets a result that has v.
+func OkElem(v Elem) ElemResult {
+	return ElemResult{value: v}
+}
+
+// FailedElem gets a result that has err instead of a value.
+func FailedElem(err error) ElemResult {
+	return ElemResult{err: err}
+}
+
+// Get gets the value and the error, of which only one is set.
+func (r ElemResult) Get() (Elem, error) {
+	return r.value, r.err
+}
+
+// Err gets the error, or nil if there is a value.
+func (r ElemResult) Err() error {
+	return r.err
+}
+
+// IsOk tells whether there is a value.
+func (r ElemResult) IsOk() bool {
+	return r.err == nil
+}
+
+// Unwrap gets the value. It panics with the error if there is one.
+func (r ElemResult) Unwrap() Elem {
+	if r.err != nil {
+		panic(r.err)
+	}
+	return r.value
+}
+
+// OrElse gets the value, or v if there is an error.
+func (r ElemResult) OrElse(v Elem) Elem {
+	if r.err != nil {
+		return v
+	}
+	return r.value
+}
+
+// Map gets a result with f of the value, or the error if there is one.
+func (r ElemResult) Map(f func(Elem) Elem) ElemResult {
+	if r.err != nil {
+		return r
+	}
+	return OkElem(f(r.value))
+}
+
+// Then gets the result of f with the value, or the error if there is one.
+func (r ElemResult) Then(f func(Elem) (Elem, error)) ElemResult {
+	if r.err != nil {
+		return r
+	}
+	return ElemResultOf(f(r.value))
+}
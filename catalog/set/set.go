@@ -0,0 +1,79 @@
+//go:build genny
+
+package set
+
+import (
+	"sync"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// Elem is the type of the items of the set.
+type Elem generic.Type
+
+// ElemSet is a set of Elems.
+type ElemSet struct {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	mu sync.RWMutex
+	/*{{- end}}*/
+	items map[Elem]struct{}
+}
+
+// NewElemSet makes a set of the elems.
+func NewElemSet(elems ...Elem) *ElemSet {
+	s := &ElemSet{items: make(map[Elem]struct{}, len(elems))}
+	for _, e := range elems {
+		s.items[e] = struct{}{}
+	}
+	return s
+}
+
+// Add adds e to the set.
+func (s *ElemSet) Add(e Elem) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	/*{{- end}}*/
+	s.items[e] = struct{}{}
+}
+
+// Remove removes e from the set.
+func (s *ElemSet) Remove(e Elem) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	/*{{- end}}*/
+	delete(s.items, e)
+}
+
+// Contains tells whether e is in the set.
+func (s *ElemSet) Contains(e Elem) bool {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	/*{{- end}}*/
+	_, ok := s.items[e]
+	return ok
+}
+
+// Len gets the number of items in the set.
+func (s *ElemSet) Len() int {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	/*{{- end}}*/
+	return len(s.items)
+}
+
+// Items gets the items of the set, in no particular order.
+func (s *ElemSet) Items() []Elem {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	/*{{- end}}*/
+	items := make([]Elem, 0, len(s.items))
+	for e := range s.items {
+		items = append(items, e)
+	}
+	return items
+}
@@ -0,0 +1,213 @@
+//go:build genny
+
+package sortedmap
+
+import (
+	"sync"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// KeyType is the type of the keys of the map, which are kept in order.
+type KeyType generic.Ordered
+
+// ValueType is the type of the values of the map.
+type ValueType generic.Type
+
+// KeyTypeValueTypeSortedMap maps KeyTypes to ValueTypes, keeping the keys in
+// order. It is a skip list, so getting, setting and deleting a key take
+// O(log n) time on average. The zero value is an empty map.
+type KeyTypeValueTypeSortedMap struct {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	mu sync.RWMutex
+	/*{{- end}}*/
+	// head has the first node of every level, of which there are level
+	head  keyTypeValueTypeSortedMapNode
+	level int
+	len   int
+	// seed is the state of the random levels of the nodes
+	seed uint64
+}
+
+type keyTypeValueTypeSortedMapNode struct {
+	key   KeyType
+	value ValueType
+	next  []*keyTypeValueTypeSortedMapNode
+}
+
+// NewKeyTypeValueTypeSortedMap makes an empty map.
+func NewKeyTypeValueTypeSortedMap() *KeyTypeValueTypeSortedMap {
+	return &KeyTypeValueTypeSortedMap{}
+}
+
+// Get gets the value of k, and whether it is in the map.
+func (m *KeyTypeValueTypeSortedMap) Get(k KeyType) (ValueType, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	if n := m.seek(k, nil); n != nil && n.key == k {
+		return n.value, true
+	}
+	var v ValueType
+	return v, false
+}
+
+// Set sets the value of k to v.
+func (m *KeyTypeValueTypeSortedMap) Set(k KeyType, v ValueType) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	/*{{- end}}*/
+	if m.head.next == nil {
+		// with a quarter of the nodes on each level, 32 levels are plenty
+		m.head.next = make([]*keyTypeValueTypeSortedMapNode, 32)
+	}
+	prev := make([]*keyTypeValueTypeSortedMapNode, len(m.head.next))
+	if n := m.seek(k, prev); n != nil && n.key == k {
+		n.value = v
+		return
+	}
+
+	level := m.randomLevel()
+	for i := m.level; i < level; i++ {
+		prev[i] = &m.head
+	}
+	if level > m.level {
+		m.level = level
+	}
+	n := &keyTypeValueTypeSortedMapNode{key: k, value: v, next: make([]*keyTypeValueTypeSortedMapNode, level)}
+	for i := range n.next {
+		n.next[i], prev[i].next[i] = prev[i].next[i], n
+	}
+	m.len++
+}
+
+// Delete removes k from the map. It returns false if k was not in it.
+func (m *KeyTypeValueTypeSortedMap) Delete(k KeyType) bool {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	/*{{- end}}*/
+	prev := make([]*keyTypeValueTypeSortedMapNode, len(m.head.next))
+	n := m.seek(k, prev)
+	if n == nil || n.key != k {
+		return false
+	}
+	for i := range n.next {
+		prev[i].next[i] = n.next[i]
+	}
+	for m.level > 0 && m.head.next[m.level-1] == nil {
+		m.level--
+	}
+	m.len--
+	return true
+}
+
+// Len gets the number of keys in the map.
+func (m *KeyTypeValueTypeSortedMap) Len() int {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	return m.len
+}
+
+// Min gets the smallest key and its value. It returns false if the map is
+// empty.
+func (m *KeyTypeValueTypeSortedMap) Min() (KeyType, ValueType, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	if m.level == 0 {
+		var k KeyType
+		var v ValueType
+		return k, v, false
+	}
+	n := m.head.next[0]
+	return n.key, n.value, true
+}
+
+// Max gets the largest key and its value. It returns false if the map is
+// empty.
+func (m *KeyTypeValueTypeSortedMap) Max() (KeyType, ValueType, bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	n := &m.head
+	for i := m.level - 1; i >= 0; i-- {
+		for n.next[i] != nil {
+			n = n.next[i]
+		}
+	}
+	if n == &m.head {
+		var k KeyType
+		var v ValueType
+		return k, v, false
+	}
+	return n.key, n.value, true
+}
+
+// Ascend calls f with every key and its value, in the order of the keys,
+// until f returns false. f must not change the map.
+func (m *KeyTypeValueTypeSortedMap) Ascend(f func(k KeyType, v ValueType) bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	if m.level == 0 {
+		return
+	}
+	for n := m.head.next[0]; n != nil && f(n.key, n.value); n = n.next[0] {
+	}
+}
+
+// Range calls f with the keys from from up to but not including to, and
+// their values, in the order of the keys, until f returns false. f must not
+// change the map.
+func (m *KeyTypeValueTypeSortedMap) Range(from, to KeyType, f func(k KeyType, v ValueType) bool) {
+	/*{{- if eq .ThreadSafe "true"}}*/
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	/*{{- end}}*/
+	for n := m.seek(from, nil); n != nil && n.key < to && f(n.key, n.value); n = n.next[0] {
+	}
+}
+
+// seek gets the first node whose key is not less than k, or nil if there
+// is none. If prev is not nil, it gets the last node before it on each
+// level.
+func (m *KeyTypeValueTypeSortedMap) seek(k KeyType, prev []*keyTypeValueTypeSortedMapNode) *keyTypeValueTypeSortedMapNode {
+	if m.level == 0 {
+		return nil
+	}
+	n := &m.head
+	for i := m.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key < k {
+			n = n.next[i]
+		}
+		if prev != nil {
+			prev[i] = n
+		}
+	}
+	return n.next[0]
+}
+
+// randomLevel gets the number of levels of a new node: one, and one more
+// with a probability of a quarter each time.
+func (m *KeyTypeValueTypeSortedMap) randomLevel() int {
+	if m.seed == 0 {
+		m.seed = 0x9e3779b97f4a7c15
+	}
+	// xorshift64
+	m.seed ^= m.seed << 13
+	m.seed ^= m.seed >> 7
+	m.seed ^= m.seed << 17
+	level := 1
+	for r := m.seed; r&3 == 0 && level < len(m.head.next); r >>= 2 {
+		level++
+	}
+	return level
+}
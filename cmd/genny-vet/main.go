@@ -0,0 +1,16 @@
+// Command genny-vet reports the files generated by genny that are out of
+// date with their templates, like
+//
+//	genny-vet ./...
+//
+// It can also run with go vet: go vet -vettool=$(which genny-vet) ./...
+package main
+
+import (
+	"github.com/mauricelam/genny/vet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(vet.Analyzer)
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/mauricelam/genny/catalog"
+	"github.com/mauricelam/genny/parse"
+)
+
+// commandAliases are the other names of the commands.
+var commandAliases = map[string]string{
+	"generate": "gen",
+	"fetch":    "get",
+	"ls":       "list",
+	"vet":      "verify",
+	"init":     "new",
+	"find":     "search",
+}
+
+// commandName gets the command of its name or alias, or "" if there is
+// none.
+func commandName(name string) string {
+	name = strings.ToLower(name)
+	if command, ok := commandAliases[name]; ok {
+		return command
+	}
+	switch name {
+	case "gen", "get", "graph", "cover", "check", "list", "search", "publish", "verify", "clean", "new", "run", "version", "doctor", "explain", "degenerify":
+		return name
+	}
+	return ""
+}
+
+// validArgs tells whether the command has the arguments it needs. With a
+// config or a type set file, gen and graph need no types.
+func validArgs(command string, args []string, haveTypeSets bool) bool {
+	switch command {
+	case "":
+		return false
+	case "gen":
+		// the templates may declare the types
+		return true
+	case "graph":
+		return len(args) > 0 || haveTypeSets
+	case "get":
+		return len(args) == 2
+	case "cover":
+		return len(args) > 0
+	case "new":
+		return len(args) > 0
+	case "explain":
+		return len(args) > 0
+	case "degenerify":
+		return len(args) <= 1
+	case "list", "version", "doctor", "check":
+		return len(args) == 0
+	case "search", "publish", "verify", "clean", "run":
+		// the words of the term, or the paths, which default to the
+		// current directory
+		return true
+	}
+	return false
+}
+
+// explain prints how the templates name the identifier of the generated
+// code for the type sets: which identifier of a template it comes from, and
+// how the specific types, the export policy and the renames made it.
+func explain(templates []parse.Template, typeSets []map[string]string, opts parse.Options, name string) (int, error) {
+	explanations, err := parse.Explain(templates, typeSets, opts, name)
+	if err != nil {
+		return exitcodeGenFailed, err
+	}
+	if len(explanations) == 0 {
+		return exitcodeInvalidArgs, fmt.Errorf("the templates don't generate %s for the types", name)
+	}
+	for _, e := range explanations {
+		where := e.Template
+		if e.Line > 0 {
+			where += ":" + strconv.Itoa(e.Line)
+		}
+		fmt.Printf("%s is %s of %s, for %s\n", e.Name, e.Identifier, where, e.TypeSet)
+		for _, step := range e.Steps {
+			fmt.Printf("  - %s\n", step)
+		}
+	}
+	return 0, nil
+}
+
+// list writes the names of the templates of the catalog to stdout.
+func list() (int, error) {
+	for _, name := range catalog.Names() {
+		fmt.Println(name)
+	}
+	return 0, nil
+}
+
+// search writes the templates of the built-in catalog and of the index at
+// indexURL that match the term, with their generic types. The index is
+// fetched like the templates of the library. Without the network only the
+// catalog is searched.
+func search(term, indexURL, library string, offline bool) (int, error) {
+	index := catalog.BuiltIn()
+	if offline {
+		fmt.Fprintln(os.Stderr, "-offline: only the built-in catalog is searched")
+	} else if data, err := fetch(indexURL, library); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: only the built-in catalog is searched, as the index can't be read: %v\n", err)
+	} else if published, err := catalog.ParseIndex(data); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: only the built-in catalog is searched, as the index %s is bad: %v\n", indexURL, err)
+	} else {
+		index = index.Merge(published)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tGENERICS\tDESCRIPTION")
+	for _, entry := range index.Search(term) {
+		description := entry.Description
+		if entry.BuiltIn {
+			description += " (built in)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Name, strings.Join(entry.Generics, " "), strings.TrimSpace(description))
+	}
+	return 0, w.Flush()
+}
+
+// goFiles gets the Go files of the paths, which are files, directories or
+// directories with /... for all the directories under them. Without paths
+// it gets those of the current directory. The vendor and testdata
+// directories, and those starting with . or _, are skipped like by the go
+// tool, and so are the files and directories that the .gitignore and
+// .gennyignore files ignore.
+func goFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var files []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, "/...") || path == "..." {
+			root := strings.TrimSuffix(strings.TrimSuffix(path, "..."), "/")
+			if root == "" {
+				root = "."
+			}
+			ig, err := newIgnorer(root)
+			if err != nil {
+				return nil, err
+			}
+			err = filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				name := info.Name()
+				if info.IsDir() {
+					if filename != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || ig.ignored(filename, true)) {
+						return filepath.SkipDir
+					}
+					return ig.load(filename)
+				}
+				if strings.HasSuffix(name, ".go") && !ig.ignored(filename, false) {
+					files = append(files, filename)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// verify reports the files of the paths that genny generated and that are
+// out of date with their templates, going by their stamps.
+func verify(paths []string) (int, error) {
+	files, err := goFiles(paths)
+	if err != nil {
+		return exitcodeSourceFileInvalid, err
+	}
+	stale := 0
+	for _, filename := range files {
+		code, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		stamp, ok := parse.FindStamp(code)
+		if !ok {
+			continue
+		}
+		current, err := stamp.Current(filepath.Dir(filename))
+		if err != nil {
+			fmt.Printf("%s: can't read its templates: %v\n", filename, err)
+			stale++
+		} else if !current {
+			fmt.Printf("%s: out of date with %s, run go generate\n", filename, strings.Join(stamp.Templates, ", "))
+			stale++
+		}
+	}
+	if stale > 0 {
+		return exitcodeSourceFileInvalid, fmt.Errorf("%d generated files are out of date", stale)
+	}
+	return 0, nil
+}
+
+// clean removes the files of the paths that genny generated.
+func clean(paths []string) (int, error) {
+	files, err := goFiles(paths)
+	if err != nil {
+		return exitcodeSourceFileInvalid, err
+	}
+	for _, filename := range files {
+		code, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		if !parse.IsGenerated(code) {
+			continue
+		}
+		if err := os.Remove(filename); err != nil {
+			return exitcodeDestFileFailed, err
+		}
+		fmt.Println("removed " + filename)
+	}
+	return 0, nil
+}
+
+// newTemplate writes a new template with the generic types, in the package
+// pkgName or the one named after its directory.
+func newTemplate(filename string, generics []string, pkgName string) (int, error) {
+	if !strings.HasSuffix(filename, ".go") {
+		return exitcodeInvalidArgs, fmt.Errorf("the template '%s' must be a .go file", filename)
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return exitcodeDestFileFailed, fmt.Errorf("%s exists already", filename)
+	}
+	if len(generics) == 0 {
+		generics = []string{"Elem"}
+	}
+	if pkgName == "" {
+		dir, err := filepath.Abs(filepath.Dir(filename))
+		if err != nil {
+			return exitcodeDestFileFailed, err
+		}
+		pkgName = packageNameOf(filepath.Base(dir))
+	}
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "package %s\n\nimport \"github.com/mauricelam/genny/generic\"\n\n", pkgName)
+	types := make([]string, len(generics))
+	for i, generic := range generics {
+		types[i] = generic + "=int"
+	}
+	fmt.Fprintf(&src, "//go:generate genny -in=$GOFILE -out=gen-$GOFILE gen %s\n", strconv.Quote(strings.Join(types, " ")))
+	for _, generic := range generics {
+		fmt.Fprintf(&src, "\n// %s is a generic type, replaced with the specific types of genny gen.\ntype %s generic.Type\n", generic, generic)
+	}
+	if err := writeFile(filename, []byte(src.String())); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	return 0, nil
+}
+
+// degenerify writes the template that the code with type parameters of the
+// -in file converts into to outFile, or stdout, with the type parameters
+// renamed to the generic types by renames. With types, the template gets a
+// //go:generate line that gens it for them, which is left to the caller, to
+// do with the flags of the run.
+func degenerify(in []string, outFile string, renames []string, types []string) (string, int, error) {
+	if len(in) != 1 {
+		return "", exitcodeInvalidArgs, errors.New("degenerify converts the code of one -in file")
+	}
+	if len(types) > 0 && outFile == "" {
+		return "", exitcodeInvalidArgs, errors.New("degenerify needs -out for the template to gen it for the types next to it")
+	}
+	renameMap, err := parseRenames(renames)
+	if err != nil {
+		return "", exitcodeInvalidArgs, err
+	}
+	src, err := ioutil.ReadFile(in[0])
+	if err != nil {
+		return "", exitcodeSourceFileInvalid, err
+	}
+	var generate string
+	if len(types) > 0 {
+		generate = "//go:generate genny -in=$GOFILE -out=gen-$GOFILE gen " + strconv.Quote(types[0])
+	}
+	code, err := parse.Degenerify(in[0], src, renameMap, generate)
+	if err != nil {
+		return "", exitcodeGenFailed, err
+	}
+	if outFile == "" {
+		_, err = os.Stdout.Write(code)
+		return "", 0, err
+	}
+	if err := writeFile(outFile, code); err != nil {
+		return "", exitcodeDestFileFailed, err
+	}
+	return outFile, 0, nil
+}
+
+// packageNameOf makes a package name of a directory name, like mylist of
+// my-list.
+func packageNameOf(dir string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, dir)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "p" + name
+	}
+	return name
+}
+
+// run runs the //go:generate genny lines of the files of the paths, like go
+// generate does, with this genny. The files may be in any number of
+// modules, which share the cache of the generated code at cache, or in a
+// temporary directory for the run, and the results are reported by module.
+// The lines may only run the plugins that the policies allow. With verify,
+// the packages of the files are built and vetted after all the lines ran,
+// and tested with test, as -verify-build and -verify-test do.
+func run(paths []string, cache string, offline bool, policies []string, verify, test bool) (int, error) {
+	files, err := goFiles(paths)
+	if err != nil {
+		return exitcodeSourceFileInvalid, err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return exitcodeInternalError, err
+	}
+	if cache == "" {
+		cache, err = ioutil.TempDir("", "genny-cache")
+		if err != nil {
+			return exitcodeInternalError, err
+		}
+		defer os.RemoveAll(cache)
+	}
+	opened, err := openCache(cache, offline)
+	if err != nil {
+		return exitcodeInvalidArgs, err
+	}
+	if _, ok := opened.(parse.DirCache); ok {
+		// the commands run in the directories of the files
+		if cache, err = filepath.Abs(cache); err != nil {
+			return exitcodeInternalError, err
+		}
+	}
+
+	var modules []string
+	moduleFiles := make(map[string][]string)
+	for _, filename := range files {
+		module := fileModule(filename)
+		if _, ok := moduleFiles[module]; !ok {
+			modules = append(modules, module)
+		}
+		moduleFiles[module] = append(moduleFiles[module], filename)
+	}
+	failed := 0
+	var generatedFiles []string
+	for _, module := range modules {
+		start := time.Now()
+		generated, failures := 0, 0
+		for _, filename := range moduleFiles[module] {
+			n, errs := runFile(self, filename, cache, runPolicy(policies))
+			if n > 0 {
+				generatedFiles = append(generatedFiles, filename)
+			}
+			generated += n
+			failures += len(errs)
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		if generated == 0 && failures == 0 {
+			continue
+		}
+		status := "ok  "
+		if failures > 0 {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s\t%s\t%d generated, %d failed\t%.3fs\n", status, module, generated, failures, time.Since(start).Seconds())
+	}
+	if failed > 0 {
+		return exitcodeGenFailed, fmt.Errorf("%d of the %d modules failed", failed, len(modules))
+	}
+	if verify {
+		// the packages are those of the files of the lines, which are
+		// only complete once every line ran
+		return verifyBuild(generatedFiles, test)
+	}
+	return 0, nil
+}
+
+// fileModule gets the path of the module that the file is in, or its
+// directory if it isn't in one.
+func fileModule(filename string) string {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return filepath.Dir(filename)
+	}
+	if _, modPath := moduleRoot(dir); modPath != "" {
+		return modPath
+	}
+	return filepath.Dir(filename)
+}
+
+// runFile runs the //go:generate genny lines of the file with genny, the
+// executable self, which caches the code at cache and runs the plugins that
+// the policy allows. It gets the number of lines that generated their code
+// and the errors of those that failed.
+func runFile(self, filename, cache, policy string) (int, []error) {
+	code, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, []error{err}
+	}
+	pkgName, err := packageName(filename, code)
+	if err != nil {
+		return 0, []error{err}
+	}
+	generated := 0
+	var errs []error
+	for i, line := range strings.Split(string(code), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "//go:generate ") {
+			continue
+		}
+		env := map[string]string{
+			"GOFILE":    filepath.Base(filename),
+			"GOLINE":    strconv.Itoa(i + 1),
+			"GOPACKAGE": pkgName,
+			"DOLLAR":    "$",
+		}
+		words, err := generateWords(strings.TrimPrefix(line, "//go:generate "), env)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", filename, i+1, err))
+			continue
+		}
+		if len(words) == 0 || words[0] != "genny" {
+			continue
+		}
+		cmd := exec.Command(self, words[1:]...)
+		cmd.Dir = filepath.Dir(filename)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		cmd.Env = append(os.Environ(), envName("cache")+"="+cache, envName("allow-plugins")+"="+policy, envName("verify-build")+"=false", envName("verify-test")+"=false")
+		for name, value := range env {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %v", filename, i+1, strings.Join(words, " "), err))
+			continue
+		}
+		generated++
+	}
+	return generated, errs
+}
+
+// packageName gets the name in the package clause of the Go code.
+func packageName(filename string, code []byte) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, code, parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	return file.Name.Name, nil
+}
+
+// generateWords splits a //go:generate line into its words like go
+// generate: at spaces, except in double quoted strings, which are unquoted
+// like Go strings, with the environment variables expanded.
+func generateWords(line string, env map[string]string) ([]string, error) {
+	var words []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimLeft(line, " \t") {
+		var word string
+		if line[0] == '"' {
+			end := 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return nil, errors.New("unterminated quoted string")
+			}
+			unquoted, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			word, line = unquoted, line[end+1:]
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			word, line = line[:end], line[end:]
+		}
+		words = append(words, os.Expand(word, func(name string) string {
+			if value, ok := env[name]; ok {
+				return value
+			}
+			return os.Getenv(name)
+		}))
+	}
+	return words, nil
+}
+
+// printVersion writes the version of genny to stdout, as it was built.
+func printVersion() (int, error) {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	fmt.Println("genny " + version)
+	return 0, nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/mauricelam/genny/parse"
+)
+
+// gennyModule is the module of genny, whose generic package the templates
+// import.
+const gennyModule = "github.com/mauricelam/genny"
+
+// doctorSetup is what doctor checks, as the flags give it.
+type doctorSetup struct {
+	in       []string
+	imports  []string
+	outFile  string
+	config   string
+	plugins  []string
+	policies []string
+}
+
+// finding is the result of a check of doctor, with what would fix it
+// unless it is fine.
+type finding struct {
+	level string
+	what  string
+	fix   string
+}
+
+// The levels of the findings.
+const (
+	findingOK   = "ok"
+	findingWarn = "warn"
+	findingFail = "FAIL"
+)
+
+// doctor checks the environment that genny runs in: its version against
+// the one the module requires, the go command and the module mode, the
+// imports of -imp and the config, the directories of the output files and
+// the plugins. It prints a line for every finding, with what to do about
+// the problems, and fails if any check failed.
+func doctor(setup doctorSetup) (int, error) {
+	var findings []finding
+	report := func(level, what, fix string) {
+		findings = append(findings, finding{level: level, what: what, fix: fix})
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return exitcodeInternalError, err
+	}
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	modDir, modPath := moduleRoot(cwd)
+	required := ""
+	if modDir != "" {
+		required = requiredVersion(filepath.Join(modDir, "go.mod"), gennyModule)
+	}
+	switch {
+	case modPath == gennyModule:
+		report(findingOK, "genny "+version+", in its own module", "")
+	case required == "" && importsGeneric(setup.in):
+		report(findingWarn, "the templates import "+gennyModule+"/generic, but the module doesn't require "+gennyModule, "go get "+gennyModule+"@"+pickVersion(version))
+	case required != "" && version != "(devel)" && required != version:
+		report(findingWarn, "the module requires "+gennyModule+" "+required+", but this genny is "+version, "go install "+gennyModule+"@"+required)
+	default:
+		report(findingOK, "genny "+version, "")
+	}
+
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		report(findingFail, "the go command isn't in the PATH, which -in module@version templates and the plugins may need", "install Go from https://go.dev/dl")
+	} else {
+		out, _ := exec.Command(goCmd, "version").Output()
+		report(findingOK, strings.TrimSpace(string(out)), "")
+		env := goEnv(goCmd, "GO111MODULE", "GOMOD", "GOFLAGS")
+		switch {
+		case env["GO111MODULE"] == "off":
+			report(findingWarn, "GO111MODULE=off, so the imports of the types are looked up in GOPATH", "unset GO111MODULE")
+		case env["GOMOD"] == "" || env["GOMOD"] == os.DevNull:
+			report(findingWarn, "not in a module, so the imports of the types are looked up in GOPATH", "go mod init <module path>")
+		default:
+			report(findingOK, "module "+modPath+" in "+env["GOMOD"], "")
+		}
+		if strings.Contains(env["GOFLAGS"], "-mod=vendor") {
+			report(findingWarn, "GOFLAGS has -mod=vendor, so the packages of the types must be vendored", "go mod vendor")
+		}
+	}
+	if ws, err := parse.FindWorkspace(cwd); err != nil {
+		report(findingFail, err.Error(), "fix the go.work file, or set GOWORK=off")
+	} else if ws != nil {
+		report(findingOK, fmt.Sprintf("workspace %s of %d modules", ws.Filename, len(ws.Modules)), "")
+	}
+
+	var outFiles []string
+	if setup.outFile != "" {
+		outFiles = append(outFiles, setup.outFile)
+	}
+	imports := append([]string(nil), setup.imports...)
+	if setup.config != "" {
+		config, dir, _, err := readConfig(setup.config)
+		if err != nil {
+			report(findingFail, err.Error(), "fix the config file")
+		} else {
+			var paths []string
+			for _, path := range config.Imports {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			imports = append(imports, paths...)
+			for _, generate := range config.Generate {
+				outputs, err := generate.Outputs(0)
+				if err != nil {
+					report(findingFail, err.Error(), "fix the config file")
+					continue
+				}
+				for _, output := range outputs {
+					outFiles = append(outFiles, filepath.Join(dir, output.Out))
+				}
+			}
+		}
+	}
+
+	for _, filename := range setup.in {
+		if _, err := os.Stat(filename); err != nil {
+			report(findingFail, "the template "+filename+" can't be read: "+err.Error(), "give the path of the template to -in")
+		}
+	}
+	if len(imports) > 0 && goCmd != "" {
+		problems, err := unresolvedImports(goCmd, imports)
+		if err != nil {
+			report(findingFail, "the imports can't be listed: "+err.Error(), "fix the go.mod file, like with go mod tidy")
+		}
+		for _, problem := range problems {
+			report(findingFail, "the import "+problem.path+" doesn't resolve: "+problem.why, "go get "+problem.path+", or fix the -imp or the imports of the config")
+		}
+		if err == nil && len(problems) == 0 {
+			report(findingOK, fmt.Sprintf("the %d imports resolve", len(imports)), "")
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, outFile := range outFiles {
+		dir := filepath.Dir(outFile)
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+		if err := writableDir(dir); err != nil {
+			report(findingFail, "the output directory "+dir+" isn't writable: "+err.Error(), "fix the permissions of "+dir+", or write the output elsewhere")
+		} else {
+			report(findingOK, "the output directory "+dir+" is writable", "")
+		}
+	}
+
+	for _, arg := range setup.plugins {
+		plugin, err := parse.ParsePlugin(arg)
+		if err != nil {
+			report(findingFail, err.Error(), "give the plugin as name or name=parameter")
+			continue
+		}
+		if err := checkPlugin(plugin.Name, setup.policies); err != nil {
+			report(findingFail, err.Error(), "-allow-plugins="+plugin.Name)
+			continue
+		}
+		if path, err := exec.LookPath(parse.PluginPrefix + plugin.Name); err != nil {
+			report(findingFail, "the plugin "+plugin.Name+" isn't in the PATH as "+parse.PluginPrefix+plugin.Name, "install "+parse.PluginPrefix+plugin.Name+" into a directory of the PATH")
+		} else {
+			report(findingOK, "the plugin "+plugin.Name+" is "+path, "")
+		}
+	}
+
+	failed := 0
+	for _, f := range findings {
+		fmt.Printf("%-4s  %s\n", f.level, strings.Replace(f.what, "\n", "\n      ", -1))
+		if f.fix != "" {
+			fmt.Printf("      → %s\n", f.fix)
+		}
+		if f.level == findingFail {
+			failed++
+		}
+	}
+	switch {
+	case failed == 1:
+		return exitcodeInvalidArgs, errors.New("1 problem found")
+	case failed > 1:
+		return exitcodeInvalidArgs, fmt.Errorf("%d problems found", failed)
+	}
+	return 0, nil
+}
+
+// requiredVersion gets the version of the module that the go.mod file
+// requires, or "" if it doesn't.
+func requiredVersion(goMod, module string) string {
+	data, err := ioutil.ReadFile(goMod)
+	if err != nil {
+		return ""
+	}
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && fields[0] == "require" && fields[1] == "(":
+			inBlock = true
+		case inBlock && len(fields) == 1 && fields[0] == ")":
+			inBlock = false
+		case inBlock && len(fields) == 2 && fields[0] == module:
+			return fields[1]
+		case len(fields) == 3 && fields[0] == "require" && fields[1] == module:
+			return fields[2]
+		}
+	}
+	return ""
+}
+
+// pickVersion gets the version to install for the version of genny, which
+// is the latest for a development build.
+func pickVersion(version string) string {
+	if version == "(devel)" {
+		return "latest"
+	}
+	return version
+}
+
+// importsGeneric tells whether any of the template files imports the
+// generic package.
+func importsGeneric(templates []string) bool {
+	for _, filename := range templates {
+		src, err := ioutil.ReadFile(filename)
+		if err == nil && bytes.Contains(src, []byte(`"`+gennyModule+`/generic"`)) {
+			return true
+		}
+	}
+	return false
+}
+
+// goEnv gets the variables of go env.
+func goEnv(goCmd string, names ...string) map[string]string {
+	env := make(map[string]string)
+	out, err := exec.Command(goCmd, append([]string{"env"}, names...)...).Output()
+	if err != nil {
+		return env
+	}
+	for i, value := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if i < len(names) {
+			env[names[i]] = strings.TrimSpace(value)
+		}
+	}
+	return env
+}
+
+// importProblem is an import path that the go command can't find, with
+// why.
+type importProblem struct {
+	path string
+	why  string
+}
+
+// unresolvedImports gets the import paths that the go command can't find.
+func unresolvedImports(goCmd string, paths []string) ([]importProblem, error) {
+	cmd := exec.Command(goCmd, append([]string{"list", "-e", "-find", "-json"}, paths...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if why := strings.TrimSpace(stderr.String()); why != "" {
+			return nil, errors.New(why)
+		}
+		return nil, err
+	}
+	var problems []importProblem
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		var pkg struct {
+			ImportPath string
+			Error      *struct{ Err string }
+		}
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		if pkg.Error != nil {
+			problems = append(problems, importProblem{path: pkg.ImportPath, why: strings.TrimSpace(pkg.Error.Err)})
+		}
+	}
+	return problems, nil
+}
+
+// writableDir checks that a file can be written into the directory, or
+// into the directory it would be created in.
+func writableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			return err
+		}
+		dir = filepath.Dir(dir)
+	}
+	file, err := ioutil.TempFile(dir, ".genny-doctor")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
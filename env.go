@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix starts the names of the environment variables that set the
+// flags, like GENNY_PKG for -pkg and GENNY_STRIP_TAG for -strip-tag.
+const envPrefix = "GENNY_"
+
+// envName gets the environment variable of a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// setFromEnv sets the flags that the command line didn't set from their
+// environment variables, so the flags win over the environment, which wins
+// over the config file.
+func setFromEnv(flags *flag.FlagSet) error {
+	given := make(map[flag.Value]bool)
+	flags.Visit(func(f *flag.Flag) {
+		// the long names share the values of the short ones
+		given[f.Value] = true
+	})
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || given[f.Value] || err != nil {
+			return
+		}
+		if e := flags.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("bad %s=%s: %v", envName(f.Name), value, e)
+		}
+		given[f.Value] = true
+	})
+	return err
+}
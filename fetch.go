@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// fetchTimeout is how long fetching a template may take.
+const fetchTimeout = time.Minute
+
+// fetchTemplate downloads a template of the online library, or of any URL
+// for a name like https://host/path/list.go, or of a bundle of templates
+// published as an OCI artifact for one like oci://registry/org/templates:v1.
+// It gets the detached signature of the template too, from the URL with
+// .sig or the bundle, or nil if there is none.
+func fetchTemplate(library, name string) ([]byte, []byte, error) {
+	if strings.HasPrefix(name, "oci://") {
+		return fetchOCI(name)
+	}
+	url := library + name
+	if strings.HasPrefix(name, "https://") || strings.HasPrefix(name, "http://") {
+		url = name
+	}
+	src, err := fetch(url, library)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a missing signature is up to the signature policy
+	sig, _ := fetch(url+signatureExt, library)
+	return src, sig, nil
+}
+
+// fetch downloads the URL. The proxies of HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY are used, and the request is authorized with the token or the
+// login of the host, so that private repositories work.
+func fetch(url, library string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, library)
+	client := &http.Client{
+		Transport:     &http.Transport{Proxy: http.ProxyFromEnvironment},
+		Timeout:       fetchTimeout,
+		CheckRedirect: checkRedirect,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// checkRedirect follows up to 10 redirects of a fetch. The authorization
+// for a host is never sent to another one, or over http after https, so a
+// redirect can't take it anywhere else or put it in the clear.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	if req.URL.Host != via[0].URL.Host || (via[0].URL.Scheme == "https" && req.URL.Scheme != "https") {
+		req.Header.Del("Authorization")
+		req.Header.Del("Private-Token")
+	}
+	return nil
+}
+
+// authorize authorizes the request for its host with GENNY_TOKEN if it is
+// the token host of the library, or with GITHUB_TOKEN or GITLAB_TOKEN for
+// those hosts, or else with the login of the host in the netrc file, if
+// there is any. Only requests that are secure get the credentials.
+func authorize(req *http.Request, library string) {
+	if !secure(req.URL) {
+		return
+	}
+	host := req.URL.Hostname()
+	switch {
+	case os.Getenv("GENNY_TOKEN") != "" && host == tokenHost(library):
+		req.Header.Set("Authorization", "Bearer "+os.Getenv("GENNY_TOKEN"))
+	case (host == "github.com" || strings.HasSuffix(host, ".githubusercontent.com")) && os.Getenv("GITHUB_TOKEN") != "":
+		req.Header.Set("Authorization", "token "+os.Getenv("GITHUB_TOKEN"))
+	case host == "gitlab.com" && os.Getenv("GITLAB_TOKEN") != "":
+		req.Header.Set("Private-Token", os.Getenv("GITLAB_TOKEN"))
+	default:
+		if login, password, ok := netrcLogin(host); ok {
+			req.SetBasicAuth(login, password)
+		}
+	}
+}
+
+// secure tells whether a URL is https, or http on a loopback address, so
+// that nobody on the way can read the credentials of a request to it.
+func secure(u *url.URL) bool {
+	if u.Scheme == "https" {
+		return true
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return u.Scheme == "http" && ip.IsLoopback()
+	}
+	return u.Scheme == "http" && host == "localhost"
+}
+
+// tokenHost gets the host that GENNY_TOKEN is sent to: GENNY_TOKEN_HOST,
+// or else the host of the library.
+func tokenHost(library string) string {
+	if host := os.Getenv("GENNY_TOKEN_HOST"); host != "" {
+		return host
+	}
+	u, err := url.Parse(library)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// netrcLogin gets the login and password of the host in the netrc file of
+// NETRC, or else in the home directory, like curl and the go command.
+func netrcLogin(host string) (string, string, bool) {
+	filename := os.Getenv("NETRC")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		filename = filepath.Join(home, name)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", "", false
+	}
+	return parseNetrc(string(data), host)
+}
+
+// parseNetrc gets the login and password of the machine of the netrc file
+// that is the host, or else of its default.
+func parseNetrc(netrc, host string) (string, string, bool) {
+	type entry struct {
+		machine, login, password string
+	}
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(netrc, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case inMacro:
+			// a macro runs to the next empty line
+			inMacro = line != ""
+		case strings.HasPrefix(strings.TrimSpace(line), "#"):
+		default:
+			lineFields := strings.Fields(line)
+			for i, field := range lineFields {
+				if field == "macdef" {
+					lineFields, inMacro = lineFields[:i], true
+					break
+				}
+			}
+			fields = append(fields, lineFields...)
+		}
+	}
+
+	var entries []entry
+	for i := 0; i < len(fields); i++ {
+		switch field := fields[i]; {
+		case field == "default":
+			// the default has no machine
+			entries = append(entries, entry{})
+		case i+1 == len(fields):
+		case field == "machine":
+			i++
+			entries = append(entries, entry{machine: fields[i]})
+		case field == "login" && len(entries) > 0:
+			i++
+			entries[len(entries)-1].login = fields[i]
+		case field == "password" && len(entries) > 0:
+			i++
+			entries[len(entries)-1].password = fields[i]
+		}
+	}
+	for _, machine := range []string{host, ""} {
+		for _, e := range entries {
+			if e.machine == machine {
+				return e.login, e.password, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -1,5 +1,11 @@
 package generic
 
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
 // Type is the placeholder type that indicates a generic value.
 // When genny is executed, variables of this type will be replaced with
 // references to the specific types.
@@ -11,3 +17,76 @@ type Type interface{}
 // references to the specific types.
 //      var GenericType generic.Number
 type Number float64
+
+// Ordered is the placeholder type that indicates a generic value that can
+// be compared with < and >, like a number or a string.
+// When genny is executed, variables of this type will be replaced with
+// references to the specific types.
+//      var GenericType generic.Ordered
+type Ordered string
+
+// Integer is the placeholder type that indicates a generic integer value,
+// which can also be used with %, the bitwise operators and shifts.
+// When genny is executed, variables of this type will be replaced with
+// references to the specific types.
+//      var GenericType generic.Integer
+type Integer int64
+
+// Unsigned is the placeholder type that indicates a generic unsigned
+// integer value, for templates that rely on it wrapping around and not
+// being negative.
+// When genny is executed, variables of this type will be replaced with
+// references to the specific types.
+//      var GenericType generic.Unsigned
+type Unsigned uint64
+
+// Float is the placeholder type that indicates a generic floating point
+// value, so that the template can pass it to the math package.
+// When genny is executed, variables of this type will be replaced with
+// references to the specific types.
+//      var GenericType generic.Float
+type Float float64
+
+// FormatVerb is the placeholder of the fmt verb of a specific type, like
+// %d for an int or %q for a string. A constant set to it that is named
+// after a generic type gets the verb of the specific type.
+//      const ElemVerb = generic.FormatVerb
+const FormatVerb = "%v"
+
+// Hash is the placeholder of the hash function of a specific type, which
+// is given after the type, like Key=Name:string:hashString. A variable set
+// to it that is named after a generic type gets the hash function of the
+// specific type, the one with hash in its name if the type has several.
+//      var KeyTypeHash = generic.Hash
+// Hash itself hashes how fmt prints v, so that the template works too.
+func Hash(v interface{}) uint64 {
+	h := fnv.New64a()
+	fmt.Fprint(h, v)
+	return h.Sum64()
+}
+
+// Less is the placeholder of the function that tells whether a value of a
+// specific type is less than another, which is given after the type, like
+// Elem=Point:geom.Point:geom.PointLess, for the types that can't be
+// compared with <. A variable set to it that is named after a generic type
+// gets the less function of the specific type, the one with less in its
+// name if the type has several.
+//      var ElemLess = generic.Less
+// Less itself compares how fmt prints a and b, so that the template works
+// too.
+func Less(a, b interface{}) bool {
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// Equal is the placeholder of the function that tells whether two values
+// of a specific type are equal, which is given after the type, like
+// Elem=Floats:[]float64:equalFloats, for the types that can't be compared
+// with ==, or that need another equality, like floats within an epsilon.
+// A variable set to it that is named after a generic type gets the equal
+// function of the specific type, the one with equal in its name if the type
+// has several.
+//      var ElemEqual = generic.Equal
+// Equal itself is reflect.DeepEqual, so that the template works too.
+func Equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
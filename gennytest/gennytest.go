@@ -0,0 +1,101 @@
+// Package gennytest tests templates against golden files of the code genny
+// generates from them, like
+//
+//	func TestList(t *testing.T) {
+//		gennytest.Golden(t, "testdata/list_int.golden", []string{"list.go"}, "Elem=int", parse.Options{})
+//	}
+//
+// Running the tests with -update writes the golden files instead, after a
+// change to the templates:
+//
+//	go test -update
+package gennytest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mauricelam/genny/parse"
+)
+
+var update = updateFlag()
+
+// updateFlag gets the -update flag, which another package may have defined
+// already.
+func updateFlag() flag.Value {
+	if f := flag.Lookup("update"); f != nil {
+		return f.Value
+	}
+	flag.Bool("update", false, "write the golden files of gennytest instead of comparing with them")
+	return flag.Lookup("update").Value
+}
+
+// Golden generates the code of the templates for the types, written like
+// the argument of genny gen, and compares it with the golden file. With
+// -update it writes the golden file instead. The paths are relative to the
+// directory of the test.
+func Golden(t testing.TB, golden string, templates []string, types string, opts parse.Options) {
+	t.Helper()
+	typeSets, err := parse.TypeSet(types)
+	if err != nil {
+		t.Fatalf("bad types %q: %v", types, err)
+		return
+	}
+	var files []parse.Template
+	for _, filename := range templates {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatalf("can't read the template: %v", err)
+			return
+		}
+		defer file.Close()
+		files = append(files, parse.Template{Filename: filename, Source: file})
+	}
+	code, err := parse.GenericsTemplates(files, typeSets, opts)
+	if err != nil {
+		t.Fatalf("genny failed on %s for %q: %v", strings.Join(templates, ", "), types, err)
+		return
+	}
+
+	if update.String() == "true" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("can't write the golden file: %v", err)
+			return
+		}
+		if err := ioutil.WriteFile(golden, code, 0644); err != nil {
+			t.Fatalf("can't write the golden file: %v", err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("can't read the golden file, run go test -update to write it: %v", err)
+		return
+	}
+	if line, want, got, differ := firstDifference(string(expected), string(code)); differ {
+		t.Errorf("the code generated for %q differs from %s at line %d, run go test -update if it should:\n-%s\n+%s", types, golden, line, want, got)
+	}
+}
+
+// firstDifference gets the first line that differs, 1-based, and what it is
+// in both.
+func firstDifference(expected, actual string) (int, string, string, bool) {
+	want, got := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g || i >= len(want) || i >= len(got) {
+			return i + 1, w, g, true
+		}
+	}
+	return 0, "", "", false
+}
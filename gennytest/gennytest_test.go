@@ -0,0 +1,63 @@
+package gennytest_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauricelam/genny/gennytest"
+	"github.com/mauricelam/genny/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the failures of a test instead of failing it.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestGolden(t *testing.T) {
+	gennytest.Golden(t, "testdata/list_int.golden", []string{"testdata/list.go"}, "Elem=int", parse.Options{})
+
+	r := &recorder{TB: t}
+	gennytest.Golden(r, "testdata/list_int.golden", []string{"testdata/list.go"}, "Elem=string", parse.Options{})
+	if assert.Len(t, r.failures, 1) {
+		assert.Contains(t, r.failures[0], "differs from testdata/list_int.golden at line 8")
+		assert.Contains(t, r.failures[0], "-// IntList is a list of int values.\n+// StringList is a list of string values.")
+	}
+
+	for _, golden := range []string{"testdata/missing.golden", "testdata/list_int.golden"} {
+		r = &recorder{TB: t}
+		gennytest.Golden(r, golden, []string{"testdata/list.go"}, "Elem=", parse.Options{})
+		gennytest.Golden(r, golden, []string{"testdata/missing.go"}, "Elem=int", parse.Options{})
+		assert.Len(t, r.failures, 2)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gennytest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	golden := filepath.Join(dir, "testdata", "list_string.golden")
+
+	assert.NoError(t, flag.Set("update", "true"))
+	gennytest.Golden(t, golden, []string{"testdata/list.go"}, "Elem=string", parse.Options{})
+	assert.NoError(t, flag.Set("update", "false"))
+	data, err := ioutil.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "type StringList []string")
+	gennytest.Golden(t, golden, []string{"testdata/list.go"}, "Elem=string", parse.Options{})
+}
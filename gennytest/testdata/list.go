@@ -0,0 +1,8 @@
+package list
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list of Elem values.
+type ElemList []Elem
@@ -1,8 +1,15 @@
 module github.com/mauricelam/genny
 
-go 1.12
+go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/tools v0.0.0-20190319232107-3f1ed9edd1b4
+	gopkg.in/yaml.v2 v2.2.2
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
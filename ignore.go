@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are the files of ignore rules that the walks of the batch
+// commands honor in every directory: .gitignore for the files that git
+// ignores, and .gennyignore for those that only genny should, like
+// third-party code that is checked in.
+var ignoreFiles = []string{".gitignore", ".gennyignore"}
+
+// ignoreRule is a line of an ignore file, with the syntax of .gitignore.
+type ignoreRule struct {
+	// dir is the directory of the ignore file, which the rule is relative
+	// to, with forward slashes.
+	dir string
+	// pattern matches the paths relative to dir, or their names if the
+	// rule isn't anchored.
+	pattern *regexp.Regexp
+	// anchored is whether the rule has a slash before its end, which
+	// makes it match the paths relative to dir rather than the names.
+	anchored bool
+	// negate is whether the rule starts with !, which makes the paths it
+	// matches not ignored.
+	negate bool
+	// dirOnly is whether the rule ends with a slash, which makes it match
+	// directories only.
+	dirOnly bool
+}
+
+// ignorer tells which files of a walk the ignore files ignore.
+type ignorer struct {
+	rules []ignoreRule
+}
+
+// newIgnorer gets the ignorer for a walk from root, with the rules of the
+// ignore files of the directories above it up to that of the git
+// repository, which apply to it as well.
+func newIgnorer(root string) (*ignorer, error) {
+	ig := &ignorer{}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for dir := abs; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// not in a repository, so only the ignore files of the walk count
+			dirs = nil
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := ig.load(dirs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ig, nil
+}
+
+// load adds the rules of the ignore files of the directory.
+func (ig *ignorer) load(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range ignoreFiles {
+		file, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(filepath.ToSlash(abs), scanner.Text()); ok {
+				ig.rules = append(ig.rules, rule)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseIgnoreRule parses a line of an ignore file in dir, if it is a rule
+// rather than a blank line or a comment.
+func parseIgnoreRule(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{dir: dir}
+	if strings.HasPrefix(line, "!") {
+		rule.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored, line = true, strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	pattern, err := regexp.Compile("^" + globRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// globRegexp translates a pattern of an ignore file into a regular
+// expression, where * and ? don't match a slash, and ** matches any number
+// of directories.
+func globRegexp(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end >= 0 {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				re.WriteString("[" + class + "]")
+				i += end + 1
+			} else {
+				re.WriteString(`\[`)
+			}
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// ignored tells whether the rules ignore the file or directory, which the
+// last rule that matches it decides.
+func (ig *ignorer) ignored(filename string, isDir bool) bool {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	abs = filepath.ToSlash(abs)
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir || !strings.HasPrefix(abs, rule.dir+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(abs, rule.dir+"/")
+		if !rule.anchored {
+			rel = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if rule.pattern.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
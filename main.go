@@ -2,16 +2,23 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"go/build"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
-	// "path"
+	"path"
+	"path/filepath"
+	"regexp"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mauricelam/genny/catalog"
 	"github.com/mauricelam/genny/out"
 	"github.com/mauricelam/genny/parse"
 )
@@ -32,12 +39,21 @@ const (
 	exitcodeSourceFileInvalid
 	exitcodeDestFileFailed
 	exitcodeInternalError
+	exitcodeProfileFailed
+	exitcodeTimeout
+)
+
+// The formats of -diag-format.
+const (
+	diagFormatText  = "text"
+	diagFormatSARIF = "sarif"
 )
 
 func main() {
 	var (
-		mainErr  error
-		exitCode int
+		mainErr    error
+		exitCode   int
+		diagFormat = diagFormatText
 	)
 
 	defer func() {
@@ -46,101 +62,1043 @@ func main() {
 			exitCode = exitcodeInternalError
 		}
 		if mainErr != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", mainErr)
+			reportError(os.Stderr, mainErr, diagFormat)
 		}
 		os.Exit(exitCode)
 	}()
 
 	var (
-		in      = flag.String("in", "", "file to parse instead of stdin")
 		out     = flag.String("out", "", "file to save output to instead of stdout")
 		pkgName = flag.String("pkg", "", "package name for generated files")
 		genTag  = flag.String("tag", "", "build tag that is stripped from output")
 		useAst  = flag.Bool("ast", false, "whether to use AST implementation")
+		preproc = flag.Bool("preprocess", false, "run the templates as text/templates with the type set as data, with actions between /*{{ and }}*/")
+		naming  = flag.String("naming", "", "how qualified types are named: package (default), type or alias")
+		keep    = flag.Bool("keep-constraints", false, "preserve the build constraints of the template in the output")
+		subDoc  = flag.Bool("subst-pkgdoc", false, "substitute the first type set into the package doc comment")
+		exports = flag.String("export", "", "which kinds of generated declarations are exported, like types=exported,funcs=unexported, of types, funcs, methods, consts and vars, each exported, unexported or keep (default)")
+		sortBy  = flag.String("sort-decls", "", "order of the generated declarations: none (default), alpha or template")
+		regFile = flag.String("registry", "", "write a map from the specific types to their generated constructors to this file")
+		dispOut = flag.String("dispatch", "", "write functions that call the instantiation for the type of their argument to this file")
+		srcMap  = flag.String("sourcemap", "", "write a JSON source map from the generated declarations to the template lines and type sets to this file")
+		dispArg = flag.String("dispatch-type", "interface{}", "type of the argument of the -dispatch functions, like a marker interface")
+		mdFile  = flag.String("report", "", "write a Markdown report of the templates, their generic types and every instantiation to this file")
+		stats   = flag.Bool("stats", false, "print statistics of the run to stderr: templates, instantiations, files, output size and time per phase")
+		debug   = flag.String("debug-dir", "", "write the code of every type set before it is merged, the collected imports and the merged code before goimports into a directory per output file in this directory")
+		timeout = flag.Duration("timeout", 0, "fail if the run takes longer than this, like 1m, killing the plugins still running (0 for no limit)")
+		stamp   = flag.Bool("stamp", false, "record the templates and a hash of them under the header of the -out files, for genny-vet to find the files whose templates changed")
+		cacheAt = flag.String("cache", "", "cache the generated code in a directory, an https:// URL (or http:// on this machine) or s3://bucket/prefix, shared by the runs of genny, and read it from there when the templates, types, flags and genny are the same again")
+		offline = flag.Bool("offline", false, "never use the network: fail instead of fetching a template or using a remote -cache, and keep the go command from downloading modules")
+		indexAt = flag.String("index", "https://github.com/metabition/gennylib/raw/master/index.json", "URL of the JSON index of published templates that search looks in")
+		allowPl = flag.String("allow-plugins", "", "plugins that -plugin and -naming-plugin may run, separated by commas, or all or none, which is the default unless "+envName("allow-plugins")+" is set; it can't allow more than "+envName("allow-plugins")+" does")
+		reqSig  = flag.Bool("require-signature", false, "fail get, and -in templates of modules, unless the fetched template has a signature that verifies with a -verify-key")
+		signKey = flag.String("sign-key", "", "sign the templates that publish packages with this Ed25519 private key, a PEM file")
+		cacheTo = flag.String("cache-dir", "", "cache the generated code in this directory, like -cache")
+		skipOld = flag.Bool("skip-existing", false, "leave out the generated declarations that the package of -out (or the current directory) has already, like hand-written versions for some of the types")
+		version = flag.Bool("version", false, "print the version of genny, like the version command")
+		cmdLine = flag.Bool("command", false, "record the genny command that generates the -out files again under their header")
+		stream  = flag.Bool("stream", false, "write the output as it is generated, to keep memory flat for many type sets")
+		cpuProf = flag.String("cpuprofile", "", "write a CPU profile to this file")
+		memProf = flag.String("memprofile", "", "write a memory profile to this file")
+		traceTo = flag.String("trace", "", "write an execution trace to this file")
+		maxOut  = flag.Int("max-output", parse.DefaultLimits.MaxOutputSize, "maximum size of the generated code in bytes (0 for no limit)")
+		splitSz = flag.Int("split-size", 0, "split an -out file that would be over this many bytes into numbered parts, like gen_1.go and gen_2.go, between its declarations (0 for no limit)")
+		splitLn = flag.Int("split-lines", 0, "split an -out file that would be over this many lines into numbered parts, like -split-size (0 for no limit)")
+		genMode = flag.String("mode", "", "how the templates are generated: copies (default), a copy for every type set, or typeparams, one version of them with Go 1.18 type parameters in place of the generic types, which takes no gen types")
+		rawText = flag.Bool("raw", false, "substitute the types into the -in files as text, like SQL schemas, Markdown or protos that go along with the Go code, without parsing, formatting or imports")
+		annotat = flag.Bool("annotate", false, "mark every generated declaration with the template line and type set it was generated from, like // genny: list.go:42 Elem=int")
+		verBld  = flag.Bool("verify-build", false, "after writing the output files, run go build and go vet on their packages, and fail with the problems in the generated code told with the templates they came from")
+		verTst  = flag.Bool("verify-test", false, "run go test on the packages of the output files too, like -verify-build")
+		keepOrd = flag.Bool("keep-order", false, "keep the order of the declarations of the -out file that genny generated before, adding the new ones after those they follow, to only change what differs")
+		maxInst = flag.Int("max-instantiations", parse.DefaultLimits.MaxInstantiations, "maximum number of type sets times templates (0 for no limit)")
+		maxLine = flag.Int("max-line", parse.DefaultLimits.MaxLineLength, "maximum length of a template line in bytes (0 for no limit)")
+		setFile = flag.String("typesets", "", "file of the type sets, a line of gen types for each, that are generated a batch at a time to keep memory flat, instead of the gen types")
+		config  = flag.String("config", "", "YAML or TOML file describing the templates, type sets and outputs, instead of -in, -out and the gen types, which gen without them reads from genny.yaml, genny.yml or genny.toml at the root of the module")
+		local   = flag.String("local", "", "put imports beginning with this string after 3rd-party packages (comma separated)")
 		imports Strings
+		strip   Strings
+		in      Strings
+		asm     Strings
+		exams   Strings
+		plats   Strings
+		plugins Strings
+		namers  Strings
+		renames Strings
+		pubKeys Strings
 		prefix  = "https://github.com/metabition/gennylib/raw/master/"
 	)
+	flag.StringVar(&diagFormat, "diag-format", diagFormatText, "how errors are written to stderr: text, or sarif for code scanning tools and editors")
+	flag.Var(&in, "in", "file to parse instead of stdin, or - for stdin, or module@version/path/file.go to download through GOPROXY (can be specified multiple times to merge templates)")
 	flag.Var(&imports, "imp", "specify an import explicitly (can be specified multiple times)")
+	flag.Var(&asm, "asm", "assembly template to copy next to -out for every type set (can be specified multiple times)")
+	flag.Var(&exams, "examples", "example test template to generate next to -out for every type set (can be specified multiple times)")
+	flag.Var(&plats, "platform", "GOOS/GOARCH:types to generate into a _GOOS_GOARCH suffixed copy of -out, with the types added to the gen types (can be specified multiple times)")
+	flag.Var(&plugins, "plugin", "run the genny-gen-<name> binary in the PATH on the code of every type set, given as name or name=parameter (can be specified multiple times)")
+	flag.Var(&namers, "naming-plugin", "name the specific types with the genny-gen-<name> binary in the PATH, given as name or name=parameter (can be specified multiple times, the first name wins)")
+	flag.Var(&renames, "rename", "give a generated declaration another name, like ListInt=IntSlice (can be specified multiple times)")
+	flag.Var(&strip, "strip-tag", "build tags that are stripped from output (comma separated, can be specified multiple times)")
+	// the long names of the flags
+	flag.Var(&pubKeys, "verify-key", "verify the signatures of the templates that get fetches, and of the -in templates of modules, with this Ed25519 public key, a PEM file or base64 (can be specified multiple times)")
+	flag.Var(&in, "input", "alias of -in")
+	flag.StringVar(out, "output", "", "alias of -out")
+	flag.StringVar(pkgName, "package", "", "alias of -pkg")
+	flag.Var(&imports, "import", "alias of -imp")
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
-
-	if len(args) < 2 {
+	if *version {
+		args = []string{"version"}
+	}
+	if len(args) == 0 {
 		usage()
 		os.Exit(exitcodeInvalidArgs)
 	}
+	// the flags can come after the command too
+	command := commandName(args[0])
+	if err := flag.CommandLine.Parse(args[1:]); err != nil {
+		os.Exit(exitcodeInvalidArgs)
+	}
+	args = append([]string{command}, flag.Args()...)
+	if err := setFromEnv(flag.CommandLine); err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	if *offline {
+		goOffline()
+	}
 
-	if strings.ToLower(args[0]) != "gen" && strings.ToLower(args[0]) != "get" {
+	if diagFormat != diagFormatText && diagFormat != diagFormatSARIF {
+		bad := diagFormat
+		diagFormat = diagFormatText
+		exitCode, mainErr = exitcodeInvalidArgs, fmt.Errorf("unknown -diag-format '%s', expected text or sarif", bad)
+		return
+	}
+
+	in, fromStdin, err := stdinIn(in)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	if !validArgs(command, args[1:], *config != "" || *setFile != "") {
 		usage()
 		os.Exit(exitcodeInvalidArgs)
 	}
 
-	// parse the typesets
-	var setsArg = args[1]
-	if strings.ToLower(args[0]) == "get" {
-		setsArg = args[2]
+	// the keys that the fetched templates are verified with
+	if *reqSig && len(pubKeys) == 0 {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-require-signature needs the keys that the templates are signed with, given with -verify-key")
+		return
 	}
-	typeSets, err := parse.TypeSet(setsArg)
+	keys, err := readPublicKeys(pubKeys)
 	if err != nil {
-		exitCode, mainErr = exitcodeInvalidTypeSet, err
+		exitCode, mainErr = exitcodeInvalidArgs, err
 		return
 	}
 
-	outWriter := newWriter(*out)
+	prof, err := startProfiling(*cpuProf, *memProf, *traceTo)
+	if err != nil {
+		exitCode, mainErr = exitcodeProfileFailed, err
+		return
+	}
+	defer func() {
+		if err := prof.stop(); err != nil && mainErr == nil {
+			exitCode, mainErr = exitcodeProfileFailed, err
+		}
+	}()
+
+	var deadline time.Time
+	if *timeout > 0 {
+		deadline = time.Now().Add(*timeout)
+		// a generation that runs away can't be stopped from the inside,
+		// so the whole run is, after the plugins were killed and had the
+		// chance to fail with an error of their own
+		watchdog := watch(*timeout+time.Second, *timeout, prof, os.Exit)
+		defer watchdog.Stop()
+	}
 
-	if strings.ToLower(args[0]) == "get" {
-		if len(args) != 3 {
-			fmt.Println("not enough arguments to get")
-			usage()
-			os.Exit(exitcodeInvalidArgs)
+	// the templates of modules are read from the module cache
+	in, err = moduleTemplates(in, keys, *reqSig)
+	if err != nil {
+		exitCode, mainErr = exitcodeGetFailed, err
+		return
+	}
+
+	switch command {
+	case "cover":
+		exitCode, mainErr = cover(in, *out, args[1:])
+		return
+	case "check":
+		exitCode, mainErr = check(in, diagFormat)
+		return
+	case "list":
+		exitCode, mainErr = list()
+		return
+	case "publish":
+		exitCode, mainErr = publish(append(append([]string(nil), in...), args[1:]...), *out, *signKey, diagFormat)
+		return
+	case "search":
+		exitCode, mainErr = search(strings.Join(args[1:], " "), *indexAt, prefix, *offline)
+		return
+	case "verify":
+		exitCode, mainErr = verify(args[1:])
+		return
+	case "clean":
+		exitCode, mainErr = clean(args[1:])
+		return
+	case "new":
+		exitCode, mainErr = newTemplate(args[1], args[2:], *pkgName)
+		return
+	case "run":
+		if *cacheAt == "" {
+			*cacheAt = *cacheTo
+		}
+		exitCode, mainErr = run(args[1:], *cacheAt, *offline, pluginPolicies(*allowPl), *verBld || *verTst, *verTst)
+		return
+	case "degenerify":
+		template, code, err := degenerify(in, *out, renames, args[1:])
+		if err != nil || len(args) == 1 {
+			exitCode, mainErr = code, err
+			return
+		}
+		// the copies for the types are generated next to the template, as
+		// its //go:generate line does
+		command, in, renames = "gen", Strings{template}, nil
+		*out = filepath.Join(filepath.Dir(template), "gen-"+filepath.Base(template))
+	case "version":
+		exitCode, mainErr = printVersion()
+		return
+	case "doctor":
+		exitCode, mainErr = doctor(doctorSetup{
+			in:       in,
+			imports:  imports,
+			outFile:  *out,
+			config:   *config,
+			plugins:  append(append([]string(nil), plugins...), namers...),
+			policies: pluginPolicies(*allowPl),
+		})
+		return
+	}
+
+	// parse the typesets
+	var setsArg string
+	if command == "get" {
+		setsArg = args[2]
+	} else if command == "explain" {
+		setsArg = strings.Join(args[2:], " ")
+	} else if len(args) > 1 {
+		setsArg = args[1]
+	}
+	// the types of each platform are added to them, if there are platforms
+	var typeSets []map[string]string
+	// the type sets that the arguments of gen place into files of their
+	// own, after those that go to -out
+	var placed []parse.ConfigOutput
+	placing := command == "gen" && len(args) > 2
+	if placing {
+		if len(plats) > 0 || *config != "" || *setFile != "" {
+			exitCode, mainErr = exitcodeInvalidArgs, errors.New("-platform, -config and -typesets give the type sets, so gen takes one argument of gen types with them")
+			return
+		}
+		placements, err := parse.ParsePlacements(args[1:])
+		if err == nil {
+			placed, err = parse.PlacedOutputs(placements, *maxInst)
 		}
-		r, err := http.Get(prefix + args[1])
 		if err != nil {
-			exitCode, mainErr = exitcodeGetFailed, err
+			exitCode, mainErr = exitcodeInvalidTypeSet, err
 			return
 		}
-		b, err := ioutil.ReadAll(r.Body)
+		if len(placed) > 0 && placed[0].Out == "" {
+			typeSets, placed = placed[0].TypeSets, placed[1:]
+		}
+		setsArg = strings.Join(args[1:], " ")
+	}
+	mode, err := parse.ParseMode(*genMode)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	typeParams := mode == parse.ModeTypeParams
+	if typeParams && (command != "gen" || strings.TrimSpace(setsArg) != "" || len(plats) > 0 || *config != "" || *setFile != "" || len(asm) > 0 || len(exams) > 0 || *rawText || *annotat || *srcMap != "" || *regFile != "" || *dispOut != "") {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-mode=typeparams makes one version of the templates for every type, so it is only for gen without gen types, and -platform, -config, -typesets, -asm, -examples, -raw, -annotate, -sourcemap, -registry and -dispatch can't be used with it")
+		return
+	}
+	// without templates and types, gen generates the config of the project,
+	// unless -in=- reads the template from stdin
+	if command == "gen" && len(in) == 0 && strings.TrimSpace(setsArg) == "" && len(plats) == 0 && *config == "" && *setFile == "" && !typeParams && !fromStdin {
+		*config = projectConfig(".")
+	}
+	// without gen types, gen takes them from the genny:defaults of the
+	// templates once they are read
+	useDefaults := (command == "gen" || command == "explain") && strings.TrimSpace(setsArg) == "" && len(plats) == 0 && *config == "" && *setFile == "" && !typeParams
+	if len(plats) == 0 && *config == "" && *setFile == "" && !useDefaults && !placing && !typeParams {
+		typeSets, err = parse.TypeSetLimit(setsArg, *maxInst)
 		if err != nil {
-			exitCode, mainErr = exitcodeGetFailed, err
+			exitCode, mainErr = exitcodeInvalidTypeSet, err
 			return
 		}
-		r.Body.Close()
-		br := bytes.NewReader(b)
-		err = gen(*in, *pkgName, br, typeSets, imports, outWriter, *genTag, *useAst)
-	} else if len(*in) > 0 {
-		var file *os.File
-		file, err = os.Open(*in)
+	}
+	namingPolicy, err := parse.ParseNamingPolicy(*naming)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	declOrder, err := parse.ParseDeclOrder(*sortBy)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	exportPolicy, err := parse.ParseExportPolicy(*exports)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	if *exports != "" && (*regFile != "" || *dispOut != "") {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-registry and -dispatch refer to the names without -export, so they can't be used with it")
+		return
+	}
+	var stripTags []string
+	if *genTag != "" {
+		stripTags = append(stripTags, *genTag)
+	}
+	for _, tags := range strip {
+		stripTags = append(stripTags, strings.Split(tags, ",")...)
+	}
+	var localPrefixes []string
+	if *local != "" {
+		localPrefixes = strings.Split(*local, ",")
+	}
+	opts := parse.Options{
+		PkgName:              *pkgName,
+		ImportPaths:          imports,
+		StripTags:            stripTags,
+		UseAst:               *useAst,
+		Preprocess:           *preproc,
+		Naming:               namingPolicy,
+		KeepConstraints:      *keep,
+		SubstitutePackageDoc: *subDoc,
+		SortDecls:            declOrder,
+		Annotate:             *annotat,
+		Raw:                  *rawText,
+		Mode:                 mode,
+		Export:               exportPolicy,
+		LocalPrefixes:        localPrefixes,
+		DebugDir:             *debug,
+		CacheDir:             *cacheTo,
+		Limits: parse.Limits{
+			MaxOutputSize:     *maxOut,
+			MaxInstantiations: *maxInst,
+			MaxLineLength:     *maxLine,
+		},
+	}
+	write := writeOptions{split: parse.SplitLimits{MaxBytes: *splitSz, MaxLines: *splitLn}, keepOrder: *keepOrd}
+	if *rawText && (write.split != (parse.SplitLimits{}) || write.keepOrder || *annotat || *srcMap != "" || *regFile != "" || *dispOut != "") {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-raw text has no declarations, so -split-size, -split-lines, -keep-order, -annotate, -sourcemap, -registry and -dispatch can't be used with it")
+		return
+	}
+	if write.split != (parse.SplitLimits{}) && *out == "" && *config == "" && len(placed) == 0 {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-split-size and -split-lines need -out to know where to write the parts")
+		return
+	}
+	if (*verBld || *verTst) && *out == "" && *config == "" && len(placed) == 0 {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-verify-build and -verify-test need -out to know which packages to build")
+		return
+	}
+	if *verBld || *verTst {
+		var outputs []string
+		write.outputs = &outputs
+		defer func() {
+			if mainErr != nil {
+				return
+			}
+			if *out != "" {
+				outputs = append(outputs, *out)
+			}
+			exitCode, mainErr = verifyBuild(outputs, *verTst)
+		}()
+	}
+	opts.Cache, err = openCache(*cacheAt, *offline)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	if *stamp {
+		// replaced with the directory of each output file
+		opts.StampDir = "."
+	}
+	opts.Renames, err = parseRenames(renames)
+	if err != nil {
+		exitCode, mainErr = exitcodeInvalidArgs, err
+		return
+	}
+	if *cmdLine {
+		// the directory of each output file is added
+		opts.Command = shellCommand(append([]string{"genny"}, os.Args[1:]...))
+	}
+	if *skipOld {
+		// replaced with the directory of each output file
+		opts.SkipExistingDir = "."
+	}
+	policies := pluginPolicies(*allowPl)
+	for _, arg := range plugins {
+		plugin, err := parse.ParsePlugin(arg)
+		if err == nil {
+			err = checkPlugin(plugin.Name, policies)
+		}
 		if err != nil {
-			exitCode = exitcodeSourceFileInvalid
+			exitCode, mainErr = exitcodeInvalidArgs, err
 			return
 		}
-		defer file.Close()
-		err = gen(*in, *pkgName, file, typeSets, imports, outWriter, *genTag, *useAst)
+		plugin.Deadline = deadline
+		if opts.Transforms == nil {
+			opts.Transforms = make(map[parse.Stage][]parse.Transformer)
+		}
+		opts.Transforms[parse.StageSubstitute] = append(opts.Transforms[parse.StageSubstitute], plugin)
+	}
+	if len(namers) > 0 {
+		var plugins []parse.Namer
+		for _, arg := range namers {
+			plugin, err := parse.ParsePlugin(arg)
+			if err == nil {
+				err = checkPlugin(plugin.Name, policies)
+			}
+			if err != nil {
+				exitCode, mainErr = exitcodeInvalidArgs, err
+				return
+			}
+			plugin.Deadline = deadline
+			plugins = append(plugins, plugin.Namer())
+		}
+		opts.Namer = parse.Namers(plugins...)
+	}
+	var report *parse.Report
+	if *mdFile != "" {
+		report = parse.NewReport()
+		defer func() {
+			if mainErr != nil {
+				return
+			}
+			var buf bytes.Buffer
+			report.WriteMarkdown(&buf)
+			if err := writeFile(*mdFile, buf.Bytes()); err != nil {
+				exitCode, mainErr = exitcodeDestFileFailed, err
+			}
+		}()
+	}
+	if *stats {
+		opts.Stats = &parse.Stats{}
+		defer func() {
+			if mainErr == nil {
+				opts.Stats.WriteTo(os.Stderr)
+			}
+		}()
+	}
+
+	if command == "graph" {
+		exitCode, mainErr = graph(*config, in, *out, typeSets, plats, setsArg, *maxInst)
+		return
+	}
+
+	if command == "explain" && (*config != "" || *setFile != "" || len(plats) > 0) {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("explain takes the templates from -in and the types after the name, so -config, -typesets and -platform can't be used with it")
+		return
+	}
+	if *config != "" {
+		if len(in) > 0 || *out != "" || len(asm) > 0 || len(exams) > 0 || len(plats) > 0 || *regFile != "" || *dispOut != "" || *srcMap != "" || *setFile != "" || setsArg != "" {
+			exitCode, mainErr = exitcodeInvalidArgs, errors.New("-config describes the templates, type sets and outputs, so -in, -out, -asm, -examples, -platform, -registry, -dispatch, -sourcemap, -typesets and the gen types can't be used with it")
+			return
+		}
+		exitCode, mainErr = genConfig(*config, opts, *maxInst, *stream, write, report)
+		return
+	}
+
+	var templates []parse.Template
+	if command == "get" {
+		if template, ok := catalog.Lookup(args[1]); ok {
+			// the templates of the catalog are built in, preprocessed and
+			// built only with the genny tag
+			templates = []parse.Template{{Filename: args[1], Source: strings.NewReader(template.Source)}}
+			typeSets = template.TypeSets(typeSets)
+			opts.Preprocess = true
+			opts.StripTags = append(opts.StripTags, catalog.Tag)
+		} else if *offline {
+			exitCode, mainErr = exitcodeGetFailed, fmt.Errorf("-offline: %s isn't in the built-in catalog, and fetching it from %s needs the network", args[1], prefix)
+			return
+		} else {
+			b, sig, err := fetchTemplate(prefix, args[1])
+			if err != nil {
+				exitCode, mainErr = exitcodeGetFailed, err
+				return
+			}
+			if err := verifySignature(args[1], b, sig, keys, *reqSig); err != nil {
+				exitCode, mainErr = exitcodeGetFailed, err
+				return
+			}
+			templates = []parse.Template{{Filename: args[1], Source: bytes.NewReader(b)}}
+		}
+	} else if len(in) > 0 {
+		for _, filename := range in {
+			var file *os.File
+			file, err = os.Open(filename)
+			if err != nil {
+				exitCode, mainErr = exitcodeSourceFileInvalid, err
+				return
+			}
+			defer file.Close()
+			templates = append(templates, parse.Template{Filename: filename, Source: file})
+		}
 	} else {
 		var source []byte
-		source, err = ioutil.ReadAll(os.Stdin)
+		source, err = ioutil.ReadAll(opts.Limits.LineReader("stdin", os.Stdin))
 		if err != nil {
-			exitCode = exitcodeStdinFailed
+			exitCode, mainErr = exitcodeStdinFailed, err
 			return
 		}
 		reader := bytes.NewReader(source)
-		err = gen("stdin", *pkgName, reader, typeSets, imports, outWriter, *genTag, *useAst)
+		templates = []parse.Template{{Filename: "stdin", Source: reader}}
+	}
+
+	if useDefaults {
+		templates, typeSets, err = defaultTypeSets(templates, opts.Limits)
+		if err != nil {
+			exitCode, mainErr = exitcodeInvalidTypeSet, err
+			return
+		}
+	}
+
+	if command == "explain" {
+		exitCode, mainErr = explain(templates, typeSets, opts, args[1])
+		return
+	}
+
+	// the templates generated into a file of their own for every type set
+	var extras []extraTemplate
+	for _, filename := range asm {
+		extras = append(extras, extraTemplate{flag: "-asm", filename: filename, generate: parse.GenericsAsm})
+	}
+	for _, filename := range exams {
+		extras = append(extras, extraTemplate{flag: "-examples", filename: filename, generate: parse.GenericsExamples})
+	}
+
+	if len(plats) > 0 {
+		if *regFile != "" || *dispOut != "" || *srcMap != "" {
+			exitCode, mainErr = exitcodeInvalidArgs, errors.New("-registry, -dispatch and -sourcemap can't be used with -platform")
+			return
+		}
+		exitCode, mainErr = genPlatforms(plats, templates, setsArg, opts, *maxInst, *stream, write, extras, *out, report)
+		return
+	}
+
+	if *setFile != "" {
+		exitCode, mainErr = genTypeSetFile(*setFile, templates, opts, *out, setsArg != "" || len(extras) > 0 || len(plats) > 0 || *regFile != "" || *dispOut != "" || *srcMap != "" || report != nil)
+		return
+	}
+
+	if len(placed) > 0 && (len(extras) > 0 || *regFile != "" || *dispOut != "" || *srcMap != "") {
+		exitCode, mainErr = exitcodeInvalidArgs, errors.New("-asm, -examples, -registry, -dispatch and -sourcemap are for the -out file, so they can't be used with out= placements")
+		return
 	}
 
 	// do the work
+	if len(typeSets) > 0 || len(placed) == 0 {
+		err = gen(templates, typeSets, opts, *stream, write, *out, report)
+		if err != nil {
+			exitCode, mainErr = exitcodeGenFailed, err
+			return
+		}
+	}
+	if exitCode, mainErr = genPlaced(placed, templates, opts, *stream, write, report); mainErr != nil {
+		return
+	}
+
+	for _, extra := range extras {
+		exitCode, mainErr = extra.gen(typeSets, opts, *out)
+		if mainErr != nil {
+			return
+		}
+	}
+
+	if *srcMap != "" {
+		outFile := *out
+		if outFile == "" {
+			outFile = "stdout"
+		}
+		sourceMap, err := parse.GenericsSourceMap(templates, typeSets, opts, outFile)
+		if err != nil {
+			exitCode, mainErr = exitcodeGenFailed, err
+			return
+		}
+		if err := writeFile(*srcMap, sourceMap); err != nil {
+			exitCode, mainErr = exitcodeDestFileFailed, err
+			return
+		}
+	}
+	if *regFile != "" {
+		registry, err := parse.GenericsRegistry(templates, typeSets, opts)
+		if err != nil {
+			exitCode, mainErr = exitcodeGenFailed, err
+			return
+		}
+		if err := writeFile(*regFile, registry); err != nil {
+			exitCode, mainErr = exitcodeDestFileFailed, err
+			return
+		}
+	}
+
+	if *dispOut != "" {
+		dispatch, err := parse.GenericsDispatch(templates, typeSets, opts, *dispArg)
+		if err != nil {
+			exitCode, mainErr = exitcodeGenFailed, err
+			return
+		}
+		if err := writeFile(*dispOut, dispatch); err != nil {
+			exitCode, mainErr = exitcodeDestFileFailed, err
+		}
+	}
+}
+
+// defaultTypeSets gets the type sets that the templates declare with
+// genny:defaults, for a gen without types. The templates are read for it,
+// so it gets them again to be generated.
+func defaultTypeSets(templates []parse.Template, limits parse.Limits) ([]parse.Template, []map[string]string, error) {
+	var typeSets []map[string]string
+	read := make([]parse.Template, 0, len(templates))
+	for _, template := range templates {
+		src, err := ioutil.ReadAll(limits.LineReader(template.Filename, template.Source))
+		if err != nil {
+			return nil, nil, err
+		}
+		sets, err := parse.DefaultTypeSets(template.Filename, src, limits.MaxInstantiations)
+		if err != nil {
+			return nil, nil, err
+		}
+		typeSets = append(typeSets, sets...)
+		read = append(read, parse.Template{Filename: template.Filename, Source: bytes.NewReader(src)})
+	}
+	if len(typeSets) == 0 {
+		return nil, nil, errors.New("no types to generate: give them after gen, or declare them in the template with // genny:defaults Type=int,string")
+	}
+	return read, typeSets, nil
+}
+
+// genPlatforms generates a copy of the output file for each platform, with
+// the platform's types added to the types of the gen argument.
+func genPlatforms(plats []string, templates []parse.Template, setsArg string, opts parse.Options, maxInst int, stream bool, write writeOptions, extras []extraTemplate, outFile string, report *parse.Report) (int, error) {
+	if outFile == "" {
+		return exitcodeInvalidArgs, errors.New("-platform needs -out to know where to write the files")
+	}
+	for _, plat := range plats {
+		platform, typeSets, code, err := parsePlatform(plat, setsArg, maxInst)
+		if err != nil {
+			return code, err
+		}
+		platformOpts := opts
+		platformOpts.BuildConstraint = platform.Constraint()
+		platformOut := platform.Filename(outFile)
+		if err := gen(templates, typeSets, platformOpts, stream, write, platformOut, report); err != nil {
+			return exitcodeGenFailed, err
+		}
+		for _, extra := range extras {
+			if code, err := extra.gen(typeSets, platformOpts, platformOut); err != nil {
+				return code, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+// parsePlatform parses a -platform argument into the platform and its type
+// sets, which have the types of the gen argument too.
+func parsePlatform(plat, setsArg string, maxInst int) (parse.Platform, []map[string]string, int, error) {
+	i := strings.Index(plat, ":")
+	if i < 0 {
+		return parse.Platform{}, nil, exitcodeInvalidArgs, fmt.Errorf("-platform %q is bad: GOOS/GOARCH:types expected", plat)
+	}
+	platform, err := parse.ParsePlatform(plat[:i])
+	if err != nil {
+		return parse.Platform{}, nil, exitcodeInvalidArgs, err
+	}
+	typeSets, err := parse.TypeSetLimit(setsArg+" "+plat[i+1:], maxInst)
+	if err != nil {
+		return parse.Platform{}, nil, exitcodeInvalidTypeSet, err
+	}
+	return platform, typeSets, 0, nil
+}
+
+// genPlaced generates the type sets that the arguments of gen place into
+// files of their own, each in the package it is placed in, which wins over
+// -pkg.
+func genPlaced(placed []parse.ConfigOutput, templates []parse.Template, opts parse.Options, stream bool, write writeOptions, report *parse.Report) (int, error) {
+	for _, output := range placed {
+		placedOpts := opts
+		if output.Pkg != "" {
+			placedOpts.PkgName = output.Pkg
+		}
+		if err := gen(templates, output.TypeSets, placedOpts, stream, write, output.Out, report); err != nil {
+			return exitcodeGenFailed, err
+		}
+	}
+	return 0, nil
+}
+
+// readConfig reads a config file. The paths in it are relative to the
+// directory it gets.
+func readConfig(configFile string) (*parse.Config, string, int, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return nil, "", exitcodeSourceFileInvalid, err
+	}
+	defer file.Close()
+	config, err := parse.ReadConfig(configFile, file)
+	if err != nil {
+		return nil, "", exitcodeInvalidArgs, err
+	}
+	return config, filepath.Dir(configFile), 0, nil
+}
+
+// genConfig generates the code described by a config file.
+func genConfig(configFile string, opts parse.Options, maxInst int, stream bool, write writeOptions, report *parse.Report) (int, error) {
+	config, dir, code, err := readConfig(configFile)
+	if err != nil {
+		return code, err
+	}
+
+	// a broken entry doesn't keep the others from being generated
+	var failures []configFailure
+	fail := func(what string, code int, err error) {
+		failures = append(failures, configFailure{what: what, code: code, err: err})
+	}
+	for _, generate := range config.Generate {
+		what := strings.Join(generate.In, ", ")
+		outputs, err := generate.Outputs(maxInst)
+		if err != nil {
+			fail(what, exitcodeInvalidTypeSet, err)
+			continue
+		}
+		conversions, err := generate.ConversionOutputs()
+		if err != nil {
+			fail(what, exitcodeInvalidTypeSet, err)
+			continue
+		}
+		var templates []parse.Template
+		for _, filename := range generate.In {
+			file, err := os.Open(filepath.Join(dir, filename))
+			if err != nil {
+				fail(what, exitcodeSourceFileInvalid, err)
+				templates = nil
+				break
+			}
+			defer file.Close()
+			templates = append(templates, parse.Template{Filename: file.Name(), Source: file})
+		}
+		if templates == nil {
+			continue
+		}
+		generateOpts := opts
+		generateOpts.Values = config.Values
+		if len(config.Renames) > 0 {
+			// the -rename flags win over the config
+			generateOpts.Renames = make(map[string]string)
+			for from, to := range config.Renames {
+				generateOpts.Renames[from] = to
+			}
+			for from, to := range opts.Renames {
+				generateOpts.Renames[from] = to
+			}
+		}
+		// -pkg and GENNY_PKG win over the config, whose routes may put an
+		// output in a package of its own
+		for _, output := range outputs {
+			if opts.PkgName == "" {
+				generateOpts.PkgName = output.Pkg
+			}
+			generateOpts.BuildConstraint = output.BuildConstraint
+			generateOpts.ImportPaths = typeImports(opts.ImportPaths, config, output.TypeSets)
+			if err := gen(templates, output.TypeSets, generateOpts, stream, write, filepath.Join(dir, output.Out), report); err != nil {
+				fail(output.Out, exitcodeGenFailed, err)
+			}
+		}
+		generateOpts.BuildConstraint = ""
+		if opts.PkgName == "" {
+			generateOpts.PkgName = generate.Pkg
+		}
+		for _, output := range conversions {
+			var typeSets []map[string]string
+			for _, conversion := range output.Conversions {
+				typeSets = append(typeSets, conversion.From, conversion.To)
+			}
+			generateOpts.ImportPaths = typeImports(opts.ImportPaths, config, typeSets)
+			code, err := parse.GenericsConversions(templates, output.Conversions, generateOpts)
+			if err != nil {
+				fail(output.Out, exitcodeGenFailed, err)
+				continue
+			}
+			if err := writeFile(filepath.Join(dir, output.Out), code); err != nil {
+				fail(output.Out, exitcodeDestFileFailed, err)
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return 0, nil
+	}
+	return failures[0].code, &configError{configFile: configFile, failures: failures}
+}
+
+// typeImports adds the imports that the config gives for the types of the
+// type sets to those of -imp.
+func typeImports(imports []string, config *parse.Config, typeSets []map[string]string) []string {
+	seen := make(map[string]bool)
+	var all []string
+	for _, path := range append(imports, config.TypeImports(typeSets)...) {
+		if !seen[path] {
+			seen[path] = true
+			all = append(all, path)
+		}
+	}
+	return all
+}
+
+// configFailure is an output of the config, or an entry if none of its
+// outputs could be generated, that failed.
+type configFailure struct {
+	what string
+	code int
+	err  error
+}
+
+// configError is the failures of generating a config.
+type configError struct {
+	configFile string
+	failures   []configFailure
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("%d failures generating %s, the rest was generated", len(e.failures), e.configFile)
+}
+
+// reportError writes the error of the run to w, as text or, with
+// -diag-format=sarif, as a SARIF log of its diagnostics.
+func reportError(w io.Writer, err error, format string) {
+	configErr, isConfigErr := err.(*configError)
+	if format == diagFormatSARIF {
+		var diagnostics []parse.Diagnostic
+		if isConfigErr {
+			for _, f := range configErr.failures {
+				diagnostics = append(diagnostics, parse.Diagnose(f.err))
+			}
+		} else {
+			diagnostics = append(diagnostics, parse.Diagnose(err))
+		}
+		if parse.WriteSARIF(w, diagnostics) == nil {
+			return
+		}
+	}
+	if isConfigErr {
+		for _, f := range configErr.failures {
+			fmt.Fprintf(w, "failed: %s: %v\n", f.what, f.err)
+		}
+	}
+	fmt.Fprintf(w, "error: %v\n", err)
+}
+
+// genTypeSetFile generates the code for the type sets that are read from
+// a file as it goes.
+func genTypeSetFile(setFile string, templates []parse.Template, opts parse.Options, outFile string, needTypeSets bool) (int, error) {
+	if needTypeSets {
+		return exitcodeInvalidArgs, errors.New("the type sets of -typesets are only read as they are generated, so the gen types, -asm, -examples, -platform, -registry, -dispatch, -sourcemap and -report can't be used with it")
+	}
+	file, err := os.Open(setFile)
+	if err != nil {
+		return exitcodeInvalidTypeSet, err
+	}
+	defer file.Close()
+	opts.StampDir = stampDir(opts.StampDir, outFile)
+	opts.SkipExistingDir = existingDir(opts.SkipExistingDir, outFile)
+	opts.Command = commandIn(opts.Command, outFile)
+	w := newWriter(outFile)
+	defer w.Discard()
+	if err := parse.GenericsStream(w, templates, file, opts); err != nil {
+		return exitcodeGenFailed, err
+	}
+	if err := w.Close(); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	return 0, nil
+}
+
+// check checks the templates, and writes the problems found to stdout.
+func check(in []string, diagFormat string) (int, error) {
+	if len(in) == 0 {
+		return exitcodeInvalidArgs, errors.New("check takes the templates to check with -in")
+	}
+	var diagnostics []parse.Diagnostic
+	for _, filename := range in {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		diagnostics = append(diagnostics, parse.CheckTemplate(filename, src)...)
+	}
+	if diagFormat == diagFormatSARIF {
+		if err := parse.WriteSARIF(os.Stdout, diagnostics); err != nil {
+			return exitcodeDestFileFailed, err
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%d:%d: %s (%s)\n", d.Filename, d.Line, d.Column, d.Message, d.Rule)
+		}
+	}
+	if len(diagnostics) > 0 && diagFormat == diagFormatSARIF {
+		// the SARIF log already has them
+		return exitcodeSourceFileInvalid, nil
+	}
+	if len(diagnostics) > 0 {
+		return exitcodeSourceFileInvalid, fmt.Errorf("%d problems in the templates", len(diagnostics))
+	}
+	return 0, nil
+}
+
+// cover rewrites the coverage profile in, or stdin, onto the templates of
+// the source maps, and writes it to outFile, or stdout.
+func cover(in []string, outFile string, sourceMapFiles []string) (int, error) {
+	if len(in) > 1 {
+		return exitcodeInvalidArgs, errors.New("cover takes one coverage profile")
+	}
+	var sourceMaps []parse.SourceMap
+	for _, filename := range sourceMapFiles {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		var sourceMap parse.SourceMap
+		if err := json.Unmarshal(data, &sourceMap); err != nil {
+			return exitcodeSourceFileInvalid, fmt.Errorf("%s: %v", filename, err)
+		}
+		sourceMaps = append(sourceMaps, sourceMap)
+	}
+	var profile io.Reader = os.Stdin
+	if len(in) == 1 {
+		file, err := os.Open(in[0])
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		defer file.Close()
+		profile = file
+	}
+	var buf bytes.Buffer
+	if err := parse.RemapCoverage(&buf, profile, sourceMaps); err != nil {
+		return exitcodeSourceFileInvalid, err
+	}
+	if err := writeFile(outFile, buf.Bytes()); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	return 0, nil
+}
+
+// graph writes a graph of the templates, type sets and files of the
+// generation to stdout, in the DOT language.
+func graph(configFile string, in []string, outFile string, typeSets []map[string]string, plats []string, setsArg string, maxInst int) (int, error) {
+	g := parse.NewGraph()
+	switch {
+	case configFile != "":
+		config, dir, code, err := readConfig(configFile)
+		if err != nil {
+			return code, err
+		}
+		for _, generate := range config.Generate {
+			outputs, err := generate.Outputs(maxInst)
+			if err != nil {
+				return exitcodeInvalidTypeSet, err
+			}
+			var templates []string
+			for _, filename := range generate.In {
+				templates = append(templates, filepath.Join(dir, filename))
+			}
+			for _, output := range outputs {
+				if err := g.Add(templates, output.TypeSets, filepath.Join(dir, output.Out)); err != nil {
+					return exitcodeSourceFileInvalid, err
+				}
+			}
+		}
+	case len(in) == 0:
+		return exitcodeInvalidArgs, errors.New("graph needs the templates from -in or -config")
+	case len(plats) > 0:
+		for _, plat := range plats {
+			platform, typeSets, code, err := parsePlatform(plat, setsArg, maxInst)
+			if err != nil {
+				return code, err
+			}
+			if err := g.Add(in, typeSets, platform.Filename(outFile)); err != nil {
+				return exitcodeSourceFileInvalid, err
+			}
+		}
+	default:
+		if outFile == "" {
+			outFile = "stdout"
+		}
+		if err := g.Add(in, typeSets, outFile); err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+	}
+	if err := g.WriteDOT(os.Stdout); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	return 0, nil
+}
+
+// extraTemplate is a template that is generated into a file of its own for
+// every type set, next to the output file, like the assembly of -asm.
+type extraTemplate struct {
+	flag     string
+	filename string
+	generate func(parse.Template, []map[string]string, parse.Options) ([]parse.OutputFile, error)
+}
+
+// gen generates the copies of the template next to the output file.
+func (extra extraTemplate) gen(typeSets []map[string]string, opts parse.Options, outFile string) (int, error) {
+	if outFile == "" {
+		return exitcodeInvalidArgs, errors.New(extra.flag + " needs -out to know where to write the files")
+	}
+	file, err := os.Open(extra.filename)
 	if err != nil {
-		exitCode, mainErr = exitcodeGenFailed, err
+		return exitcodeSourceFileInvalid, err
 	}
+	defer file.Close()
+	files, err := extra.generate(parse.Template{Filename: extra.filename, Source: file}, typeSets, opts)
+	if err != nil {
+		return exitcodeGenFailed, err
+	}
+	for _, f := range files {
+		if err := writeFile(filepath.Join(filepath.Dir(outFile), f.Filename), f.Code); err != nil {
+			return exitcodeDestFileFailed, err
+		}
+	}
+	return 0, nil
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, `usage: genny [{flags}] gen "{types}"
+	fmt.Fprintln(os.Stderr, `usage: genny [{flags}] <command> [{flags}] [{arguments}]
 
-gen - generates type specific code from generic code.
-get <package/file> - fetch a generic template from the online library and gen it.
+commands (and their aliases):
+  gen (generate) "{types}" [out={file} [pkg={name}]]... - generates type specific code from generic code, with the types placed into files of their own by out= and pkg=.
+  get (fetch) <package/file> "{types}" - gen a template of the built-in catalog, or fetch one from the online library, a URL or an oci:// bundle and gen it.
+  graph "{types}" - writes a Graphviz graph of the templates, type sets and generated files instead.
+  cover <sourcemap.json>... - rewrites the coverage profile -in onto the templates of the -sourcemap files.
+  check - checks that the -in templates parse, type check and can have types substituted into them.
+  list (ls) - lists the templates of the built-in catalog.
+  search (find) [{term}] - searches the built-in catalog and the -index of published templates.
+  publish <template.go>... - checks the templates and packages them with their index into the -out tarball.
+  verify (vet) [{paths}] - reports the generated files that are out of date with their templates.
+  clean [{paths}] - removes the files that genny generated.
+  new (init) <file.go> [{generic}]... - writes a new template with the generic types, Elem by default.
+  run [{paths}] - runs the //go:generate genny lines of the files.
+  degenerify ["{types}"] - converts the -in code with type parameters into a template, written to -out or else stdout, and gens the template for the types next to -out, which the types need.
+  explain <identifier> [{types}] - tells how the templates name the identifier of the generated code for the types.
+  version - prints the version of genny.
+  doctor - checks the environment that the flags would run genny in, and tells how to fix its problems.
 
-{flags}  - (optional) Command line flags (see below)
-{types}  - (required) Specific types for each generic type in the source
+{flags}  - (optional) Command line flags (see below), before or after the command
+{paths}  - files and directories, with /... for everything under a directory, the current directory by default
+{types}  - (required) Specific types for each generic type in the source, unless the templates declare them with genny:defaults
 {types} format:  {generic}={specific}[,another][ {generic2}={specific2}]
 
 Examples:
@@ -148,18 +1106,78 @@ Examples:
   Generic1=Specific1 Generic2=Specific2
   Generic1=Specific1,Specific2 Generic2=Specific3,Specific4
   Generic=SpecificTitle:package.Type,AnotherSpecific
+  Generic=package.Type#type
+  Generic=Title:package.Type@example.com/import/path/package
+  Generic=Title:'func(a, b int) bool'
+  "Generic=Specific1" "Generic=Specific2" out=other/gen.go pkg=other
 
 Flags:`)
 	flag.PrintDefaults()
 }
 
-func newWriter(fileName string) io.Writer {
+// outputFile is where generated code is written: stdout, or a file that only
+// appears once it is closed, complete.
+type outputFile interface {
+	io.WriteCloser
+	// Discard drops what was written, unless the output was closed.
+	Discard() error
+}
+
+// pending are the files that are written, which are discarded if the run
+// times out, so that it never leaves a half written file behind.
+var pending struct {
+	sync.Mutex
+	files []*out.AtomicFile
+}
+
+func newWriter(fileName string) outputFile {
 	if fileName == "" {
-		return os.Stdout
+		return stdout{}
 	}
-	lf := &out.LazyFile{FileName: fileName}
-	defer lf.Close()
-	return lf
+	af := &out.AtomicFile{FileName: fileName}
+	pending.Lock()
+	pending.files = append(pending.files, af)
+	pending.Unlock()
+	return af
+}
+
+// watch ends the run with exitcodeTimeout if it is still going after wait,
+// when the -timeout it was given has run out. The files that are still
+// being written are discarded and the profiles are written before it exits.
+func watch(wait, timeout time.Duration, prof *profiler, exit func(int)) *time.Timer {
+	return time.AfterFunc(wait, func() {
+		fmt.Fprintf(os.Stderr, "error: genny did not finish within -timeout=%v\n", timeout)
+		discardPending()
+		prof.stop()
+		exit(exitcodeTimeout)
+	})
+}
+
+// discardPending discards the files that are still being written.
+func discardPending() {
+	pending.Lock()
+	defer pending.Unlock()
+	for _, af := range pending.files {
+		af.Discard()
+	}
+}
+
+// stdout is the outputFile of stdout, whose writes can't be taken back.
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdout) Close() error                { return nil }
+func (stdout) Discard() error              { return nil }
+
+// writeFile writes a generated file like the outputs of gen, so that it
+// is never half written.
+func writeFile(filename string, data []byte) error {
+	w := newWriter(filename)
+	defer w.Discard()
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
 }
 
 func fatal(code int, a ...interface{}) {
@@ -167,21 +1185,386 @@ func fatal(code int, a ...interface{}) {
 	os.Exit(code)
 }
 
-// gen performs the generic generation.
-func gen(filename, pkgName string, in io.ReadSeeker, typesets []map[string]string, imports []string, out io.Writer, tag string, useAst bool) error {
+// gen performs the generic generation, writing to outFile or stdout, and
+// adds it to the report if there is one. An outFile over the split limits is
+// written in parts, and one that keeps the order of its declarations is
+// reordered, which the code is held in memory for even if it would be
+// streamed.
+func gen(templates []parse.Template, typesets []map[string]string, opts parse.Options, stream bool, write writeOptions, outFile string, report *parse.Report) error {
+	out := newWriter(outFile)
+	defer out.Discard()
+	opts.StampDir = stampDir(opts.StampDir, outFile)
+	opts.Command = commandIn(opts.Command, outFile)
+	if strings.HasSuffix(opts.PkgName, "_test") && opts.TestedImport == "" {
+		opts.TestedImport = packageImportPath(filepath.Dir(outFile))
+	}
+	opts.SkipExistingDir = existingDir(opts.SkipExistingDir, outFile)
+	ws, err := workspace(filepath.Dir(outFile))
+	if err != nil {
+		return err
+	}
+	if ws != nil {
+		opts.ImportPaths = append(append([]string(nil), opts.ImportPaths...), ws.TypeImports(typesets, opts.ImportPaths)...)
+	}
+	if opts.DebugDir != "" {
+		name := "stdout"
+		if outFile != "" {
+			name = filepath.Base(outFile)
+		}
+		opts.DebugDir = filepath.Join(opts.DebugDir, name)
+	}
 
-	var output []byte
-	var err error
+	splitting := outFile != "" && write.split != (parse.SplitLimits{})
+	keepOrder := outFile != "" && write.keepOrder
+	if stream && !splitting && !keepOrder {
+		if err := parse.GenericsTo(out, templates, typesets, opts); err != nil {
+			return err
+		}
+	} else {
+		output, err := parse.GenericsTemplates(templates, typesets, opts)
+		if err != nil {
+			return err
+		}
+		if keepOrder {
+			if output, err = parse.KeepOrder(output, existingOutput(outFile)...); err != nil {
+				return err
+			}
+		}
+		if splitting {
+			if err := writeParts(outFile, output, write.split); err != nil {
+				return err
+			}
+		} else {
+			out.Write(output)
+		}
+		if outFile != "" {
+			warnInternal(output, outFile)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if outFile != "" && write.outputs != nil {
+		*write.outputs = append(*write.outputs, outFile)
+	}
+
+	if report != nil {
+		if outFile == "" {
+			outFile = "stdout"
+		}
+		return report.Add(templates, typesets, outFile, opts)
+	}
+	return nil
+}
+
+// writeOptions are how gen writes the code to the output files.
+type writeOptions struct {
+	// split are the limits over which the files are split into parts.
+	split parse.SplitLimits
+	// keepOrder keeps the order of the declarations of the files that
+	// genny generated before.
+	keepOrder bool
+	// outputs collects the output files that are written, if it is set.
+	outputs *[]string
+}
 
-	output, err = parse.Generics(filename, pkgName, in, typesets, imports, tag, useAst)
+// existingOutput gets the code that genny generated into outFile before,
+// or into its parts if it was split, in order.
+func existingOutput(outFile string) [][]byte {
+	filenames := []string{outFile}
+	for n := 1; ; n++ {
+		filename := filepath.Join(filepath.Dir(outFile), parse.PartFilename(filepath.Base(outFile), n))
+		if _, err := os.Stat(filename); err != nil {
+			break
+		}
+		filenames = append(filenames, filename)
+	}
+	var existing [][]byte
+	for _, filename := range filenames {
+		if code, err := ioutil.ReadFile(filename); err == nil && parse.IsGenerated(code) {
+			existing = append(existing, code)
+		}
+	}
+	return existing
+}
+
+// writeParts writes the generated code to outFile, or to its numbered parts
+// if it is over the split limits, and removes the files that genny
+// generated for outFile before, so a file that was split into more parts
+// doesn't leave the extra ones behind, and one that no longer needs
+// splitting doesn't leave any.
+func writeParts(outFile string, code []byte, split parse.SplitLimits) error {
+	files, err := parse.SplitCode(outFile, code, split)
 	if err != nil {
 		return err
 	}
+	dir := filepath.Dir(outFile)
+	written := make(map[string]bool)
+	for _, f := range files {
+		filename := filepath.Join(dir, f.Filename)
+		if err := writeFile(filename, f.Code); err != nil {
+			return err
+		}
+		written[filename] = true
+	}
 
-	out.Write(output)
+	stale := []string{outFile}
+	for n := 1; ; n++ {
+		filename := filepath.Join(dir, parse.PartFilename(filepath.Base(outFile), n))
+		if _, err := os.Stat(filename); err != nil {
+			break
+		}
+		stale = append(stale, filename)
+	}
+	for _, filename := range stale {
+		if written[filename] {
+			continue
+		}
+		if code, err := ioutil.ReadFile(filename); err == nil && parse.IsGenerated(code) {
+			if err := os.Remove(filename); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// goOffline keeps the go command, which goimports and the plugins may run,
+// from using the network to download modules, checksums or toolchains, and
+// the genny commands that run does from using it at all.
+func goOffline() {
+	os.Setenv("GOPROXY", "off")
+	os.Setenv("GOSUMDB", "off")
+	os.Setenv("GOTOOLCHAIN", "local")
+	os.Setenv(envName("offline"), "true")
+}
+
+// openCache opens the cache of -cache, which has to be a directory with
+// -offline.
+func openCache(location string, offline bool) (parse.Cache, error) {
+	cache, err := parse.OpenCache(location)
+	if err != nil {
+		return nil, err
+	}
+	if _, local := cache.(parse.DirCache); offline && cache != nil && !local {
+		return nil, fmt.Errorf("-offline: the cache %s needs the network", location)
+	}
+	return cache, nil
+}
+
+// stampDir gets the StampDir of the options for the output file, which is
+// its directory with -stamp. Output written to stdout isn't stamped.
+func stampDir(dir, outFile string) string {
+	if dir == "" || outFile == "" {
+		return ""
+	}
+	return filepath.Dir(outFile)
+}
+
+// parseRenames gets the renames of the -rename flags, each like
+// ListInt=IntSlice.
+func parseRenames(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	renames := make(map[string]string)
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad -rename '%s', expected From=To", arg)
+		}
+		renames[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return renames, nil
+}
+
+// packageImportPath gets the import path of the package in dir from the
+// go.mod it is in, or from the GOPATH, or "" if it isn't in either.
+func packageImportPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if root, modPath := moduleRoot(abs); root != "" {
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return ""
+		}
+		return path.Join(modPath, filepath.ToSlash(rel))
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		if rel, err := filepath.Rel(filepath.Join(gopath, "src"), abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
+// stdinIn takes the - of -in=-, which reads the template from stdin like no
+// -in does, out of the -in files, and tells whether it was given.
+func stdinIn(in []string) ([]string, bool, error) {
+	for _, filename := range in {
+		if filename == "-" && len(in) > 1 {
+			return nil, false, errors.New("-in=- reads the template from stdin, which can't be merged with other templates")
+		}
+	}
+	if len(in) == 1 && in[0] == "-" {
+		return nil, true, nil
+	}
+	return in, false, nil
+}
+
+// projectConfigs are the names of the config file of a project, at the
+// root of its module, which genny gen generates without -in and types.
+var projectConfigs = []string{"genny.yaml", "genny.yml", "genny.toml"}
+
+// projectConfig gets the config file of the project of dir, or "" if it
+// has none.
+func projectConfig(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	root, _ := moduleRoot(abs)
+	if root == "" {
+		return ""
+	}
+	for _, name := range projectConfigs {
+		filename := filepath.Join(root, name)
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return ""
+}
+
+// moduleRoot gets the directory of the go.mod that the absolute directory
+// dir is in, and the module path, or "" if there is none.
+func moduleRoot(dir string) (string, string) {
+	for mod := dir; ; mod = filepath.Dir(mod) {
+		if data, err := ioutil.ReadFile(filepath.Join(mod, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 && fields[0] == "module" {
+					return mod, strings.Trim(fields[1], `"`)
+				}
+			}
+			return "", ""
+		}
+		if filepath.Dir(mod) == mod {
+			return "", ""
+		}
+	}
+}
+
+// warnInternal warns about the internal packages that the code generated
+// into outFile can't import, and about the packages of the module that
+// can't import it, as neither compiles.
+func warnInternal(code []byte, outFile string) {
+	dir, err := filepath.Abs(filepath.Dir(outFile))
+	if err != nil {
+		return
+	}
+	pkgPath := packageImportPath(dir)
+	if pkgPath == "" {
+		return
+	}
+	disallowed, err := parse.DisallowedImports(code, pkgPath)
+	if err != nil {
+		return
+	}
+	for _, imported := range disallowed {
+		fmt.Fprintf(os.Stderr, "warning: %s imports %s, which is internal to another package, so %s can't import it\n", outFile, imported, pkgPath)
+	}
+	// the other modules of the workspace may import it too
+	var modules []parse.Module
+	if root, modPath := moduleRoot(dir); root != "" {
+		modules = append(modules, parse.Module{Dir: root, Path: modPath})
+	}
+	if ws, err := workspace(dir); err == nil && ws != nil {
+		for _, module := range ws.Modules {
+			if len(modules) == 0 || module.Dir != modules[0].Dir {
+				modules = append(modules, module)
+			}
+		}
+	}
+	for _, module := range modules {
+		importers, err := parse.InternalImporters(module.Dir, module.Path, pkgPath)
+		if err != nil {
+			continue
+		}
+		for _, importer := range importers {
+			fmt.Fprintf(os.Stderr, "warning: %s is generated into %s, which %s imports but can't, as it is internal to another package\n", outFile, pkgPath, importer)
+		}
+	}
+}
+
+// workspaces are the workspaces read so far by their go.work files, so
+// that the packages of a workspace are found once for all of the outputs.
+var workspaces = make(map[string]*parse.Workspace)
+
+// workspace gets the workspace that dir is in, or nil if it isn't in one.
+func workspace(dir string) (*parse.Workspace, error) {
+	ws, err := parse.FindWorkspace(dir)
+	if ws == nil || err != nil {
+		return nil, err
+	}
+	if read, ok := workspaces[ws.Filename]; ok {
+		return read, nil
+	}
+	workspaces[ws.Filename] = ws
+	return ws, nil
+}
+
+// reShellWord matches the arguments that a shell takes as they are.
+var reShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellCommand joins the arguments of a command, quoting them for a shell
+// where needed.
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if reShellWord.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// commandIn gets the command of the options for the output file, which
+// changes from its directory to the one genny runs in, so that it works
+// from where the file is. The command of stdout is left as it is.
+func commandIn(command, outFile string) string {
+	if command == "" || outFile == "" {
+		return command
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return command
+	}
+	dir, err := filepath.Abs(filepath.Dir(outFile))
+	if err != nil {
+		return command
+	}
+	rel, err := filepath.Rel(dir, wd)
+	if err != nil || rel == "." {
+		return command
+	}
+	return "cd " + shellCommand([]string{filepath.ToSlash(rel)}) + " && " + command
+}
+
+// existingDir gets the SkipExistingDir of the options for the output file,
+// which is its directory with -skip-existing, or the current directory for
+// output written to stdout.
+func existingDir(dir, outFile string) string {
+	if dir == "" || outFile == "" {
+		return dir
+	}
+	return filepath.Dir(outFile)
+}
+
 // Strings is a list of strings for flag
 type Strings []string
 
@@ -0,0 +1,1184 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauricelam/genny/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+// setenv sets the environment variables for a test, and gets the function
+// that restores them.
+func setenv(vars map[string]string) func() {
+	old := make(map[string]*string)
+	for key, value := range vars {
+		if previous, ok := os.LookupEnv(key); ok {
+			old[key] = &previous
+		} else {
+			old[key] = nil
+		}
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+	}
+	return func() {
+		for key, value := range old {
+			if value == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *value)
+			}
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	defer setenv(map[string]string{
+		"GENNY_TOKEN":      "secret",
+		"GENNY_TOKEN_HOST": "",
+		"GITHUB_TOKEN":     "gh",
+		"GITLAB_TOKEN":     "",
+		"NETRC":            os.DevNull,
+	})()
+	authorization := func(url, library string) string {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		authorize(req, library)
+		return req.Header.Get("Authorization")
+	}
+
+	library := "https://git.example.com/templates/raw/master/"
+	assert.Equal(t, "Bearer secret", authorization(library+"list.go", library))
+	// the token of the library isn't sent to other hosts
+	assert.Equal(t, "", authorization("https://evil.example.com/list.go", library))
+	assert.Equal(t, "token gh", authorization("https://github.com/me/templates/raw/master/list.go", library))
+
+	defer setenv(map[string]string{"GENNY_TOKEN_HOST": "evil.example.com"})()
+	assert.Equal(t, "Bearer secret", authorization("https://evil.example.com/list.go", library))
+	assert.Equal(t, "", authorization(library+"list.go", library))
+
+	// the credentials only go over https, or over http to this machine
+	library = "http://git.example.com/templates/raw/master/"
+	defer setenv(map[string]string{"GENNY_TOKEN_HOST": ""})()
+	assert.Equal(t, "", authorization(library+"list.go", library))
+	assert.Equal(t, "", authorization("http://github.com/me/templates/raw/master/list.go", library))
+	for _, library := range []string{"http://localhost:8080/", "http://127.0.0.1:8080/", "http://[::1]:8080/"} {
+		assert.Equal(t, "Bearer secret", authorization(library+"list.go", library), library)
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	headers := func(from, to string) http.Header {
+		via, err := http.NewRequest(http.MethodGet, from, nil)
+		assert.NoError(t, err)
+		req, err := http.NewRequest(http.MethodGet, to, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Private-Token", "secret")
+		assert.NoError(t, checkRedirect(req, []*http.Request{via}))
+		return req.Header
+	}
+	assert.Equal(t, "Bearer secret", headers("https://git.example.com/a", "https://git.example.com/b").Get("Authorization"))
+	assert.Equal(t, "secret", headers("http://localhost:8080/a", "https://localhost:8080/b").Get("Private-Token"))
+	// not to another host, and not in the clear after https on the same one
+	for _, to := range []string{"https://evil.example.com/b", "http://git.example.com/b"} {
+		h := headers("https://git.example.com/a", to)
+		assert.Equal(t, "", h.Get("Authorization"), to)
+		assert.Equal(t, "", h.Get("Private-Token"), to)
+	}
+
+	via := make([]*http.Request, 10)
+	for i := range via {
+		via[i], _ = http.NewRequest(http.MethodGet, "https://git.example.com/a", nil)
+	}
+	assert.EqualError(t, checkRedirect(via[0], via), "stopped after 10 redirects")
+}
+
+func TestFetchRedirect(t *testing.T) {
+	defer setenv(map[string]string{
+		"GENNY_TOKEN":      "secret",
+		"GENNY_TOKEN_HOST": "",
+		"NETRC":            os.DevNull,
+	})()
+	var other, library *httptest.Server
+	authorizations := make(map[string]string)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		authorizations[r.Host+r.URL.Path] = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, library.URL+"/list.go", http.StatusFound)
+		case "/cross":
+			http.Redirect(w, r, other.URL+"/list.go", http.StatusFound)
+		default:
+			w.Write([]byte("package list\n"))
+		}
+	}
+	library = httptest.NewServer(http.HandlerFunc(handler))
+	defer library.Close()
+	// the servers both are on 127.0.0.1, the token host, but not on the same
+	// port
+	other = httptest.NewServer(http.HandlerFunc(handler))
+	defer other.Close()
+	libraryHost, otherHost := library.Listener.Addr().String(), other.Listener.Addr().String()
+
+	src, err := fetch(library.URL+"/same", library.URL+"/")
+	assert.NoError(t, err)
+	assert.Equal(t, "package list\n", string(src))
+	assert.Equal(t, "Bearer secret", authorizations[libraryHost+"/same"])
+	assert.Equal(t, "Bearer secret", authorizations[libraryHost+"/list.go"])
+
+	_, err = fetch(library.URL+"/cross", library.URL+"/")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authorizations[libraryHost+"/cross"])
+	value, ok := authorizations[otherHost+"/list.go"]
+	assert.True(t, ok)
+	assert.Equal(t, "", value)
+}
+
+func TestParseNetrc(t *testing.T) {
+	netrc := `# the logins
+machine git.example.com login me password first
+machine other.example.com
+  login you
+  password second
+
+macdef init
+  machine git.example.com login macro password macro
+
+default login anonymous password guest
+`
+	for host, expected := range map[string][2]string{
+		"git.example.com":   {"me", "first"},
+		"other.example.com": {"you", "second"},
+		"unknown.com":       {"anonymous", "guest"},
+	} {
+		login, password, ok := parseNetrc(netrc, host)
+		assert.True(t, ok, host)
+		assert.Equal(t, expected, [2]string{login, password}, host)
+	}
+
+	// the lines of a macro are not entries
+	login, password, ok := parseNetrc("macdef init\n  machine a.com login macro password macro\n\nmachine a.com login real password real\n", "a.com")
+	assert.True(t, ok)
+	assert.Equal(t, "real", login)
+	assert.Equal(t, "real", password)
+
+	_, _, ok = parseNetrc("machine a.com login me password secret\n", "b.com")
+	assert.False(t, ok)
+}
+
+func TestCheckGoSum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Chdir(wd)
+	assert.NoError(t, os.Chdir(dir))
+	download := moduleDownload{Path: "github.com/me/templates", Version: "v1.2.0", Sum: "h1:good="}
+
+	// without a module nothing is checked
+	assert.NoError(t, checkGoSum(download))
+
+	assert.NoError(t, ioutil.WriteFile("go.mod", []byte("module example.com/app\n"), 0644))
+	// nor without a go.sum
+	assert.NoError(t, checkGoSum(download))
+
+	assert.NoError(t, ioutil.WriteFile("go.sum", []byte(
+		"github.com/me/templates v1.2.0 h1:good=\n"+
+			"github.com/me/templates v1.2.0/go.mod h1:mod=\n"+
+			"github.com/me/other v1.0.0 h1:other=\n"), 0644))
+	assert.NoError(t, checkGoSum(download))
+	// a module that go.sum doesn't have is up to the checksum database
+	assert.NoError(t, checkGoSum(moduleDownload{Path: "github.com/me/templates", Version: "v1.3.0", Sum: "h1:new="}))
+
+	download.Sum = "h1:evil="
+	err = checkGoSum(download)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checksum mismatch")
+		assert.Contains(t, err.Error(), "h1:evil=")
+		assert.Contains(t, err.Error(), "h1:good=")
+	}
+}
+
+func TestCheckDigest(t *testing.T) {
+	data := []byte("package list\n")
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	assert.NoError(t, checkDigest(digest, data))
+
+	err := checkDigest(digest, []byte("package evil\n"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "digest mismatch")
+	}
+	assert.Error(t, checkDigest("sha512:"+hex.EncodeToString(sum[:]), data))
+}
+
+// bundle makes the gzipped tarball of a bundle with the files.
+func bundle(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}))
+		tw.Write([]byte(files[name]))
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestBundleTemplate(t *testing.T) {
+	name := "oci://registry.example.com/me/templates:v1"
+	single := bundle(t, map[string]string{
+		"./list/list.go":     "package list\n",
+		"list/list.go.sig":   "signature",
+		"README.md":          "# templates\n",
+		"list//list_test.go": "",
+	})
+	// the paths of the entries are cleaned
+	src, sig, err := bundleTemplate(name, single, "list/list.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package list\n", string(src))
+	assert.Equal(t, "signature", string(sig))
+	src, sig, err = bundleTemplate(name, single, "list/list_test.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(src))
+	assert.Nil(t, sig)
+	_, _, err = bundleTemplate(name, single, "README.md")
+	assert.Error(t, err)
+
+	several := bundle(t, map[string]string{
+		"list/list.go":   "package list\n",
+		"set/set.go":     "package set\n",
+		"set/set.go.sig": "signature",
+	})
+	src, sig, err = bundleTemplate(name, several, "set/set.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package set\n", string(src))
+	assert.Equal(t, "signature", string(sig))
+	_, _, err = bundleTemplate(name, several, "")
+	assert.EqualError(t, err, name+" has the templates list/list.go, set/set.go, name one like "+name+"/list/list.go")
+	_, _, err = bundleTemplate(name, several, "map/map.go")
+	assert.EqualError(t, err, name+" has no map/map.go, only list/list.go, set/set.go")
+
+	only := bundle(t, map[string]string{"./list.go": "package list\n", "list.go.sig": "signature"})
+	src, sig, err = bundleTemplate(name, only, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "package list\n", string(src))
+	assert.Equal(t, "signature", string(sig))
+
+	_, _, err = bundleTemplate(name, []byte("not gzip"), "")
+	assert.Error(t, err)
+}
+
+func TestFetchOCI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-oci")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	defer setenv(map[string]string{"DOCKER_CONFIG": dir, "NETRC": os.DevNull})()
+	layer := bundle(t, map[string]string{"list.go": "package list\n"})
+	sum := sha256.Sum256(layer)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	realm := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			w.Write([]byte(`{"token": "registry-token"}`))
+		case r.Header.Get("Authorization") != "Bearer registry-token":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`",service="registry",scope="repository:me/templates:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/me/templates/manifests/v1":
+			fmt.Fprintf(w, `{"layers": [{"mediaType": %q, "digest": %q}]}`, ociMediaType, digest)
+		case r.URL.Path == "/v2/me/templates/manifests/big":
+			w.Write(bytes.Repeat([]byte(" "), ociMaxManifest+1))
+		case r.URL.Path == "/v2/me/templates/blobs/"+digest:
+			w.Write(layer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	registry := "oci://" + server.Listener.Addr().String() + "/me/templates"
+
+	realm = server.URL + "/token"
+	src, sig, err := fetchOCI(registry + ":v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "package list\n", string(src))
+	assert.Nil(t, sig)
+
+	// a registry can't get the login sent anywhere in the clear
+	realm = "http://auth.example.com/token"
+	_, _, err = fetchOCI(registry + ":v1")
+	assert.EqualError(t, err, server.Listener.Addr().String()+" asks for a token of http://auth.example.com/token, which isn't https")
+
+	// nor send more than a manifest can be
+	realm = server.URL + "/token"
+	_, _, err = fetchOCI(registry + ":big")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf("larger than %d bytes", ociMaxManifest))
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	src := []byte("package list\n")
+	sig := ed25519.Sign(private, src)
+	keys := []ed25519.PublicKey{other, public}
+
+	assert.NoError(t, verifySignature("list.go", src, sig, keys, true))
+	assert.NoError(t, verifySignature("list.go", src, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), keys, true))
+
+	err = verifySignature("list.go", []byte("package evil\n"), sig, keys, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "doesn't verify")
+	}
+	assert.Error(t, verifySignature("list.go", src, sig, []ed25519.PublicKey{other}, false))
+	err = verifySignature("list.go", src, []byte("not a signature"), keys, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bad signature")
+	}
+
+	// a missing signature is only fine if it isn't required
+	assert.NoError(t, verifySignature("list.go", src, nil, keys, false))
+	err = verifySignature("list.go", src, nil, keys, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no signature list.go.sig")
+	}
+}
+
+func TestReadPublicKeys(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if !assert.NoError(t, err) {
+		return
+	}
+	file, err := ioutil.TempFile("", "genny")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(file.Name())
+	pem.Encode(file, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	file.Close()
+
+	keys, err := readPublicKeys([]string{file.Name(), base64.StdEncoding.EncodeToString(public)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []ed25519.PublicKey{public, public}, keys)
+	}
+
+	for _, bad := range []string{
+		"not a key",
+		base64.StdEncoding.EncodeToString([]byte("short")),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("not der")})),
+	} {
+		_, err := readPublicKeys([]string{bad})
+		if assert.Error(t, err, bad) {
+			assert.Contains(t, err.Error(), "bad key", bad)
+		}
+	}
+}
+
+func TestModuleTemplatesSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	public, private, err := ed25519.GenerateKey(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// a proxy of the module in the directory
+	src := "package list\n"
+	files := map[string]string{
+		"go.mod":           "module example.com/templates\n",
+		"list/list.go":     src,
+		"list/list.go.sig": string(ed25519.Sign(private, []byte(src))),
+		"set/set.go":       "package set\n",
+	}
+	versions := filepath.Join(dir, "proxy", "example.com", "templates", "@v")
+	assert.NoError(t, os.MkdirAll(versions, 0755))
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create("example.com/templates@v1.0.0/" + name)
+		if assert.NoError(t, err) {
+			w.Write([]byte(data))
+		}
+	}
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versions, "v1.0.0.zip"), buf.Bytes(), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versions, "v1.0.0.mod"), []byte(files["go.mod"]), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versions, "v1.0.0.info"), []byte(`{"Version":"v1.0.0"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versions, "list"), []byte("v1.0.0\n"), 0644))
+	defer setenv(map[string]string{
+		"GOPROXY":    "file://" + filepath.ToSlash(filepath.Join(dir, "proxy")),
+		"GOSUMDB":    "off",
+		"GOMODCACHE": filepath.Join(dir, "cache"),
+		// so that the module cache can be removed
+		"GOFLAGS": "-modcacherw",
+	})()
+
+	keys := []ed25519.PublicKey{public}
+	in, err := moduleTemplates([]string{"example.com/templates@v1.0.0/list/list.go"}, keys, true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(dir, "cache", "example.com", "templates@v1.0.0", "list", "list.go"), in[0])
+	}
+	// the template of the module must be signed too
+	_, err = moduleTemplates([]string{"example.com/templates@v1.0.0/set/set.go"}, keys, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no signature")
+	}
+	_, err = moduleTemplates([]string{"example.com/templates@v1.0.0/set/set.go"}, keys, false)
+	assert.NoError(t, err)
+}
+
+func TestCheckPlugin(t *testing.T) {
+	defer setenv(map[string]string{envName("allow-plugins"): ""})()
+
+	// no plugin runs without a policy
+	assert.Error(t, checkPlugin("metrics", pluginPolicies("")))
+	assert.NoError(t, checkPlugin("metrics", pluginPolicies("metrics, trace")))
+	assert.NoError(t, checkPlugin("metrics", pluginPolicies(allowAll)))
+	assert.Error(t, checkPlugin("metric", pluginPolicies("metrics")))
+	assert.Error(t, checkPlugin("metrics", pluginPolicies(allowNone)))
+
+	// the flag narrows the policy of the environment, but doesn't widen it
+	defer setenv(map[string]string{envName("allow-plugins"): "metrics,trace"})()
+	assert.NoError(t, checkPlugin("trace", pluginPolicies("")))
+	assert.NoError(t, checkPlugin("metrics", pluginPolicies("metrics")))
+	assert.Error(t, checkPlugin("trace", pluginPolicies("metrics")))
+	assert.Error(t, checkPlugin("fieldalign", pluginPolicies(allowAll)))
+	assert.Error(t, checkPlugin("metrics", pluginPolicies(allowNone)))
+}
+
+func TestRunPolicy(t *testing.T) {
+	for _, test := range []struct {
+		flag, env, expected string
+	}{
+		{"", "", allowNone},
+		{allowAll, "", allowAll},
+		{"metrics,trace", "", "metrics,trace"},
+		{"", "metrics,trace", "metrics,trace"},
+		// the flag wins where it is narrower
+		{"metrics", "metrics,trace", "metrics"},
+		{allowNone, "metrics", allowNone},
+		{"metrics, fieldalign", "metrics,trace", "metrics"},
+		{allowAll, "metrics", "metrics"},
+		{"trace", allowAll, "trace"},
+	} {
+		env := map[string]string{envName("allow-plugins"): test.env}
+		restore := setenv(env)
+		assert.Equal(t, test.expected, runPolicy(pluginPolicies(test.flag)), "%+v", test)
+		restore()
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	for _, test := range []struct {
+		glob    string
+		matches []string
+		misses  []string
+	}{
+		{"*.go", []string{"a.go", ".go"}, []string{"a/b.go", "a.gox"}},
+		{"a?c", []string{"abc"}, []string{"a/c", "ac"}},
+		{"**/gen.go", []string{"gen.go", "a/gen.go", "a/b/gen.go"}, []string{"agen.go"}},
+		{"legacy/**/*_gen.go", []string{"legacy/x_gen.go", "legacy/a/b/x_gen.go"}, []string{"legacy_gen.go", "other/legacy/x_gen.go"}},
+		{"vendor/**", []string{"vendor/a", "vendor/a/b.go"}, []string{"vendor"}},
+		{"[!a]b", []string{"bb"}, []string{"ab"}},
+		{`\*.go`, []string{"*.go"}, []string{"a.go"}},
+		{"a[b", []string{"a[b"}, nil},
+	} {
+		re := regexp.MustCompile("^" + globRegexp(test.glob) + "$")
+		for _, s := range test.matches {
+			assert.True(t, re.MatchString(s), "%s matches %s", test.glob, s)
+		}
+		for _, s := range test.misses {
+			assert.False(t, re.MatchString(s), "%s doesn't match %s", test.glob, s)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	root, err := filepath.Abs("repo")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ig := &ignorer{}
+	for _, line := range []string{
+		"# a comment",
+		"",
+		"*_gen.go",
+		"!keep_gen.go",
+		"/build",
+		"tmp/",
+		"docs/*.go",
+		"legacy/**/old.go",
+	} {
+		if rule, ok := parseIgnoreRule(filepath.ToSlash(root), line); ok {
+			ig.rules = append(ig.rules, rule)
+		}
+	}
+	assert.Len(t, ig.rules, 6)
+	path := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+
+	for _, test := range []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		// names match in every directory
+		{"list_gen.go", false, true},
+		{"a/b/list_gen.go", false, true},
+		{"list.go", false, false},
+		// the last rule that matches wins
+		{"keep_gen.go", false, false},
+		{"a/keep_gen.go", false, false},
+		// anchored rules match relative to the ignore file only
+		{"build", true, true},
+		{"build", false, true},
+		{"a/build", true, false},
+		{"docs/x.go", false, true},
+		{"a/docs/x.go", false, false},
+		{"docs/a/x.go", false, false},
+		// dir-only rules don't match files
+		{"tmp", true, true},
+		{"a/tmp", true, true},
+		{"tmp", false, false},
+		// ** matches any number of directories
+		{"legacy/old.go", false, true},
+		{"legacy/a/b/old.go", false, true},
+		{"old.go", false, false},
+		// the rules don't match outside of their directory
+		{"../list_gen.go", false, false},
+	} {
+		assert.Equal(t, test.ignored, ig.ignored(path(test.rel), test.isDir), "%+v", test)
+	}
+}
+
+func TestStdinIn(t *testing.T) {
+	in, fromStdin, err := stdinIn([]string{"-"})
+	assert.NoError(t, err)
+	assert.Empty(t, in)
+	assert.True(t, fromStdin)
+
+	in, fromStdin, err = stdinIn([]string{"list.go", "set.go"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"list.go", "set.go"}, in)
+	assert.False(t, fromStdin)
+
+	in, fromStdin, err = stdinIn(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, in)
+	assert.False(t, fromStdin)
+
+	_, _, err = stdinIn([]string{"list.go", "-"})
+	assert.Error(t, err)
+}
+
+func TestGenConfigFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-config")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	template := "package list\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype Elem generic.Type\n\nfunc FirstElem(list []Elem) Elem { return list[0] }\n"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "list.go"), []byte(template), 0644))
+	config := filepath.Join(dir, "genny.yaml")
+	assert.NoError(t, ioutil.WriteFile(config, []byte(`generate:
+- in: [list.go]
+  out: int_gen.go
+  types: Elem=int
+- in: [missing.go]
+  out: missing_gen.go
+  types: Elem=int
+- in: [list.go]
+  out: bad_gen.go
+  types: Elem
+- in: [list.go]
+  out: string_gen.go
+  types: Elem=string
+`), 0644))
+
+	// the broken entries don't keep the others from being generated
+	code, err := genConfig(config, parse.Options{}, 0, false, writeOptions{}, nil)
+	assert.Equal(t, exitcodeSourceFileInvalid, code)
+	configErr, ok := err.(*configError)
+	if assert.True(t, ok, "%v", err) {
+		assert.EqualError(t, err, "2 failures generating "+config+", the rest was generated")
+		if assert.Len(t, configErr.failures, 2) {
+			assert.Equal(t, "missing.go", configErr.failures[0].what)
+			assert.Equal(t, "list.go", configErr.failures[1].what)
+			assert.Equal(t, exitcodeInvalidTypeSet, configErr.failures[1].code)
+		}
+	}
+	for out, decl := range map[string]string{"int_gen.go": "func FirstInt(", "string_gen.go": "func FirstString("} {
+		src, err := ioutil.ReadFile(filepath.Join(dir, out))
+		if assert.NoError(t, err, out) {
+			assert.Contains(t, string(src), decl, out)
+		}
+	}
+	_, err = os.Stat(filepath.Join(dir, "bad_gen.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	// all the failures are reported
+	var report bytes.Buffer
+	reportError(&report, configErr, diagFormatText)
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if assert.Len(t, lines, 3, report.String()) {
+		assert.True(t, strings.HasPrefix(lines[0], "failed: missing.go: "), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "failed: list.go: "), lines[1])
+		assert.Equal(t, "error: 2 failures generating "+config+", the rest was generated", lines[2])
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-watch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	defer func() { pending.files = nil }()
+
+	prof, err := startProfiling(filepath.Join(dir, "cpu.prof"), filepath.Join(dir, "mem.prof"), "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	outFile := filepath.Join(dir, "list_gen.go")
+	w := newWriter(outFile)
+	w.Write([]byte("package list\n"))
+
+	// the run times out in the middle of writing
+	exited := make(chan int, 1)
+	watch(10*time.Millisecond, time.Minute, prof, func(code int) { exited <- code })
+	select {
+	case code := <-exited:
+		assert.Equal(t, exitcodeTimeout, code)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the watchdog didn't end the run")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if assert.NoError(t, err) {
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+			if file.Name() == "cpu.prof" || file.Name() == "mem.prof" {
+				assert.NotZero(t, file.Size(), file.Name())
+			}
+		}
+		// the half written output never appears, and the profiles are written
+		assert.Equal(t, []string{"cpu.prof", "mem.prof"}, names)
+	}
+	assert.NoError(t, w.Close())
+	_, err = os.Stat(outFile)
+	assert.True(t, os.IsNotExist(err))
+
+	// a run that finishes in time stops the watchdog
+	watchdog := watch(time.Hour, time.Hour, &profiler{}, func(code int) { exited <- code })
+	assert.True(t, watchdog.Stop())
+}
+
+func TestCommandName(t *testing.T) {
+	for name, command := range map[string]string{
+		"gen":      "gen",
+		"GEN":      "gen",
+		"generate": "gen",
+		"fetch":    "get",
+		"ls":       "list",
+		"vet":      "verify",
+		"init":     "new",
+		"find":     "search",
+		"run":      "run",
+		"bogus":    "",
+		"":         "",
+	} {
+		assert.Equal(t, command, commandName(name), name)
+	}
+}
+
+func TestValidArgs(t *testing.T) {
+	for _, test := range []struct {
+		command      string
+		args         []string
+		haveTypeSets bool
+		valid        bool
+	}{
+		{"", nil, false, false},
+		{"bogus", nil, false, false},
+		{"gen", nil, false, true},
+		{"gen", []string{"Elem=int"}, false, true},
+		{"graph", nil, false, false},
+		{"graph", nil, true, true},
+		{"graph", []string{"Elem=int"}, false, true},
+		{"get", []string{"set/set.go"}, false, false},
+		{"get", []string{"set/set.go", "Elem=int"}, false, true},
+		{"cover", nil, false, false},
+		{"new", []string{"list"}, false, true},
+		{"explain", nil, false, false},
+		{"degenerify", nil, false, true},
+		{"degenerify", []string{"T=int", "U=int"}, false, false},
+		{"list", []string{"x"}, false, false},
+		{"check", nil, false, true},
+		{"check", []string{"list.go"}, false, false},
+		{"search", nil, false, true},
+		{"run", []string{"./..."}, false, true},
+	} {
+		assert.Equal(t, test.valid, validArgs(test.command, test.args, test.haveTypeSets), "%+v", test)
+	}
+}
+
+func TestGenerateWords(t *testing.T) {
+	defer setenv(map[string]string{"GENNY_TEST_TYPE": "string"})()
+	env := map[string]string{"GOFILE": "list.go", "DOLLAR": "$"}
+	for line, words := range map[string][]string{
+		"genny -in=$GOFILE gen Elem=int":    {"genny", "-in=list.go", "gen", "Elem=int"},
+		"  genny \t gen  ":                  {"genny", "gen"},
+		`genny gen "Elem=int Other=string"`: {"genny", "gen", "Elem=int Other=string"},
+		`genny gen "a\"b" "\tc"`:            {"genny", "gen", `a"b`, "\tc"},
+		"genny gen Elem=$GENNY_TEST_TYPE":   {"genny", "gen", "Elem=string"},
+		"genny gen Elem=${DOLLAR}x":         {"genny", "gen", "Elem=$x"},
+		"":                                  nil,
+	} {
+		got, err := generateWords(line, env)
+		if assert.NoError(t, err, line) {
+			assert.Equal(t, words, got, line)
+		}
+	}
+	_, err := generateWords(`genny gen "Elem=int`, env)
+	assert.EqualError(t, err, "unterminated quoted string")
+}
+
+func TestMain(m *testing.M) {
+	// the test binary is genny for TestNoArgs
+	if os.Getenv("GENNY_TEST_MAIN") != "" {
+		os.Args = append([]string{"genny"}, os.Args[1:]...)
+		main()
+		return
+	}
+	// and the genny that TestRunFile runs the lines with
+	if runs := os.Getenv("GENNY_TEST_RUNS"); runs != "" {
+		if len(os.Args) > 1 && os.Args[1] == "fail" {
+			os.Exit(3)
+		}
+		wd, _ := os.Getwd()
+		f, err := os.OpenFile(runs, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			os.Exit(1)
+		}
+		fmt.Fprintln(f, wd, os.Getenv("GOFILE")+":"+os.Getenv("GOLINE"), os.Getenv("GOPACKAGE"), os.Getenv("GENNY_CACHE"), os.Getenv("GENNY_ALLOW_PLUGINS"), os.Getenv("GENNY_VERIFY_BUILD"), strings.Join(os.Args[1:], " "))
+		f.Close()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// genny runs the main of genny in the test binary with the arguments, and
+// gets its exit code and stderr.
+func genny(t *testing.T, args ...string) (int, string) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GENNY_TEST_MAIN=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), stderr.String()
+	}
+	assert.NoError(t, err)
+	return 0, stderr.String()
+}
+
+func TestNoArgs(t *testing.T) {
+	code, stderr := genny(t)
+	assert.Equal(t, exitcodeInvalidArgs, code)
+	assert.True(t, strings.HasPrefix(stderr, "usage: genny "), stderr)
+}
+
+func TestRunFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-run")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	runs := filepath.Join(dir, "runs")
+	defer setenv(map[string]string{"GENNY_TEST_RUNS": runs})()
+	sub := filepath.Join(dir, "sub")
+	assert.NoError(t, os.Mkdir(sub, 0755))
+	filename := filepath.Join(sub, "list.go")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`// Package list is generated.
+package list
+
+//go:generate genny -in=$GOFILE gen "Elem=int"
+//go:generate stringer -type=Kind
+//go:generate genny fail
+//go:generate genny "gen
+//go:generate genny gen Elem=string
+`), 0644))
+
+	generated, errs := runFile(os.Args[0], filename, "/tmp/cache", "all")
+	assert.Equal(t, 2, generated)
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], filename+":6: genny fail: exit status 3")
+		assert.EqualError(t, errs[1], filename+":7: unterminated quoted string")
+	}
+	data, err := ioutil.ReadFile(runs)
+	if assert.NoError(t, err) {
+		// the lines run in the directory of the file, like with go generate
+		assert.Equal(t, sub+" list.go:4 list /tmp/cache all false -in=list.go gen Elem=int\n"+
+			sub+" list.go:8 list /tmp/cache all false gen Elem=string\n", string(data))
+	}
+
+	_, errs = runFile(os.Args[0], filepath.Join(dir, "missing.go"), "", "")
+	assert.Len(t, errs, 1)
+}
+
+func TestSetFromEnv(t *testing.T) {
+	defer setenv(map[string]string{
+		"GENNY_PKG":       "fromenv",
+		"GENNY_STRIP_TAG": "genny",
+		"GENNY_OUT":       "out.go",
+		"GENNY_V":         "",
+	})()
+	newFlags := func() (*flag.FlagSet, *string, *string, *string, *bool) {
+		flags := flag.NewFlagSet("genny", flag.ContinueOnError)
+		pkg := flags.String("pkg", "", "")
+		stripTag := flags.String("strip-tag", "", "")
+		out := flags.String("out", "", "")
+		flags.StringVar(out, "o", "", "")
+		verbose := flags.Bool("v", false, "")
+		return flags, pkg, stripTag, out, verbose
+	}
+
+	// an explicit flag beats the environment, which sets the rest
+	flags, pkg, stripTag, out, _ := newFlags()
+	assert.NoError(t, flags.Parse([]string{"-pkg", "fromflag"}))
+	assert.NoError(t, setFromEnv(flags))
+	assert.Equal(t, "fromflag", *pkg)
+	assert.Equal(t, "genny", *stripTag)
+	assert.Equal(t, "out.go", *out)
+
+	// the aliases share a value, so GENNY_OUT doesn't override -o
+	flags, _, _, out, _ = newFlags()
+	assert.NoError(t, flags.Parse([]string{"-o", "short.go"}))
+	assert.NoError(t, setFromEnv(flags))
+	assert.Equal(t, "short.go", *out)
+
+	// a bad value names its variable
+	defer setenv(map[string]string{"GENNY_V": "maybe"})()
+	flags, _, _, _, _ = newFlags()
+	assert.NoError(t, flags.Parse(nil))
+	err := setFromEnv(flags)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "bad GENNY_V=maybe")
+	}
+}
+
+func TestOpenCacheOffline(t *testing.T) {
+	defer setenv(map[string]string{"AWS_REGION": "us-east-1", "AWS_ACCESS_KEY_ID": "id", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_ENDPOINT_URL_S3": "", "AWS_ENDPOINT_URL": ""})()
+	for _, location := range []string{"https://cache.example.com/genny", "http://localhost:8080", "s3://bucket/genny"} {
+		_, err := openCache(location, true)
+		assert.EqualError(t, err, "-offline: the cache "+location+" needs the network", location)
+	}
+	cache, err := openCache("some/dir", true)
+	assert.NoError(t, err)
+	assert.Equal(t, parse.DirCache("some/dir"), cache)
+	cache, err = openCache("", true)
+	assert.NoError(t, err)
+	assert.Nil(t, cache)
+	cache, err = openCache("https://cache.example.com/genny", false)
+	assert.NoError(t, err)
+	assert.IsType(t, &parse.HTTPCache{}, cache)
+}
+
+func TestGoOffline(t *testing.T) {
+	defer setenv(map[string]string{"GOPROXY": "", "GOSUMDB": "", "GOTOOLCHAIN": "", "GENNY_OFFLINE": ""})()
+	goOffline()
+	assert.Equal(t, "off", os.Getenv("GOPROXY"))
+	assert.Equal(t, "off", os.Getenv("GOSUMDB"))
+	assert.Equal(t, "local", os.Getenv("GOTOOLCHAIN"))
+	// genny run passes it on to the lines it runs
+	assert.Equal(t, "true", os.Getenv("GENNY_OFFLINE"))
+}
+
+func TestOfflineFetches(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"templates": []}`))
+	}))
+	defer server.Close()
+
+	code, stderr := genny(t, "-offline", "-index", server.URL+"/index.json", "search", "queue")
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "-offline: only the built-in catalog is searched")
+
+	code, stderr = genny(t, "-offline", "get", "github.com/someone/templates/queue.go", "Something=int")
+	assert.Equal(t, exitcodeGetFailed, code, stderr)
+	assert.Contains(t, stderr, "-offline: github.com/someone/templates/queue.go isn't in the built-in catalog")
+
+	code, stderr = genny(t, "-offline", "-cache", server.URL, "-in", "parse/test/queue/generic_queue.go", "gen", "Something=int")
+	assert.Equal(t, exitcodeInvalidArgs, code, stderr)
+	assert.Contains(t, stderr, "-offline: the cache "+server.URL+" needs the network")
+	assert.Zero(t, requests)
+}
+
+func TestPublish(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-publish")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	public, private, err := ed25519.GenerateKey(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if !assert.NoError(t, err) {
+		return
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	template := "parse/test/queue/generic_queue.go"
+	outFile := filepath.Join(dir, "templates.tar.gz")
+	code, err := publish([]string{template}, outFile, keyFile, diagFormatText)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	// the index comes first, then each template with its signature
+	tarball, err := ioutil.ReadFile(outFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(tarball))
+	if !assert.NoError(t, err) {
+		return
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+		files[header.Name], _ = ioutil.ReadAll(tr)
+	}
+	assert.Equal(t, []string{"index.json", template, template + signatureExt}, names)
+	src, _ := ioutil.ReadFile(template)
+	assert.Equal(t, string(src), string(files[template]))
+	assert.True(t, ed25519.Verify(public, src, files[template+signatureExt]))
+	assert.JSONEq(t, `{"templates": [{"name": "`+template+`", "description": "SomethingQueue is a queue of Somethings.", "generics": ["Something"]}]}`, string(files["index.json"]))
+	indexJSON, err := ioutil.ReadFile(filepath.Join(dir, "templates.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(files["index.json"]), string(indexJSON))
+
+	// genny get takes the templates of the tarball as a bundle
+	fetched, sig, err := bundleTemplate("oci://registry.example.com/me/templates:v1", tarball, template)
+	assert.NoError(t, err)
+	assert.NoError(t, verifySignature(template, fetched, sig, []ed25519.PublicKey{public}, true))
+
+	// without a key there are no signatures
+	code, err = publish([]string{template}, outFile, "", diagFormatText)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, code)
+	tarball, _ = ioutil.ReadFile(outFile)
+	_, sig, err = bundleTemplate("oci://registry.example.com/me/templates:v1", tarball, template)
+	assert.NoError(t, err)
+	assert.Nil(t, sig)
+}
+
+// captureStdout gets what f writes to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		done <- data
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+func TestRequiredVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-doctor")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	goMod := filepath.Join(dir, "go.mod")
+	for _, test := range []struct {
+		goMod    string
+		expected string
+	}{
+		{"module example.com/m\n\nrequire github.com/mauricelam/genny v1.2.0\n", "v1.2.0"},
+		{"module example.com/m\n\nrequire (\n\tgolang.org/x/tools v0.1.0\n\tgithub.com/mauricelam/genny v1.3.0 // indirect\n)\n", "v1.3.0"},
+		{"module example.com/m\n\nrequire (\n)\n\nrequire github.com/mauricelam/genny/other v1.0.0\n", ""},
+		{"module example.com/m\n\n// require github.com/mauricelam/genny v1.0.0\n", ""},
+	} {
+		assert.NoError(t, ioutil.WriteFile(goMod, []byte(test.goMod), 0644))
+		assert.Equal(t, test.expected, requiredVersion(goMod, gennyModule), test.goMod)
+	}
+	assert.Equal(t, "", requiredVersion(filepath.Join(dir, "missing.mod"), gennyModule))
+	assert.Equal(t, "latest", pickVersion("(devel)"))
+	assert.Equal(t, "v1.2.0", pickVersion("v1.2.0"))
+}
+
+func TestImportsGeneric(t *testing.T) {
+	assert.True(t, importsGeneric([]string{"missing.go", "parse/test/queue/generic_queue.go"}))
+	assert.False(t, importsGeneric([]string{"missing.go", "doctor.go"}))
+}
+
+func TestWritableDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-doctor")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, writableDir(dir))
+	// a directory that doesn't exist yet is created in the one that does
+	assert.NoError(t, writableDir(filepath.Join(dir, "gen", "sub")))
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	assert.EqualError(t, writableDir(file), file+" is not a directory")
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
+
+func TestDoctor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-doctor")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+
+	var code int
+	stdout := captureStdout(t, func() {
+		code, err = doctor(doctorSetup{
+			in:      []string{"parse/test/queue/generic_queue.go", filepath.Join(dir, "missing.go")},
+			imports: []string{"fmt", "example.com/missing/pkg"},
+			outFile: filepath.Join(file, "queue_gen.go"),
+			plugins: []string{"gofmt"},
+		})
+	})
+	assert.Equal(t, exitcodeInvalidArgs, code)
+	assert.EqualError(t, err, "4 problems found")
+	assert.Contains(t, stdout, "ok    genny ")
+	assert.Contains(t, stdout, "FAIL  the template "+filepath.Join(dir, "missing.go")+" can't be read")
+	assert.Contains(t, stdout, "FAIL  the import example.com/missing/pkg doesn't resolve")
+	assert.Contains(t, stdout, "FAIL  the output directory "+file+" isn't writable")
+	assert.Contains(t, stdout, "FAIL  the plugin gofmt isn't allowed, as no plugin policy is set")
+	assert.Contains(t, stdout, "      → -allow-plugins=gofmt\n")
+
+	stdout = captureStdout(t, func() {
+		code, err = doctor(doctorSetup{
+			in:      []string{"parse/test/queue/generic_queue.go"},
+			imports: []string{"fmt"},
+			outFile: filepath.Join(dir, "gen", "queue_gen.go"),
+		})
+	})
+	assert.Equal(t, 0, code, stdout)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "ok    the 1 imports resolve")
+	assert.Contains(t, stdout, "ok    the output directory "+filepath.Join(dir, "gen")+" is writable")
+	assert.NotContains(t, stdout, "FAIL")
+}
+
+func TestProfiling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-profile")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	cpu, mem, trace := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out"), filepath.Join(dir, "trace.out")
+	p, err := startProfiling(cpu, mem, trace)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = parse.GenericsTemplates([]parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader("package queue\n\ntype T int\n")}}, []map[string]string{{"T": "int"}}, parse.Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, p.stop())
+	for _, filename := range []string{cpu, mem, trace} {
+		info, err := os.Stat(filename)
+		if assert.NoError(t, err, filename) {
+			assert.NotZero(t, info.Size(), filename)
+		}
+	}
+
+	// nothing is recorded without the paths
+	p, err = startProfiling("", "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, p.stop())
+
+	// a trace that can't be written stops the CPU profile, so that the next
+	// one can start
+	missing := filepath.Join(dir, "missing", "trace.out")
+	_, err = startProfiling(cpu, "", missing)
+	assert.Error(t, err)
+	p, err = startProfiling(cpu, "", "")
+	if assert.NoError(t, err) {
+		assert.NoError(t, p.stop())
+	}
+	p, err = startProfiling("", filepath.Join(dir, "missing", "mem.out"), "")
+	assert.NoError(t, err)
+	assert.Error(t, p.stop())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// reModuleTemplate matches a template in a module, like
+// github.com/me/templates@v1.2.0/list/list.go, with the module path, the
+// version and the path of the template in the module.
+var reModuleTemplate = regexp.MustCompile(`^([^/@]+\.[^/@]+(?:/[^@]+)?)@([^/]+)/(.+\.go)$`)
+
+// moduleTemplates replaces the templates of -in that are in modules, like
+// github.com/me/templates@v1.2.0/list/list.go, with their files in the
+// module cache. The modules are downloaded by the go command, through
+// GOPROXY, and verified with the checksum database like any dependency,
+// and with the go.sum of the current module if it has them. The signature
+// of a template, next to it in the module, is verified like that of one
+// that get fetches.
+func moduleTemplates(in []string, keys []ed25519.PublicKey, requireSignature bool) ([]string, error) {
+	resolved := make([]string, len(in))
+	for i, filename := range in {
+		resolved[i] = filename
+		m := reModuleTemplate.FindStringSubmatch(filepath.ToSlash(filename))
+		if m == nil {
+			continue
+		}
+		if _, err := os.Stat(filename); err == nil {
+			// a file like it
+			continue
+		}
+		dir, err := downloadModule(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = filepath.Join(dir, filepath.FromSlash(m[3]))
+		src, err := ioutil.ReadFile(resolved[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s has no %s", m[1], m[2], m[3])
+		}
+		// a missing signature is up to the signature policy
+		sig, _ := ioutil.ReadFile(resolved[i] + signatureExt)
+		if err := verifySignature(filename, src, sig, keys, requireSignature); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// moduleDownload is the output of go mod download -json.
+type moduleDownload struct {
+	Path, Version, Dir, Sum, Error string
+}
+
+// downloadModule downloads the module into the module cache with the go
+// command, and gets its directory.
+func downloadModule(modPath, version string) (string, error) {
+	// outside of any module, so that no go.mod or go.work changes
+	tmp, err := ioutil.TempDir("", "genny-mod")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	cmd := exec.Command("go", "mod", "download", "-json", modPath+"@"+version)
+	cmd.Dir = tmp
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOWORK=off")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+	var download moduleDownload
+	if err := json.Unmarshal(stdout.Bytes(), &download); err != nil {
+		if runErr != nil {
+			return "", fmt.Errorf("downloading %s@%s: %v: %s", modPath, version, runErr, strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("downloading %s@%s: %v", modPath, version, err)
+	}
+	if download.Error != "" {
+		return "", fmt.Errorf("downloading %s@%s: %s", modPath, version, download.Error)
+	}
+	if runErr != nil {
+		return "", fmt.Errorf("downloading %s@%s: %v: %s", modPath, version, runErr, strings.TrimSpace(stderr.String()))
+	}
+	if err := checkGoSum(download); err != nil {
+		return "", err
+	}
+	return download.Dir, nil
+}
+
+// checkGoSum checks the hash of the downloaded module against the go.sum of
+// the module of the current directory, if it has the module.
+func checkGoSum(download moduleDownload) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	root, _ := moduleRoot(wd)
+	if root == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == download.Path && fields[1] == download.Version && fields[2] != download.Sum {
+			return fmt.Errorf("verifying %s@%s: checksum mismatch\n\tdownloaded: %s\n\tgo.sum:     %s", download.Path, download.Version, download.Sum, fields[2])
+		}
+	}
+	return nil
+}
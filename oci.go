@@ -0,0 +1,326 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ociMediaType is the media type of the layer of a bundle of templates, the
+// tarball of genny publish.
+const ociMediaType = "application/vnd.genny.templates.v1.tar+gzip"
+
+// The most that is read of a manifest, like the limit of containerd, and
+// of a bundle of templates, so that a registry can't run genny out of
+// memory.
+const (
+	ociMaxManifest = 4 << 20
+	ociMaxBlob     = 64 << 20
+)
+
+// ociRef is a template in a bundle of templates published as an OCI
+// artifact, like oci://ghcr.io/org/templates:v1/list/list.go.
+type ociRef struct {
+	registry, repository string
+	// reference is the tag or the digest of the artifact.
+	reference string
+	// path is the template in the bundle, or "" for its only one.
+	path string
+}
+
+// parseOCIRef parses a template of an OCI artifact, like
+// oci://registry/org/templates:v1/list.go, or with a digest instead of the
+// tag, like oci://registry/org/templates@sha256:0123.../list.go.
+func parseOCIRef(name string) (ociRef, error) {
+	var ref ociRef
+	rest := strings.TrimPrefix(name, "oci://")
+	slash := strings.Index(rest, "/")
+	if slash <= 0 {
+		return ref, fmt.Errorf("bad %s, expected oci://registry/repository:tag/path", name)
+	}
+	ref.registry, rest = rest[:slash], rest[slash+1:]
+	var after string
+	if at := strings.Index(rest, "@"); at >= 0 {
+		ref.repository, after = rest[:at], rest[at+1:]
+	} else if colon := strings.Index(rest, ":"); colon >= 0 {
+		ref.repository, after = rest[:colon], rest[colon+1:]
+	} else {
+		ref.repository, after = rest, "latest"
+	}
+	if slash := strings.Index(after, "/"); slash >= 0 {
+		after, ref.path = after[:slash], path.Clean(after[slash+1:])
+	}
+	ref.reference = after
+	if ref.repository == "" || ref.reference == "" {
+		return ref, fmt.Errorf("bad %s, expected oci://registry/repository:tag/path", name)
+	}
+	return ref, nil
+}
+
+// fetchOCI downloads a template of a bundle published as an OCI artifact,
+// verifying the digests of what the registry sends, and its signature in
+// the bundle, if it has one.
+func fetchOCI(name string) ([]byte, []byte, error) {
+	ref, err := parseOCIRef(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &ociClient{registry: ref.registry, client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}, Timeout: fetchTimeout, CheckRedirect: checkRedirect}}
+
+	manifestData, err := c.get("manifests/"+ref.reference, ref.repository, "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json", ociMaxManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasPrefix(ref.reference, "sha256:") {
+		if err := checkDigest(ref.reference, manifestData); err != nil {
+			return nil, nil, err
+		}
+	}
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("bad manifest of %s: %v", name, err)
+	}
+	digest := ""
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == ociMediaType || digest == "" && strings.HasSuffix(layer.MediaType, "tar+gzip") {
+			digest = layer.Digest
+		}
+	}
+	if digest == "" {
+		return nil, nil, fmt.Errorf("%s has no layer of templates, of the media type %s", name, ociMediaType)
+	}
+	bundle, err := c.get("blobs/"+digest, ref.repository, "", ociMaxBlob)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkDigest(digest, bundle); err != nil {
+		return nil, nil, err
+	}
+	return bundleTemplate(name, bundle, ref.path)
+}
+
+// checkDigest checks that the data has the sha256 digest.
+func checkDigest(digest string, data []byte) error {
+	sum := sha256.Sum256(data)
+	if got := "sha256:" + hex.EncodeToString(sum[:]); got != digest {
+		return fmt.Errorf("verifying %s: digest mismatch, got %s", digest, got)
+	}
+	return nil
+}
+
+// bundleTemplate gets the template at the path in the tarball of a bundle,
+// or its only template if the path is "", and its signature if the bundle
+// has one.
+func bundleTemplate(name string, bundle []byte, templatePath string) ([]byte, []byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad bundle %s: %v", name, err)
+	}
+	tr := tar.NewReader(gz)
+	templates := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad bundle %s: %v", name, err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".go") && !strings.HasSuffix(header.Name, ".go"+signatureExt) {
+			continue
+		}
+		src, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad bundle %s: %v", name, err)
+		}
+		templates[path.Clean(header.Name)] = src
+	}
+	var names []string
+	for name := range templates {
+		if strings.HasSuffix(name, ".go") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if templatePath == "" && len(names) == 1 {
+		templatePath = names[0]
+	}
+	if src, ok := templates[templatePath]; ok && strings.HasSuffix(templatePath, ".go") {
+		return src, templates[templatePath+signatureExt], nil
+	}
+	if templatePath == "" {
+		return nil, nil, fmt.Errorf("%s has the templates %s, name one like %s/%s", name, strings.Join(names, ", "), name, names[0])
+	}
+	return nil, nil, fmt.Errorf("%s has no %s, only %s", name, templatePath, strings.Join(names, ", "))
+}
+
+// ociClient gets the manifests and blobs of a registry with the OCI
+// distribution API, authenticating with the token of its challenge.
+type ociClient struct {
+	registry string
+	client   *http.Client
+	// authorization is the Authorization header once the registry asked
+	// for one.
+	authorization string
+}
+
+// get gets the manifest or blob of the repository at what, like
+// manifests/v1, which may be at most max bytes.
+func (c *ociClient) get(what, repository, accept string, max int64) ([]byte, error) {
+	scheme := "https"
+	if host := strings.Split(c.registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	u := scheme + "://" + c.registry + "/v2/" + repository + "/" + what
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > max {
+			return nil, fmt.Errorf("fetching %s: larger than %d bytes", u, max)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := c.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", u, resp.Status)
+		}
+		return data, nil
+	}
+}
+
+// reChallengeParam matches a parameter of a WWW-Authenticate challenge,
+// like realm="https://ghcr.io/token".
+var reChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate answers the challenge of the registry: a Bearer challenge
+// with a token of its realm, which is asked for with the login of the
+// registry if there is one, and a Basic challenge with the login. The realm
+// has to be https, or on this machine, for the login to be sent to it.
+func (c *ociClient) authenticate(challenge string) error {
+	login, password, hasLogin := registryLogin(c.registry)
+	switch {
+	case strings.HasPrefix(strings.ToLower(challenge), "basic"):
+		if !hasLogin {
+			return fmt.Errorf("%s needs a login, in the docker config or netrc", c.registry)
+		}
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(login+":"+password))
+		return nil
+	case !strings.HasPrefix(strings.ToLower(challenge), "bearer"):
+		return fmt.Errorf("%s asks for an unknown authentication: %s", c.registry, challenge)
+	}
+	params := make(map[string]string)
+	for _, m := range reChallengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	query := url.Values{}
+	for _, name := range []string{"service", "scope"} {
+		if params[name] != "" {
+			query.Set(name, params[name])
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if !secure(req.URL) {
+		return fmt.Errorf("%s asks for a token of %s, which isn't https", c.registry, params["realm"])
+	}
+	if hasLogin {
+		req.SetBasicAuth(login, password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("getting a token of %s: %s", c.registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, ociMaxManifest)).Decode(&token); err != nil {
+		return fmt.Errorf("getting a token of %s: %v", c.registry, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return errors.New("getting a token of " + c.registry + ": no token")
+	}
+	c.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// registryLogin gets the login of the registry from the docker config, of
+// DOCKER_CONFIG or the home directory, like docker login writes it, or else
+// from the netrc file.
+func registryLogin(registry string) (string, string, bool) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".docker")
+		}
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "config.json")); err == nil {
+		var config struct {
+			Auths map[string]struct {
+				Auth string `json:"auth"`
+			} `json:"auths"`
+		}
+		if json.Unmarshal(data, &config) == nil {
+			for _, key := range []string{registry, "https://" + registry, "http://" + registry} {
+				if auth, ok := config.Auths[key]; ok && auth.Auth != "" {
+					if decoded, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+						if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+							return parts[0], parts[1], true
+						}
+					}
+				}
+			}
+		}
+	}
+	return netrcLogin(strings.Split(registry, ":")[0])
+}
@@ -0,0 +1,76 @@
+package out
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+)
+
+// AtomicFile is an io.WriteCloser like LazyFile, which writes to a temporary file next to the
+// file it is supposed to write in, and renames it to that file when it is closed. The file
+// therefore never is half written, even if genny is stopped while writing it.
+type AtomicFile struct {
+	// FileName is path to the file to which genny will write.
+	FileName string
+	mu       sync.Mutex
+	temp     *os.File
+	done     bool
+}
+
+// Write writes to the temporary file and creates it the first time it is called.
+func (af *AtomicFile) Write(p []byte) (int, error) {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	if af.done {
+		return 0, errors.New("write to " + af.FileName + " after it was closed or discarded")
+	}
+	if af.temp == nil {
+		err := os.MkdirAll(path.Dir(af.FileName), 0755)
+		if err != nil {
+			return 0, err
+		}
+		af.temp, err = ioutil.TempFile(path.Dir(af.FileName), "."+path.Base(af.FileName)+".tmp")
+		if err != nil {
+			return 0, err
+		}
+	}
+	return af.temp.Write(p)
+}
+
+// Close renames the temporary file to the file, if it is created. Returns nil if no file is
+// created.
+func (af *AtomicFile) Close() error {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	if af.done || af.temp == nil {
+		af.done = true
+		return nil
+	}
+	af.done = true
+	err := af.temp.Chmod(0644)
+	if closeErr := af.temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(af.temp.Name(), af.FileName)
+	}
+	if err != nil {
+		os.Remove(af.temp.Name())
+	}
+	return err
+}
+
+// Discard removes the temporary file, leaving the file as it was. It does nothing after Close.
+func (af *AtomicFile) Discard() error {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	if af.done || af.temp == nil {
+		af.done = true
+		return nil
+	}
+	af.done = true
+	af.temp.Close()
+	return os.Remove(af.temp.Name())
+}
@@ -0,0 +1,52 @@
+package out_test
+
+import (
+	"github.com/mauricelam/genny/out"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestAtomicWrites(t *testing.T) {
+	defer tearDown()
+	af := out.AtomicFile{FileName: testFileName}
+	af.Write([]byte("Word1"))
+	af.Write([]byte("Word2"))
+	_, err := os.Stat(testFileName)
+	assert.True(t, os.IsNotExist(err), "Expected file not to be written before it is closed")
+	assert.NoError(t, af.Close())
+	assertFileContains(t, "Word1Word2")
+	_, err = af.Write([]byte("Word3"))
+	assert.Error(t, err)
+	assertNoTempFiles(t)
+}
+
+func TestAtomicDiscard(t *testing.T) {
+	defer tearDown()
+	assert.NoError(t, ioutil.WriteFile(testFileName, []byte("Old"), 0644))
+	af := out.AtomicFile{FileName: testFileName}
+	af.Write([]byte("Half"))
+	assert.NoError(t, af.Discard())
+	assert.NoError(t, af.Close())
+	assertFileContains(t, "Old")
+	assertNoTempFiles(t)
+}
+
+func TestAtomicNoWrite(t *testing.T) {
+	defer tearDown()
+	af := out.AtomicFile{FileName: testFileName}
+	assert.NoError(t, af.Close())
+	_, err := os.Stat(testFileName)
+	assert.True(t, os.IsNotExist(err), "Expected file not to be created")
+}
+
+func assertNoTempFiles(t *testing.T) {
+	files, err := ioutil.ReadDir(".")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		assert.False(t, len(file.Name()) > len(testFileName) && file.Name()[:len(testFileName)+1] == "."+testFileName, "Temporary file %s is left", file.Name())
+	}
+}
@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// annotationPrefix starts the line that Options.Annotate marks every
+// generated declaration with, at the end of its doc comment, like
+//
+//	// genny: list.go:42 Elem=int
+const annotationPrefix = "// genny: "
+
+// annotate marks the declarations of the code generated from a template for
+// a type set with the declaration of the template they were generated
+// from, which is one of the decls of the template. The declarations that
+// none of them generates, like those a plugin adds, aren't marked.
+func annotate(filename string, code []byte, decls []sourceDecl, typeSet map[string]string, clause string) []byte {
+	generated, err := sourceDecls(filename, code)
+	if err != nil {
+		// leave it to imports.Process to report the syntax error
+		return code
+	}
+	marks := make(map[int]string)
+	for _, decl := range generated {
+		for _, t := range decls {
+			if subIntoType(t.name, typeSet) == decl.name {
+				marks[decl.line] = annotationPrefix + filepath.Base(t.filename) + ":" + strconv.Itoa(t.line) + " " + clause
+				break
+			}
+		}
+	}
+	if len(marks) == 0 {
+		return code
+	}
+	lines := strings.SplitAfter(string(code), "\n")
+	var out strings.Builder
+	for i, line := range lines {
+		if mark, ok := marks[i+1]; ok {
+			out.WriteString(makeLine(mark))
+		}
+		out.WriteString(line)
+	}
+	return []byte(out.String())
+}
+
+// FindAnnotation gets the annotation of the generated declaration that the
+// line of the code is in, like "list.go:42 Elem=int", which is the last one
+// at or before the line, if Options.Annotate marked the code.
+func FindAnnotation(code []byte, line int) (string, bool) {
+	annotation, found := "", false
+	for i, text := range strings.Split(string(code), "\n") {
+		if i >= line {
+			break
+		}
+		text = strings.TrimSpace(strings.TrimRight(text, linefeed))
+		if strings.HasPrefix(text, annotationPrefix) {
+			annotation, found = strings.TrimPrefix(text, annotationPrefix), true
+		}
+	}
+	return annotation, found
+}
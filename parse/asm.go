@@ -0,0 +1,136 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// OutputFile is a file generated for one type set.
+type OutputFile struct {
+	// Filename is the name of the file, without a directory, or the path of
+	// an output of a config relative to it, for GenericsConfig.
+	Filename string
+	// Code is the content of the file.
+	Code []byte
+}
+
+// GenericsAsm generates a copy of an assembly template for every type set,
+// to go along with the generated functions that are declared without a body
+// in the Go template. The generic types in symbol names and comments are
+// replaced like in Go code, so ·ElemSum becomes ·IntSum. The name of each
+// copy is the name of the template with the generic types replaced, so
+// elem_amd64.s becomes int_amd64.s, or prefixed with the specific types if
+// the name contains none of them. Type sets that generate the same file
+// with the same code share it.
+func GenericsAsm(template Template, typeSets []map[string]string, opts Options) ([]OutputFile, error) {
+	if err := opts.Limits.checkInstantiations(len(typeSets)); err != nil {
+		return nil, err
+	}
+	src, err := opts.Limits.readSource(template)
+	if err != nil {
+		return nil, err
+	}
+	src = normalizeEOL(src)
+
+	argTypeSets := typeSets
+	typeSets, err = namedTypeSets(typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []OutputFile
+	generatedBy := make(map[string]int)
+	for typeSetIndex, typeSet := range typeSets {
+		file := OutputFile{
+			Filename: typeSetFilename(filepath.Base(template.Filename), typeSet),
+			Code:     generateAsm(src, typeSet, opts.StripTags),
+		}
+		if prev, ok := generatedBy[file.Filename]; ok {
+			if !bytes.Equal(files[prev].Code, file.Code) {
+				return nil, &errConflictingDecl{
+					Name:     file.Filename,
+					TypeSet:  typeSetClause(argTypeSets[prev]),
+					Conflict: typeSetClause(argTypeSets[typeSetIndex]),
+				}
+			}
+			continue
+		}
+		generatedBy[file.Filename] = len(files)
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// namedTypeSets gets the type sets with the imports and the naming policies
+// resolved, like the code is generated with.
+func namedTypeSets(typeSets []map[string]string, opts Options) ([]map[string]string, error) {
+	typeSets, _, err := qualifyTypeSets(typeSets, importPaths(opts.ImportPaths))
+	if err != nil {
+		return nil, err
+	}
+	return applyNaming(typeSets, opts.Naming, opts.Namer)
+}
+
+// generateAsm replaces the generic types in the words of the assembly code
+// and strips the tags from its build constraints.
+func generateAsm(src []byte, typeSet map[string]string, stripTags []string) []byte {
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(stripTags) > 0 && isConstraintLine(line) {
+			for _, l := range stripConstraintLine(line, stripTags) {
+				buf.WriteString(makeLine(l))
+			}
+			continue
+		}
+		for _, generic := range sortedKeys(typeSet) {
+			line = subTypeIntoWords(line, generic, typeSet[generic])
+		}
+		buf.WriteString(makeLine(line))
+	}
+	return buf.Bytes()
+}
+
+// subTypeIntoWords substitutes the specific type into every word of s
+// that contains the generic type, leaving everything between the words,
+// like the spacing of the columns of assembly code, as it is.
+func subTypeIntoWords(s, typeTemplate, specificType string) string {
+	var out strings.Builder
+	runes := []rune(s)
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && isAlphaNumeric(runes[end]) {
+			end++
+		}
+		if end == start {
+			out.WriteRune(runes[start])
+			start++
+			continue
+		}
+		out.WriteString(subIntoLiteral(string(runes[start:end]), typeTemplate, specificType))
+		start = end
+	}
+	return out.String()
+}
+
+// typeSetFilename gets the name of the copy of a template for a type set,
+// by replacing the generic types in its name, or else prefixing it with the
+// specific types.
+func typeSetFilename(name string, typeSet map[string]string) string {
+	replaced := name
+	var words []string
+	for _, generic := range sortedKeys(typeSet) {
+		word := strings.ToLower(wordify(typeSet[generic], false))
+		words = append(words, word)
+		if idx := strings.Index(strings.ToLower(replaced), strings.ToLower(generic)); idx >= 0 {
+			replaced = replaced[:idx] + word + replaced[idx+len(generic):]
+		}
+	}
+	if replaced != name {
+		return replaced
+	}
+	return strings.Join(words, "_") + "_" + name
+}
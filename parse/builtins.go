@@ -1,5 +1,7 @@
 package parse
 
+import "sort"
+
 // Builtins contains a slice of all built-in Go types.
 var Builtins = []string{
 	"bool",
@@ -39,3 +41,44 @@ var Numbers = []string{
 	"uint64",
 	"uint8",
 }
+
+// Integers contains a slice of all built-in integer types.
+var Integers = []string{
+	"int",
+	"int16",
+	"int32",
+	"int64",
+	"int8",
+	"uint",
+	"uint16",
+	"uint32",
+	"uint64",
+	"uint8",
+}
+
+// Numeric contains a slice of all built-in types that arithmetic can be
+// done with, the numbers and the complex numbers.
+var Numeric = append([]string{"complex128", "complex64"}, Numbers...)
+
+// Ordered contains a slice of all built-in types that can be compared with
+// < and >, the numbers and string.
+var Ordered = append(append([]string{}, Numbers...), "string")
+
+// typeClasses are the lists of types that a specific type starting with @
+// stands for, like T=@numeric.
+var typeClasses = map[string][]string{
+	"@all-builtin": Builtins,
+	"@integer":     Integers,
+	"@numeric":     Numeric,
+	"@ordered":     Ordered,
+}
+
+// typeClassNames gets the names of the type classes in order.
+func typeClassNames() []string {
+	var names []string
+	for name := range typeClasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,142 @@
+package parse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	runtimedebug "runtime/debug"
+	"sort"
+	"strconv"
+)
+
+// cacheKey is what the code generated by GenericsTemplates depends on,
+// which it is cached by.
+type cacheKey struct {
+	Genny     string
+	Templates []string
+	Sources   [][]byte
+	TypeSets  []map[string]string
+	Options   Options
+	Stamp     string
+	Existing  []string
+}
+
+// cachedKey gets the key of the code that the templates generate for the
+// type sets, and the templates, read into memory. It gets "" if the code
+// can't be cached, as the options run code of their own or write files.
+func cachedKey(templates []Template, typeSets []map[string]string, opts Options) (string, []Template, error) {
+	if opts.Namer != nil || len(opts.Transforms) > 0 || opts.DebugDir != "" {
+		return "", templates, nil
+	}
+	key := cacheKey{Genny: gennyBuild(), TypeSets: typeSets}
+	read := make([]Template, len(templates))
+	for i, template := range templates {
+		src, err := readSource(template)
+		if err != nil {
+			return "", nil, err
+		}
+		read[i] = Template{Filename: template.Filename, Source: bytes.NewReader(src)}
+		resolved, err := resolveTemplate(template.Filename, normalizeEOL(src))
+		if err != nil {
+			// leave it to the generation to report
+			return "", read, nil
+		}
+		key.Templates = append(key.Templates, template.Filename)
+		key.Sources = append(key.Sources, resolved)
+	}
+	if opts.StampDir != "" {
+		key.Stamp = stampLine(opts.StampDir, templates, key.Sources)
+	}
+	if opts.SkipExistingDir != "" {
+		existing, err := existingDecls(opts.SkipExistingDir)
+		if err != nil {
+			return "", read, nil
+		}
+		for name := range existing {
+			key.Existing = append(key.Existing, name)
+		}
+		sort.Strings(key.Existing)
+	}
+	key.Options = opts
+	key.Options.Stats, key.Options.StampDir, key.Options.SkipExistingDir = nil, "", ""
+	key.Options.Cache, key.Options.CacheDir = nil, ""
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", read, nil
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), read, nil
+}
+
+// gennyBuild identifies the build of genny that generates the code, so
+// that another one doesn't use the code it cached. A development build is
+// told apart by its executable.
+func gennyBuild() string {
+	if info, ok := runtimedebug.ReadBuildInfo(); ok {
+		module := &info.Main
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/mauricelam/genny" {
+				module = dep
+			}
+		}
+		if module.Version != "" && module.Version != "(devel)" {
+			return module.Path + "@" + module.Version + " " + module.Sum
+		}
+	}
+	if self, err := os.Executable(); err == nil {
+		if info, err := os.Stat(self); err == nil {
+			return self + " " + strconv.FormatInt(info.Size(), 10) + " " + info.ModTime().String()
+		}
+	}
+	return ""
+}
+
+// Cache stores the code generated by GenericsTemplates by a key, which is
+// a hash of the templates, type sets, options and build of genny it was
+// generated from. The cache is only an optimization, so GenericsTemplates
+// generates the code when it can't be read and carries on when it can't be
+// written.
+type Cache interface {
+	// Get gets the code of the key, or false if it isn't cached.
+	Get(key string) ([]byte, bool)
+	// Put caches the code of the key.
+	Put(key string, code []byte) error
+}
+
+// DirCache is a Cache in a directory, which runs of genny may share.
+type DirCache string
+
+// Get gets the code of the key from its file in the directory.
+func (dir DirCache) Get(key string) ([]byte, bool) {
+	code, err := ioutil.ReadFile(filepath.Join(string(dir), key[:2], key+".go"))
+	return code, err == nil
+}
+
+// Put writes the code of the key to its file in the directory.
+func (dir DirCache) Put(key string, code []byte) error {
+	sub := filepath.Join(string(dir), key[:2])
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		return err
+	}
+	// written to a temporary file first, as other runs may read it already
+	file, err := ioutil.TempFile(sub, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(code)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(sub, key+".go"))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
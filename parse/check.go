@@ -0,0 +1,129 @@
+package parse
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// CheckTemplate checks that a template parses, type checks against the
+// generic package and has nothing that the types can't be substituted
+// into, before it is generated for any types. The template is checked on
+// its own, with its includes and base. It gets the problems found, which
+// are none for a template that is fine.
+func CheckTemplate(filename string, src []byte) []Diagnostic {
+	src, err := resolveTemplate(filename, normalizeEOL(src))
+	if err != nil {
+		return []Diagnostic{Diagnose(err)}
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		var diagnostics []Diagnostic
+		if list, ok := err.(scanner.ErrorList); ok {
+			for _, e := range list {
+				diagnostics = append(diagnostics, Diagnostic{Rule: "syntax", Filename: e.Pos.Filename, Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+			}
+			return diagnostics
+		}
+		return []Diagnostic{{Rule: "syntax", Filename: filename, Message: err.Error()}}
+	}
+
+	diagnostics := checkGenericUses(fset, file)
+	if _, err := expandPlaceholders(filename, src, templateGenerics(file), nil); err != nil {
+		if _, ok := err.(*errPlaceholder); ok {
+			diagnostics = append(diagnostics, Diagnose(err))
+		}
+	}
+
+	config := types.Config{
+		Importer: importer.For("source", nil),
+		Error: func(err error) {
+			e := err.(types.Error)
+			pos := fset.Position(e.Pos)
+			diagnostics = append(diagnostics, Diagnostic{Rule: "type", Filename: pos.Filename, Line: pos.Line, Column: pos.Column, Message: e.Msg})
+		},
+	}
+	config.Check(file.Name.Name, fset, []*ast.File{file}, nil)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return diagnostics
+}
+
+// templateGenerics gets the generic types that the template declares, each
+// mapped to itself.
+func templateGenerics(file *ast.File) map[string]string {
+	generics := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if sel, ok := ts.Type.(*ast.SelectorExpr); ok && isGenericTypeSelector(sel) {
+				generics[ts.Name.Name] = ts.Name.Name
+			}
+		}
+	}
+	return generics
+}
+
+// checkGenericUses finds the uses of the generic package that the types
+// can't be substituted into: placeholder types used anywhere but in the
+// declaration of a generic type, which is the only place the line they
+// are on is dropped from, and generic types whose names contain each other,
+// which are substituted into each other.
+func checkGenericUses(fset *token.FileSet, file *ast.File) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(rule string, pos token.Pos, message string) {
+		p := fset.Position(pos)
+		diagnostics = append(diagnostics, Diagnostic{Rule: rule, Filename: p.Filename, Line: p.Line, Column: p.Column, Message: message})
+	}
+
+	declared := make(map[*ast.SelectorExpr]bool)
+	var names []string
+	namePos := make(map[string]token.Pos)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if sel, ok := ts.Type.(*ast.SelectorExpr); ok && isGenericTypeSelector(sel) {
+				declared[sel] = true
+				names = append(names, ts.Name.Name)
+				namePos[ts.Name.Name] = ts.Name.Pos()
+			}
+		}
+	}
+	if len(names) == 0 {
+		report("no-generics", file.Name.Pos(), "the template declares no generic types, like type Elem generic.Type")
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if ok && isGenericTypeSelector(sel) && !declared[sel] {
+			report("generic-use", sel.Pos(), "generic."+sel.Sel.Name+" can only declare a generic type, like type Elem generic."+sel.Sel.Name+", as the line it is on is left out of the generated code")
+		}
+		return true
+	})
+
+	for _, a := range names {
+		for _, b := range names {
+			if a != b && strings.Contains(b, a) {
+				report("overlapping-generics", namePos[b], "the generic type "+b+" contains the generic type "+a+", which would be substituted into it")
+			}
+		}
+	}
+	return diagnostics
+}
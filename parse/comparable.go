@@ -0,0 +1,116 @@
+package parse
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// mapKeyGenerics gets the generic types that the template uses in the key
+// type of a map, which only comparable specific types can be, with the
+// line of their first use. The source is left to the generation to report
+// if it doesn't parse.
+func mapKeyGenerics(filename string, src []byte) map[string]int {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil
+	}
+	keys := make(map[string]int)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if m, ok := node.(*ast.MapType); ok {
+			for _, ident := range keyIdents(m.Key) {
+				if _, ok := keys[ident.Name]; !ok {
+					keys[ident.Name] = fset.Position(ident.Pos()).Line
+				}
+			}
+		}
+		return true
+	})
+	return keys
+}
+
+// keyIdents gets the type names that a map key type is comparable only if
+// they are: itself, the elements of its arrays and the fields of its
+// structs, but not what it points to.
+func keyIdents(expr ast.Expr) []*ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return []*ast.Ident{e}
+	case *ast.ParenExpr:
+		return keyIdents(e.X)
+	case *ast.ArrayType:
+		if e.Len != nil {
+			return keyIdents(e.Elt)
+		}
+	case *ast.StructType:
+		var idents []*ast.Ident
+		for _, field := range e.Fields.List {
+			idents = append(idents, keyIdents(field.Type)...)
+		}
+		return idents
+	}
+	return nil
+}
+
+// incomparable tells why a specific type isn't comparable, as far as its
+// syntax tells: slices, maps and funcs aren't, and neither are the arrays
+// and structs of them. Named types are taken to be comparable.
+func incomparable(specific string) (string, bool) {
+	expr, err := parser.ParseExpr(specific)
+	if err != nil {
+		return "", false
+	}
+	return incomparableExpr(expr)
+}
+
+// incomparableExpr is incomparable for a parsed type.
+func incomparableExpr(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return incomparableExpr(e.X)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "a slice", true
+		}
+		return incomparableExpr(e.Elt)
+	case *ast.MapType:
+		return "a map", true
+	case *ast.FuncType:
+		return "a func", true
+	case *ast.StructType:
+		for _, field := range e.Fields.List {
+			if why, ok := incomparableExpr(field.Type); ok {
+				return "a struct with " + why, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkMapKeys fails if a type set has a specific type that isn't
+// comparable for a generic type that a template uses in a map key, which
+// would otherwise only fail to compile in the generated code.
+func (g *generation) checkMapKeys() error {
+	for i, template := range g.templates {
+		generics := make([]string, 0, len(g.mapKeys[i]))
+		for generic := range g.mapKeys[i] {
+			generics = append(generics, generic)
+		}
+		sort.Strings(generics)
+		for _, generic := range generics {
+			line := g.mapKeys[i][generic]
+			for j, typeSet := range g.typeSets {
+				specific, ok := typeSet[generic]
+				if !ok {
+					continue
+				}
+				if why, ok := incomparable(typify(specific)); ok {
+					return &errNotComparable{Filename: template.Filename, Line: line, Generic: generic, Specific: typify(g.argTypeSets[j][generic]), Why: why}
+				}
+			}
+		}
+	}
+	return nil
+}
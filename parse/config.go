@@ -0,0 +1,495 @@
+package parse
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes the code to generate, as read from a YAML file like
+//
+//	generate:
+//	- in: [bitset.go]
+//	  out: bitset_gen.go
+//	  typesets:
+//	  - types: Word=uint32
+//	    build: 386 || arm
+//	    out: bitset_32.go
+//	  - types: Word=uint64
+//	    build: "!(386 || arm)"
+//	    out: bitset_64.go
+type Config struct {
+	// Generate lists the code to generate.
+	Generate []GenerateConfig `yaml:"generate"`
+	// Imports maps specific types to the import paths they need, like
+	// time.Time to time, which are added wherever the types are used.
+	Imports map[string]string `yaml:"imports"`
+	// Values are the Options.Values of the specific types.
+	Values map[string]map[string]string `yaml:"values"`
+	// Renames are the Options.Renames of all the generated code.
+	Renames map[string]string `yaml:"renames"`
+}
+
+// GenerateConfig describes the code generated from some templates.
+type GenerateConfig struct {
+	// In are the templates, merged like with Template.
+	In []string `yaml:"in"`
+	// Out is the file the code is written to.
+	Out string `yaml:"out"`
+	// Pkg is the package name of the generated code, like Options.PkgName.
+	Pkg string `yaml:"pkg"`
+	// Types are the type sets, written like the argument of genny gen.
+	Types string `yaml:"types"`
+	// TypeSets are type sets with settings of their own. The types of
+	// each are added to Types.
+	TypeSets []TypeSetConfig `yaml:"typesets"`
+	// Conversions are pairs of type sets to generate conversion functions
+	// between, like GenericsConversions.
+	Conversions []ConversionConfig `yaml:"conversions"`
+	// Variants are pairs of type sets that are generated for a build tag
+	// and without it, like a portable implementation for purego and an
+	// accelerated one otherwise. Each type set is generated in both
+	// variants.
+	Variants []VariantConfig `yaml:"variants"`
+	// Routes send some of the type sets to files of their own, like those
+	// of the types of generated protobuf packages to a directory of them.
+	// The first route that matches a type set takes it, and the others are
+	// written to Out.
+	Routes []RouteConfig `yaml:"routes"`
+}
+
+// RouteConfig sends the type sets that a pattern of types matches, or those
+// of a named TypeSetConfig, to a file of their own, like
+//
+//	routes:
+//	- types: pb.*
+//	  out: gen/proto/list_gen.go
+//	  pkg: proto
+type RouteConfig struct {
+	// Types is a pattern, like path.Match takes, that matches a type set if
+	// it matches one of its specific types, without the title, like
+	// pb.User, or the import path given with one, like example.com/proto/*.
+	Types string `yaml:"types"`
+	// TypeSet is the name of the TypeSetConfig whose type sets are routed,
+	// instead of Types.
+	TypeSet string `yaml:"typeset"`
+	// Out is the file the type sets are written to.
+	Out string `yaml:"out"`
+	// Pkg is the package name of the code written to Out, instead of
+	// GenerateConfig.Pkg, which a file in another directory usually needs.
+	Pkg string `yaml:"pkg"`
+}
+
+// matches tells whether the route takes a type set of the named type set
+// config.
+func (r RouteConfig) matches(name string, typeSet map[string]string) bool {
+	if r.TypeSet != "" {
+		return r.TypeSet == name
+	}
+	for _, specific := range typeSet {
+		arg := parseSpecificArg(specific)
+		if ok, _ := path.Match(r.Types, arg.Type); ok {
+			return true
+		}
+		if ok, _ := path.Match(r.Types, arg.ImportPath); ok && arg.ImportPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// VariantConfig is a pair of type sets generated for a build tag and
+// without it, into files of their own. The files are named after the out
+// file, with _<tag> and _no<tag> added, like sum_gen_purego.go and
+// sum_gen_nopurego.go.
+type VariantConfig struct {
+	// Tag is the build tag.
+	Tag string `yaml:"tag"`
+	// Types are added to the types that are generated with the tag,
+	// written like the argument of genny gen.
+	Types string `yaml:"types"`
+	// Otherwise are added to the types that are generated without the tag.
+	Otherwise string `yaml:"otherwise"`
+}
+
+// TypeSetConfig is a type set with settings of its own.
+type TypeSetConfig struct {
+	// Name names the type set for the routes.
+	Name string `yaml:"name"`
+	// Types are written like the argument of genny gen.
+	Types string `yaml:"types"`
+	// Build is a build constraint expression, like "386 || arm", that the
+	// code generated for the types is restricted to.
+	Build string `yaml:"build"`
+	// Out is the file the code is written to, instead of
+	// GenerateConfig.Out. Type sets written to the same file must have the
+	// same build constraint.
+	Out string `yaml:"out"`
+}
+
+// ConversionConfig is a pair of type sets to convert between. Unlike those
+// of TypeSets, the types are not added to GenerateConfig.Types, which are
+// usually all of the types converted between.
+type ConversionConfig struct {
+	// From and To are single type sets, written like the argument of
+	// genny gen.
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Out is the file the conversion functions are written to. It must not
+	// be one of the files the type sets are generated into.
+	Out string `yaml:"out"`
+}
+
+// ConfigOutput is a file to generate, with the type sets that are
+// generated into it.
+type ConfigOutput struct {
+	Out string
+	// Pkg is the package name of the code, if it is not that of the
+	// templates.
+	Pkg             string
+	BuildConstraint string
+	TypeSets        []map[string]string
+}
+
+// ReadConfig reads a config from YAML, or from TOML if the filename ends
+// with .toml, like genny.toml, whose tables and keys are those of the YAML.
+// The filename is used in errors.
+func ReadConfig(filename string, r io.Reader) (*Config, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	isTOML := strings.HasSuffix(filename, ".toml")
+	if isTOML {
+		tables, err := readTOML(string(src))
+		if err != nil {
+			err.(*errBadConfig).Filename = filename
+			return nil, err
+		}
+		// the tables are checked like the YAML they would be
+		if src, err = yaml.Marshal(tables); err != nil {
+			return nil, &errBadConfig{Filename: filename, Message: err.Error()}
+		}
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict(src, &config); err != nil {
+		message := err.Error()
+		if isTOML {
+			// the lines are those of the YAML
+			message = yamlLine.ReplaceAllString(message, "")
+		}
+		return nil, &errBadConfig{Filename: filename, Message: message}
+	}
+	for i, gen := range config.Generate {
+		if len(gen.In) == 0 {
+			return nil, &errBadConfig{Filename: filename, Message: "generate " + strconv.Itoa(i+1) + " has no templates in 'in'"}
+		}
+	}
+	return &config, nil
+}
+
+// yamlLine matches the line that an error of the YAML package is at.
+var yamlLine = regexp.MustCompile(`line \d+: `)
+
+// TypeImports gets the import paths of Imports that the types of the type
+// sets need, sorted.
+func (c Config) TypeImports(typeSets []map[string]string) []string {
+	paths := make(map[string]bool)
+	for _, typeSet := range typeSets {
+		for _, specific := range typeSet {
+			typ := parseSpecificArg(specific).Type
+			for name, path := range c.Imports {
+				if !paths[path] && usesTypeName(typ, name) {
+					paths[path] = true
+				}
+			}
+		}
+	}
+	var imports []string
+	for path := range paths {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// usesTypeName tells whether a type expression, like []*time.Time, refers
+// to a type name, like time.Time.
+func usesTypeName(typ, name string) bool {
+	for i := 0; i+len(name) <= len(typ); i++ {
+		end := i + len(name)
+		if typ[i:end] != name {
+			continue
+		}
+		before := i == 0 || typ[i-1] != '.' && !isAlphaNumeric(rune(typ[i-1]))
+		after := end == len(typ) || !isAlphaNumeric(rune(typ[end]))
+		if before && after {
+			return true
+		}
+	}
+	return false
+}
+
+// Outputs gets the files that the templates are generated into, with the
+// type sets of each of them. maxInstantiations limits the type sets like
+// in TypeSetLimit.
+func (c GenerateConfig) Outputs(maxInstantiations int) ([]ConfigOutput, error) {
+	typeSetConfigs := c.TypeSets
+	if len(typeSetConfigs) == 0 {
+		typeSetConfigs = []TypeSetConfig{{}}
+	}
+	if len(c.Variants) > 0 {
+		var err error
+		typeSetConfigs, err = c.variantTypeSets(typeSetConfigs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkRoutes(typeSetConfigs); err != nil {
+		return nil, err
+	}
+
+	var outputs []ConfigOutput
+	index := make(map[string]int)
+	for _, ts := range typeSetConfigs {
+		typeSets, err := TypeSetLimit(c.Types+" "+ts.Types, maxInstantiations)
+		if err != nil {
+			return nil, err
+		}
+		for _, typeSet := range typeSets {
+			out, pkg := ts.Out, c.Pkg
+			if out == "" {
+				out = c.Out
+			}
+			for _, route := range c.Routes {
+				if route.matches(ts.Name, typeSet) {
+					out = route.Out
+					if route.Pkg != "" {
+						pkg = route.Pkg
+					}
+					break
+				}
+			}
+			if out == "" {
+				return nil, &errBadConfig{Message: "no 'out' file for the types '" + ts.Types + "' of " + strings.Join(c.In, ", ")}
+			}
+
+			i, ok := index[out]
+			if !ok {
+				i = len(outputs)
+				index[out] = i
+				outputs = append(outputs, ConfigOutput{Out: out, Pkg: pkg, BuildConstraint: ts.Build})
+			} else if outputs[i].BuildConstraint != ts.Build {
+				return nil, &errBadConfig{Message: "the type sets written to '" + out + "' have different build constraints"}
+			} else if outputs[i].Pkg != pkg {
+				return nil, &errBadConfig{Message: "the type sets written to '" + out + "' are in different packages"}
+			}
+			outputs[i].TypeSets = append(outputs[i].TypeSets, typeSet)
+		}
+	}
+	return outputs, nil
+}
+
+// checkRoutes checks that every route has an out file and either a valid
+// pattern of types or the name of one of the type set configs.
+func (c GenerateConfig) checkRoutes(typeSetConfigs []TypeSetConfig) error {
+	for i, route := range c.Routes {
+		what := "route " + strconv.Itoa(i+1) + " of " + strings.Join(c.In, ", ")
+		if route.Out == "" {
+			return &errBadConfig{Message: what + " has no 'out' file"}
+		}
+		if (route.Types == "") == (route.TypeSet == "") {
+			return &errBadConfig{Message: what + " needs either 'types' or 'typeset'"}
+		}
+		if route.Types != "" {
+			if _, err := path.Match(route.Types, ""); err != nil {
+				return &errBadConfig{Message: what + " has the bad pattern '" + route.Types + "': " + err.Error()}
+			}
+			continue
+		}
+		named := false
+		for _, ts := range typeSetConfigs {
+			named = named || ts.Name == route.TypeSet
+		}
+		if !named {
+			return &errBadConfig{Message: what + " routes the type set '" + route.TypeSet + "', but no type set has that name"}
+		}
+	}
+	return nil
+}
+
+// variantTypeSets gets the type sets of both variants of every variant of
+// the type sets.
+func (c GenerateConfig) variantTypeSets(typeSetConfigs []TypeSetConfig) ([]TypeSetConfig, error) {
+	var variants []TypeSetConfig
+	for _, ts := range typeSetConfigs {
+		out := ts.Out
+		if out == "" {
+			out = c.Out
+		}
+		for _, v := range c.Variants {
+			if !reIdentifier.MatchString(v.Tag) {
+				return nil, &errBadConfig{Message: "the variant tag '" + v.Tag + "' of " + strings.Join(c.In, ", ") + " is not a build tag"}
+			}
+			tagged := TypeSetConfig{Name: ts.Name, Types: ts.Types + " " + v.Types, Build: andConstraint(ts.Build, v.Tag)}
+			untagged := TypeSetConfig{Name: ts.Name, Types: ts.Types + " " + v.Otherwise, Build: andConstraint(ts.Build, "!"+v.Tag)}
+			// without an out file, Outputs reports it
+			if out != "" {
+				base := strings.TrimSuffix(out, ".go")
+				tagged.Out, untagged.Out = base+"_"+v.Tag+".go", base+"_no"+v.Tag+".go"
+			}
+			variants = append(variants, tagged, untagged)
+		}
+	}
+	return variants, nil
+}
+
+// andConstraint gets the build constraint expression of both expressions,
+// either of which may be empty.
+func andConstraint(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return "(" + a + ") && " + b
+}
+
+// ConversionOutput is a file of conversion functions to generate, with the
+// conversions that are generated into it.
+type ConversionOutput struct {
+	Out         string
+	Conversions []Conversion
+}
+
+// ConversionOutputs gets the files that the conversions are generated
+// into, with the conversions of each of them.
+func (c GenerateConfig) ConversionOutputs() ([]ConversionOutput, error) {
+	var outputs []ConversionOutput
+	index := make(map[string]int)
+	for _, cc := range c.Conversions {
+		if cc.Out == "" {
+			return nil, &errBadConfig{Message: "no 'out' file for the conversion from '" + cc.From + "' to '" + cc.To + "' of " + strings.Join(c.In, ", ")}
+		}
+		from, err := c.conversionTypeSet(cc.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := c.conversionTypeSet(cc.To)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[cc.Out]
+		if !ok {
+			i = len(outputs)
+			index[cc.Out] = i
+			outputs = append(outputs, ConversionOutput{Out: cc.Out})
+		}
+		outputs[i].Conversions = append(outputs[i].Conversions, Conversion{From: from, To: to})
+	}
+	return outputs, nil
+}
+
+// conversionTypeSet parses a side of a conversion, which must be a single
+// type set.
+func (c GenerateConfig) conversionTypeSet(types string) (map[string]string, error) {
+	typeSets, err := TypeSet(types)
+	if err != nil {
+		return nil, err
+	}
+	if len(typeSets) != 1 {
+		return nil, &errBadConfig{Message: "the conversion types '" + types + "' of " + strings.Join(c.In, ", ") + " are " + strconv.Itoa(len(typeSets)) + " type sets instead of one"}
+	}
+	return typeSets[0], nil
+}
+
+// GenericsConfig generates the code that the config describes, like genny
+// gen does with -config, with the paths of its templates and outputs
+// relative to dir. It gets the files of the outputs and the conversions of
+// every entry, named by their paths in the config. The options apply to
+// every entry, with the values, renames and imports of the config, and the
+// package names and build constraints of its outputs unless opts gives a
+// package name; the renames of opts win over those of the config.
+func GenericsConfig(config *Config, dir string, opts Options) ([]OutputFile, error) {
+	renames := make(map[string]string)
+	for from, to := range config.Renames {
+		renames[from] = to
+	}
+	for from, to := range opts.Renames {
+		renames[from] = to
+	}
+	generateOpts := opts
+	generateOpts.Renames = renames
+	if generateOpts.Values == nil {
+		generateOpts.Values = config.Values
+	}
+	importPaths := func(typeSets []map[string]string) []string {
+		seen := make(map[string]bool)
+		var all []string
+		for _, path := range append(append([]string(nil), opts.ImportPaths...), config.TypeImports(typeSets)...) {
+			if !seen[path] {
+				seen[path] = true
+				all = append(all, path)
+			}
+		}
+		return all
+	}
+
+	var files []OutputFile
+	for _, generate := range config.Generate {
+		outputs, err := generate.Outputs(opts.Limits.MaxInstantiations)
+		if err != nil {
+			return nil, err
+		}
+		conversions, err := generate.ConversionOutputs()
+		if err != nil {
+			return nil, err
+		}
+		var templates []Template
+		for _, filename := range generate.In {
+			filename = filepath.Join(dir, filename)
+			src, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, &errSource{Err: err}
+			}
+			templates = append(templates, Template{Filename: filename, Source: bytes.NewReader(src)})
+		}
+		for _, output := range outputs {
+			if opts.PkgName == "" {
+				generateOpts.PkgName = output.Pkg
+			}
+			generateOpts.BuildConstraint = output.BuildConstraint
+			generateOpts.ImportPaths = importPaths(output.TypeSets)
+			code, err := GenericsTemplates(templates, output.TypeSets, generateOpts)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, OutputFile{Filename: output.Out, Code: code})
+		}
+		generateOpts.BuildConstraint = ""
+		if opts.PkgName == "" {
+			generateOpts.PkgName = generate.Pkg
+		}
+		for _, output := range conversions {
+			var typeSets []map[string]string
+			for _, conversion := range output.Conversions {
+				typeSets = append(typeSets, conversion.From, conversion.To)
+			}
+			generateOpts.ImportPaths = importPaths(typeSets)
+			code, err := GenericsConversions(templates, output.Conversions, generateOpts)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, OutputFile{Filename: output.Out, Code: code})
+		}
+	}
+	return files, nil
+}
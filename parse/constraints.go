@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"go/build/constraint"
+	"strings"
+)
+
+// isConstraintLine gets whether the line is a //go:build or // +build line.
+func isConstraintLine(line string) bool {
+	return constraint.IsGoBuild(line) || constraint.IsPlusBuild(line)
+}
+
+// templateConstraint gets the build constraint in front of the package
+// clause of the template, with the given tags removed. It returns nil if the
+// template is not constrained by anything but those tags.
+func templateConstraint(src []byte, stripTags []string) (constraint.Expr, error) {
+	var goBuild constraint.Expr
+	var plusBuild []constraint.Expr
+	var finder packageClauseFinder
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// the lines of block comments are not constraints, even if they
+		// look like ones or like the package clause
+		inBlock := finder.inBlock
+		if finder.find(line) >= 0 {
+			break
+		}
+		if inBlock || !isConstraintLine(line) {
+			continue
+		}
+		expr, err := constraint.Parse(line)
+		if err != nil {
+			return nil, &errSource{Err: err}
+		}
+		if constraint.IsGoBuild(line) {
+			goBuild = expr
+		} else {
+			plusBuild = append(plusBuild, expr)
+		}
+	}
+
+	// //go:build takes precedence, multiple // +build lines are ANDed
+	expr := goBuild
+	if expr == nil {
+		for _, x := range plusBuild {
+			expr = andExpr(expr, x)
+		}
+	}
+	if expr == nil {
+		return nil, nil
+	}
+	expr, value := withoutTags(expr, stripTags)
+	if expr == nil && !value {
+		// the template can never be built together with the stripped tags
+		expr = &constraint.TagExpr{Tag: "ignore"}
+	}
+	return expr, nil
+}
+
+// withoutTags removes the given tags from expr by assuming they are set. The
+// returned bool is the value of the expression if it became constant, in
+// which case the returned expression is nil.
+func withoutTags(expr constraint.Expr, tags []string) (constraint.Expr, bool) {
+	switch x := expr.(type) {
+	case *constraint.TagExpr:
+		for _, tag := range tags {
+			if x.Tag == tag {
+				return nil, true
+			}
+		}
+		return x, false
+	case *constraint.NotExpr:
+		inner, value := withoutTags(x.X, tags)
+		if inner == nil {
+			return nil, !value
+		}
+		return &constraint.NotExpr{X: inner}, false
+	case *constraint.AndExpr:
+		left, leftValue := withoutTags(x.X, tags)
+		right, rightValue := withoutTags(x.Y, tags)
+		switch {
+		case left == nil && !leftValue, right == nil && !rightValue:
+			return nil, false
+		case left == nil:
+			return right, rightValue
+		case right == nil:
+			return left, leftValue
+		}
+		return &constraint.AndExpr{X: left, Y: right}, false
+	case *constraint.OrExpr:
+		left, leftValue := withoutTags(x.X, tags)
+		right, rightValue := withoutTags(x.Y, tags)
+		switch {
+		case left == nil && leftValue, right == nil && rightValue:
+			return nil, true
+		case left == nil:
+			return right, rightValue
+		case right == nil:
+			return left, leftValue
+		}
+		return &constraint.OrExpr{X: left, Y: right}, false
+	}
+	return expr, false
+}
+
+func andExpr(x, y constraint.Expr) constraint.Expr {
+	if x == nil {
+		return y
+	}
+	return &constraint.AndExpr{X: x, Y: y}
+}
+
+// stripConstraintLine removes the given tags from a //go:build or
+// // +build line. It returns the lines to write in place of the line, which
+// are none if the line only consisted of those tags.
+func stripConstraintLine(line string, tags []string) []string {
+	expr, err := constraint.Parse(line)
+	if err != nil {
+		return []string{line}
+	}
+	stripped, value := withoutTags(expr, tags)
+	switch {
+	case stripped == nil && value:
+		return nil
+	case stripped == nil, stripped.String() == expr.String():
+		return []string{line}
+	case constraint.IsGoBuild(line):
+		return []string{"//go:build " + stripped.String()}
+	}
+	lines, err := constraint.PlusBuildLines(stripped)
+	if err != nil {
+		return []string{line}
+	}
+	return lines
+}
@@ -0,0 +1,166 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// Conversion is a pair of type sets to generate conversion functions
+// between, from the code generated for From to the code generated for To.
+type Conversion struct {
+	From map[string]string
+	To   map[string]string
+}
+
+// convertibleType is a type of a template that conversion functions can
+// be generated for: a slice or a map whose elements have a generic type.
+type convertibleType struct {
+	name string
+	// key is the key type of a map, and empty for a slice
+	key  string
+	elem string
+}
+
+// GenericsConversions generates a file with functions that convert between
+// the code generated from the templates for the type sets of each
+// conversion. A function is generated for every slice and map type of the
+// templates whose elements have a generic type, converting the elements
+// with a function of its own:
+//
+//	func IntListToStringList(from IntList, f func(int) string) StringList
+//
+// Map types whose keys differ between the type sets are skipped, as two
+// keys could be converted into one.
+func GenericsConversions(templates []Template, conversions []Conversion, opts Options) ([]byte, error) {
+	var typeSets []map[string]string
+	for _, c := range conversions {
+		typeSets = append(typeSets, c.From, c.To)
+	}
+	opts.Stats = nil
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	var body bytes.Buffer
+	specs := g.importSpecs
+	seen := make(map[string]bool)
+	for i, template := range g.templates {
+		src := g.sources[i]
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, template.Filename, src, 0)
+		if err != nil {
+			return nil, &errSource{Err: err}
+		}
+		if i == 0 {
+			name = file.Name.Name
+		}
+		specs = append(specs, fileImports(file)...)
+		for _, t := range convertibleTypes(fset, file, src) {
+			for j := 0; j < len(g.typeSets); j += 2 {
+				writeConversion(&body, t, g.typeSets[j], g.typeSets[j+1], seen)
+			}
+		}
+	}
+	if opts.PkgName != "" {
+		name = opts.PkgName
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString(makeLine("package " + name))
+	buf.WriteString(makeLine(""))
+	for _, line := range importDecl(specs, opts.LocalPrefixes) {
+		buf.WriteString(line)
+	}
+	buf.Write(body.Bytes())
+	return formatOutput(templates[0].Filename, renameSymbols(buf.Bytes(), opts.Renames))
+}
+
+// writeConversion writes the function that converts a type from one type
+// set to another, unless it would convert nothing or was already written.
+func writeConversion(buf *bytes.Buffer, t convertibleType, from, to map[string]string, seen map[string]bool) {
+	fromName, toName := subIntoType(t.name, from), subIntoType(t.name, to)
+	fromElem, toElem := subIntoType(t.elem, from), subIntoType(t.elem, to)
+	if fromName == toName || fromElem == toElem || subIntoType(t.key, from) != subIntoType(t.key, to) {
+		return
+	}
+	funcName := fromName + "To" + toName
+	if seen[funcName] {
+		return
+	}
+	seen[funcName] = true
+
+	fmt.Fprintf(buf, "\n// %s converts the elements of from with f.\n", funcName)
+	fmt.Fprintf(buf, "func %s(from %s, f func(%s) %s) %s {\n", funcName, fromName, fromElem, toElem, toName)
+	buf.WriteString("\tif from == nil {\n\t\treturn nil\n\t}\n")
+	fmt.Fprintf(buf, "\tto := make(%s, len(from))\n", toName)
+	if t.key == "" {
+		buf.WriteString("\tfor i, e := range from {\n\t\tto[i] = f(e)\n\t}\n")
+	} else {
+		buf.WriteString("\tfor k, v := range from {\n\t\tto[k] = f(v)\n\t}\n")
+	}
+	buf.WriteString("\treturn to\n}\n")
+}
+
+// convertibleTypes gets the slice and map types of a template.
+func convertibleTypes(fset *token.FileSet, file *ast.File, src []byte) []convertibleType {
+	text := func(node ast.Node) string {
+		return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+	}
+	var types []convertibleType
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			switch typ := ts.Type.(type) {
+			case *ast.ArrayType:
+				if typ.Len == nil {
+					types = append(types, convertibleType{name: ts.Name.Name, elem: text(typ.Elt)})
+				}
+			case *ast.MapType:
+				types = append(types, convertibleType{name: ts.Name.Name, key: text(typ.Key), elem: text(typ.Value)})
+			}
+		}
+	}
+	return types
+}
+
+// subIntoType substitutes the specific types of the type set into the
+// identifiers of a type expression, like *Elem or map[string]ElemList.
+func subIntoType(expr string, typeSet map[string]string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(expr))
+	var s scanner.Scanner
+	s.Init(file, []byte(expr), nil, 0)
+
+	var buf strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.IDENT {
+			continue
+		}
+		offset := file.Offset(pos)
+		buf.WriteString(expr[last:offset])
+		last = offset + len(lit)
+		for _, generic := range sortedKeys(typeSet) {
+			lit = subIntoLiteral(lit, generic, typeSet[generic])
+		}
+		buf.WriteString(lit)
+	}
+	buf.WriteString(expr[last:])
+	return buf.String()
+}
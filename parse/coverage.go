@@ -0,0 +1,168 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// coverBlock is a block of a coverage profile, as written by go test
+// -coverprofile:
+//
+//	file.go:startLine.startCol,endLine.endCol numStmt count
+type coverBlock struct {
+	file                string
+	startLine, startCol int
+	endLine, endCol     int
+	numStmt             int
+	count               int
+}
+
+func (b coverBlock) String() string {
+	return fmt.Sprintf("%s:%d.%d,%d.%d %d %d", b.file, b.startLine, b.startCol, b.endLine, b.endCol, b.numStmt, b.count)
+}
+
+// RemapCoverage rewrites the blocks of the coverage profile that are in the
+// generated files of the source maps onto the lines of the templates they
+// were generated from, and writes the profile to w. The blocks that the
+// type sets generated from the same template lines are merged, with the
+// columns of the first, as the columns differ with the length of the
+// specific types. The other blocks are kept as they are.
+//
+// The files of the profile are import paths, so a generated file is
+// matched by the longest end of its path, and its templates are written
+// relative to it.
+func RemapCoverage(w io.Writer, profile io.Reader, sourceMaps []SourceMap) error {
+	var out bytes.Buffer
+	mode := ""
+	var blocks []coverBlock
+	index := make(map[string]int)
+	sc := bufio.NewScanner(profile)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := sc.Text()
+		if lineNo == 1 {
+			if !strings.HasPrefix(line, "mode: ") {
+				return &errCoverProfile{Line: lineNo, Message: "expected a mode line"}
+			}
+			mode = strings.TrimPrefix(line, "mode: ")
+			out.WriteString(makeLine(line))
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		b, err := parseCoverBlock(line)
+		if err != nil {
+			return &errCoverProfile{Line: lineNo, Message: err.Error()}
+		}
+		key := fmt.Sprintf("%s:%d.%d,%d.%d", b.file, b.startLine, b.startCol, b.endLine, b.endCol)
+		if sourceMap := matchSourceMap(b.file, sourceMaps); sourceMap != nil {
+			var remapped bool
+			if b, remapped = remapBlock(b, *sourceMap); remapped {
+				key = fmt.Sprintf("%s:%d,%d %d", b.file, b.startLine, b.endLine, b.numStmt)
+			}
+		}
+		if i, ok := index[key]; ok {
+			if mode == "set" {
+				if b.count > blocks[i].count {
+					blocks[i].count = b.count
+				}
+			} else {
+				blocks[i].count += b.count
+			}
+			continue
+		}
+		index[key] = len(blocks)
+		blocks = append(blocks, b)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		out.WriteString(makeLine(b.String()))
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func parseCoverBlock(line string) (coverBlock, error) {
+	var b coverBlock
+	bad := fmt.Errorf("expected file:startLine.startCol,endLine.endCol numStmt count, got %q", line)
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return b, bad
+	}
+	b.file = line[:colon]
+	fields := strings.Fields(line[colon+1:])
+	if len(fields) != 3 {
+		return b, bad
+	}
+	positions := strings.FieldsFunc(fields[0], func(r rune) bool { return r == '.' || r == ',' })
+	numbers := append(positions, fields[1], fields[2])
+	if len(numbers) != 6 {
+		return b, bad
+	}
+	values := make([]int, len(numbers))
+	for i, s := range numbers {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return b, fmt.Errorf("bad number %q", s)
+		}
+		values[i] = v
+	}
+	b.startLine, b.startCol, b.endLine, b.endCol, b.numStmt, b.count = values[0], values[1], values[2], values[3], values[4], values[5]
+	return b, nil
+}
+
+// matchSourceMap gets the source map of the generated file, which is the
+// one whose file path has the longest end in common with it.
+func matchSourceMap(file string, sourceMaps []SourceMap) *SourceMap {
+	var match *SourceMap
+	longest := 0
+	for i, sourceMap := range sourceMaps {
+		n := commonSuffix(file, path.Clean(filepath.ToSlash(sourceMap.File)))
+		if n > longest {
+			match, longest = &sourceMaps[i], n
+		}
+	}
+	return match
+}
+
+// commonSuffix gets the number of path elements that the ends of the paths
+// have in common.
+func commonSuffix(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] && bs[len(bs)-1-n] != ".." {
+		n++
+	}
+	return n
+}
+
+// remapBlock moves a block of a generated file onto the template lines of
+// the declaration it is in, if it is in one.
+func remapBlock(b coverBlock, sourceMap SourceMap) (coverBlock, bool) {
+	for _, decl := range sourceMap.Declarations {
+		if b.startLine < decl.Line || b.startLine > decl.EndLine {
+			continue
+		}
+		rel := filepath.ToSlash(decl.Template)
+		if r, err := filepath.Rel(filepath.Dir(sourceMap.File), decl.Template); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+		b.file = path.Join(path.Dir(b.file), rel)
+		if decl.EndLine-decl.Line == decl.TemplateEndLine-decl.TemplateLine {
+			b.startLine += decl.TemplateLine - decl.Line
+			b.endLine += decl.TemplateLine - decl.Line
+		} else {
+			b.startLine, b.endLine = decl.TemplateLine, decl.TemplateEndLine
+		}
+		return b, true
+	}
+	return b, false
+}
@@ -0,0 +1,53 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The intermediate artifacts written to Options.DebugDir, so that a bug can
+// be put down to the phase that introduced it:
+//
+//	typesets.txt           the type sets, by index
+//	substitute/T_name_S.go the code of template T for type set S, before
+//	                       it is merged
+//	imports.txt            the imports collected from all of the code
+//	merged.go              the merged code, before goimports
+const (
+	debugTypeSets   = "typesets.txt"
+	debugSubstitute = "substitute"
+	debugImports    = "imports.txt"
+	debugMerged     = "merged.go"
+)
+
+// debugWrite writes an intermediate artifact to the debug directory, if
+// there is one.
+func (opts Options) debugWrite(name string, data []byte) error {
+	if opts.DebugDir == "" {
+		return nil
+	}
+	path := filepath.Join(opts.DebugDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// debugTypeSetList writes the type sets as they were given, by index.
+func (opts Options) debugTypeSetList(typeSets []map[string]string) error {
+	var buf bytes.Buffer
+	for i, typeSet := range typeSets {
+		fmt.Fprintf(&buf, "%d %s\n", i, typeSetClause(typeSet))
+	}
+	return opts.debugWrite(debugTypeSets, buf.Bytes())
+}
+
+// debugSubstituted writes the code of a template for a type set.
+func (opts Options) debugSubstituted(g *generation, file generatedFile) error {
+	name := strings.TrimSuffix(filepath.Base(g.templates[file.template].Filename), ".go")
+	return opts.debugWrite(filepath.Join(debugSubstitute, fmt.Sprintf("%d_%s_%d.go", file.template, name, file.typeSet)), file.code)
+}
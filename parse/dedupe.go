@@ -0,0 +1,122 @@
+package parse
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// declDeduper removes the declarations that were already generated, with
+// the same code, for an earlier type set. This happens when type sets share
+// the specific type of a generic that a declaration depends on, e.g. the
+// StringSet of KeyType=string ValueType=int and KeyType=string
+// ValueType=bool. Declarations with a common name but different code are an
+// error.
+type declDeduper struct {
+	// typeSets are the type sets of the files given to dedupe
+	typeSets []map[string]string
+	// existing are the declarations that the package has already, which
+	// are removed too
+	existing map[string]bool
+	// only a hash of the code is kept, so that generated code does not
+	// have to stay in memory
+	seen map[string]seenDecl
+}
+
+type seenDecl struct {
+	hash [sha1.Size]byte
+	// typeSet is the clause of the type set that generated it, which can
+	// be one of an earlier batch
+	typeSet string
+}
+
+func newDeclDeduper(typeSets []map[string]string) *declDeduper {
+	return &declDeduper{typeSets: typeSets, seen: make(map[string]seenDecl)}
+}
+
+// dedupe removes the declarations of file that an earlier file generated.
+func (d *declDeduper) dedupe(file generatedFile) (generatedFile, error) {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, "", file.code, parser.ParseComments)
+	if err != nil {
+		// leave it to imports.Process to report the syntax error
+		return file, nil
+	}
+	offset := func(pos token.Pos) int {
+		return fs.Position(pos).Offset
+	}
+
+	var code []byte
+	start := 0
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		kind, name := declKind(decl)
+		if name == "" || name == "_" || name == "init" {
+			continue
+		}
+		declStart, declEnd := offset(decl.Pos()), offset(decl.End())
+		if doc := declDoc(decl); doc != nil {
+			declStart = offset(doc.Pos())
+		}
+		// the rest of the line goes along, which is a semicolon in the
+		// code of the legacy implementation
+		if eol := bytes.IndexByte(file.code[declEnd:], '\n'); eol >= 0 {
+			declEnd += eol + 1
+		} else {
+			declEnd = len(file.code)
+		}
+		hash := sha1.Sum(file.code[declStart:declEnd])
+
+		// the methods of an existing type are left to it too
+		if d.existing[name] || kind == 3 && d.existing[strings.SplitN(name, ".", 2)[0]] {
+			code = append(code, file.code[start:declStart]...)
+			start = declEnd
+			continue
+		}
+		key := strconv.Itoa(kind) + " " + name
+		prev, ok := d.seen[key]
+		if !ok {
+			d.seen[key] = seenDecl{hash: hash, typeSet: typeSetClause(d.typeSets[file.typeSet])}
+			continue
+		}
+		if prev.hash != hash {
+			return file, &errConflictingDecl{
+				Name:     name,
+				TypeSet:  prev.typeSet,
+				Conflict: typeSetClause(d.typeSets[file.typeSet]),
+			}
+		}
+		code = append(code, file.code[start:declStart]...)
+		start = declEnd
+	}
+	if start > 0 {
+		file.code = append(code, file.code[start:]...)
+	}
+	return file, nil
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+// typeSetClause gets the type set in the form it is given on the command
+// line, e.g. KeyType=string ValueType=int.
+func typeSetClause(typeSet map[string]string) string {
+	clauses := make([]string, 0, len(typeSet))
+	for _, generic := range sortedKeys(typeSet) {
+		clauses = append(clauses, generic+"="+quoteSpecific(typeSet[generic]))
+	}
+	return strings.Join(clauses, " ")
+}
@@ -0,0 +1,114 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// defaultsDirective is a comment that declares the type sets a template is
+// generated for when it is given no types, written like the gen types, like
+//
+//	// genny:defaults Elem=int,string,float64
+//
+// The type sets of every directive of the template are generated, so a
+// template can declare type sets that aren't all combinations of the types.
+const defaultsDirective = "genny:defaults"
+
+// defaultDirective is a comment that declares the specific type of a
+// generic type that the type sets may leave out, written like a generic
+// type with a single specific type of the gen types, like
+//
+//	// genny:default Hasher=fnvHasher
+//	type Hasher generic.Type
+const defaultDirective = "genny:default"
+
+// DefaultTypeSets gets the type sets that the genny:defaults directives of
+// the template declare, or none if it has no directive. It fails if there
+// would be more than maxTypeSets type sets, unless maxTypeSets is zero.
+func DefaultTypeSets(filename string, src []byte, maxTypeSets int) ([]map[string]string, error) {
+	if !bytes.Contains(src, []byte(defaultsDirective)) {
+		return nil, nil
+	}
+	var typeSets []map[string]string
+	for _, line := range strings.Split(string(normalizeEOL(src)), "\n") {
+		arg, ok := directiveArg(line, defaultsDirective)
+		if !ok {
+			continue
+		}
+		sets, err := TypeSetLimit(arg, maxTypeSets)
+		if err != nil {
+			return nil, &errDefaults{Filename: filename, Directive: defaultsDirective, Err: err}
+		}
+		typeSets = append(typeSets, sets...)
+		if maxTypeSets > 0 && len(typeSets) > maxTypeSets {
+			return nil, &errLimit{What: "the genny:defaults of '" + filename + "' make at least " + strconv.Itoa(len(typeSets)) + " type sets", Max: maxTypeSets}
+		}
+	}
+	return typeSets, nil
+}
+
+// defaultTypes gets the specific types that the genny:default directives
+// of the template declare for its generic types.
+func defaultTypes(filename string, src []byte) (map[string]string, error) {
+	if !bytes.Contains(src, []byte(defaultDirective)) {
+		return nil, nil
+	}
+	types := make(map[string]string)
+	for _, line := range strings.Split(string(src), "\n") {
+		arg, ok := directiveArg(line, defaultDirective)
+		if !ok {
+			continue
+		}
+		typeArgs, err := parseTypeArgs(arg)
+		if err == nil && (len(typeArgs) != 1 || len(typeArgs[0].Specifics) != 1) {
+			err = errors.New("expected a single generic type with a single specific type, like Elem=int")
+		}
+		if err != nil {
+			return nil, &errDefaults{Filename: filename, Directive: defaultDirective, Err: err}
+		}
+		if _, ok := types[typeArgs[0].Generic]; ok {
+			return nil, &errDefaults{Filename: filename, Directive: defaultDirective, Err: errors.New("'" + typeArgs[0].Generic + "' has a default already")}
+		}
+		types[typeArgs[0].Generic] = typeArgs[0].Specifics[0]
+	}
+	return types, nil
+}
+
+// withDefaultTypes adds the default types to the type sets that leave their
+// generic types out.
+func withDefaultTypes(typeSets []map[string]string, defaults map[string]string) []map[string]string {
+	if len(defaults) == 0 {
+		return typeSets
+	}
+	result := make([]map[string]string, 0, len(typeSets))
+	for _, typeSet := range typeSets {
+		withDefaults := make(map[string]string, len(typeSet)+len(defaults))
+		for generic, specific := range defaults {
+			withDefaults[generic] = specific
+		}
+		for generic, specific := range typeSet {
+			withDefaults[generic] = specific
+		}
+		result = append(result, withDefaults)
+	}
+	return result
+}
+
+// dropDefaults drops the genny:defaults and genny:default directives from
+// a template, as they aren't code to generate.
+func dropDefaults(src []byte) []byte {
+	if !bytes.Contains(src, []byte(defaultDirective)) {
+		return src
+	}
+	var kept bytes.Buffer
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		_, isDefaults := directiveArg(line, defaultsDirective)
+		_, isDefault := directiveArg(line, defaultDirective)
+		if !isDefaults && !isDefault {
+			kept.WriteString(line)
+		}
+	}
+	return kept.Bytes()
+}
@@ -0,0 +1,681 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// degenerifyConstraints are the placeholder types of the constraints of the
+// packages of constraints, by import path.
+var degenerifyConstraints = map[string]map[string]string{
+	"golang.org/x/exp/constraints": {"Ordered": "Ordered", "Integer": "Integer", "Unsigned": "Unsigned", "Float": "Float"},
+	"cmp":                          {"Ordered": "Ordered"},
+}
+
+// typeParamOwner is a top-level type or function of code with type
+// parameters that has them.
+type typeParamOwner struct {
+	name *ast.Ident
+	list *ast.FieldList
+	// params are the names of its type parameters, and generics the
+	// generic types they become.
+	params   []string
+	generics []string
+	// newName is its name in the template, with the generic types in it.
+	newName string
+}
+
+// Degenerify converts Go code written with the type parameters of Go 1.18
+// into a template, whose copies genny gen generates for the Go versions
+// before them. The type parameters become the generic types of the
+// template, with the placeholder type of their constraint: generic.Type for
+// any and comparable, and generic.Number, Ordered, Integer, Unsigned and
+// Float for the constraints of their built-in types, which are declared in
+// the code or come from golang.org/x/exp/constraints or cmp. The types and
+// functions with type parameters are named with the generic types, in front
+// if they are exported, after New if they are constructors, and after the
+// name if they are unexported, so List[T any] becomes TList, NewList[T any]
+// NewTList and newList[T any] newListT. renames give the type parameters other names as
+// generic types, like T=Elem, which they need when genny would substitute
+// the specific types into other identifiers of the code too, as it would
+// with T into ToUpper. generate is a //go:generate line that the template
+// gets after its imports, or none if it is empty. The Go versions of the
+// build constraint of the code, like go1.18, are dropped from the template,
+// and so is the header of code that genny generated.
+//
+// A type parameter has the same placeholder type everywhere it is used, and
+// the types and functions with type parameters can only be used with their
+// own, or in declarations that have those, since a template names the copy
+// of them that it uses after its generic types.
+func Degenerify(filename string, src []byte, renames map[string]string, generate string) ([]byte, error) {
+	src = normalizeEOL(src)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	tf := fset.File(file.Pos())
+	offset := tf.Offset
+	fail := func(pos token.Pos, message string) error {
+		return &errDegenerify{Filename: filename, Line: fset.Position(pos).Line, Message: message}
+	}
+	text := func(node ast.Node) string {
+		return string(src[offset(node.Pos()):offset(node.End())])
+	}
+
+	imported := make(map[string]string)
+	for _, s := range file.Imports {
+		spec := importSpec{Path: strings.Trim(s.Path.Value, "`\"")}
+		if s.Name != nil {
+			spec.Name = s.Name.Name
+		}
+		imported[spec.localName()] = spec.Path
+	}
+	interfaces := make(map[string]*ast.TypeSpec)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+			for _, spec := range gen.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.TypeParams == nil {
+					if _, ok := ts.Type.(*ast.InterfaceType); ok {
+						interfaces[ts.Name.Name] = ts
+					}
+				}
+			}
+		}
+	}
+	visiting := make(map[string]bool)
+	var placeholderOf func(expr ast.Expr) string
+	placeholderOf = func(expr ast.Expr) string {
+		switch t := expr.(type) {
+		case *ast.Ident:
+			if t.Name == "any" || t.Name == "comparable" {
+				return "Type"
+			}
+			if ts, ok := interfaces[t.Name]; ok && !visiting[t.Name] {
+				visiting[t.Name] = true
+				defer delete(visiting, t.Name)
+				return placeholderOf(ts.Type)
+			}
+		case *ast.SelectorExpr:
+			if x, ok := t.X.(*ast.Ident); ok {
+				return degenerifyConstraints[imported[x.Name]][t.Sel.Name]
+			}
+		case *ast.InterfaceType:
+			if len(t.Methods.List) == 0 {
+				return "Type"
+			}
+			if len(t.Methods.List) != 1 || len(t.Methods.List[0].Names) > 0 {
+				return ""
+			}
+			elem := t.Methods.List[0].Type
+			if _, ok := elem.(*ast.Ident); ok {
+				return placeholderOf(elem)
+			}
+			if terms, ok := unionTerms(elem); ok {
+				for _, c := range typeParamConstraints {
+					if sameTerms(terms, c.types) {
+						return c.placeholder
+					}
+				}
+			}
+		}
+		return ""
+	}
+
+	// the types and functions with type parameters, and the generic types
+	// of these, in the order of the code
+	owners := make(map[string]*typeParamOwner)
+	var ordered []*typeParamOwner
+	var generics []string
+	placeholders := make(map[string]string)
+	firstOwner := make(map[string]*typeParamOwner)
+	paramFields := make(map[*ast.Field]bool)
+	lists := make(map[*ast.FieldList]bool)
+	constraintRefs := make(map[*ast.Ident]bool)
+	addOwner := func(name *ast.Ident, list *ast.FieldList) error {
+		o := &typeParamOwner{name: name, list: list}
+		lists[list] = true
+		for _, field := range list.List {
+			paramFields[field] = true
+			if ident, ok := field.Type.(*ast.Ident); ok {
+				constraintRefs[ident] = true
+			}
+			placeholder := placeholderOf(field.Type)
+			if placeholder == "" {
+				return fail(field.Type.Pos(), "the constraint "+text(field.Type)+" of "+name.Name+" has no placeholder type in the generic package, which has them for any, comparable and the unions of the built-in types of generic.Number, Ordered, Integer, Unsigned and Float")
+			}
+			for _, param := range field.Names {
+				if param.Name == "_" {
+					return fail(param.Pos(), "the type parameters of "+name.Name+" need names to be generic types")
+				}
+				generic := param.Name
+				if renamed, ok := renames[generic]; ok {
+					generic = renamed
+				}
+				if p, ok := placeholders[generic]; !ok {
+					placeholders[generic] = placeholder
+					firstOwner[generic] = o
+					generics = append(generics, generic)
+				} else if p != placeholder {
+					return fail(param.Pos(), "the type parameter "+param.Name+" of "+name.Name+" is generic."+placeholder+", but that of "+firstOwner[generic].name.Name+" is generic."+p+", and a generic type has one placeholder type")
+				}
+				o.params = append(o.params, param.Name)
+				o.generics = append(o.generics, generic)
+			}
+		}
+		owners[name.Name] = o
+		ordered = append(ordered, o)
+		return nil
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Type.TypeParams != nil {
+				if err := addOwner(d.Name, d.Type.TypeParams); err != nil {
+					return nil, err
+				}
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.TypeParams != nil {
+					if err := addOwner(ts.Name, ts.TypeParams); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return nil, fail(file.Name.Pos(), "it has no type parameters")
+	}
+	for _, generic := range generics {
+		pos := firstOwner[generic].name.Pos()
+		if !isIdentifier(generic) || token.Lookup(generic).IsKeyword() || !isExported(generic) {
+			return nil, fail(pos, "the generic type "+generic+" of "+firstOwner[generic].name.Name+" must be an identifier that starts with an upper case letter, to be a word of the names that have it, which a rename can give it")
+		}
+		if file.Scope.Lookup(generic) != nil {
+			return nil, fail(pos, "the generic type "+generic+" of "+firstOwner[generic].name.Name+" has the name of a declaration of the code, which a rename can change")
+		}
+	}
+	taken := make(map[string]string)
+	for _, o := range ordered {
+		if name := o.name.Name; strings.HasPrefix(name, "New") && isWordStart(name[len("New"):]) {
+			o.newName = "New" + strings.Join(o.generics, "") + name[len("New"):]
+		} else if isExported(name) {
+			o.newName = strings.Join(o.generics, "") + name
+		} else {
+			o.newName = name + strings.Join(o.generics, "")
+		}
+	}
+	for _, o := range ordered {
+		if file.Scope.Lookup(o.newName) != nil || placeholders[o.newName] != "" || taken[o.newName] != "" {
+			return nil, fail(o.name.Pos(), o.name.Name+" would be named "+o.newName+" in the template, which is taken")
+		}
+		taken[o.newName] = o.name.Name
+	}
+
+	// the identifiers that aren't references, like fields, methods and
+	// selectors
+	notRefs := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.SelectorExpr:
+			notRefs[n.Sel] = true
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok {
+				notRefs[key] = true
+			}
+		case *ast.StructType:
+			for _, field := range n.Fields.List {
+				for _, name := range field.Names {
+					notRefs[name] = true
+				}
+			}
+		case *ast.InterfaceType:
+			for _, field := range n.Methods.List {
+				for _, name := range field.Names {
+					notRefs[name] = true
+				}
+			}
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				notRefs[n.Name] = true
+			}
+		case *ast.LabeledStmt:
+			notRefs[n.Label] = true
+		case *ast.BranchStmt:
+			if n.Label != nil {
+				notRefs[n.Label] = true
+			}
+		}
+		return true
+	})
+	notRefs[file.Name] = true
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			notRefs[spec.Name] = true
+		}
+	}
+	refersOwner := func(ident *ast.Ident) (*typeParamOwner, bool) {
+		o, ok := owners[ident.Name]
+		if !ok || notRefs[ident] || ident.Obj != nil && ident.Obj != file.Scope.Lookup(ident.Name) {
+			return nil, false
+		}
+		return o, true
+	}
+
+	var edits []textEdit
+	remove := func(doc *ast.CommentGroup, node ast.Node) {
+		pos := node.Pos()
+		if doc != nil {
+			pos = doc.Pos()
+		}
+		edits = append(edits, textEdit{start: offset(tf.LineStart(tf.Line(pos))), end: offset(node.End())})
+	}
+	for _, o := range ordered {
+		edits = append(edits, textEdit{start: offset(o.list.Opening), end: offset(o.list.Closing) + 1})
+	}
+
+	// the constraints that have placeholder types are dropped, unless the
+	// code uses them for more than constraints
+	refs := make(map[string]int)
+	constraints := make(map[string]int)
+	droppedSpecs := make(map[ast.Spec]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Ident); ok && interfaces[ident.Name] != nil && ident != interfaces[ident.Name].Name && ident.Obj == file.Scope.Lookup(ident.Name) {
+			refs[ident.Name]++
+			if constraintRefs[ident] {
+				constraints[ident.Name]++
+			}
+		}
+		return true
+	})
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		var dropped []*ast.TypeSpec
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if name := ts.Name.Name; interfaces[name] == ts && constraints[name] > 0 && constraints[name] == refs[name] && placeholderOf(ts.Name) != "" {
+				dropped = append(dropped, ts)
+				droppedSpecs[ts] = true
+			}
+		}
+		if len(dropped) > 0 && len(dropped) == len(gen.Specs) {
+			remove(gen.Doc, gen)
+		} else {
+			for _, ts := range dropped {
+				remove(ts.Doc, ts)
+			}
+		}
+	}
+
+	// the references to the types and functions with type parameters are
+	// named with the generic types, and so are the type parameters, in the
+	// declarations that have them
+	convert := func(node ast.Node, name string, have []string, params map[string]bool) error {
+		inDecl := make(map[string]bool)
+		for _, generic := range have {
+			inDecl[generic] = true
+		}
+		isParam := func(ident *ast.Ident) bool {
+			if ident.Obj != nil {
+				field, ok := ident.Obj.Decl.(*ast.Field)
+				return ok && paramFields[field]
+			}
+			return params[ident.Name] && !notRefs[ident]
+		}
+		// genny substitutes the specific types into every identifier that
+		// has a generic type in it, which only those named with their own
+		// may
+		check := func(ident *ast.Ident, final string, own []string) error {
+			for _, generic := range generics {
+				if final == generic || contains(own, generic) {
+					continue
+				}
+				if subIntoLiteral(final, generic, "int") != final {
+					return fail(ident.Pos(), "genny would substitute the specific types of the generic type "+generic+" into "+final+" too, which a longer name for "+generic+", given with a rename, keeps apart")
+				}
+			}
+			return nil
+		}
+		uses := func(pos token.Pos, what string, o *typeParamOwner) error {
+			for _, generic := range o.generics {
+				if !inDecl[generic] {
+					return fail(pos, name+" uses "+what+" without having the type parameters "+strings.Join(o.params, ", ")+" of it, which a template can only give it from those of "+name)
+				}
+			}
+			return nil
+		}
+		var err error
+		ast.Inspect(node, func(node ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			var x ast.Expr
+			var indices []ast.Expr
+			switch n := node.(type) {
+			case *ast.FieldList:
+				// the type parameters are removed
+				return !lists[n]
+			case *ast.IndexExpr:
+				x, indices = n.X, []ast.Expr{n.Index}
+			case *ast.IndexListExpr:
+				x, indices = n.X, n.Indices
+			case *ast.Ident:
+				if o, ok := refersOwner(n); ok {
+					if err = uses(n.Pos(), n.Name, o); err == nil {
+						edits = append(edits, textEdit{start: offset(n.Pos()), end: offset(n.End()), text: o.newName})
+						err = check(n, o.newName, o.generics)
+					}
+				} else if isParam(n) {
+					if renamed, ok := renames[n.Name]; ok {
+						edits = append(edits, textEdit{start: offset(n.Pos()), end: offset(n.End()), text: renamed})
+					}
+				} else if !notRefs[n] || n.Obj == nil {
+					err = check(n, n.Name, nil)
+				}
+				return true
+			default:
+				return true
+			}
+			ident, ok := x.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			o, ok := refersOwner(ident)
+			if !ok {
+				return true
+			}
+			var got []string
+			for i, index := range indices {
+				param, ok := index.(*ast.Ident)
+				if !ok || !isParam(param) {
+					got = nil
+					break
+				}
+				generic := param.Name
+				if renamed, ok := renames[generic]; ok {
+					generic = renamed
+				}
+				if generic == "_" && i < len(o.generics) {
+					generic = o.generics[i]
+				}
+				got = append(got, generic)
+			}
+			if strings.Join(got, ",") != strings.Join(o.generics, ",") {
+				err = fail(node.Pos(), text(node)+" gives "+ident.Name+" other types than its type parameters "+strings.Join(o.params, ", ")+", which a template can't, as it names the copy of "+ident.Name+" that it uses after its generic types")
+				return false
+			}
+			if err = uses(node.Pos(), text(node), o); err == nil {
+				edits = append(edits, textEdit{start: offset(node.Pos()), end: offset(node.End()), text: o.newName})
+			}
+			return false
+		})
+		return err
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			var have []string
+			var params map[string]bool
+			name := d.Name.Name
+			if o, ok := owners[name]; ok && d.Recv == nil {
+				have = o.generics
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := d.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				var indices []ast.Expr
+				switch r := recv.(type) {
+				case *ast.IndexExpr:
+					recv, indices = r.X, []ast.Expr{r.Index}
+				case *ast.IndexListExpr:
+					recv, indices = r.X, r.Indices
+				}
+				if ident, ok := recv.(*ast.Ident); ok && owners[ident.Name] != nil {
+					o := owners[ident.Name]
+					name = ident.Name + "." + name
+					params = make(map[string]bool)
+					for i, index := range indices {
+						param, ok := index.(*ast.Ident)
+						if !ok || len(indices) != len(o.params) || param.Name != o.params[i] && param.Name != "_" {
+							return nil, fail(index.Pos(), "the receiver of "+name+" names the type parameters of "+ident.Name+" other than "+strings.Join(o.params, ", ")+", as "+ident.Name+" does, which a template can't")
+						}
+						params[param.Name] = true
+					}
+					have = o.generics
+				}
+			}
+			if err := convert(d, name, have, params); err != nil {
+				return nil, err
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				if droppedSpecs[spec] {
+					continue
+				}
+				var have []string
+				name := "the declaration"
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					name = s.Name.Name
+					if o, ok := owners[name]; ok {
+						have = o.generics
+					}
+				case *ast.ValueSpec:
+					name = s.Names[0].Name
+				}
+				if err := convert(spec, name, have, nil); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// the template and its copies are for every Go version, so the Go
+	// versions of the build constraint, like go1.18, are dropped
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if comment.Pos() > file.Package || !isConstraintLine(comment.Text) {
+				continue
+			}
+			tags := goVersionTags(comment.Text)
+			if len(tags) == 0 {
+				continue
+			}
+			start, end := offset(comment.Pos()), offset(comment.End())
+			lines := stripConstraintLine(comment.Text, tags)
+			if len(lines) == 0 && end < len(src) && src[end] == '\n' {
+				end++
+			}
+			edits = append(edits, textEdit{start: start, end: end, text: strings.Join(lines, "\n")})
+		}
+	}
+
+	// code that genny generated, like with -mode=typeparams, becomes a
+	// template that isn't, so its header is dropped
+	headerLines := strings.Split(strings.TrimSpace(header), "\n")
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if comment.Pos() > file.Package || !contains(headerLines, comment.Text) {
+				continue
+			}
+			start, end := offset(comment.Pos()), offset(comment.End())
+			if end < len(src) && src[end] == '\n' {
+				end++
+			}
+			edits = append(edits, textEdit{start: start, end: end})
+		}
+	}
+
+	var bodyStart = offset(file.Name.End())
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			bodyStart = offset(gen.End())
+		}
+	}
+	newNames := make(map[string]string)
+	for name, o := range owners {
+		newNames[name] = o.newName
+	}
+	for param, generic := range renames {
+		if _, ok := placeholders[generic]; ok {
+			newNames[param] = generic
+		}
+	}
+	if len(newNames) > 0 {
+		var names []string
+		for name := range newNames {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+		words := regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if offset(comment.Pos()) < bodyStart || strings.HasPrefix(comment.Text, "//go:") {
+					continue
+				}
+				renamed := words.ReplaceAllStringFunc(comment.Text, func(name string) string { return newNames[name] })
+				if renamed != comment.Text {
+					edits = append(edits, textEdit{start: offset(comment.Pos()), end: offset(comment.End()), text: renamed})
+				}
+			}
+		}
+	}
+
+	// the generic package is imported and the generic types declared after
+	// the imports
+	genericSpec := strconv.Quote(genericImport)
+	var first *ast.GenDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			first = gen
+			break
+		}
+	}
+	switch {
+	case first == nil:
+		edits = append(edits, textEdit{start: bodyStart, end: bodyStart, text: "\n\nimport " + genericSpec})
+	case first.Lparen.IsValid():
+		edits = append(edits, textEdit{start: offset(first.Rparen), end: offset(first.Rparen), text: "\t" + genericSpec + "\n"})
+	default:
+		spec := first.Specs[0]
+		edits = append(edits, textEdit{start: offset(spec.Pos()), end: offset(spec.End()), text: "(\n\t" + text(spec) + "\n\t" + genericSpec + "\n)"})
+	}
+	var decls strings.Builder
+	decls.WriteString("\n")
+	if generate != "" {
+		decls.WriteString("\n" + generate + "\n")
+	}
+	for _, generic := range generics {
+		decls.WriteString("\n// " + generic + " is a generic type, replaced with the specific types of genny gen.\n")
+		decls.WriteString("type " + generic + " generic." + placeholders[generic] + "\n")
+	}
+	edits = append(edits, textEdit{start: bodyStart, end: bodyStart, text: decls.String()})
+
+	// the edits within the type parameters and constraints that are removed
+	// are dropped
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	var buf bytes.Buffer
+	at := 0
+	for _, edit := range edits {
+		if edit.start < at {
+			continue
+		}
+		buf.Write(src[at:edit.start])
+		buf.WriteString(edit.text)
+		at = edit.end
+	}
+	buf.Write(src[at:])
+	return formatOutput(filename, buf.Bytes())
+}
+
+// reGoVersion matches the build tags of Go versions, like go1.18.
+var reGoVersion = regexp.MustCompile(`^go\d+\.\d+$`)
+
+// goVersionTags gets the tags of Go versions of a build constraint line.
+func goVersionTags(line string) []string {
+	expr, err := constraint.Parse(line)
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	expr.Eval(func(tag string) bool {
+		if reGoVersion.MatchString(tag) {
+			tags = append(tags, tag)
+		}
+		return true
+	})
+	return tags
+}
+
+// unionTerms gets the types of a union of a constraint, like ~int | ~int8,
+// if they are all identifiers.
+func unionTerms(expr ast.Expr) ([]string, bool) {
+	switch t := expr.(type) {
+	case *ast.BinaryExpr:
+		if t.Op != token.OR {
+			return nil, false
+		}
+		x, ok := unionTerms(t.X)
+		if !ok {
+			return nil, false
+		}
+		y, ok := unionTerms(t.Y)
+		return append(x, y...), ok
+	case *ast.UnaryExpr:
+		if t.Op == token.TILDE {
+			return unionTerms(t.X)
+		}
+	case *ast.Ident:
+		return []string{t.Name}, true
+	}
+	return nil, false
+}
+
+// sameTerms tells whether the terms of a union are the types.
+func sameTerms(terms, types []string) bool {
+	if len(terms) != len(types) {
+		return false
+	}
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		seen[term] = true
+	}
+	for _, t := range types {
+		if !seen[t] {
+			return false
+		}
+	}
+	return len(seen) == len(types)
+}
+
+// contains tells whether the list has s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
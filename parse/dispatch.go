@@ -0,0 +1,251 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// dispatchFunc is a function of a template that a dispatcher can be
+// generated for: its name has one generic type, and one of its parameters,
+// the value switched on, has exactly that type.
+type dispatchFunc struct {
+	name    string
+	generic string
+	// params are the parameters with their names, the value is params[value]
+	params   []dispatchParam
+	value    int
+	variadic bool
+	results  string
+}
+
+type dispatchParam struct {
+	name, typ string
+}
+
+// GenericsDispatch generates a file with a dispatcher for every function of
+// the templates that takes a value of a generic type and has it in its
+// name. The dispatcher takes the value as argType instead, which is
+// interface{} if it is empty, and calls the function generated for its
+// type:
+//
+//	func Fprint(w io.Writer, e interface{}) (int, error) {
+//		switch e := e.(type) {
+//		case int:
+//			return FprintInt(w, e)
+//		case string:
+//			return FprintString(w, e)
+//		default:
+//			panic(fmt.Sprintf("Fprint: no instantiation for %T", e))
+//		}
+//	}
+//
+// The dispatcher is named after the function without the generic type, and
+// panics for values of other types. argType may be a marker interface that
+// the specific types implement.
+func GenericsDispatch(templates []Template, typeSets []map[string]string, opts Options, argType string) ([]byte, error) {
+	if argType == "" {
+		argType = "interface{}"
+	}
+	opts.Stats = nil
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	genericSet := make(map[string]string)
+	for _, typeSet := range typeSets {
+		for generic := range typeSet {
+			genericSet[generic] = ""
+		}
+	}
+
+	var name string
+	var body bytes.Buffer
+	specs := append([]importSpec{{Path: "fmt"}}, g.importSpecs...)
+	for i, template := range g.templates {
+		src := g.sources[i]
+		pkgName, imports, funcs, err := templateDispatchFuncs(template.Filename, src, genericSet)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			name = pkgName
+		}
+		specs = append(specs, imports...)
+		for _, f := range funcs {
+			writeDispatch(&body, f, argType, g.typeSets)
+		}
+	}
+	if opts.PkgName != "" {
+		name = opts.PkgName
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString(makeLine("package " + name))
+	buf.WriteString(makeLine(""))
+	for _, line := range importDecl(specs, opts.LocalPrefixes) {
+		buf.WriteString(line)
+	}
+	buf.Write(body.Bytes())
+	return formatOutput(templates[0].Filename, renameSymbols(buf.Bytes(), opts.Renames))
+}
+
+// writeDispatch writes the dispatcher of a function.
+func writeDispatch(buf *bytes.Buffer, f dispatchFunc, argType string, typeSets []map[string]string) {
+	dispatcher := strings.Replace(f.name, f.generic, "", -1)
+	value := f.params[f.value].name
+
+	var params, args []string
+	for i, p := range f.params {
+		typ, arg := p.typ, p.name
+		if i == f.value {
+			typ = argType
+		}
+		if f.variadic && i == len(f.params)-1 {
+			typ, arg = "..."+typ, arg+"..."
+		}
+		params = append(params, p.name+" "+typ)
+		args = append(args, arg)
+	}
+
+	fmt.Fprintf(buf, "\n// %s calls the %s generated for the type of %s.\n", dispatcher, f.name, value)
+	fmt.Fprintf(buf, "func %s(%s) %s {\n", dispatcher, strings.Join(params, ", "), f.results)
+	fmt.Fprintf(buf, "\tswitch %s := %s.(type) {\n", value, value)
+	seen := make(map[string]bool)
+	for _, typeSet := range typeSets {
+		specific := parseSpecificArg(typeSet[f.generic]).Type
+		if seen[specific] {
+			continue
+		}
+		seen[specific] = true
+		name := subIntoLiteral(f.name, f.generic, typeSet[f.generic])
+		fmt.Fprintf(buf, "\tcase %s:\n", specific)
+		if f.results != "" {
+			fmt.Fprintf(buf, "\t\treturn %s(%s)\n", name, strings.Join(args, ", "))
+		} else {
+			fmt.Fprintf(buf, "\t\t%s(%s)\n", name, strings.Join(args, ", "))
+		}
+	}
+	buf.WriteString("\tdefault:\n")
+	fmt.Fprintf(buf, "\t\tpanic(fmt.Sprintf(%s, %s))\n", strconv.Quote(dispatcher+": no instantiation for %T"), value)
+	buf.WriteString("\t}\n}\n")
+}
+
+// templateDispatchFuncs gets the package name, the imports and the
+// functions of a template that dispatchers can be generated for.
+func templateDispatchFuncs(filename string, src []byte, genericSet map[string]string) (string, []importSpec, []dispatchFunc, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return "", nil, nil, &errSource{Err: err}
+	}
+	text := func(node ast.Node) string {
+		return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+	}
+
+	var funcs []dispatchFunc
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+			continue
+		}
+		if f, ok := dispatchable(fn, genericSet, text); ok {
+			funcs = append(funcs, f)
+		}
+	}
+	return file.Name.Name, fileImports(file), funcs, nil
+}
+
+// fileImports gets the imports of a parsed template, to import them in code
+// that refers to its types.
+func fileImports(file *ast.File) []importSpec {
+	var imports []importSpec
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imp := importSpec{Path: path}
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+		imports = append(imports, imp)
+	}
+	return imports
+}
+
+// dispatchable gets the dispatchFunc of a function, if it is one.
+func dispatchable(fn *ast.FuncDecl, genericSet map[string]string, text func(ast.Node) string) (dispatchFunc, bool) {
+	f := dispatchFunc{name: fn.Name.Name, value: -1}
+	for generic := range genericSet {
+		if strings.Contains(f.name, generic) {
+			if f.generic != "" {
+				return f, false
+			}
+			f.generic = generic
+		}
+	}
+	if f.generic == "" || f.name == f.generic {
+		return f, false
+	}
+
+	for _, field := range fn.Type.Params.List {
+		typ := field.Type
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			f.variadic = true
+			typ = ellipsis.Elt
+		}
+		isValue := false
+		if ident, ok := typ.(*ast.Ident); ok && ident.Name == f.generic && !f.variadic {
+			isValue = true
+		} else if mentionsGeneric(typ, genericSet) {
+			return f, false
+		}
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{nil}
+		}
+		for _, name := range names {
+			p := dispatchParam{typ: text(typ)}
+			if name != nil && name.Name != "_" {
+				p.name = name.Name
+			} else {
+				p.name = "p" + strconv.Itoa(len(f.params))
+			}
+			if isValue {
+				if f.value >= 0 {
+					return f, false
+				}
+				f.value = len(f.params)
+			}
+			f.params = append(f.params, p)
+		}
+	}
+	if f.value < 0 {
+		return f, false
+	}
+	if fn.Type.Results != nil {
+		if mentionsGeneric(fn.Type.Results, genericSet) {
+			return f, false
+		}
+		f.results = text(fn.Type.Results)
+	}
+	return f, true
+}
+
+// mentionsGeneric tells whether a node refers to any of the generic types.
+func mentionsGeneric(node ast.Node, genericSet map[string]string) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if _, ok := genericSet[ident.Name]; ok {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -2,6 +2,8 @@ package parse
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 )
 
 // errMissingSpecificType represents an error when a generic type is not
@@ -45,3 +47,410 @@ func (e errBadTypeArgs) Error() string {
 }
 
 var errMissingTypeInformation = errors.New("No type arguments were specified and no \"// +gogen\" tag was found in the source.")
+
+var errNoTemplates = errors.New("No templates were given to generate the code from.")
+
+// errBadNamingPolicy represents an error when an unknown naming policy is
+// requested.
+type errBadNamingPolicy struct {
+	Name string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadNamingPolicy) Error() string {
+	return "Unknown naming policy '" + e.Name + "' (expected package, type or alias)"
+}
+
+// errMissingAlias represents an error when the alias naming policy is in
+// effect and a qualified specific type is given without a title.
+type errMissingAlias struct {
+	GenericType  string
+	SpecificType string
+}
+
+// Error gets a human readable string describing this error.
+func (e errMissingAlias) Error() string {
+	return "Specific type '" + e.SpecificType + "' for '" + e.GenericType + "' needs an alias, e.g. Title:" + e.SpecificType
+}
+
+// errNamer represents an error naming a specific type with Options.Namer.
+type errNamer struct {
+	GenericType  string
+	SpecificType string
+	Err          error
+}
+
+// Error gets a human readable string describing this error.
+func (e errNamer) Error() string {
+	return "Failed to name specific type '" + e.SpecificType + "' for '" + e.GenericType + "': " + e.Err.Error()
+}
+
+type errBadTitle struct {
+	Title string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadTitle) Error() string {
+	return "'" + e.Title + "' is not an identifier"
+}
+
+// errAmbiguousImport represents an error when a specific type is qualified
+// with a package name that several imports share.
+type errAmbiguousImport struct {
+	SpecificType string
+	Name         string
+}
+
+// Error gets a human readable string describing this error.
+func (e errAmbiguousImport) Error() string {
+	return "Package '" + e.Name + "' in specific type '" + e.SpecificType + "' is ambiguous; qualify the type with the full import path instead"
+}
+
+// errPackageMismatch represents an error when templates that are merged into
+// one file are in different packages.
+type errPackageMismatch struct {
+	Filename string
+	Package  string
+	Expected string
+}
+
+// Error gets a human readable string describing this error.
+func (e errPackageMismatch) Error() string {
+	return "Template '" + e.Filename + "' is in package '" + e.Package + "' instead of '" + e.Expected + "'; specify a package name to merge them"
+}
+
+// errBadDeclOrder represents an error when an unknown declaration order is
+// requested.
+type errBadDeclOrder struct {
+	Name string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadDeclOrder) Error() string {
+	return "Unknown declaration order '" + e.Name + "' (expected none, alpha or template)"
+}
+
+// errBadExportPolicy represents an error when an export policy can't be
+// parsed.
+type errBadExportPolicy struct {
+	Policy  string
+	Message string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadExportPolicy) Error() string {
+	return "Bad export policy '" + e.Policy + "': " + e.Message
+}
+
+// errNoTestedImport represents an error when code generated into an
+// external test package refers to the package under test, without its
+// import path.
+type errNoTestedImport struct {
+	Package string
+}
+
+// Error gets a human readable string describing this error.
+func (e errNoTestedImport) Error() string {
+	return "The code generated into package '" + e.Package + "_test' refers to package '" + e.Package + "', but its import path isn't known"
+}
+
+// errConflictingDecl represents an error when two type sets generate
+// different declarations with the same name.
+type errConflictingDecl struct {
+	Name     string
+	TypeSet  string
+	Conflict string
+}
+
+// Error gets a human readable string describing this error.
+func (e errConflictingDecl) Error() string {
+	return "Type sets '" + e.TypeSet + "' and '" + e.Conflict + "' both generate '" + e.Name + "' but with different code"
+}
+
+// errLimit represents an error when the input exceeds one of the Limits.
+type errLimit struct {
+	What string
+	Max  int
+}
+
+// Error gets a human readable string describing this error.
+func (e errLimit) Error() string {
+	return e.What + ", which is over the limit of " + strconv.Itoa(e.Max)
+}
+
+// errBadPlatform represents an error when an unknown platform is requested.
+type errBadPlatform struct {
+	Name string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadPlatform) Error() string {
+	return "Unknown platform '" + e.Name + "' (expected GOOS/GOARCH, GOOS or GOARCH)"
+}
+
+// errBadConstraint represents an error when a build constraint can't be
+// parsed.
+type errBadConstraint struct {
+	Constraint string
+	Err        error
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadConstraint) Error() string {
+	return "Bad build constraint '" + e.Constraint + "': " + e.Err.Error()
+}
+
+// errBadConfig represents an error in a config.
+type errBadConfig struct {
+	Filename string
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadConfig) Error() string {
+	if e.Filename == "" {
+		return "Bad config: " + e.Message
+	}
+	return "Bad config '" + e.Filename + "': " + e.Message
+}
+
+// errInclude represents an error with a genny:include directive.
+type errInclude struct {
+	Filename string
+	Include  string
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errInclude) Error() string {
+	return "Can't include '" + e.Include + "' in '" + e.Filename + "': " + e.Message
+}
+
+// errBase represents an error with a genny:base directive.
+type errBase struct {
+	Filename string
+	Base     string
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBase) Error() string {
+	return "Can't derive '" + e.Filename + "' from '" + e.Base + "': " + e.Message
+}
+
+// errDefaults represents an error with a genny:defaults or genny:default
+// directive.
+type errDefaults struct {
+	Filename  string
+	Directive string
+	Err       error
+}
+
+// Error gets a human readable string describing this error.
+func (e errDefaults) Error() string {
+	return "Bad " + e.Directive + " in '" + e.Filename + "': " + e.Err.Error()
+}
+
+// errBadRename represents an error when a rename isn't from and to an
+// identifier.
+type errBadRename struct {
+	From string
+	To   string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadRename) Error() string {
+	return "Can't rename '" + e.From + "' to '" + e.To + "': both must be identifiers"
+}
+
+// errPreprocess represents an error when running a template as a
+// text/template.
+type errPreprocess struct {
+	Filename string
+	Err      error
+}
+
+// Error gets a human readable string describing this error.
+func (e errPreprocess) Error() string {
+	return "Failed to preprocess '" + e.Filename + "': " + e.Err.Error()
+}
+
+type errTransform struct {
+	Stage    Stage
+	Filename string
+	Err      error
+}
+
+// Error gets a human readable string describing this error.
+func (e errTransform) Error() string {
+	return "Failed to transform '" + e.Filename + "' after the " + e.Stage.String() + " stage: " + e.Err.Error()
+}
+
+type errPlugin struct {
+	Name   string
+	Err    error
+	Stderr string
+}
+
+// Error gets a human readable string describing this error.
+func (e errPlugin) Error() string {
+	msg := "Plugin '" + e.Name + "' failed: " + e.Err.Error()
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+type errBadPlugin struct {
+	Arg string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadPlugin) Error() string {
+	return "\"" + e.Arg + "\" is not a plugin, expected name or name=parameter"
+}
+
+type errCoverProfile struct {
+	Line    int
+	Message string
+}
+
+// Error gets a human readable string describing this error.
+func (e errCoverProfile) Error() string {
+	return "Bad coverage profile at line " + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+type errTypeSetLine struct {
+	Line int
+	Err  error
+}
+
+// Error gets a human readable string describing this error.
+func (e errTypeSetLine) Error() string {
+	return "Bad type sets at line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+type errPlaceholder struct {
+	Filename    string
+	Line        int
+	Name        string
+	Placeholder string
+}
+
+// Error gets a human readable string describing this error.
+func (e errPlaceholder) Error() string {
+	return "The " + e.Placeholder + " '" + e.Name + "' at " + e.Filename + ":" + strconv.Itoa(e.Line) + " is not named after a generic type"
+}
+
+type errNoFunc struct {
+	Placeholder  string
+	GenericType  string
+	SpecificType string
+}
+
+// Error gets a human readable string describing this error.
+func (e errNoFunc) Error() string {
+	return "The specific type '" + e.SpecificType + "' of " + e.GenericType + " has no function for " + e.Placeholder + ", give it like " + e.GenericType + "=Title:Type:func"
+}
+
+// errWorkspace represents an error in a go.work file.
+type errWorkspace struct {
+	Filename string
+	Line     int
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errWorkspace) Error() string {
+	return "Bad workspace '" + e.Filename + "' at line " + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+// errLeftoverGeneric represents an error when the code generated from a
+// template still refers to the generic package or to a generic type.
+type errLeftoverGeneric struct {
+	Filename string
+	TypeSet  string
+	Names    []string
+	Lines    []string
+}
+
+// Error gets a human readable string describing this error.
+func (e errLeftoverGeneric) Error() string {
+	return "The code generated from '" + e.Filename + "' for '" + e.TypeSet + "' still refers to " + strings.Join(e.Names, ", ") + ", left over by the substitution, in:\n\t" + strings.Join(e.Lines, "\n\t")
+}
+
+// errSharedGeneric represents an error with a generic type that a template
+// shares with the other files of its package.
+type errSharedGeneric struct {
+	Filename string
+	Generic  string
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errSharedGeneric) Error() string {
+	return "The generic type '" + e.Generic + "' of '" + e.Filename + "' is shared, but " + e.Message
+}
+
+// errNotComparable represents an error when a generic type that a template
+// uses in a map key is given a specific type that isn't comparable.
+type errNotComparable struct {
+	Filename string
+	Line     int
+	Generic  string
+	Specific string
+	Why      string
+}
+
+// Error gets a human readable string describing this error.
+func (e errNotComparable) Error() string {
+	return "The specific type '" + e.Specific + "' of '" + e.Generic + "' is " + e.Why + ", which isn't comparable, but " + e.Filename + ":" + strconv.Itoa(e.Line) + " uses '" + e.Generic + "' in a map key"
+}
+
+// errBadPlacement represents an error when the pkg= and out= arguments of
+// genny gen don't place a type set argument.
+type errBadPlacement struct {
+	Arg     string
+	Message string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadPlacement) Error() string {
+	return "Bad placement '" + e.Arg + "': " + e.Message
+}
+
+// errBadMode represents an error when an unknown mode is requested.
+type errBadMode struct {
+	Name string
+}
+
+// Error gets a human readable string describing this error.
+func (e errBadMode) Error() string {
+	return "Unknown mode '" + e.Name + "' (expected copies or typeparams)"
+}
+
+// errTypeParams represents an error when a template can't be converted to
+// type parameters.
+type errTypeParams struct {
+	Filename string
+	Line     int
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errTypeParams) Error() string {
+	return "Can't convert '" + e.Filename + ":" + strconv.Itoa(e.Line) + "' to type parameters: " + e.Message
+}
+
+// errDegenerify represents an error when code with type parameters can't
+// be converted to a template.
+type errDegenerify struct {
+	Filename string
+	Line     int
+	Message  string
+}
+
+// Error gets a human readable string describing this error.
+func (e errDegenerify) Error() string {
+	return "Can't convert '" + e.Filename + ":" + strconv.Itoa(e.Line) + "' to a template: " + e.Message
+}
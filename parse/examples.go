@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// GenericsExamples generates a test file from an example template for
+// every type set, so that the generated types get runnable examples in
+// their documentation. The generic types are replaced like in any
+// template, so func ExampleElemStack_Push becomes ExampleIntStack_Push.
+// The name of each file is the name of the template with the generic types
+// replaced, like for GenericsAsm, so example_elem_test.go becomes
+// example_int_test.go.
+func GenericsExamples(template Template, typeSets []map[string]string, opts Options) ([]OutputFile, error) {
+	if err := opts.Limits.checkInstantiations(len(typeSets)); err != nil {
+		return nil, err
+	}
+	namedSets, err := namedTypeSets(typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []OutputFile
+	for i, typeSet := range typeSets {
+		code, err := GenericsTemplates([]Template{template}, []map[string]string{typeSet}, opts)
+		if err != nil {
+			return nil, err
+		}
+		filename := typeSetFilename(filepath.Base(template.Filename), namedSets[i])
+		if !strings.HasSuffix(filename, "_test.go") {
+			filename = strings.TrimSuffix(filename, ".go") + "_test.go"
+		}
+		files = append(files, OutputFile{Filename: filename, Code: code})
+	}
+	return files, nil
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// gennyGenerated marks the files that genny generated, which are left out
+// of the existing declarations, as they are generated again.
+var gennyGenerated = []byte(strings.SplitN(header, "\n", 2)[0])
+
+// IsGenerated tells whether genny generated the code.
+func IsGenerated(code []byte) bool {
+	return bytes.Contains(code, gennyGenerated)
+}
+
+// existingDecls gets the names of the top level declarations of the
+// package in dir, leaving out the test files, the files that aren't built
+// for the current platform and the files generated by genny. Methods are
+// named like Type.Method.
+func existingDecls(dir string) (map[string]bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, info := range files {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if IsGenerated(src) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			return nil, &errSource{Err: err}
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				_, name := declKind(d)
+				existing[name] = true
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						existing[s.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, name := range s.Names {
+							existing[name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	delete(existing, "_")
+	delete(existing, "init")
+	return existing, nil
+}
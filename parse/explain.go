@@ -0,0 +1,274 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// Explanation tells how an identifier of the generated code was named from
+// an identifier of a template, for when the names that genny gives are a
+// surprise.
+type Explanation struct {
+	// Name is the identifier of the generated code.
+	Name string
+	// Identifier is the identifier of the template that it was generated
+	// from, and Template and Line are where the template has it first. The
+	// line is 0 if it isn't known, as it is in an included template.
+	Identifier string
+	Template   string
+	Line       int
+	// TypeSet is the type set that it was generated for, like "Elem=int".
+	TypeSet string
+	// Steps are how the identifier became the name, in turn.
+	Steps []string
+}
+
+// Explain tells how the templates, generated for the type sets with opts,
+// come to have the identifier name in the generated code. It gets an
+// Explanation for every identifier of a template and type set that becomes
+// name, or none if none does.
+func Explain(templates []Template, typeSets []map[string]string, opts Options, name string) ([]Explanation, error) {
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+	var explanations []Explanation
+	for templateIndex, template := range g.templates {
+		idents, err := templateIdents(template, g.sources[templateIndex])
+		if err != nil {
+			return nil, err
+		}
+		for _, ident := range idents {
+			if len(g.typeSets) > 0 {
+				if _, ok := g.typeSets[0][ident.name]; ok {
+					// the generic types themselves aren't generated
+					continue
+				}
+			}
+			for typeSetIndex, typeSet := range g.typeSets {
+				steps, result := g.explainIdent(ident, typeSet, g.argTypeSets[typeSetIndex])
+				if result != name {
+					continue
+				}
+				explanations = append(explanations, Explanation{
+					Name:       name,
+					Identifier: ident.name,
+					Template:   ident.filename,
+					Line:       ident.line,
+					TypeSet:    typeSetClause(g.argTypeSets[typeSetIndex]),
+					Steps:      steps,
+				})
+				if ident.name == name {
+					// it is the same for every type set
+					break
+				}
+			}
+		}
+	}
+	return explanations, nil
+}
+
+// methodKind is the kind of the methods of templateIdent, which declKind
+// has as functions.
+const methodKind = 5
+
+// templateIdent is an identifier of a template, where it is first, and the
+// kind of the top-level declaration it names, if it does.
+type templateIdent struct {
+	name     string
+	filename string
+	line     int
+	// kind is that of declKind, or methodKind for a method, or -1 if it
+	// isn't a top-level declaration.
+	kind int
+}
+
+// templateIdents gets the identifiers of the resolved source of a template
+// that substitution may rename, in the order of the source, which leaves
+// out the package name, imports and the selectors of packages.
+func templateIdents(template Template, resolved []byte) ([]templateIdent, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, template.Filename, resolved, 0)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	// the lines are those of the template, unless it includes or derives
+	// from others, which move them; the declarations of the templates say
+	// where they are then
+	var decls []sourceDecl
+	lines := false
+	if src, err := readSource(template); err == nil {
+		src = normalizeEOL(src)
+		lines = !bytes.Contains(src, []byte(includeDirective)) && !bytes.Contains(src, []byte(baseDirective))
+		decls, _ = templateSourceDecls(template.Filename, src)
+	}
+
+	kinds := make(map[*ast.Ident]int)
+	for _, decl := range file.Decls {
+		kind, _ := declKind(decl)
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil {
+				kind = methodKind
+			}
+			kinds[d.Name] = kind
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kinds[s.Name] = kind
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						kinds[name] = kind
+					}
+				}
+			}
+		}
+	}
+	skip := map[*ast.Ident]bool{file.Name: true}
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			skip[spec.Name] = true
+		}
+	}
+
+	var idents []templateIdent
+	seen := make(map[string]int)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil && isPackageName(file, x.Name) {
+				return false
+			}
+		}
+		ident, ok := node.(*ast.Ident)
+		if !ok || skip[ident] || ident.Name == "_" {
+			return true
+		}
+		kind, declared := kinds[ident]
+		if !declared {
+			kind = -1
+		}
+		if i, ok := seen[ident.Name]; ok {
+			if declared && idents[i].kind < 0 {
+				idents[i].kind = kind
+			}
+			return true
+		}
+		seen[ident.Name] = len(idents)
+		t := templateIdent{name: ident.Name, filename: template.Filename, kind: kind}
+		if lines {
+			t.line = fset.Position(ident.Pos()).Line
+		}
+		idents = append(idents, t)
+		return true
+	})
+	for i, ident := range idents {
+		for _, decl := range decls {
+			if decl.name == ident.name || strings.HasSuffix(decl.name, "."+ident.name) {
+				idents[i].filename, idents[i].line = decl.filename, decl.line
+				break
+			}
+		}
+	}
+	return idents, nil
+}
+
+// isPackageName tells whether the file imports a package under the name.
+func isPackageName(file *ast.File, name string) bool {
+	for _, s := range file.Imports {
+		spec := importSpec{Path: strings.Trim(s.Path.Value, "`\"")}
+		if s.Name != nil {
+			spec.Name = s.Name.Name
+		}
+		if spec.localName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// explainIdent substitutes the type set into the identifier of a template
+// the way the generation does, and tells how, step by step.
+func (g *generation) explainIdent(ident templateIdent, typeSet, argTypeSet map[string]string) ([]string, string) {
+	var steps []string
+	name := ident.name
+	for _, generic := range sortedKeys(typeSet) {
+		specific := typeSet[generic]
+		next := subIntoLiteral(name, generic, specific)
+		if next == name {
+			continue
+		}
+		if name == generic {
+			steps = append(steps, generic+" is the type "+typify(specific)+", so it becomes "+next)
+		} else {
+			steps = append(steps, generic+" in "+name+" is "+typify(specific)+", which is named "+describeWord(generic, name, specific, argTypeSet[generic], g.opts)+", so "+name+" becomes "+next)
+		}
+		name = next
+	}
+	if len(steps) == 0 {
+		steps = append(steps, name+" has none of the generic types in it, so it is the same for every type set")
+	}
+
+	if ident.kind >= 0 {
+		policy := g.opts.Export
+		exportedness := []Exportedness{policy.Types, policy.Consts, policy.Vars, policy.Funcs, ExportKeep, policy.Methods}[ident.kind]
+		if exported := applyExportedness(name, exportedness); exported != name && name != "init" && name != "main" {
+			what := map[Exportedness]string{Exported: "exported", Unexported: "unexported"}[exportedness]
+			steps = append(steps, "the export policy has it "+what+", so "+name+" becomes "+exported)
+			name = exported
+		}
+	}
+	if renamed, ok := g.opts.Renames[name]; ok {
+		steps = append(steps, "the rename "+name+"="+renamed+" renames it to "+renamed)
+		name = renamed
+	}
+	return steps, name
+}
+
+// describeWord tells how the specific type of a generic type is turned
+// into the word of it that goes into the identifier, the way wordify does.
+func describeWord(generic, name, specific, arg string, opts Options) string {
+	exported := isExported(generic)
+	word := wordify(specific, exported)
+	if strings.HasPrefix(name, generic) && !isExported(name) {
+		word = wordify(specific, false)
+	}
+	var how string
+	if i := strings.Index(specific, titleSep); i >= 0 {
+		switch {
+		case parseSpecificArg(arg).Title != "":
+			how = "the title " + specific[:i] + " given with it"
+		case opts.Namer != nil:
+			how = "the title " + specific[:i] + " that the naming plugin gives it"
+		default:
+			how = "the title " + specific[:i] + " of the type naming policy, which is the name of the type without its package"
+		}
+	} else {
+		how = "the type"
+		var dropped []string
+		if strings.ContainsAny(specific, "*&") {
+			dropped = append(dropped, "the * and &")
+		}
+		if strings.HasSuffix(specific, "{}") {
+			dropped = append(dropped, "the {}")
+		}
+		if strings.Contains(specific, ".") {
+			dropped = append(dropped, "the dots, keeping the package as the package naming policy does")
+		}
+		if len(dropped) > 0 {
+			how += " without " + strings.Join(dropped, " and ")
+		}
+	}
+	switch {
+	case strings.HasPrefix(name, generic) && !isExported(name):
+		how += ", starting with a lower case letter as " + name + " is unexported"
+	case exported:
+		how += ", starting with an upper case letter as " + generic + " is exported"
+	default:
+		how += ", starting with a lower case letter as " + generic + " is unexported"
+	}
+	return word + " from " + how
+}
@@ -0,0 +1,145 @@
+package parse
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Exportedness controls whether a kind of generated declaration is
+// exported.
+type Exportedness int
+
+const (
+	// ExportKeep keeps the declarations exported or not as genny names
+	// them, after the case of the generic types in the template. This is
+	// the default.
+	ExportKeep Exportedness = iota
+	// Exported exports the declarations.
+	Exported
+	// Unexported unexports the declarations.
+	Unexported
+)
+
+var exportednesses = map[string]Exportedness{
+	"keep":       ExportKeep,
+	"exported":   Exported,
+	"unexported": Unexported,
+}
+
+// ExportPolicy controls which kinds of generated declarations are exported,
+// instead of the case of the names in the template, like exported types
+// with unexported helper functions.
+type ExportPolicy struct {
+	Types   Exportedness
+	Funcs   Exportedness
+	Methods Exportedness
+	Consts  Exportedness
+	Vars    Exportedness
+}
+
+// ParseExportPolicy returns the ExportPolicy written like
+// "types=exported,funcs=unexported". The kinds are types, funcs, methods,
+// consts and vars, and each is exported, unexported or keep. The kinds
+// left out are kept.
+func ParseExportPolicy(s string) (ExportPolicy, error) {
+	var policy ExportPolicy
+	if strings.TrimSpace(s) == "" {
+		return policy, nil
+	}
+	kinds := map[string]*Exportedness{
+		"types":   &policy.Types,
+		"funcs":   &policy.Funcs,
+		"methods": &policy.Methods,
+		"consts":  &policy.Consts,
+		"vars":    &policy.Vars,
+	}
+	for _, clause := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(clause), "=", 2)
+		kind, ok := kinds[parts[0]]
+		if !ok {
+			return ExportPolicy{}, &errBadExportPolicy{Policy: s, Message: "unknown kind '" + parts[0] + "' (expected types, funcs, methods, consts or vars)"}
+		}
+		if len(parts) != 2 {
+			return ExportPolicy{}, &errBadExportPolicy{Policy: s, Message: "expected " + parts[0] + "=exported, unexported or keep"}
+		}
+		exportedness, ok := exportednesses[parts[1]]
+		if !ok {
+			return ExportPolicy{}, &errBadExportPolicy{Policy: s, Message: "unknown exportedness '" + parts[1] + "' (expected exported, unexported or keep)"}
+		}
+		*kind = exportedness
+	}
+	return policy, nil
+}
+
+// symbolRenames gets the renames of the generated code: those that the
+// export policy makes of its declarations, and then renames.
+func symbolRenames(code []byte, policy ExportPolicy, renames map[string]string) map[string]string {
+	if policy == (ExportPolicy{}) {
+		return renames
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", code, 0)
+	if err != nil {
+		// leave it to imports.Process to report the syntax error
+		return renames
+	}
+	result := make(map[string]string)
+	add := func(name string, exportedness Exportedness) {
+		if name == "_" || name == "init" || name == "main" {
+			return
+		}
+		if renamed := applyExportedness(name, exportedness); renamed != name {
+			result[name] = renamed
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil {
+				add(d.Name.Name, policy.Methods)
+			} else {
+				add(d.Name.Name, policy.Funcs)
+			}
+		case *ast.GenDecl:
+			exportedness := map[token.Token]Exportedness{token.TYPE: policy.Types, token.CONST: policy.Consts, token.VAR: policy.Vars}[d.Tok]
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, exportedness)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						add(name.Name, exportedness)
+					}
+				}
+			}
+		}
+	}
+	for from, to := range renames {
+		result[from] = to
+	}
+	return result
+}
+
+// applyExportedness exports or unexports the name. The initials of an
+// unexported name are all lowercased, so JSONList becomes jsonList.
+func applyExportedness(name string, exportedness Exportedness) string {
+	r, size := utf8.DecodeRuneInString(name)
+	switch exportedness {
+	case Exported:
+		return string(unicode.ToUpper(r)) + name[size:]
+	case Unexported:
+		runes := []rune(name)
+		for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+			// the last initial starts the next word, as in JSONList
+			if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				break
+			}
+			runes[i] = unicode.ToLower(runes[i])
+		}
+		return string(runes)
+	}
+	return name
+}
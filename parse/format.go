@@ -0,0 +1,84 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+)
+
+// formatOutput formats the generated code and fixes its imports. Running
+// goimports is slow for large outputs, as it may have to look for packages,
+// so it is skipped when every package the code refers to is already
+// imported. Then removing the unused imports and gofmt are all it takes.
+func formatOutput(filename string, src []byte) ([]byte, error) {
+	if output, ok := formatImported(filename, src); ok {
+		return output, nil
+	}
+	output, err := imports.Process(filename, src, nil)
+	if err != nil {
+		return nil, &errImports{Err: err}
+	}
+	return output, nil
+}
+
+// formatImported formats src if all of the packages it refers to are
+// imported under known names. It returns false if goimports is needed.
+func formatImported(filename string, src []byte) ([]byte, bool) {
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, src, parser.ParseComments)
+	if err != nil {
+		// leave it to goimports to report the error
+		return nil, false
+	}
+
+	// the package names that qualify identifiers
+	qualifiers := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				qualifiers[x.Name] = true
+			}
+		}
+		return true
+	})
+
+	var unused []importSpec
+	imported := make(map[string]bool)
+	for _, s := range file.Imports {
+		spec := importSpec{Path: strings.Trim(s.Path.Value, "`\"")}
+		if s.Name != nil {
+			spec.Name = s.Name.Name
+		}
+		name := spec.localName()
+		switch {
+		case name == "_":
+			continue
+		case name == "." || strings.IndexFunc(name, func(r rune) bool { return !isAlphaNumeric(r) }) >= 0:
+			// the names the package brings in are unknown
+			return nil, false
+		case !qualifiers[name]:
+			unused = append(unused, spec)
+		}
+		imported[name] = true
+	}
+	for qualifier := range qualifiers {
+		if !imported[qualifier] {
+			return nil, false
+		}
+	}
+
+	for _, spec := range unused {
+		astutil.DeleteNamedImport(fs, file, spec.Name, spec.Path)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fs, file); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
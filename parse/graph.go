@@ -0,0 +1,126 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+)
+
+// Graph is a graph of what genny generates: the templates, the templates
+// they include or derive from, the type sets they are generated with and
+// the files the code is written to. It is written in the DOT language of
+// Graphviz.
+type Graph struct {
+	nodes []graphNode
+	edges []graphEdge
+	seen  map[string]bool
+}
+
+type graphNode struct {
+	id, label, shape string
+}
+
+type graphEdge struct {
+	from, to, label string
+}
+
+// NewGraph gets an empty graph.
+func NewGraph() *Graph {
+	return &Graph{seen: make(map[string]bool)}
+}
+
+// Add adds the generation of the templates with the type sets into the
+// file out. The templates are read to find the templates they include or
+// derive from.
+func (g *Graph) Add(templates []string, typeSets []map[string]string, out string) error {
+	for _, template := range templates {
+		if err := g.addTemplate(template); err != nil {
+			return err
+		}
+	}
+	file := "file:" + out
+	g.node(file, out, "box")
+	for _, typeSet := range typeSets {
+		clause := typeSetClause(typeSet)
+		id := "typeset:" + out + ":" + clause
+		g.node(id, clause, "ellipse")
+		for _, template := range templates {
+			g.edge("template:"+filepath.Clean(template), id, "")
+		}
+		g.edge(id, file, "")
+	}
+	return nil
+}
+
+// addTemplate adds a template and the templates it includes or derives
+// from, once.
+func (g *Graph) addTemplate(filename string) error {
+	filename = filepath.Clean(filename)
+	id := "template:" + filename
+	if g.seen[id] {
+		return nil
+	}
+	g.node(id, filename, "note")
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return &errSource{Err: err}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(normalizeEOL(src)))
+	for scanner.Scan() {
+		for _, directive := range []string{includeDirective, baseDirective} {
+			arg, ok := directiveArg(scanner.Text(), directive)
+			if !ok {
+				continue
+			}
+			path := filepath.Clean(templatePath(filename, arg))
+			if err := g.addTemplate(path); err != nil {
+				return err
+			}
+			label := "include"
+			if directive == baseDirective {
+				label = "base"
+			}
+			g.edge("template:"+path, id, label)
+		}
+	}
+	return nil
+}
+
+func (g *Graph) node(id, label, shape string) {
+	if !g.seen[id] {
+		g.seen[id] = true
+		g.nodes = append(g.nodes, graphNode{id: id, label: label, shape: shape})
+	}
+}
+
+func (g *Graph) edge(from, to, label string) {
+	key := "edge:" + from + "\x00" + to
+	if !g.seen[key] {
+		g.seen[key] = true
+		g.edges = append(g.edges, graphEdge{from: from, to: to, label: label})
+	}
+}
+
+// WriteDOT writes the graph in the DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph genny {\n\trankdir=LR;\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(&buf, "\t%s [label=%s, shape=%s];\n", strconv.Quote(n.id), strconv.Quote(n.label), n.shape)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&buf, "\t%s -> %s", strconv.Quote(e.from), strconv.Quote(e.to))
+		if e.label != "" {
+			fmt.Fprintf(&buf, " [label=%s, style=dashed]", strconv.Quote(e.label))
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,341 @@
+package parse
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// importSpec is an import added to the generated code. Name is the alias
+// used for the import, or empty if the package is imported under its own
+// name.
+type importSpec struct {
+	Name string
+	Path string
+}
+
+// String gets the import spec as it appears inside an import declaration.
+func (spec importSpec) String() string {
+	if spec.Name == "" {
+		return strconv.Quote(spec.Path)
+	}
+	return spec.Name + " " + strconv.Quote(spec.Path)
+}
+
+// localName gets the name the package is referred to by in the generated
+// code.
+func (spec importSpec) localName() string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	return importBase(spec.Path)
+}
+
+var (
+	// matches a type qualified with a full import path, like
+	// github.com/me/util.Type
+	rePathQualified = regexp.MustCompile(`((?:[\w\-~]+\.)*[\w\-~]+(?:/[\w\-~.]+)+)\.([A-Za-z_]\w*)`)
+	// matches a type qualified with a package name, like util.Type
+	reNameQualified = regexp.MustCompile(`(^|[^\w./])([A-Za-z_]\w*)\.([A-Za-z_]\w*)`)
+)
+
+// matches the major version suffix of a module path, like the v2 in
+// github.com/me/zoo/v2. Modules have none for v0 and v1, so the v1 of
+// k8s.io/api/core/v1 is the package name.
+var reMajorVersion = regexp.MustCompile(`^v([2-9]|[1-9][0-9]+)$`)
+
+// matches the version of a gopkg.in path, like the v1 in gopkg.in/yaml.v1
+var reGopkgVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// importBase gets the package name that is assumed for an import path. Major
+// version suffixes are not part of the package name, so both
+// github.com/me/zoo/v2 and gopkg.in/zoo.v2 are assumed to be package zoo.
+func importBase(path string) string {
+	elems := pathElems(path)
+	base := elems[len(elems)-1]
+	if strings.HasPrefix(path, "gopkg.in/") {
+		if sepIdx := strings.LastIndex(base, ".v"); sepIdx > 0 && reGopkgVersion.MatchString(base[sepIdx+1:]) {
+			base = base[:sepIdx]
+		}
+	}
+	return base
+}
+
+// pathElems splits an import path into its elements, leaving out a major
+// version suffix.
+func pathElems(path string) []string {
+	elems := strings.Split(path, "/")
+	if len(elems) > 1 && reMajorVersion.MatchString(elems[len(elems)-1]) {
+		elems = elems[:len(elems)-1]
+	}
+	return elems
+}
+
+// resolveImports turns import requests into import specs. A request may
+// ask for a name; packages that would end up with the same name are given
+// distinct aliases, derived from the enclosing path elements.
+func resolveImports(requests []importSpec) []importSpec {
+	var specs []importSpec
+	byName := make(map[string][]int)
+	for _, request := range requests {
+		if containsImport(specs, request.Path) {
+			continue
+		}
+		if request.Name == importBase(request.Path) {
+			request.Name = ""
+		}
+		name := request.localName()
+		byName[name] = append(byName[name], len(specs))
+		specs = append(specs, request)
+	}
+
+	taken := make(map[string]bool)
+	for name, indices := range byName {
+		if len(indices) == 1 {
+			taken[name] = true
+		}
+	}
+	for i, spec := range specs {
+		if len(byName[spec.localName()]) == 1 {
+			continue
+		}
+		alias := importAlias(spec.Path)
+		unique := alias
+		for n := 2; taken[unique]; n++ {
+			unique = alias + strconv.Itoa(n)
+		}
+		taken[unique] = true
+		specs[i].Name = unique
+	}
+	return specs
+}
+
+// importPaths turns the given paths into import requests.
+func importPaths(paths []string) []importSpec {
+	requests := make([]importSpec, 0, len(paths))
+	for _, path := range paths {
+		requests = append(requests, importSpec{Path: path})
+	}
+	return requests
+}
+
+// importAlias builds an alias from the last two elements of an import path,
+// so github.com/me/util and github.com/me/util/v2 both become meutil.
+func importAlias(path string) string {
+	elems := pathElems(path)
+	elems[len(elems)-1] = importBase(path)
+	if len(elems) > 2 {
+		elems = elems[len(elems)-2:]
+	}
+	alias := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && isAlphaNumeric(r) && r != '_' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, strings.Join(elems, ""))
+	if alias == "" || unicode.IsDigit(rune(alias[0])) {
+		alias = "pkg" + alias
+	}
+	return alias
+}
+
+func containsImport(specs []importSpec, path string) bool {
+	for _, spec := range specs {
+		if spec.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifyTypeSets rewrites specific types that are qualified with a full
+// import path (github.com/me/util.Type) or annotated with one
+// (util.Type@github.com/me/util) to use the local name of that import,
+// adding the import if it wasn't already given. It is an error to qualify a
+// type with a package name that is shared by several imports.
+func qualifyTypeSets(typeSets []map[string]string, specs []importSpec) ([]map[string]string, []importSpec, error) {
+	// add any imports only mentioned in the type sets first, so that
+	// conflicting names are aliased consistently
+	requests := append([]importSpec{}, specs...)
+	for _, typeSet := range typeSets {
+		for _, generic := range sortedKeys(typeSet) {
+			arg := parseSpecificArg(typeSet[generic])
+			for _, m := range rePathQualified.FindAllStringSubmatch(arg.Type, -1) {
+				requests = append(requests, importSpec{Path: m[1]})
+			}
+			if arg.ImportPath != "" {
+				qualifier, err := annotatedQualifier(arg)
+				if err != nil {
+					return nil, nil, err
+				}
+				if reMajorVersion.MatchString(qualifier) {
+					// zoo/v2.Type is really package zoo, don't alias it as v2
+					qualifier = ""
+				}
+				requests = append(requests, importSpec{Name: qualifier, Path: arg.ImportPath})
+			}
+		}
+	}
+	specs = resolveImports(requests)
+
+	byPath := make(map[string]importSpec)
+	byName := make(map[string][]importSpec)
+	for _, spec := range specs {
+		byPath[spec.Path] = spec
+		byName[importBase(spec.Path)] = append(byName[importBase(spec.Path)], spec)
+	}
+
+	result := make([]map[string]string, 0, len(typeSets))
+	for _, typeSet := range typeSets {
+		qualified := make(map[string]string, len(typeSet))
+		for generic, specific := range typeSet {
+			arg := parseSpecificArg(specific)
+			typ := rePathQualified.ReplaceAllStringFunc(arg.Type, func(s string) string {
+				m := rePathQualified.FindStringSubmatch(s)
+				return byPath[m[1]].localName() + "." + m[2]
+			})
+			if arg.ImportPath != "" {
+				qualifier, _ := annotatedQualifier(arg)
+				local := byPath[arg.ImportPath].localName()
+				typ = replaceQualifier(typ, qualifier, local)
+				arg.ImportPath = ""
+			}
+			for _, m := range reNameQualified.FindAllStringSubmatch(typ, -1) {
+				if len(byName[m[2]]) > 1 {
+					return nil, nil, &errAmbiguousImport{SpecificType: arg.Type, Name: m[2]}
+				}
+			}
+			arg.Type = typ
+			qualified[generic] = arg.String()
+		}
+		result = append(result, qualified)
+	}
+	return result, specs, nil
+}
+
+// annotatedQualifier finds the package name in the type of an argument that
+// refers to its import path annotation. This is the base name of the import
+// path, or the only package name in the type.
+func annotatedQualifier(arg specificArg) (string, error) {
+	var names []string
+	for _, m := range reNameQualified.FindAllStringSubmatch(arg.Type, -1) {
+		if m[2] == importBase(arg.ImportPath) {
+			return m[2], nil
+		}
+		if !stringArraySet(names).contains(m[2]) {
+			names = append(names, m[2])
+		}
+	}
+	if len(names) != 1 {
+		return "", &errBadTypeArgs{Arg: arg.String(), Message: "cannot tell which package in the type is imported from " + arg.ImportPath}
+	}
+	return names[0], nil
+}
+
+// replaceQualifier replaces the package name old with new in all qualified
+// identifiers in typ.
+func replaceQualifier(typ, old, new string) string {
+	if old == new {
+		return typ
+	}
+	return reNameQualified.ReplaceAllStringFunc(typ, func(s string) string {
+		m := reNameQualified.FindStringSubmatch(s)
+		if m[2] != old {
+			return s
+		}
+		return m[1] + new + "." + m[3]
+	})
+}
+
+func sortedKeys(typeSet map[string]string) []string {
+	keys := make([]string, 0, len(typeSet))
+	for key := range typeSet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseImportLine parses a line of an import declaration of the template,
+// like "fmt" or str "strings". It returns false for lines without an import,
+// like blank lines and comments.
+func parseImportLine(line string) (importSpec, bool) {
+	line = strings.TrimSpace(line)
+	if commentIdx := strings.Index(line, "//"); commentIdx >= 0 && !strings.Contains(line[:commentIdx], `"`) {
+		return importSpec{}, false
+	}
+	fields := strings.Fields(line)
+	var spec importSpec
+	switch {
+	case len(fields) >= 2 && !strings.HasPrefix(fields[0], `"`) && !strings.HasPrefix(fields[0], "`"):
+		spec.Name, fields = fields[0], fields[1:]
+	case len(fields) == 0:
+		return importSpec{}, false
+	}
+	path, err := strconv.Unquote(fields[0])
+	if err != nil {
+		return importSpec{}, false
+	}
+	spec.Path = path
+	return spec, true
+}
+
+// importGroup gets the goimports group of a path: 0 for the standard
+// library, 1 for third party packages and 2 for packages under one of the
+// local prefixes.
+func importGroup(path string, localPrefixes []string) int {
+	for _, prefix := range localPrefixes {
+		if prefix != "" && (path == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(path, prefix)) {
+			return 2
+		}
+	}
+	if !strings.Contains(strings.Split(path, "/")[0], ".") {
+		return 0
+	}
+	return 1
+}
+
+// importDecl builds the import declaration of the generated code, with the
+// imports grouped and sorted the way goimports would. It returns no lines if
+// there are no imports.
+func importDecl(specs []importSpec, localPrefixes []string) []string {
+	var unique []importSpec
+	for _, spec := range specs {
+		if !containsSpec(unique, spec) {
+			unique = append(unique, spec)
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		a, b := unique[i], unique[j]
+		if groupA, groupB := importGroup(a.Path, localPrefixes), importGroup(b.Path, localPrefixes); groupA != groupB {
+			return groupA < groupB
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+
+	lines := []string{makeLine("import (")}
+	for i, spec := range unique {
+		if i > 0 && importGroup(spec.Path, localPrefixes) != importGroup(unique[i-1].Path, localPrefixes) {
+			lines = append(lines, makeLine(""))
+		}
+		lines = append(lines, makeLine("\t"+spec.String()))
+	}
+	return append(lines, makeLine(")"))
+}
+
+func containsSpec(specs []importSpec, spec importSpec) bool {
+	for _, s := range specs {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
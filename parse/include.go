@@ -0,0 +1,130 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// includeDirective is a comment that inlines the declarations of another
+// template, like
+//
+//	// genny:include iterator.go
+//
+// The path is relative to the directory of the template.
+const includeDirective = "genny:include"
+
+// directiveArg gets the argument of the directive if the line is a comment
+// with it, like "// genny:include iterator.go".
+func directiveArg(line, directive string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "//") {
+		return "", false
+	}
+	line = strings.TrimSpace(line[2:])
+	if !strings.HasPrefix(line, directive+" ") {
+		return "", false
+	}
+	return strings.TrimSpace(line[len(directive):]), true
+}
+
+// templatePath gets the path of a template named in a directive of the
+// template filename.
+func templatePath(filename, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(filename), path)
+}
+
+// resolveIncludes replaces the include directives of a template with the
+// declarations of the included templates, before any type is substituted,
+// and adds their imports after the imports of the template. including are
+// the templates that include this one, to catch cycles.
+func resolveIncludes(filename string, src []byte, including []string) ([]byte, error) {
+	if !bytes.Contains(src, []byte(includeDirective)) {
+		return src, nil
+	}
+	importsEnd, _, err := importsRange(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	// the lines up to the end of the imports stay as they are
+	headEnd := len(src)
+	if i := bytes.IndexByte(src[importsEnd:], '\n'); i >= 0 {
+		headEnd = importsEnd + i + 1
+	}
+
+	var body bytes.Buffer
+	var imports []byte
+	offset := 0
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		lineStart := offset
+		offset += len(line)
+		path, ok := directiveArg(line, includeDirective)
+		if !ok {
+			if lineStart >= headEnd {
+				body.WriteString(line)
+			}
+			continue
+		}
+		if lineStart < headEnd {
+			return nil, &errInclude{Filename: filename, Include: path, Message: "must come after the imports"}
+		}
+		path = templatePath(filename, path)
+		for _, f := range including {
+			if f == path {
+				return nil, &errInclude{Filename: filename, Include: path, Message: "includes itself"}
+			}
+		}
+
+		included, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, &errInclude{Filename: filename, Include: path, Message: err.Error()}
+		}
+		included, err = resolveIncludes(path, normalizeEOL(included), append(including, filepath.Clean(filename)))
+		if err != nil {
+			return nil, err
+		}
+		end, start, err := importsRange(path, included)
+		if err != nil {
+			return nil, err
+		}
+		if start >= 0 {
+			imports = append(imports, '\n')
+			imports = append(imports, included[start:end]...)
+			imports = append(imports, '\n')
+		}
+		body.Write(bytes.TrimLeft(included[end:], "\n"))
+	}
+
+	var out bytes.Buffer
+	out.Write(src[:headEnd])
+	out.Write(imports)
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// importsRange gets where the imports of a template end, or the package
+// clause if there are none, and where they start, or -1.
+func importsRange(filename string, src []byte) (int, int, error) {
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, src, parser.ImportsOnly)
+	if err != nil {
+		return 0, 0, &errSource{Err: err}
+	}
+	end, start := fs.Position(file.Name.End()).Offset, -1
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			if start < 0 {
+				start = fs.Position(decl.Pos()).Offset
+			}
+			end = fs.Position(decl.End()).Offset
+		}
+	}
+	return end, start, nil
+}
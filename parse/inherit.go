@@ -0,0 +1,153 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// baseDirective is a comment that makes a template derive from a base
+// template, like
+//
+//	// genny:base list.go
+//
+// The template is generated as the base template with the declarations of
+// the template in place of the base declarations of the same name, and the
+// other declarations of the template after them. The path is relative to
+// the directory of the template.
+const baseDirective = "genny:base"
+
+// templateDecl is a top level declaration of a template, along with the
+// comments and white space in front of it.
+type templateDecl struct {
+	kind int
+	name string
+	text []byte
+}
+
+// resolveBase merges a template that declares a base template into the
+// base. deriving are the templates that derive from this one, to catch
+// cycles.
+func resolveBase(filename string, src []byte, deriving []string) ([]byte, error) {
+	if !bytes.Contains(src, []byte(baseDirective)) {
+		return src, nil
+	}
+
+	// the directive is dropped from the template
+	var derived bytes.Buffer
+	var path string
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		arg, ok := directiveArg(line, baseDirective)
+		if !ok {
+			derived.WriteString(line)
+			continue
+		}
+		if path != "" {
+			return nil, &errBase{Filename: filename, Base: arg, Message: "there is already a base template '" + path + "'"}
+		}
+		path = templatePath(filename, arg)
+	}
+	if path == "" {
+		return src, nil
+	}
+	for _, f := range deriving {
+		if f == path {
+			return nil, &errBase{Filename: filename, Base: path, Message: "derives from itself"}
+		}
+	}
+
+	base, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &errBase{Filename: filename, Base: path, Message: err.Error()}
+	}
+	base, err = resolveIncludes(path, normalizeEOL(base), nil)
+	if err != nil {
+		return nil, err
+	}
+	base, err = resolveBase(path, base, append(deriving, filepath.Clean(filename)))
+	if err != nil {
+		return nil, err
+	}
+
+	head, decls, tail, err := templateDecls(filename, derived.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	_, baseDecls, _, err := templateDecls(path, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.Write(head)
+	if end, start, err := importsRange(path, base); err != nil {
+		return nil, err
+	} else if start >= 0 {
+		out.WriteString("\n")
+		out.Write(base[start:end])
+		out.WriteString("\n")
+	}
+	overridden := make([]bool, len(decls))
+	for _, baseDecl := range baseDecls {
+		text := baseDecl.text
+		for i, decl := range decls {
+			if !overridden[i] && decl.kind == baseDecl.kind && decl.name == baseDecl.name {
+				text = decl.text
+				overridden[i] = true
+				break
+			}
+		}
+		writeDecl(&out, text)
+	}
+	for i, decl := range decls {
+		if !overridden[i] {
+			writeDecl(&out, decl.text)
+		}
+	}
+	out.Write(tail)
+	return out.Bytes(), nil
+}
+
+// writeDecl writes the text of a declaration on a line of its own.
+func writeDecl(out *bytes.Buffer, text []byte) {
+	if !bytes.HasPrefix(text, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	out.Write(text)
+}
+
+// templateDecls splits a template into the lines up to the end of its
+// imports, its other declarations and what follows the last of them.
+func templateDecls(filename string, src []byte) ([]byte, []templateDecl, []byte, error) {
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, nil, &errSource{Err: err}
+	}
+	start, _, err := importsRange(filename, src)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if i := bytes.IndexByte(src[start:], '\n'); i >= 0 {
+		start += i + 1
+	} else {
+		start = len(src)
+	}
+	head := src[:start]
+
+	var decls []templateDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		end := fs.Position(decl.End()).Offset
+		kind, name := declKind(decl)
+		decls = append(decls, templateDecl{kind: kind, name: name, text: src[start:end]})
+		start = end
+	}
+	return head, decls, src[start:], nil
+}
@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DisallowedImports gets the imports of the generated code that the
+// package with the import path pkgPath isn't allowed to import, because
+// they are internal to packages it isn't in, like a/internal/b for any
+// package outside a.
+func DisallowedImports(code []byte, pkgPath string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", code, parser.ImportsOnly)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	var disallowed []string
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if !internalAllowed(pkgPath, path) {
+			disallowed = append(disallowed, path)
+		}
+	}
+	return disallowed, nil
+}
+
+// internalAllowed tells whether the package importer may import the
+// package path, which it may unless path has an internal element and
+// importer isn't within the parent of its last one.
+func internalAllowed(importer, path string) bool {
+	var parent string
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		parent = strings.TrimSuffix(path, "/internal")
+	case strings.Contains(path, "/internal/"):
+		parent = path[:strings.LastIndex(path, "/internal/")]
+	case path == "internal" || strings.HasPrefix(path, "internal/"):
+		// only the standard library has these
+		return false
+	default:
+		return true
+	}
+	return importer == parent || strings.HasPrefix(importer, parent+"/")
+}
+
+// InternalImporters gets the packages under root, whose import path is
+// rootPath, that import the package pkgPath but aren't allowed to, because
+// it is internal to a package they aren't in. The vendor and testdata
+// directories, and those starting with . or _, are skipped like by the go
+// tool.
+func InternalImporters(root, rootPath, pkgPath string) ([]string, error) {
+	if internalAllowed("", pkgPath) {
+		// anything may import it
+		return nil, nil
+	}
+	var importers []string
+	seen := make(map[string]bool)
+	err := filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if filename != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(filename))
+		if err != nil {
+			return err
+		}
+		importer := path.Join(rootPath, filepath.ToSlash(rel))
+		if seen[importer] || internalAllowed(importer, pkgPath) {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.ImportsOnly)
+		if err != nil {
+			// the go tool reports it
+			return nil
+		}
+		for _, spec := range file.Imports {
+			if imported, err := strconv.Unquote(spec.Path.Value); err == nil && imported == pkgPath {
+				seen[importer] = true
+				importers = append(importers, importer)
+				break
+			}
+		}
+		return nil
+	})
+	sort.Strings(importers)
+	return importers, err
+}
@@ -0,0 +1,127 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// KeepOrder reorders the top-level declarations of the generated code to
+// the order they have in the existing code of the file it overwrites, so
+// that regenerating the file after reordering the template or adding a type
+// set changes only what differs. The declarations that the existing code
+// doesn't have go right after the one they follow in the generated code,
+// and those that it has but the generated code doesn't are dropped. The
+// header and the imports are those of the generated code. The existing
+// code is given as the files it is split into, if it is, in order.
+func KeepOrder(code []byte, existing ...[]byte) ([]byte, error) {
+	head, decls, err := orderedDecls(code)
+	if err != nil {
+		return nil, err
+	}
+	order := make(map[string]int)
+	for _, src := range existing {
+		_, old, err := orderedDecls(src)
+		if err != nil {
+			// the existing file is no guide to the order
+			return code, nil
+		}
+		for _, decl := range old {
+			if _, ok := order[decl.key]; !ok {
+				order[decl.key] = len(order)
+			}
+		}
+	}
+
+	// the declarations that the existing code has, in its order, and then
+	// each of the others after the one it follows
+	kept := make([]keyedDecl, 0, len(decls))
+	for _, decl := range decls {
+		if _, ok := order[decl.key]; ok {
+			kept = append(kept, decl)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return order[kept[i].key] < order[kept[j].key] })
+	for i, decl := range decls {
+		if _, ok := order[decl.key]; ok {
+			continue
+		}
+		at := 0
+		if i > 0 {
+			for j, other := range kept {
+				if other.key == decls[i-1].key {
+					at = j + 1
+					break
+				}
+			}
+		}
+		kept = append(kept[:at], append([]keyedDecl{decl}, kept[at:]...)...)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(head)
+	for _, decl := range kept {
+		buf.WriteString("\n\n")
+		buf.Write(decl.text)
+	}
+	buf.WriteString("\n")
+	output, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	return output, nil
+}
+
+// keyedDecl is a top-level declaration of generated code with the comments
+// in front of it, and the key that tells it apart from the others.
+type keyedDecl struct {
+	key  string
+	text []byte
+}
+
+// orderedDecls splits generated code into its head, which is everything up
+// to the end of its imports, and its declarations, in order. The key of a
+// declaration is its kind and name, with the number of the ones before it
+// of the same kind and name if there are any, like init functions.
+func orderedDecls(code []byte) ([]byte, []keyedDecl, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", code, parser.ParseComments)
+	if err != nil {
+		return nil, nil, &errSource{Err: err}
+	}
+	tf := fset.File(file.Pos())
+	headEnd := tf.Offset(file.Name.End())
+	var starts []int
+	var keys []string
+	seen := make(map[string]int)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && len(starts) == 0 {
+			headEnd = tf.Offset(decl.End())
+			continue
+		}
+		pos := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			pos = doc.Pos()
+		}
+		starts = append(starts, tf.Offset(tf.LineStart(tf.Line(pos))))
+		kind, name := declKind(decl)
+		key := strconv.Itoa(kind) + " " + name
+		if n := seen[key]; n > 0 {
+			seen[key]++
+			key += " " + strconv.Itoa(n)
+		} else {
+			seen[key] = 1
+		}
+		keys = append(keys, key)
+	}
+	starts = append(starts, len(code))
+	decls := make([]keyedDecl, len(keys))
+	for i, key := range keys {
+		decls[i] = keyedDecl{key: key, text: bytes.TrimSpace(code[starts[i]:starts[i+1]])}
+	}
+	return code[:headEnd], decls, nil
+}
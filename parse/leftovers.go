@@ -0,0 +1,84 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// checkLeftovers fails if substitution left references to the generic
+// package or to the generic types of the type set in the code of a
+// template, which half-substituted code from a typo or unusual syntax has,
+// and which would otherwise only fail to compile later on. A reference is
+// left over if the code doesn't declare it, so a generic type whose
+// specific type is named like it doesn't count.
+func checkLeftovers(filename string, code []byte, typeSet map[string]string, clause string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, code, 0)
+	if err != nil {
+		// leave it to imports.Process to report the syntax error
+		return nil
+	}
+	if len(leftovers(file, typeSet)) == 0 {
+		return nil
+	}
+
+	// the lines are reported as gofmt prints them, as the legacy
+	// implementation separates every token with a space, and without their
+	// numbers, which are of the code of the template alone
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, file); err == nil {
+		if formatted, err := parser.ParseFile(fset, filename, buf.Bytes(), 0); err == nil {
+			file, code = formatted, buf.Bytes()
+		}
+	}
+	lines := strings.Split(string(code), "\n")
+	e := &errLeftoverGeneric{Filename: filename, TypeSet: clause}
+	seenNames, seenLines := make(map[string]bool), make(map[int]bool)
+	for _, ref := range leftovers(file, typeSet) {
+		if !seenNames[ref.name] {
+			seenNames[ref.name] = true
+			e.Names = append(e.Names, ref.name)
+		}
+		line := fset.Position(ref.pos).Line
+		if !seenLines[line] && line <= len(lines) {
+			seenLines[line] = true
+			e.Lines = append(e.Lines, strings.TrimSpace(lines[line-1]))
+		}
+	}
+	sort.Strings(e.Names)
+	return e
+}
+
+// leftoverRef is a reference that substitution left in the code.
+type leftoverRef struct {
+	name string
+	pos  token.Pos
+}
+
+// leftovers finds the selectors of the generic package and the generic types
+// that the file refers to but doesn't declare, in the order of the code.
+func leftovers(file *ast.File, typeSet map[string]string) []leftoverRef {
+	var refs []leftoverRef
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == genericPackage && ident.Obj == nil {
+				refs = append(refs, leftoverRef{name: genericPackage + "." + sel.Sel.Name, pos: sel.Pos()})
+				return false
+			}
+		}
+		return true
+	})
+	for _, ident := range file.Unresolved {
+		specific, ok := typeSet[ident.Name]
+		if ok && !containsBoundary(specific, ident.Name) {
+			refs = append(refs, leftoverRef{name: ident.Name, pos: ident.Pos()})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].pos < refs[j].pos })
+	return refs
+}
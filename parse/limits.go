@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// Limits guard against pathological inputs, like a mistyped cross product
+// of type sets or a binary file given as a template, so that they fail fast
+// instead of using up all the memory. A zero limit is not enforced.
+type Limits struct {
+	// MaxOutputSize is the maximum number of bytes of generated code.
+	MaxOutputSize int
+	// MaxInstantiations is the maximum number of type sets, times the
+	// number of templates.
+	MaxInstantiations int
+	// MaxLineLength is the maximum length of a line of a template, in bytes.
+	MaxLineLength int
+}
+
+// DefaultLimits are the limits that the genny command enforces unless asked
+// otherwise.
+var DefaultLimits = Limits{
+	MaxOutputSize:     64 << 20,
+	MaxInstantiations: 10000,
+	MaxLineLength:     16 << 10,
+}
+
+// LineReader wraps the reader of a template so that reading it fails as
+// soon as a line is over the limit, instead of after the whole of a file
+// without lines, like a binary file, is in memory.
+func (l Limits) LineReader(filename string, r io.Reader) io.Reader {
+	if l.MaxLineLength <= 0 {
+		return r
+	}
+	return &lineReader{r: r, filename: filename, max: l.MaxLineLength, lineNo: 1}
+}
+
+// readSource reads the whole source of a template like readSource, with the
+// length of its lines checked while it is read.
+func (l Limits) readSource(template Template) ([]byte, error) {
+	if seeker, ok := template.Source.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
+	}
+	src, err := ioutil.ReadAll(l.LineReader(template.Filename, template.Source))
+	if limit, ok := err.(*errLimit); ok {
+		return nil, limit
+	} else if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	return src, nil
+}
+
+// lineReader counts the bytes of the line it reads, for LineReader. Carriage
+// returns aren't counted, so that lines end the same on every platform.
+type lineReader struct {
+	r        io.Reader
+	filename string
+	max      int
+	lineNo   int
+	length   int
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for _, c := range p[:n] {
+		switch c {
+		case '\n':
+			r.lineNo++
+			r.length = 0
+		case '\r':
+		default:
+			if r.length++; r.length > r.max {
+				return 0, &errLimit{
+					What: "Line " + strconv.Itoa(r.lineNo) + " of '" + r.filename + "' is at least " + strconv.Itoa(r.length) + " bytes long",
+					Max:  r.max,
+				}
+			}
+		}
+	}
+	return n, err
+}
+
+// checkInstantiations checks the number of instantiations.
+func (l Limits) checkInstantiations(n int) error {
+	if l.MaxInstantiations > 0 && n > l.MaxInstantiations {
+		return &errLimit{
+			What: "The type sets make " + strconv.Itoa(n) + " instantiations",
+			Max:  l.MaxInstantiations,
+		}
+	}
+	return nil
+}
+
+// checkOutputSize checks the size of the code generated so far.
+func (l Limits) checkOutputSize(size int) error {
+	if l.MaxOutputSize > 0 && size > l.MaxOutputSize {
+		return &errLimit{
+			What: "The generated code is at least " + strconv.Itoa(size) + " bytes",
+			Max:  l.MaxOutputSize,
+		}
+	}
+	return nil
+}
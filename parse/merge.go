@@ -0,0 +1,245 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// codeMerger merges the files generated for every type set into a single
+// file, line by line. It keeps one package clause and collects the imports
+// of all the files, so that they can be written in a single block.
+type codeMerger struct {
+	opts   Options
+	pkgDoc []string
+
+	lines []string
+	// preambleStart is where the lines copied from the template start
+	preambleStart int
+	// packageLine is the index of the package clause, or -1
+	packageLine int
+	// importLine is where the imports were found, or -1
+	importLine int
+	imports    []importSpec
+	// cgo is whether the code imports "C", and cgoPreambles are the
+	// distinct comments in front of the imports
+	cgo          bool
+	cgoPreambles [][]string
+
+	fileIndex    int
+	lastTemplate int
+	packageFound bool
+	// Whether to wait for the "genny:start" comment to start copying. This will be set to true
+	// after we have went through the first generated type, so subsequent generated types will
+	// not copy anything before that line
+	fileHasGennyStart bool
+}
+
+func newCodeMerger(g *generation) *codeMerger {
+	m := &codeMerger{
+		opts:        g.opts,
+		pkgDoc:      g.pkgDoc,
+		lines:       []string{header},
+		packageLine: -1,
+		importLine:  -1,
+	}
+	if g.stamp != "" || g.opts.Command != "" {
+		m.lines = []string{strings.TrimSuffix(header, linefeed[1:])}
+		if g.stamp != "" {
+			m.lines = append(m.lines, g.stamp)
+		}
+		if g.opts.Command != "" {
+			m.lines = append(m.lines, commandLine(g.opts.Command))
+		}
+		m.lines = append(m.lines, makeLine(""))
+	}
+	if g.keptConstraint != nil {
+		m.lines = append(m.lines, makeLine("//go:build "+g.keptConstraint.String()), makeLine(""))
+	}
+	m.preambleStart = len(m.lines)
+	return m
+}
+
+// add cleans up the next generated file and appends it to the lines.
+func (m *codeMerger) add(transformedOutput generatedFile) {
+	fileIndex := m.fileIndex
+	if fileIndex > 0 && transformedOutput.template != m.lastTemplate {
+		// the next template may not have a "genny:start" comment
+		m.fileHasGennyStart = false
+	}
+	m.fileIndex++
+	m.lastTemplate = transformedOutput.template
+
+	opts := m.opts
+	if opts.SortDecls != DeclOrderNone && fileIndex > 0 {
+		m.lines = append(m.lines, makeTypeSetMarker(fileIndex))
+	}
+	// where the lines of this file that follow the package clause start
+	bodyStart := len(m.lines)
+	insideImportBlock := false
+	packageFoundForFile := false
+	var packageClause packageClauseFinder
+	scanner := bufio.NewScanner(bytes.NewReader(transformedOutput.code))
+	pastGennyStart := false
+	// whether build constraint lines were removed or kept, to know
+	// whether the blank line after them is still needed
+	constraintsRemoved, constraintsKept := false, false
+
+FORSCAN:
+	for scanner.Scan() {
+
+		if bytes.HasPrefix(scanner.Bytes(), []byte("//genny:start")) {
+			pastGennyStart = true
+			m.fileHasGennyStart = true
+			continue
+		}
+
+		// end of imports block?
+		if insideImportBlock {
+			if bytes.HasSuffix(scanner.Bytes(), closeBrace) {
+				insideImportBlock = false
+			} else if spec, ok := parseImportLine(scanner.Text()); ok {
+				m.imports = append(m.imports, spec)
+			}
+			continue
+		}
+
+		if !packageFoundForFile && packageClause.find(scanner.Text()) >= 0 {
+			packageFoundForFile = true
+			if !m.packageFound {
+				m.packageFound = true
+				// keep exactly one copy of the package doc comment
+				docStart := trailingComment(m.lines, m.preambleStart)
+				if !opts.SubstitutePackageDoc {
+					m.lines = append(m.lines[:docStart], m.pkgDoc...)
+				}
+				m.packageLine = len(m.lines)
+				m.lines = append(m.lines, makeLine(scanner.Text()))
+				if opts.SortDecls != DeclOrderNone {
+					m.lines = append(m.lines, makeTypeSetMarker(fileIndex))
+				}
+				bodyStart = len(m.lines)
+			}
+			continue
+		} else if isCgoImport(scanner.Text()) {
+			// the preamble must stay right in front of import "C"
+			preambleStart := trailingComment(m.lines, bodyStart)
+			m.addCgoPreamble(m.lines[preambleStart:])
+			m.lines = m.lines[:preambleStart]
+			if m.importLine == -1 {
+				m.importLine = len(m.lines)
+			}
+			continue
+		} else if bytes.HasPrefix(scanner.Bytes(), importKeyword) {
+			if m.importLine == -1 {
+				m.importLine = len(m.lines)
+			}
+			if bytes.HasSuffix(scanner.Bytes(), openBrace) {
+				insideImportBlock = true
+			} else if spec, ok := parseImportLine(scanner.Text()[len(importKeyword):]); ok {
+				m.imports = append(m.imports, spec)
+			}
+
+			continue
+		}
+
+		if fileIndex != 0 && !packageFoundForFile {
+			continue
+		}
+
+		if m.fileHasGennyStart && !pastGennyStart {
+			continue
+		}
+
+		// the constraints were already written atop the output
+		if opts.KeepConstraints && !packageFoundForFile && isConstraintLine(scanner.Text()) {
+			constraintsRemoved = true
+			continue
+		}
+
+		// check all unwantedLinePrefixes - and skip them
+		for _, prefix := range unwantedLinePrefixes {
+			if bytes.HasPrefix(scanner.Bytes(), prefix) {
+				continue FORSCAN
+			}
+		}
+
+		// strip the tags from build constraints
+		if len(opts.StripTags) > 0 && !packageFoundForFile && isConstraintLine(scanner.Text()) {
+			lines := stripConstraintLine(scanner.Text(), opts.StripTags)
+			for _, line := range lines {
+				m.lines = append(m.lines, makeLine(line))
+			}
+			if len(lines) > 0 {
+				constraintsKept = true
+			} else {
+				constraintsRemoved = true
+			}
+			continue
+		}
+
+		// drop the blank line that separated the removed constraints
+		// from the package clause
+		if constraintsRemoved && !constraintsKept && !packageFoundForFile && strings.TrimSpace(scanner.Text()) == "" {
+			constraintsRemoved = false
+			continue
+		}
+
+		m.lines = append(m.lines, makeLine(scanner.Text()))
+	}
+}
+
+// isCgoImport gets whether the line is a single import of "C".
+func isCgoImport(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) == 2 && fields[0] == string(importKeyword) && fields[1] == `"C"`
+}
+
+func (m *codeMerger) addCgoPreamble(preamble []string) {
+	m.cgo = true
+	if len(preamble) == 0 {
+		return
+	}
+	for _, p := range m.cgoPreambles {
+		if strings.Join(p, "") == strings.Join(preamble, "") {
+			return
+		}
+	}
+	m.cgoPreambles = append(m.cgoPreambles, append([]string(nil), preamble...))
+}
+
+// importDecls gets the import declarations of the merged code: import "C"
+// after the cgo preambles, if any, then the other imports along with
+// importSpecs.
+func (m *codeMerger) importDecls(importSpecs []importSpec) []string {
+	var lines []string
+	if m.cgo {
+		for _, preamble := range m.cgoPreambles {
+			lines = append(lines, preamble...)
+		}
+		lines = append(lines, makeLine(`import "C"`), makeLine(""))
+	}
+	return append(lines, importDecl(append(m.imports, importSpecs...), m.opts.LocalPrefixes)...)
+}
+
+// split splits the lines at the imports, which are left out. The head is
+// everything in front of them, down to the package clause.
+func (m *codeMerger) split() (head, body []string) {
+	if m.importLine < 0 {
+		return m.lines[:m.packageLine+1], m.lines[m.packageLine+1:]
+	}
+	return m.lines[:m.importLine], m.lines[m.importLine+1:]
+}
+
+// output gets the merged code, with the import declaration in place of the
+// imports of the generated files.
+func (m *codeMerger) output(importDecl []string) []byte {
+	head, body := m.split()
+	var buf bytes.Buffer
+	for _, lines := range [][]string{head, importDecl, body} {
+		for _, line := range lines {
+			buf.WriteString(line)
+		}
+	}
+	return buf.Bytes()
+}
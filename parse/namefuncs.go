@@ -0,0 +1,144 @@
+package parse
+
+import (
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// commonInitialisms are the words that Go code writes in upper case, as
+// listed by golint.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"DNS": true, "EOF": true, "GUID": true, "HTML": true, "HTTP": true,
+	"HTTPS": true, "ID": true, "IP": true, "JSON": true, "LHS": true,
+	"QPS": true, "RAM": true, "RHS": true, "RPC": true, "SLA": true,
+	"SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true,
+	"TTL": true, "UDP": true, "UI": true, "UID": true, "UUID": true,
+	"URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// NamingFuncs gets the functions that templates can use to turn types and
+// names into other names:
+//
+//	lower, upper  change the case of all of it: "HTTPServer" is "httpserver"
+//	title         upper cases the first letter: "server" is "Server"
+//	snake, kebab  "HTTPServer" is "http_server" or "http-server"
+//	camel, pascal "http_server" is "httpServer" or "HttpServer"
+//	initialisms   upper cases words like ID and URL: "UserId" is "UserID"
+//	plural        "Entry" is "Entries" and "Box" is "Boxes"
+//	word          turns a type into a word like genny does: "*pet.Dog" is "PetDog"
+func NamingFuncs() template.FuncMap {
+	return template.FuncMap{
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"title":       titleCase,
+		"snake":       func(s string) string { return joinWords(s, "_") },
+		"kebab":       func(s string) string { return joinWords(s, "-") },
+		"camel":       camelCase,
+		"pascal":      pascalCase,
+		"initialisms": initialisms,
+		"plural":      plural,
+		"word":        func(s string) string { return wordify(s, true) },
+	}
+}
+
+// splitWords splits a name into its words, at the separators between them
+// and where the case changes, so "HTTPServer_v2" is "HTTP", "Server" and
+// "v2". Digits stay with the word in front of them.
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextIsLower {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+func joinWords(s, sep string) string {
+	return strings.ToLower(strings.Join(splitWords(s), sep))
+}
+
+func titleCase(s string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func pascalCase(s string) string {
+	var out strings.Builder
+	for _, word := range splitWords(s) {
+		out.WriteString(titleCase(strings.ToLower(word)))
+	}
+	return out.String()
+}
+
+func camelCase(s string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(words[0]) + pascalCase(strings.Join(words[1:], "_"))
+}
+
+// initialisms upper cases the words of a name that are common initialisms,
+// keeping the rest as it is.
+func initialisms(s string) string {
+	runes := []rune(s)
+	var out strings.Builder
+	start := 0
+	for _, word := range splitWords(s) {
+		// copy the separators in front of the word
+		wordRunes := []rune(word)
+		for string(runes[start:start+len(wordRunes)]) != word {
+			out.WriteRune(runes[start])
+			start++
+		}
+		if commonInitialisms[strings.ToUpper(word)] {
+			word = strings.ToUpper(word)
+		}
+		out.WriteString(word)
+		start += len(wordRunes)
+	}
+	out.WriteString(string(runes[start:]))
+	return out.String()
+}
+
+// plural gets the English plural of a noun, following the regular rules.
+// The suffix is lower case, so the plural of ID is IDs.
+func plural(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "":
+		return s
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	}
+	return s + "s"
+}
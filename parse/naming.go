@@ -0,0 +1,143 @@
+package parse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingPolicy controls how a package qualified specific type (like
+// person.Person) is turned into a word for identifiers and comments.
+type NamingPolicy int
+
+const (
+	// NamingPackage keeps the package name in the word, so person.Person
+	// becomes PersonPerson. This is the default.
+	NamingPackage NamingPolicy = iota
+	// NamingType uses only the type name, so person.Person becomes Person.
+	NamingType
+	// NamingAlias requires every qualified type to be given an explicit
+	// title, as in Person:person.Person.
+	NamingAlias
+)
+
+var namingPolicies = map[string]NamingPolicy{
+	"package": NamingPackage,
+	"type":    NamingType,
+	"alias":   NamingAlias,
+}
+
+// ParseNamingPolicy returns the NamingPolicy with the given name. Valid
+// names are "package", "type" and "alias".
+func ParseNamingPolicy(name string) (NamingPolicy, error) {
+	if name == "" {
+		return NamingPackage, nil
+	}
+	policy, ok := namingPolicies[name]
+	if !ok {
+		return NamingPackage, &errBadNamingPolicy{Name: name}
+	}
+	return policy, nil
+}
+
+// Namer names specific types for codebases whose naming conventions the
+// naming policies can't express. Name gets the word that the specific type
+// of a generic type becomes in identifiers and comments, like Int for int,
+// or "" to leave it to the naming policy. Specific types given with an
+// explicit title keep it.
+type Namer interface {
+	Name(generic, specific string) (string, error)
+}
+
+// NamerFunc is a function that is a Namer.
+type NamerFunc func(generic, specific string) (string, error)
+
+// Name calls f.
+func (f NamerFunc) Name(generic, specific string) (string, error) {
+	return f(generic, specific)
+}
+
+// Namers gets a Namer that asks the namers in turn, until one of them
+// names the specific type.
+func Namers(namers ...Namer) Namer {
+	return NamerFunc(func(generic, specific string) (string, error) {
+		for _, namer := range namers {
+			title, err := namer.Name(generic, specific)
+			if err != nil || title != "" {
+				return title, err
+			}
+		}
+		return "", nil
+	})
+}
+
+// applyNaming resolves the naming policy for every specific type in the
+// type sets. A specific type may override the default policy with a
+// "#policy" suffix, e.g. person.Person#type. The returned type sets have
+// the suffixes removed and contain an explicit title wherever the policy,
+// or the namer if it is not nil, requires one.
+func applyNaming(typeSets []map[string]string, naming NamingPolicy, namer Namer) ([]map[string]string, error) {
+	result := make([]map[string]string, 0, len(typeSets))
+	for _, typeSet := range typeSets {
+		resolved := make(map[string]string, len(typeSet))
+		for generic, specific := range typeSet {
+			s, err := resolveNaming(generic, specific, naming, namer)
+			if err != nil {
+				return nil, err
+			}
+			resolved[generic] = s
+		}
+		result = append(result, resolved)
+	}
+	return result, nil
+}
+
+func resolveNaming(generic, specific string, naming NamingPolicy, namer Namer) (string, error) {
+	arg := parseSpecificArg(specific)
+	if arg.Naming != "" {
+		var err error
+		naming, err = ParseNamingPolicy(arg.Naming)
+		if err != nil {
+			return "", err
+		}
+		arg.Naming = ""
+	}
+	if arg.Title != "" {
+		// already has an explicit title
+		return arg.String(), nil
+	}
+	if namer != nil {
+		title, err := namer.Name(generic, arg.Type)
+		if err != nil {
+			return "", &errNamer{GenericType: generic, SpecificType: arg.Type, Err: err}
+		}
+		if title != "" {
+			if !isIdentifier(title) {
+				return "", &errNamer{GenericType: generic, SpecificType: arg.Type, Err: &errBadTitle{Title: title}}
+			}
+			arg.Title = title
+			return arg.String(), nil
+		}
+	}
+	typeName := strings.TrimLeft(strings.TrimRight(arg.Type, "{}"), "*&")
+	dotIdx := strings.LastIndex(typeName, ".")
+	if dotIdx < 0 {
+		return arg.String(), nil
+	}
+	switch naming {
+	case NamingType:
+		arg.Title = typeName[dotIdx+1:]
+	case NamingAlias:
+		return "", &errMissingAlias{GenericType: generic, SpecificType: arg.Type}
+	}
+	return arg.String(), nil
+}
+
+// isIdentifier tells whether s is a Go identifier.
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !isAlphaNumeric(r) || i == 0 && unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -5,19 +5,20 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/build/constraint"
 	"go/parser"
 	"go/printer"
 	"go/scanner"
 	"go/token"
 	"io"
+	"io/ioutil"
 	"os"
-	"reflect"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 
 	"golang.org/x/tools/go/ast/astutil"
-	"golang.org/x/tools/imports"
 )
 
 var header = `// Code generated by genny. DO NOT EDIT.
@@ -37,9 +38,9 @@ var (
 	openBrace      = []byte("(")
 	closeBrace     = []byte(")")
 	genericPackage = "generic"
-	genericType    = "generic.Type"
-	genericNumber  = "generic.Number"
-	linefeed       = "\r\n"
+	// genericTypeNames are the placeholder types of the generic package
+	genericTypeNames = []string{"Type", "Number", "Ordered", "Integer", "Unsigned", "Float"}
+	linefeed         = "\r\n"
 )
 var unwantedLinePrefixes = [][]byte{
 	[]byte("//go:generate genny "),
@@ -49,7 +50,7 @@ var unwantedLinePrefixes = [][]byte{
 func subIntoLiteral(lit, typeTemplate, specificType string) string {
 	// print("l >> %s ... tt >> %s", lit, typeTemplate)
 	if lit == typeTemplate {
-		return specificType
+		return typify(specificType)
 	}
 	if !containsFold(lit, typeTemplate) {
 		return lit
@@ -64,7 +65,7 @@ func subIntoLiteral(lit, typeTemplate, specificType string) string {
 	}
 	// result := lit //replaceBoundary(lit, typeTemplate, specificType)
 	typeregex := regexp.MustCompile("\\b" + typeTemplate + "\\b")
-	result := typeregex.ReplaceAllString(lit, specificType)
+	result := typeregex.ReplaceAllString(lit, typify(specificType))
 	result = strings.Replace(result, typeTemplate, replacer, -1)
 	if strings.HasPrefix(result, specificLg) && !isExported(lit) {
 		result = strings.Replace(result, specificLg, specificSm, 1)
@@ -176,7 +177,7 @@ func generateSpecific(filename string, in io.ReadSeeker, typeSet map[string]stri
 		}
 
 		// does this line contain generic.Type?
-		if strings.Contains(line, genericType) || strings.Contains(line, genericNumber) {
+		if containsGenericType(line) {
 			comment = ""
 			if len(interfaceLines) > 0 {
 				interfaceContainsType = true
@@ -222,143 +223,541 @@ func generateSpecific(filename string, in io.ReadSeeker, typeSet map[string]stri
 	return buf.Bytes(), nil
 }
 
+// Options controls how Generics generates code.
+type Options struct {
+	// PkgName is the package name for the generated code. The package name
+	// of the template is kept if empty.
+	PkgName string
+	// ImportPaths are imports explicitly added to the generated code.
+	ImportPaths []string
+	// StripTags are build tags that are stripped from the output.
+	StripTags []string
+	// Mode is how the templates are generated, a copy for every type set
+	// by default. ModeTypeParams converts them to type parameters instead,
+	// which takes no type sets.
+	Mode Mode
+	// UseAst selects the AST based implementation, which substitutes the
+	// types into the identifiers of the parsed template rather than into
+	// the words of its lines, so that code the lines would mangle, like
+	// pointer types in composite literals or the fields that are named
+	// after the generic type, comes out right.
+	UseAst bool
+	// Preprocess runs each template as a text/template with the type set
+	// as its data before the types are substituted. The actions are
+	// written between /*{{ and }}*/.
+	Preprocess bool
+	// Values are named values of the specific types, keyed by the type,
+	// without its title, and then the name, for the code that differs by
+	// more than the type, like a format verb. A preprocessed template gets
+	// those of a type with the values function, like
+	// /*{{(values .Elem).verb}}*/.
+	Values map[string]map[string]string
+	// Naming is the default policy for turning qualified specific types
+	// into words. It can be overridden per type with a "#policy" suffix.
+	Naming NamingPolicy
+	// Namer, if it is set, names the specific types instead of the naming
+	// policy.
+	Namer Namer
+	// KeepConstraints preserves the build constraints of the template, other
+	// than StripTags, as a single //go:build line atop the generated code.
+	KeepConstraints bool
+	// BuildConstraint is an expression like "linux && amd64" that restricts
+	// the generated code to some platforms. It is written atop the code as a
+	// //go:build line, combined with the constraints of the template as if
+	// KeepConstraints was set.
+	BuildConstraint string
+	// SubstitutePackageDoc substitutes the first type set into the package
+	// doc comment of the template, instead of copying it verbatim.
+	SubstitutePackageDoc bool
+	// SortDecls controls the order of the generated declarations.
+	SortDecls DeclOrder
+	// LocalPrefixes are import path prefixes of the packages that are
+	// grouped after the third party packages, like goimports -local.
+	LocalPrefixes []string
+	// Limits guard against pathological inputs.
+	Limits Limits
+	// Stats collects statistics of the code generation, if it is set.
+	Stats *Stats
+	// Transforms are custom stages of the code generation, which run in
+	// turn after the stage they are keyed by.
+	Transforms map[Stage][]Transformer
+	// DebugDir is a directory to write the intermediate artifacts of
+	// GenericsTemplates to, if it is set: the code of every type set before
+	// it is merged, the collected imports and the merged code before
+	// goimports.
+	DebugDir string
+	// StampDir is the directory of the generated file, if it is written to
+	// one. The templates, relative to it, and a hash of them are then
+	// recorded under the header, for genny-vet to tell whether the file is
+	// out of date with them.
+	StampDir string
+	// SkipExistingDir is the directory of the package that the code is
+	// generated into, to leave out the declarations that the package has
+	// already, like hand-written versions for some of the types, if it is
+	// set. The test files and the files generated by genny don't count.
+	SkipExistingDir string
+	// Renames are the names that the generated declarations get instead of
+	// those genny gives them, like IntSlice for ListInt, to fit the naming
+	// of the code they are added to. Every identifier and every word of the
+	// comments of the generated code is renamed.
+	Renames map[string]string
+	// Export controls which kinds of generated declarations are exported,
+	// instead of the case of the generic types in the template. The
+	// registry and the dispatchers refer to the names without it.
+	Export ExportPolicy
+	// TestedImport is the import path of the package of the templates, for
+	// code generated into its external test package, when PkgName is the
+	// name of the package with _test, like github.com/me/queue for
+	// queue_test. The exported identifiers that the generated code refers
+	// to but doesn't declare are qualified with the package.
+	TestedImport string
+	// Command is the command that generates the code again, which is
+	// recorded under the header if it is set.
+	Command string
+	// Raw substitutes the type sets into the templates as text, for the
+	// files that go along with the Go code, like SQL schemas, Markdown or
+	// protos: the generic types are replaced in its words like in Go code,
+	// and it isn't parsed, formatted or given a header or imports. The text
+	// of every template for every type set follows one another.
+	Raw bool
+	// Annotate marks every generated declaration with the template, line
+	// and type set it was generated from, in a line at the end of its doc
+	// comment like // genny: list.go:42 Elem=int, for the reviewers of the
+	// generated code to trace it back without a source map.
+	Annotate bool
+	// Cache caches the code generated by GenericsTemplates by a hash of the
+	// templates, type sets and options it was generated from, so that it is
+	// read from there when they are the same again. The code isn't cached
+	// with a Namer, Transforms or a DebugDir.
+	Cache Cache
+	// CacheDir is a directory to cache the code in when Cache is nil, which
+	// runs of genny may share.
+	CacheDir string
+}
+
 // Generics parses the source file and generates the bytes replacing the
 // generic types for the keys map with the specific types (its value).
-func Generics(filename, pkgName string, in io.ReadSeeker, typeSets []map[string]string, importPaths []string, stripTag string, useAstImpl bool) ([]byte, error) {
-	localUnwantedLinePrefixes := [][]byte{}
-	localUnwantedLinePrefixes = append(localUnwantedLinePrefixes, unwantedLinePrefixes...)
-
+func Generics(filename, pkgName string, in io.Reader, typeSets []map[string]string, importPaths []string, stripTag string, useAstImpl bool) ([]byte, error) {
+	opts := Options{
+		PkgName:     pkgName,
+		ImportPaths: importPaths,
+		UseAst:      useAstImpl,
+	}
 	if stripTag != "" {
-		localUnwantedLinePrefixes = append(localUnwantedLinePrefixes, []byte(fmt.Sprintf("// +build %s", stripTag)))
+		opts.StripTags = []string{stripTag}
+	}
+	return GenericsWithOptions(filename, in, typeSets, opts)
+}
+
+// GenericsWithOptions is like Generics, but takes its settings from opts.
+func GenericsWithOptions(filename string, in io.Reader, typeSets []map[string]string, opts Options) ([]byte, error) {
+	return GenericsTemplates([]Template{{Filename: filename, Source: in}}, typeSets, opts)
+}
+
+// Template is a source file containing generic code.
+type Template struct {
+	// Filename is the name of the template, used in errors and to resolve
+	// imports.
+	Filename string
+	// Source is the content of the template. It is read once for each
+	// generation, from its start if it is an io.Seeker, so that a template
+	// whose source can seek can be generated more than once.
+	Source io.Reader
+}
+
+// readSource reads the whole source of a template.
+func readSource(template Template) ([]byte, error) {
+	if seeker, ok := template.Source.(io.Seeker); ok {
+		seeker.Seek(0, io.SeekStart)
 	}
+	src, err := ioutil.ReadAll(template.Source)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	return src, nil
+}
 
-	totalOutput := [][]byte{}
+// generatedFile is the code generated from one template for one type set.
+type generatedFile struct {
+	template int
+	typeSet  int
+	code     []byte
+}
 
-	for _, typeSet := range typeSets {
+// generation holds what is needed to generate the code of the templates
+// for every type set.
+type generation struct {
+	templates []Template
+	// sources are those of the templates, with their includes and bases
+	// resolved
+	sources [][]byte
+	// argTypeSets are the type sets as they were given, for errors
+	argTypeSets []map[string]string
+	// defaultTypes are the specific types of the generic types that the
+	// type sets may leave out, declared with genny:default
+	defaultTypes map[string]string
+	// typeSets have the imports and the naming policies resolved, and
+	// funcs are the functions given with them, by generic type
+	typeSets       []map[string]string
+	funcs          []map[string][]string
+	importSpecs    []importSpec
+	keptConstraint constraint.Expr
+	pkgDoc         []string
+	// stamp is the line under the header that records the templates, if
+	// opts.StampDir is set
+	stamp string
+	// testedPkg is the package of the templates if the code is generated
+	// into its external test package
+	testedPkg string
+	// existing are the declarations of the package of the generated code,
+	// if opts.SkipExistingDir is set
+	existing map[string]bool
+	// preprocessors are those of the templates, if opts.Preprocess is set
+	preprocessors []*preprocessor
+	// mapKeys are the generic types that each template uses in map keys,
+	// with the line of their first use
+	mapKeys []map[string]int
+	// templateDecls are the declarations of each template and of those it
+	// includes or derives from, if opts.Annotate is set
+	templateDecls [][]sourceDecl
+	opts          Options
+}
 
-		// generate the specifics
-		var parsed []byte
+func newGeneration(templates []Template, typeSets []map[string]string, opts Options) (*generation, error) {
+	if len(templates) == 0 {
+		return nil, errNoTemplates
+	}
+	if err := opts.Limits.checkInstantiations(len(templates) * len(typeSets)); err != nil {
+		return nil, err
+	}
+
+	var buildConstraint constraint.Expr
+	if opts.BuildConstraint != "" {
 		var err error
-		if useAstImpl {
-			parsed, err = generateSpecificAst(filename, in, typeSet)
-		} else {
-			parsed, err = generateSpecific(filename, in, typeSet)
+		buildConstraint, err = constraint.Parse("//go:build " + opts.BuildConstraint)
+		if err != nil {
+			return nil, &errBadConstraint{Constraint: opts.BuildConstraint, Err: err}
 		}
+		opts.KeepConstraints = true
+	}
+
+	if err := checkRenames(opts.Renames); err != nil {
+		return nil, err
+	}
+
+	g := &generation{templates: templates, argTypeSets: typeSets, defaultTypes: make(map[string]string), opts: opts}
+	opts.Stats.addTemplates(len(templates), len(typeSets))
+	var pkgName string
+	var resolved [][]byte
+	for _, template := range templates {
+		src, err := opts.Limits.readSource(template)
+		if err != nil {
+			return nil, err
+		}
+		// the code is generated from the normalized source from here on
+		src = normalizeEOL(src)
+		included, err := resolveTemplate(template.Filename, src)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, included)
+		defaults, err := defaultTypes(template.Filename, included)
+		if err != nil {
+			return nil, err
+		}
+		for generic, specific := range defaults {
+			// the first template to declare a default has it
+			if _, ok := g.defaultTypes[generic]; !ok {
+				g.defaultTypes[generic] = specific
+			}
+		}
+		included = dropDefaults(included)
+		included, err = opts.transform(StageRead, included, template.Filename, nil)
 		if err != nil {
 			return nil, err
 		}
+		g.sources = append(g.sources, included)
+		g.mapKeys = append(g.mapKeys, mapKeyGenerics(template.Filename, included))
+		if opts.Annotate {
+			decls, err := templateSourceDecls(template.Filename, src)
+			if err != nil {
+				return nil, err
+			}
+			g.templateDecls = append(g.templateDecls, decls)
+		}
+		if opts.Preprocess {
+			p, err := newPreprocessor(template.Filename, included, opts.Values)
+			if err != nil {
+				return nil, err
+			}
+			g.preprocessors = append(g.preprocessors, p)
+		}
 
-		totalOutput = append(totalOutput, parsed)
+		if opts.KeepConstraints {
+			expr, err := templateConstraint(src, opts.StripTags)
+			if err != nil {
+				return nil, err
+			}
+			if expr != nil {
+				g.keptConstraint = andExpr(g.keptConstraint, expr)
+			}
+		}
+		if g.pkgDoc == nil {
+			g.pkgDoc, err = packageDoc(template.Filename, src)
+			if err != nil {
+				return nil, err
+			}
+		}
+		name, err := packageName(template.Filename, src)
+		if err != nil {
+			return nil, err
+		}
+		if pkgName == "" {
+			pkgName = name
+		} else if name != pkgName && opts.PkgName == "" {
+			return nil, &errPackageMismatch{Filename: template.Filename, Package: name, Expected: pkgName}
+		}
 	}
 
-	// clean up the code line by line
+	if buildConstraint != nil {
+		g.keptConstraint = andExpr(g.keptConstraint, buildConstraint)
+	}
+	if opts.StampDir != "" {
+		g.stamp = stampLine(opts.StampDir, templates, resolved)
+	}
+	if opts.SkipExistingDir != "" {
+		existing, err := existingDecls(opts.SkipExistingDir)
+		if err != nil {
+			return nil, err
+		}
+		g.existing = existing
+	}
 
-	packageFound := false
-	// Whether to wait for the "genny:start" comment to start copying. This will be set to true
-	// after we have went through the first generated type, so subsequent generated types will
-	// not copy anything before that line
-	fileHasGennyStart := false
-	importLineIndex := -1
-	var collectedImports stringArraySet
-	cleanOutputLines := []string{header}
-	for fileIndex, transformedOutput := range totalOutput {
-		insideImportBlock := false
-		packageFoundForFile := false
-		scanner := bufio.NewScanner(bytes.NewReader(transformedOutput))
-		pastGennyStart := false
-
-	FORSCAN:
-		for scanner.Scan() {
-
-			if bytes.HasPrefix(scanner.Bytes(), []byte("//genny:start")) {
-				pastGennyStart = true
-				fileHasGennyStart = true
-				continue
-			}
-
-			// end of imports block?
-			if insideImportBlock {
-				if bytes.HasSuffix(scanner.Bytes(), closeBrace) {
-					insideImportBlock = false
-					// cleanOutputLines = append(cleanOutputLines, fmt.Sprintln(")"))
-				} else {
-					collectedImports = collectedImports.append(makeLine(scanner.Text()))
-					// cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
-				}
-				continue
-			}
+	specs := importPaths(opts.ImportPaths)
+	g.testedPkg = testedPackage(pkgName, opts)
+	if g.testedPkg != "" && opts.TestedImport != "" {
+		specs = append(specs, testedImport(opts.TestedImport, g.testedPkg))
+	}
+	if err := g.setTypeSets(typeSets, specs); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
 
-			if bytes.HasPrefix(scanner.Bytes(), packageKeyword) {
-				packageFoundForFile = true
-				if !packageFound {
-					packageFound = true
-					cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
-				}
-				continue
-			} else if bytes.HasPrefix(scanner.Bytes(), importKeyword) {
-				if importLineIndex == -1 {
-					importLineIndex = len(cleanOutputLines)
-				}
-				if bytes.HasSuffix(scanner.Bytes(), openBrace) {
-					insideImportBlock = true
-					// cleanOutputLines = append(cleanOutputLines, fmt.Sprintln("import ("))
-				} else {
-					importLine := strings.TrimSpace(makeLine(scanner.Text()))
-					importLine = strings.TrimSpace(importLine[6:])
-					collectedImports = collectedImports.append(importLine)
-					// cleanOutputLines = append(cleanOutputLines, importLine)
-				}
+// setTypeSets sets the type sets to generate the code for, resolving their
+// imports after the imports in specs and their naming policies.
+func (g *generation) setTypeSets(typeSets []map[string]string, specs []importSpec) error {
+	var err error
+	typeSets = withDefaultTypes(typeSets, g.defaultTypes)
+	g.argTypeSets = typeSets
+	typeSets, g.funcs = splitFuncs(typeSets)
+	g.typeSets, g.importSpecs, err = qualifyTypeSets(typeSets, specs)
+	if err != nil {
+		return err
+	}
+	g.typeSets, err = applyNaming(g.typeSets, g.opts.Naming, g.opts.Namer)
+	if err != nil {
+		return err
+	}
+	return g.checkMapKeys()
+}
 
-				continue
-			}
+// each generates the code of every template for every type set in turn and
+// passes it to f, with the declarations of earlier type sets removed.
+func (g *generation) each(f func(generatedFile) error) error {
+	return g.eachDeduped(newDeclDeduper(g.argTypeSets), f)
+}
 
-			if fileIndex != 0 && !packageFoundForFile {
-				continue
+// eachDeduped is like each, but removes the declarations that dedupe has
+// seen before, from earlier type sets or earlier calls.
+func (g *generation) eachDeduped(dedupe *declDeduper, f func(generatedFile) error) error {
+	dedupe.typeSets = g.argTypeSets
+	dedupe.existing = g.existing
+	size := 0
+	for templateIndex, template := range g.templates {
+		for typeSetIndex, typeSet := range g.typeSets {
+
+			src := g.sources[templateIndex]
+			if g.preprocessors != nil {
+				code, err := g.preprocessors[templateIndex].run(typeSet)
+				if err != nil {
+					return err
+				}
+				src = code
 			}
-
-			if fileHasGennyStart && !pastGennyStart {
-				continue
+			src, err := expandPlaceholders(template.Filename, src, typeSet, g.funcs[typeSetIndex])
+			if err != nil {
+				return err
 			}
-
-			// check all unwantedLinePrefixes - and skip them
-			for _, prefix := range localUnwantedLinePrefixes {
-				if bytes.HasPrefix(scanner.Bytes(), prefix) {
-					continue FORSCAN
+			source := bytes.NewReader(src)
+
+			// generate the specifics
+			var parsed []byte
+			if g.opts.UseAst {
+				parsed, err = generateSpecificAst(template.Filename, source, typeSet)
+			} else {
+				parsed, err = generateSpecific(template.Filename, source, typeSet)
+			}
+			if err != nil {
+				return err
+			}
+			if err := checkLeftovers(template.Filename, parsed, typeSet, typeSetClause(g.argTypeSets[typeSetIndex])); err != nil {
+				return err
+			}
+			parsed, err = g.opts.transform(StageSubstitute, parsed, template.Filename, g.argTypeSets[typeSetIndex])
+			if err != nil {
+				return err
+			}
+			parsed = renameSymbols(parsed, symbolRenames(parsed, g.opts.Export, g.opts.Renames))
+			if g.testedPkg != "" {
+				var qualified bool
+				parsed, qualified = qualifyTested(parsed, g.testedPkg)
+				if qualified && g.opts.TestedImport == "" {
+					return &errNoTestedImport{Package: g.testedPkg}
 				}
 			}
+			size += len(parsed)
+			if err := g.opts.Limits.checkOutputSize(size); err != nil {
+				return err
+			}
 
-			cleanOutputLines = append(cleanOutputLines, makeLine(scanner.Text()))
+			file, err := dedupe.dedupe(generatedFile{template: templateIndex, typeSet: typeSetIndex, code: parsed})
+			if err != nil {
+				return err
+			}
+			if g.opts.Annotate {
+				file.code = annotate(template.Filename, file.code, g.templateDecls[templateIndex], typeSet, typeSetClause(g.argTypeSets[typeSetIndex]))
+			}
+			if err := f(file); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	var linesWithImport []string
-	linesWithImport = append(linesWithImport, cleanOutputLines[:importLineIndex]...)
-	linesWithImport = append(linesWithImport, fmt.Sprintln("import ("))
-	linesWithImport = append(linesWithImport, collectedImports...)
-	linesWithImport = append(linesWithImport, fmt.Sprintln(")"))
-	linesWithImport = append(linesWithImport, cleanOutputLines[importLineIndex+1:]...)
+// GenericsTemplates generates the code for every type set from each of the
+// templates in turn, and merges it all into a single file with one package
+// clause and one import block. The templates must all be in the same
+// package, unless opts.PkgName is given.
+func GenericsTemplates(templates []Template, typeSets []map[string]string, opts Options) ([]byte, error) {
+	if len(templates) == 0 {
+		return nil, errNoTemplates
+	}
+	cache := opts.Cache
+	if cache == nil && opts.CacheDir != "" {
+		cache = DirCache(opts.CacheDir)
+	}
+	if cache == nil {
+		return genericsTemplates(templates, typeSets, opts)
+	}
+	key, templates, err := cachedKey(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return genericsTemplates(templates, typeSets, opts)
+	}
+	if output, ok := cache.Get(key); ok {
+		opts.Stats.addTemplates(len(templates), len(typeSets))
+		opts.Stats.addFile()
+		opts.Stats.addOutput(output)
+		return output, nil
+	}
+	output, err := genericsTemplates(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(key, output)
+	return output, nil
+}
 
-	cleanOutput := strings.Join(linesWithImport, "")
+// genericsTemplates is GenericsTemplates without the cache.
+func genericsTemplates(templates []Template, typeSets []map[string]string, opts Options) ([]byte, error) {
+	if opts.Raw {
+		return genericsRaw(templates, typeSets, opts)
+	}
+	if opts.Mode == ModeTypeParams {
+		return genericsTypeParams(templates, opts)
+	}
+	start := time.Now()
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Stats.phase("read", start)
 
-	output := []byte(cleanOutput)
+	// clean up the code line by line
+	start = time.Now()
+	if err := opts.debugTypeSetList(typeSets); err != nil {
+		return nil, err
+	}
+	merger := newCodeMerger(g)
+	err = g.each(func(file generatedFile) error {
+		merger.add(file)
+		return opts.debugSubstituted(g, file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	importDecls := merger.importDecls(g.importSpecs)
+	if err := opts.debugWrite(debugImports, []byte(strings.Join(importDecls, ""))); err != nil {
+		return nil, err
+	}
+	output := merger.output(importDecls)
+	output, err = opts.transform(StageMerge, output, templates[0].Filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	opts.Stats.phase("generate", start)
 
 	// change package name
-	if pkgName != "" {
-		output = changePackage(bytes.NewReader([]byte(output)), pkgName)
+	start = time.Now()
+	if opts.PkgName != "" {
+		output = changePackage(bytes.NewReader([]byte(output)), opts.PkgName)
+	}
+	output, err = opts.transform(StagePackage, output, templates[0].Filename, nil)
+	if err != nil {
+		return nil, err
 	}
-	if len(importPaths) > 0 {
-		output = addImports(bytes.NewReader(output), importPaths)
+	if err := opts.debugWrite(debugMerged, output); err != nil {
+		return nil, err
 	}
 	// fix the imports
-	var err error
-	output, err = imports.Process(filename, output, nil)
+	output, err = formatOutput(templates[0].Filename, output)
 	if err != nil {
-		return nil, &errImports{Err: err}
+		return nil, err
 	}
+	opts.Stats.phase("format", start)
 
+	if opts.SortDecls != DeclOrderNone {
+		start = time.Now()
+		output, err = sortDecls(templates[0].Filename, output, opts.SortDecls)
+		if err != nil {
+			return nil, err
+		}
+		opts.Stats.phase("sort", start)
+	}
+	output, err = opts.transform(StageFormat, output, templates[0].Filename, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Stats.addFile()
+	opts.Stats.addOutput(output)
 	return output, nil
 }
 
+// normalizeEOL turns the \r\n and \r line endings of src into \n, so that
+// templates with Windows line endings are generated the same.
+func normalizeEOL(src []byte) []byte {
+	if bytes.IndexByte(src, '\r') < 0 {
+		return src
+	}
+	src = bytes.Replace(src, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(src, []byte("\r"), []byte("\n"), -1)
+}
+
 func makeLine(s string) string {
 	return fmt.Sprintln(strings.TrimRight(s, linefeed))
 }
@@ -398,37 +797,16 @@ func changePackage(r io.Reader, pkgName string) []byte {
 	var out bytes.Buffer
 	sc := bufio.NewScanner(r)
 	done := false
+	var packageClause packageClauseFinder
 
 	for sc.Scan() {
 		s := sc.Text()
 
-		if !done && strings.HasPrefix(s, "package") {
-			parts := strings.Split(s, " ")
-			parts[1] = pkgName
-			s = strings.Join(parts, " ")
-			done = true
-		}
-
-		fmt.Fprintln(&out, s)
-	}
-	return out.Bytes()
-}
-
-func addImports(r io.Reader, importPaths []string) []byte {
-	var out bytes.Buffer
-	sc := bufio.NewScanner(r)
-	done := false
-
-	for sc.Scan() {
-		s := sc.Text()
-
-		if !done && strings.HasPrefix(s, "package") {
-			fmt.Fprintln(&out, s)
-			for _, imp := range importPaths {
-				fmt.Fprintf(&out, "import \"%s\"\n", imp)
+		if !done {
+			if offset := packageClause.find(s); offset >= 0 {
+				s = renamePackage(s, offset, pkgName)
+				done = true
 			}
-			done = true
-			continue
 		}
 
 		fmt.Fprintln(&out, s)
@@ -475,32 +853,83 @@ func deleteComment(file *ast.File, comment *ast.CommentGroup) {
 
 func transformText(text string, spec replaceSpec) string {
 	reExact := regexp.MustCompile("\\b" + spec.genericType + "\\b")
-	text = reExact.ReplaceAllString(text, spec.specificType)
+	text = reExact.ReplaceAllString(text, spec.toType())
 	return replaceBoundaryFunc(text, spec.genericType, func(match string) string {
 		return spec.toWord(unicode.IsUpper(rune(match[0])))
 	})
 }
 
-func transformType(ident *ast.Ident, spec replaceSpec, log string) *ast.Ident {
-	if ident.Name != spec.genericType {
-		// If they are not identical, the type is something like genericQueue. Perform normal text
-		// transformation
-		return transformIdentifier(ident, spec, log)
-	}
-	output := *ident
-	output.Name = spec.toType()
-	return &output
-}
-
-func transformIdentifier(ident *ast.Ident, spec replaceSpec, log string) *ast.Ident {
-	transformed := transformText(ident.Name, spec)
-
+// transformIdent substitutes the specific type of the spec into an
+// identifier of the template. The identifier is the name of a field or a
+// method if name is set, which the generic type itself gives the word of
+// the specific type to, rather than the type.
+func transformIdent(ident *ast.Ident, spec replaceSpec, name bool) *ast.Ident {
 	output := *ident
-	output.Name = transformed
+	if name && ident.Name == spec.genericType {
+		output.Name = spec.toWord(isExported(ident.Name))
+	} else {
+		output.Name = transformText(ident.Name, spec)
+	}
 	return &output
 }
 
+// generateSpecificType substitutes the specific type of the spec into the
+// identifiers and comments of the file by their place in its syntax tree,
+// rather than their place on a line, so an identifier is substituted the
+// same wherever it is, like in a selector, a composite literal or a line of
+// several statements. The names of the package, of the imported packages
+// and of what is selected from them, and the labels, are left as they are.
 func generateSpecificType(fs *token.FileSet, file *ast.File, spec replaceSpec) {
+	keep := map[*ast.Ident]bool{file.Name: true}
+	names := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.ImportSpec:
+			if v.Name != nil {
+				keep[v.Name] = true
+			}
+		case *ast.SelectorExpr:
+			if x, ok := v.X.(*ast.Ident); ok && x.Obj == nil && isPackageName(file, x.Name) {
+				keep[x], keep[v.Sel] = true, true
+			} else {
+				// a field or a method
+				names[v.Sel] = true
+			}
+		case *ast.BranchStmt:
+			if v.Label != nil {
+				keep[v.Label] = true
+			}
+		case *ast.LabeledStmt:
+			keep[v.Label] = true
+		case *ast.StructType:
+			for _, field := range v.Fields.List {
+				for _, name := range field.Names {
+					names[name] = true
+				}
+			}
+		case *ast.InterfaceType:
+			for _, method := range v.Methods.List {
+				for _, name := range method.Names {
+					names[name] = true
+				}
+			}
+		case *ast.FuncDecl:
+			if v.Recv != nil {
+				names[v.Name] = true
+			}
+		case *ast.CompositeLit:
+			for _, elt := range v.Elts {
+				// the keys that are identifiers are those of the fields
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						names[key] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
 	astutil.Apply(file,
 		func(c *astutil.Cursor) bool {
 			switch v := c.Node().(type) {
@@ -512,106 +941,14 @@ func generateSpecificType(fs *token.FileSet, file *ast.File, spec replaceSpec) {
 					}
 				}
 			case *ast.Ident:
-				var newIdent *ast.Ident
-				if containsFold(v.Name, spec.genericType) {
-					// print("    >>>>>", v.Name, spec, reflect.TypeOf(c.Parent()))
-					switch p := c.Parent().(type) {
-					case *ast.ArrayType:
-						// []generic
-						// []genericValue
-						newIdent = transformType(v, spec, "ARRAY TYPE")
-					case *ast.ValueSpec:
-						if v == p.Type {
-							// var something generic
-							// var something genericValue
-							newIdent = transformType(v, spec, "VALUE TYPE")
-						} else {
-							// var generic string
-							// var genericVariable string
-							newIdent = transformIdentifier(v, spec, "VARNAME")
-						}
-					case *ast.AssignStmt:
-						// myGeneric := something
-						// something := myGeneric
-						newIdent = transformType(v, spec, "ASSIGN")
-					case *ast.CallExpr:
-						if v == p.Fun {
-							// generic(something), a.k.a. type conversion
-							// genericSomething(something)
-							newIdent = transformType(v, spec, "TYPE CONVERSION")
-						} else {
-							// myfunc(generic)
-							// myfunc(genericVariable)
-							newIdent = transformIdentifier(v, spec, "ARG VARNAME")
-						}
-					case *ast.TypeSpec:
-						if v == p.Name {
-							// type generic someType
-							// type genericValue someType
-							newIdent = transformIdentifier(v, spec, "TYPE NAME")
-						} else {
-							// type newType generic
-							// type newType genericSomething
-							newIdent = transformType(v, spec, "TYPE VALUE")
-						}
-					case *ast.Field:
-						if v == p.Type {
-							// func a(g generic) or func a(g genericValue)
-							newIdent = transformType(v, spec, "FIELD TYPE")
-						} else {
-							// func a(genericSomething someType)
-							newIdent = transformIdentifier(v, spec, "ARG DECL NAME")
-						}
-					case *ast.FuncDecl:
-						// func PrintGeneric()
-						newIdent = transformIdentifier(v, spec, "FUNC NAME")
-					case *ast.SelectorExpr:
-						// a.PrintMyType()
-						newIdent = transformIdentifier(v, spec, "SELECTOR")
-					case *ast.StarExpr:
-						// *generic or *somethingGeneric
-						newIdent = transformType(v, spec, "STAR EXPR")
-					case *ast.CompositeLit:
-						if v == p.Type {
-							// myGen := generic{field1: 1, field2: 2}
-							newIdent = transformType(v, spec, "COMPOSITE LITERAL")
-						}
-					case *ast.BinaryExpr:
-						// myGeneric == something
-						newIdent = transformType(v, spec, "BINARY")
-					case *ast.MapType:
-						// var m map[generic]myvalue
-						// var m map[mykey]generic
-						newIdent = transformType(v, spec, "MAP TYPE")
-					case *ast.KeyValueExpr:
-						// MyStruct{ field: genericVal }
-						// MyStruct{ genericVal: field }
-						newIdent = transformType(v, spec, "KEY VALUE EXPR")
-					case *ast.BranchStmt:
-						// ignore
-					case *ast.TypeAssertExpr:
-						// a.(generic)
-						newIdent = transformType(v, spec, "TYPE ASSERT EXPR")
-					case *ast.File:
-						if debug {
-							print("UNRESOLVED???", v.Name, spec, reflect.TypeOf(c.Parent()))
-						}
-					default:
-						if debug {
-							print(">>>>>", v.Name, spec, reflect.TypeOf(c.Parent()))
-						}
-					}
-				}
-				if newIdent != nil {
-					c.Replace(newIdent)
+				if !keep[v] && containsFold(v.Name, spec.genericType) {
+					c.Replace(transformIdent(v, spec, names[v]))
 				}
 			case *ast.TypeSpec:
 				if isGenericTypeDefinition(v) {
 					deleteAllComments(file, v)
 					c.Delete()
 				}
-			default:
-				print("UNKNOWN:", v, reflect.TypeOf(v))
 			}
 			return true
 		},
@@ -648,9 +985,21 @@ func isGenericTypeDefinition(typeSpec *ast.TypeSpec) bool {
 }
 
 func isGenericTypeSelector(selector *ast.SelectorExpr) bool {
-	if ident, ok := selector.X.(*ast.Ident); ok {
-		if ident.Name == "generic" &&
-			(selector.Sel.Name == "Type" || selector.Sel.Name == "Number") {
+	if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == genericPackage {
+		for _, name := range genericTypeNames {
+			if selector.Sel.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsGenericType tells whether the line has one of the placeholder
+// types, like generic.Type.
+func containsGenericType(line string) bool {
+	for _, name := range genericTypeNames {
+		if strings.Contains(line, genericPackage+"."+name) {
 			return true
 		}
 	}
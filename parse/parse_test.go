@@ -1,11 +1,20 @@
 package parse_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/mauricelam/genny/parse"
 	"github.com/stretchr/testify/assert"
@@ -16,9 +25,20 @@ var tests = []struct {
 	filename string
 	pkgName  string
 	in       string
+	moreIn   []string
 	tag      string
+	tags     []string
 	imports  []string
 	types    []map[string]string
+	naming   parse.NamingPolicy
+	keep     bool
+	platform string
+	substDoc bool
+	sortDecl parse.DeclOrder
+	local    []string
+	preproc  bool
+	values   map[string]map[string]string
+	tested   string
 
 	// expectations
 	expectedOut string
@@ -85,6 +105,12 @@ var tests = []struct {
 		types:       []map[string]string{{"NumberType": "int"}},
 		expectedOut: `test/numbers/int_number.go`,
 	},
+	{
+		filename:    "generic_ordered.go",
+		in:          `test/ordered/generic_ordered.go`,
+		types:       []map[string]string{{"OrderedType": "string"}},
+		expectedOut: `test/ordered/string_ordered.go`,
+	},
 	{
 		filename:    "generic_digraph.go",
 		in:          `test/bugreports/generic_digraph.go`,
@@ -121,6 +147,69 @@ var tests = []struct {
 		expectedOut: `test/buildtags/buildtags_expected_multiple.go`,
 		tag:         "genny",
 	},
+	{
+		filename: "constraints.go",
+		in:       `test/buildtags/constraints.go`,
+		types: []map[string]string{
+			{"_t_": "int"},
+			{"_t_": "string"},
+		},
+		expectedOut: `test/buildtags/constraints_expected.go`,
+		tag:         "genny",
+		keep:        true,
+	},
+	{
+		filename:    "multiple_tags.go",
+		in:          `test/buildtags/multiple_tags.go`,
+		types:       []map[string]string{{"_t_": "int"}},
+		expectedOut: `test/buildtags/multiple_tags_expected.go`,
+		tags:        []string{"genny", "ignore"},
+	},
+	{
+		filename:    "paired.go",
+		in:          `test/buildtags/paired.go`,
+		types:       []map[string]string{{"_t_": "int"}},
+		expectedOut: `test/buildtags/paired_expected.go`,
+		tag:         "genny",
+	},
+	{
+		filename: "pkgdoc.go",
+		in:       `test/pkgdoc/pkgdoc.go`,
+		types: []map[string]string{
+			{"Thing": "int"},
+			{"Thing": "string"},
+		},
+		expectedOut: `test/pkgdoc/pkgdoc_expected.go`,
+	},
+	{
+		filename:    "pkgdoc.go",
+		in:          `test/pkgdoc/pkgdoc.go`,
+		types:       []map[string]string{{"Thing": "float64"}},
+		expectedOut: `test/pkgdoc/pkgdoc_substituted.go`,
+		substDoc:    true,
+	},
+	{
+		filename: "preamble.go",
+		pkgName:  "renamed",
+		in:       `test/preamble/preamble.go`,
+		types: []map[string]string{
+			{"Thing": "int"},
+			{"Thing": "string"},
+		},
+		expectedOut: `test/preamble/preamble_expected.go`,
+		tag:         "genny",
+	},
+	{
+		filename: "list.go",
+		in:       `test/merge/list.go`,
+		moreIn:   []string{`test/merge/set.go`},
+		types: []map[string]string{
+			{"Elem": "int"},
+			{"Elem": "string"},
+		},
+		expectedOut: `test/merge/merged_expected.go`,
+		tag:         "genny",
+	},
 	{
 		filename:    "join.go",
 		in:          `test/interfaces/join.go`,
@@ -182,6 +271,189 @@ var tests = []struct {
 		},
 		expectedOut: `test/bugreports/receiver_expected.go`,
 	},
+	{
+		filename:    "naming.go",
+		in:          `test/naming/naming.go`,
+		types:       []map[string]string{{"Elem": "time.Duration"}},
+		expectedOut: `test/naming/naming_package.go`,
+	},
+	{
+		filename:    "naming.go",
+		in:          `test/naming/naming.go`,
+		types:       []map[string]string{{"Elem": "time.Duration"}},
+		naming:      parse.NamingType,
+		expectedOut: `test/naming/naming_type.go`,
+	},
+	{
+		filename:    "naming.go",
+		in:          `test/naming/naming.go`,
+		types:       []map[string]string{{"Elem": "time.Duration#type"}},
+		naming:      parse.NamingAlias,
+		expectedOut: `test/naming/naming_type.go`,
+	},
+	{
+		filename: "holder.go",
+		in:       `test/aliases/holder.go`,
+		imports: []string{
+			"github.com/mauricelam/genny/parse/test/aliases/one/util",
+			"github.com/mauricelam/genny/parse/test/aliases/two/util",
+		},
+		types: []map[string]string{
+			{"Elem": "github.com/mauricelam/genny/parse/test/aliases/one/util.Value"},
+			{"Elem": "github.com/mauricelam/genny/parse/test/aliases/two/util.Value"},
+		},
+		expectedOut: `test/aliases/holder_expected.go`,
+	},
+	{
+		filename: "holder.go",
+		in:       `test/aliases/holder.go`,
+		types: []map[string]string{
+			{"Elem": "util.Value@github.com/mauricelam/genny/parse/test/aliases/one/util"},
+			{"Elem": "util.Value@github.com/mauricelam/genny/parse/test/aliases/two/util"},
+		},
+		expectedOut: `test/aliases/holder_expected.go`,
+	},
+	{
+		filename: "pairs.go",
+		in:       `test/dedupe/pairs.go`,
+		types: []map[string]string{
+			{"KeyType": "string", "ValueType": "int"},
+			{"KeyType": "string", "ValueType": "bool"},
+		},
+		expectedOut: `test/dedupe/pairs_expected.go`,
+	},
+	{
+		filename:    "groups.go",
+		in:          `test/importgroups/groups.go`,
+		types:       []map[string]string{{"Elem": "float64"}},
+		expectedOut: `test/importgroups/groups_expected.go`,
+	},
+	{
+		filename:    "groups.go",
+		in:          `test/importgroups/groups.go`,
+		types:       []map[string]string{{"Elem": "int"}},
+		local:       []string{"github.com/mauricelam/genny"},
+		expectedOut: `test/importgroups/groups_local.go`,
+	},
+	{
+		filename: "cgo.go",
+		in:       `test/cgo/cgo.go`,
+		types: []map[string]string{
+			{"Elem": "int"},
+			{"Elem": "float64"},
+		},
+		expectedOut: `test/cgo/cgo_expected.go`,
+	},
+	{
+		filename: "sortdecls.go",
+		in:       `test/sortdecls/sortdecls.go`,
+		types: []map[string]string{
+			{"Elem": "string"},
+			{"Elem": "int"},
+		},
+		sortDecl:    parse.DeclOrderAlpha,
+		expectedOut: `test/sortdecls/sortdecls_alpha.go`,
+	},
+	{
+		filename: "sortdecls.go",
+		in:       `test/sortdecls/sortdecls.go`,
+		types: []map[string]string{
+			{"Elem": "float64"},
+			{"Elem": "bool"},
+		},
+		sortDecl:    parse.DeclOrderTemplate,
+		expectedOut: `test/sortdecls/sortdecls_template.go`,
+	},
+	{
+		filename:    "bitset.go",
+		in:          `test/platform/bitset.go`,
+		types:       []map[string]string{{"Word": "uint32"}},
+		tag:         "genny",
+		platform:    "linux && 386",
+		expectedOut: `test/platform/bitset_linux_386.go`,
+	},
+	{
+		filename:    "bitset.go",
+		in:          `test/platform/bitset.go`,
+		types:       []map[string]string{{"Word": "uint64"}},
+		tag:         "genny",
+		platform:    "linux && amd64",
+		expectedOut: `test/platform/bitset_linux_amd64.go`,
+	},
+	{
+		filename:    "test/include/list.go",
+		in:          `test/include/list.go`,
+		types:       []map[string]string{{"Elem": "int"}, {"Elem": "string"}},
+		expectedOut: `test/include/list_expected.go`,
+	},
+	{
+		filename:    "test/inherit/locked_list.go",
+		in:          `test/inherit/locked_list.go`,
+		types:       []map[string]string{{"Elem": "int"}, {"Elem": "string"}},
+		tag:         "genny",
+		expectedOut: `test/inherit/locked_list_expected.go`,
+	},
+	{
+		filename:    "list.go",
+		in:          `test/preprocess/list.go`,
+		types:       []map[string]string{{"Elem": "string"}, {"Elem": "int"}},
+		tag:         "genny",
+		preproc:     true,
+		expectedOut: `test/preprocess/list_expected.go`,
+	},
+	{
+		filename:    "values.go",
+		in:          `test/preprocess/values.go`,
+		types:       []map[string]string{{"Elem": "int"}, {"Elem": "Name:string"}},
+		tag:         "genny",
+		preproc:     true,
+		values:      map[string]map[string]string{"int": {"verb": "%d"}, "string": {"verb": "%q"}},
+		expectedOut: `test/preprocess/values_expected.go`,
+	},
+	{
+		filename:    "print.go",
+		in:          `test/formatverb/print.go`,
+		types:       []map[string]string{{"Elem": "int"}, {"Elem": "string"}, {"Elem": "Name:[]string"}},
+		expectedOut: `test/formatverb/print_expected.go`,
+	},
+	{
+		filename:    "set.go",
+		in:          `test/hash/set.go`,
+		types:       []map[string]string{{"Key": "String:string:hashString"}, {"Key": "Int:int:hashInt"}},
+		expectedOut: `test/hash/set_expected.go`,
+	},
+	{
+		filename:    "sort.go",
+		in:          `test/less/sort.go`,
+		types:       []map[string]string{{"Elem": "Point:Point:lessPoint"}},
+		expectedOut: `test/less/sort_expected.go`,
+	},
+	{
+		filename:    "index.go",
+		in:          `test/equal/index.go`,
+		types:       []map[string]string{{"Elem": "Floats:[]float64:equalFloats"}},
+		expectedOut: `test/equal/index_expected.go`,
+	},
+	{
+		filename:    "bits.go",
+		in:          `test/integer/bits.go`,
+		types:       []map[string]string{{"Word": "uint32", "Signed": "int", "Ratio": "float32"}},
+		expectedOut: `test/integer/bits_expected.go`,
+	},
+	{
+		filename:    "cache.go",
+		in:          `test/defaults/cache.go`,
+		types:       []map[string]string{{"Key": "int"}, {"Key": "string", "Value": "float64"}},
+		expectedOut: `test/defaults/cache_expected.go`,
+	},
+	{
+		filename:    "queue_test.go",
+		pkgName:     "testpkg_test",
+		tested:      "github.com/mauricelam/genny/parse/test/testpkg",
+		in:          `test/testpkg/queue_test.go`,
+		types:       []map[string]string{{"Something": "int"}},
+		expectedOut: `test/testpkg/int_queue_test.go.nobuild`,
+	},
 }
 
 func TestParse(t *testing.T) {
@@ -192,17 +464,30 @@ func TestParse(t *testing.T) {
 				continue
 			}
 			t.Run(fmt.Sprintf("%d:%s/(ast:%v)", testNo, test.expectedOut, useAst), func(t *testing.T) {
-				in := contents(test.in)
+				sources := []string{contents(test.in)}
+				templates := []parse.Template{{Filename: test.filename, Source: strings.NewReader(sources[0])}}
+				for _, in := range test.moreIn {
+					sources = append(sources, contents(in))
+					templates = append(templates, parse.Template{Filename: in, Source: strings.NewReader(sources[len(sources)-1])})
+				}
 				expectedOut := contents(test.expectedOut)
 
-				bytes, err := parse.Generics(
-					test.filename,
-					test.pkgName,
-					strings.NewReader(in),
-					test.types,
-					test.imports,
-					test.tag,
-					useAst)
+				opts := parse.Options{
+					PkgName:              test.pkgName,
+					ImportPaths:          test.imports,
+					StripTags:            stripTags(test.tag, test.tags),
+					UseAst:               useAst,
+					Naming:               test.naming,
+					KeepConstraints:      test.keep,
+					BuildConstraint:      test.platform,
+					SubstitutePackageDoc: test.substDoc,
+					SortDecls:            test.sortDecl,
+					LocalPrefixes:        test.local,
+					Preprocess:           test.preproc,
+					Values:               test.values,
+					TestedImport:         test.tested,
+				}
+				bytes, err := parse.GenericsTemplates(templates, test.types, opts)
 
 				// check the error
 				if test.expectedErr == nil {
@@ -217,6 +502,35 @@ func TestParse(t *testing.T) {
 					log.Println("EXPECTED: " + expectedOut)
 					log.Println("ACTUAL: " + string(bytes))
 				}
+
+				// templates with other line endings generate the same code
+				for _, eol := range []string{"\r\n", "\r"} {
+					var eolTemplates []parse.Template
+					for i, template := range templates {
+						eolSrc := strings.Replace(sources[i], "\n", eol, -1)
+						eolTemplates = append(eolTemplates, parse.Template{Filename: template.Filename, Source: strings.NewReader(eolSrc)})
+					}
+					eolBytes, _ := parse.GenericsTemplates(eolTemplates, test.types, opts)
+					assert.Equal(t, expectedOut, string(eolBytes), "Parse didn't generate the expected output for %q line endings.", eol)
+				}
+
+				// the templates can be read from readers that can't seek
+				var readerTemplates []parse.Template
+				for i, template := range templates {
+					readerTemplates = append(readerTemplates, parse.Template{Filename: template.Filename, Source: iotest.OneByteReader(strings.NewReader(sources[i]))})
+				}
+				readerBytes, _ := parse.GenericsTemplates(readerTemplates, test.types, opts)
+				assert.Equal(t, expectedOut, string(readerBytes), "Parse didn't generate the expected output from an io.Reader.")
+
+				// the streamed output is the same
+				var streamed strings.Builder
+				err = parse.GenericsTo(&streamed, templates, test.types, opts)
+				if test.expectedErr == nil {
+					assert.NoError(t, err, "(%d: %s) No error was expected but got: %s", testNo, test.filename, err)
+					assert.Equal(t, expectedOut, streamed.String(), "GenericsTo didn't generate the expected output.")
+				} else {
+					assert.IsType(t, test.expectedErr, err, "(%d: %s) GenericsTo should return object of type %v", testNo, test.filename, test.expectedErr)
+				}
 			})
 		}
 
@@ -224,6 +538,685 @@ func TestParse(t *testing.T) {
 
 }
 
+func TestNoTemplates(t *testing.T) {
+	typeSets := []map[string]string{{"Elem": "int"}}
+	message := "No templates were given to generate the code from."
+	for _, opts := range []parse.Options{{}, {Raw: true}, {Mode: parse.ModeTypeParams}, {CacheDir: "unused"}} {
+		_, err := parse.GenericsTemplates(nil, typeSets, opts)
+		assert.EqualError(t, err, message, "%+v", opts)
+	}
+	assert.EqualError(t, parse.GenericsTo(ioutil.Discard, nil, typeSets, parse.Options{}), message)
+	_, err := parse.GenericsRegistry(nil, typeSets, parse.Options{})
+	assert.EqualError(t, err, message)
+	_, err = parse.GenericsDispatch(nil, typeSets, parse.Options{}, "")
+	assert.EqualError(t, err, message)
+	_, err = parse.GenericsSourceMap(nil, typeSets, parse.Options{}, "list_gen.go")
+	assert.EqualError(t, err, message)
+	_, err = parse.GenericsConversions(nil, []parse.Conversion{{From: typeSets[0], To: typeSets[0]}}, parse.Options{})
+	assert.EqualError(t, err, message)
+}
+
+func TestGenericsAsm(t *testing.T) {
+	src, err := ioutil.ReadFile("test/asm/elem_amd64.s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeSets := []map[string]string{{"Elem": "int"}, {"Elem": "uint64"}}
+	opts := parse.Options{StripTags: []string{"genny"}}
+
+	files, err := parse.GenericsAsm(parse.Template{Filename: "test/asm/elem_amd64.s", Source: strings.NewReader(string(src))}, typeSets, opts)
+	if assert.NoError(t, err) && assert.Len(t, files, 2) {
+		for _, f := range files {
+			expected, err := ioutil.ReadFile("test/asm/" + f.Filename)
+			if assert.NoError(t, err, "No expected output for %s", f.Filename) {
+				assert.Equal(t, string(expected), string(f.Code))
+			}
+		}
+		assert.Equal(t, "int_amd64.s", files[0].Filename)
+		assert.Equal(t, "uint64_amd64.s", files[1].Filename)
+	}
+
+	// without the generic type in the name, the name is prefixed with it
+	files, err = parse.GenericsAsm(parse.Template{Filename: "sum_amd64.s", Source: strings.NewReader(string(src))}, typeSets, opts)
+	if assert.NoError(t, err) && assert.Len(t, files, 2) {
+		assert.Equal(t, "int_sum_amd64.s", files[0].Filename)
+		assert.Equal(t, "uint64_sum_amd64.s", files[1].Filename)
+	}
+}
+
+func TestGenericsExamples(t *testing.T) {
+	template := parse.Template{Filename: "test/examples/example_elem_test.go", Source: strings.NewReader(contents("test/examples/example_elem_test.go"))}
+	files, err := parse.GenericsExamples(template, []map[string]string{{"Elem": "int"}}, parse.Options{})
+	if assert.NoError(t, err) && assert.Len(t, files, 1) {
+		assert.Equal(t, "example_int_test.go", files[0].Filename)
+		assert.Equal(t, contents("test/examples/example_int_test.go"), string(files[0].Code))
+	}
+
+	// the files are always test files
+	template = parse.Template{Filename: "examples.go", Source: strings.NewReader(contents("test/examples/example_elem_test.go"))}
+	files, err = parse.GenericsExamples(template, []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{})
+	if assert.NoError(t, err) && assert.Len(t, files, 2) {
+		assert.Equal(t, "int_examples_test.go", files[0].Filename)
+		assert.Equal(t, "string_examples_test.go", files[1].Filename)
+		assert.Contains(t, string(files[1].Code), "func ExampleStringStack_Push() {")
+	}
+}
+
+func TestGenericsRegistry(t *testing.T) {
+	templates := []parse.Template{{Filename: "test/queue/generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	registry, err := parse.GenericsRegistry(templates, []map[string]string{{"Something": "int"}, {"Something": "float32"}}, parse.Options{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, contents("test/queue/registry_expected.go"), string(registry))
+	}
+
+	// a type set that only differs in a generic type that is not in the name
+	// of the constructor gets no entry of its own
+	templates = []parse.Template{{Filename: "test/queue/generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	registry, err = parse.GenericsRegistry(templates, []map[string]string{{"Something": "int", "Other": "a"}, {"Something": "int", "Other": "b"}}, parse.Options{PkgName: "registry"})
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(registry), "package registry\n")
+		assert.Equal(t, 1, strings.Count(string(registry), `"int":`))
+	}
+}
+
+func TestGenericsDispatch(t *testing.T) {
+	templates := []parse.Template{{Filename: "test/dispatch/print.go", Source: strings.NewReader(contents("test/dispatch/print.go"))}}
+	typeSets := []map[string]string{{"Elem": "int"}, {"Elem": "string"}}
+	code, err := parse.GenericsDispatch(templates, typeSets, parse.Options{StripTags: []string{"genny"}}, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, contents("test/dispatch/dispatch_expected.go"), string(code))
+	}
+
+	// the value can be a marker interface, and qualified types are imported
+	templates = []parse.Template{{Filename: "test/dispatch/print.go", Source: strings.NewReader(contents("test/dispatch/print.go"))}}
+	typeSets = []map[string]string{{"Elem": "time.Duration"}}
+	code, err = parse.GenericsDispatch(templates, typeSets, parse.Options{StripTags: []string{"genny"}}, "fmt.Stringer")
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(code), "\t\"time\"\n")
+		assert.Contains(t, string(code), "func Fprint(w io.Writer, e fmt.Stringer) (int, error) {")
+		assert.Contains(t, string(code), "\tcase time.Duration:\n\t\treturn FprintTimeDuration(w, e)\n")
+	}
+}
+
+func TestGenericsSourceMap(t *testing.T) {
+	templates := []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}
+	sourceMap, err := parse.GenericsSourceMap(templates, []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{}, "list_gen.go")
+	assert.NoError(t, err)
+	expected, err := ioutil.ReadFile("test/include/sourcemap_expected.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(sourceMap))
+}
+
+func TestRemapCoverage(t *testing.T) {
+	data, err := ioutil.ReadFile("test/include/sourcemap_expected.json")
+	assert.NoError(t, err)
+	var sourceMap parse.SourceMap
+	assert.NoError(t, json.Unmarshal(data, &sourceMap))
+
+	for _, test := range []struct{ mode, count string }{{"set", "1"}, {"count", "5"}} {
+		profile := "mode: " + test.mode + "\n" +
+			"example.com/pkg/list_gen.go:18.35,20.2 1 2\n" +
+			"example.com/pkg/list_gen.go:56.41,58.2 1 3\n" +
+			"example.com/pkg/list_gen.go:84.46,86.2 1 0\n" +
+			"example.com/pkg/other.go:3.14,5.2 1 0\n"
+		if test.mode == "set" {
+			profile = strings.NewReplacer(" 2\n", " 1\n", " 3\n", " 1\n").Replace(profile)
+		}
+		var out strings.Builder
+		assert.NoError(t, parse.RemapCoverage(&out, strings.NewReader(profile), []parse.SourceMap{sourceMap}))
+		assert.Equal(t, "mode: "+test.mode+"\n"+
+			"example.com/pkg/test/include/list.go:13.35,15.2 1 "+test.count+"\n"+
+			"example.com/pkg/test/include/list.go:20.46,22.2 1 0\n"+
+			"example.com/pkg/other.go:3.14,5.2 1 0\n", out.String())
+	}
+
+	err = parse.RemapCoverage(ioutil.Discard, strings.NewReader("mode: set\nlist_gen.go:18.35 1 1\n"), nil)
+	assert.EqualError(t, err, `Bad coverage profile at line 2: expected file:startLine.startCol,endLine.endCol numStmt count, got "list_gen.go:18.35 1 1"`)
+}
+
+func TestGenericsConversions(t *testing.T) {
+	templates := []parse.Template{{Filename: "test/convert/list.go", Source: strings.NewReader(contents("test/convert/list.go"))}}
+	conversions := []parse.Conversion{
+		{From: map[string]string{"Elem": "int"}, To: map[string]string{"Elem": "string"}},
+		{From: map[string]string{"Elem": "string"}, To: map[string]string{"Elem": "int"}},
+		// the same conversion again is only generated once
+		{From: map[string]string{"Elem": "int"}, To: map[string]string{"Elem": "string"}},
+	}
+	code, err := parse.GenericsConversions(templates, conversions, parse.Options{StripTags: []string{"genny"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, contents("test/convert/conversions_expected.go"), string(code))
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := parse.ReadConfig("genny.yaml", strings.NewReader(`
+generate:
+- in: [bitset.go]
+  out: bitset_gen.go
+  types: Elem=string
+  typesets:
+  - types: Word=uint32
+    build: 386 || arm
+    out: bitset_32.go
+  - types: Word=uint64
+    build: "!(386 || arm)"
+    out: bitset_64.go
+  - types: Word=uint16,uint8
+`))
+	if !assert.NoError(t, err) || !assert.Len(t, config.Generate, 1) {
+		return
+	}
+	outputs, err := config.Generate[0].Outputs(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ConfigOutput{
+		{Out: "bitset_32.go", BuildConstraint: "386 || arm", TypeSets: []map[string]string{{"Elem": "string", "Word": "uint32"}}},
+		{Out: "bitset_64.go", BuildConstraint: "!(386 || arm)", TypeSets: []map[string]string{{"Elem": "string", "Word": "uint64"}}},
+		{Out: "bitset_gen.go", TypeSets: []map[string]string{{"Elem": "string", "Word": "uint16"}, {"Elem": "string", "Word": "uint8"}}},
+	}, outputs)
+
+	// without type sets, the types are generated into out
+	outputs, err = parse.GenerateConfig{In: []string{"a.go"}, Out: "b.go", Types: "T=int"}.Outputs(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ConfigOutput{{Out: "b.go", TypeSets: []map[string]string{{"T": "int"}}}}, outputs)
+
+	// the variants generate every type set for the tag and without it
+	config, err = parse.ReadConfig("genny.yaml", strings.NewReader(`
+generate:
+- in: [sum.go]
+  out: sum_gen.go
+  types: Elem=int32,int64
+  variants:
+  - tag: purego
+    types: Impl=portable
+    otherwise: Impl=simd
+`))
+	if assert.NoError(t, err) {
+		outputs, err = config.Generate[0].Outputs(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []parse.ConfigOutput{
+			{Out: "sum_gen_purego.go", BuildConstraint: "purego", TypeSets: []map[string]string{{"Elem": "int32", "Impl": "portable"}, {"Elem": "int64", "Impl": "portable"}}},
+			{Out: "sum_gen_nopurego.go", BuildConstraint: "!purego", TypeSets: []map[string]string{{"Elem": "int32", "Impl": "simd"}, {"Elem": "int64", "Impl": "simd"}}},
+		}, outputs)
+	}
+	outputs, err = parse.GenerateConfig{In: []string{"a.go"}, Out: "b.go", TypeSets: []parse.TypeSetConfig{{Types: "T=int", Build: "amd64", Out: "b_amd64.go"}}, Variants: []parse.VariantConfig{{Tag: "purego"}}}.Outputs(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ConfigOutput{
+		{Out: "b_amd64_purego.go", BuildConstraint: "(amd64) && purego", TypeSets: []map[string]string{{"T": "int"}}},
+		{Out: "b_amd64_nopurego.go", BuildConstraint: "(amd64) && !purego", TypeSets: []map[string]string{{"T": "int"}}},
+	}, outputs)
+
+	// the routes take the type sets that they match to files of their own
+	config, err = parse.ReadConfig("genny.yaml", strings.NewReader(`
+generate:
+- in: [list.go]
+  out: list_gen.go
+  pkg: coll
+  typesets:
+  - types: Elem=int,User:pb.User@example.com/proto/pb,Order:example.com/proto/pb.Order
+  - name: fast
+    types: Elem=uint64
+  routes:
+  - types: example.com/proto/*
+    out: gen/proto/list_gen.go
+    pkg: proto
+  - typeset: fast
+    out: fast_gen.go
+`))
+	if assert.NoError(t, err) {
+		outputs, err = config.Generate[0].Outputs(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []parse.ConfigOutput{
+			{Out: "list_gen.go", Pkg: "coll", TypeSets: []map[string]string{{"Elem": "int"}}},
+			{Out: "gen/proto/list_gen.go", Pkg: "proto", TypeSets: []map[string]string{{"Elem": "User:pb.User@example.com/proto/pb"}, {"Elem": "Order:example.com/proto/pb.Order"}}},
+			{Out: "fast_gen.go", Pkg: "coll", TypeSets: []map[string]string{{"Elem": "uint64"}}},
+		}, outputs)
+	}
+	outputs, err = parse.GenerateConfig{In: []string{"a.go"}, Types: "T=int,pb.User", Routes: []parse.RouteConfig{{Types: "pb.*", Out: "pb.go"}, {Types: "*", Out: "b.go"}}}.Outputs(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ConfigOutput{{Out: "b.go", TypeSets: []map[string]string{{"T": "int"}}}, {Out: "pb.go", TypeSets: []map[string]string{{"T": "pb.User"}}}}, outputs)
+
+	for _, bad := range []string{
+		"generate:\n- out: x.go\n",
+		"generate:\n- in: [a.go]\n  typo: x\n",
+	} {
+		_, err := parse.ReadConfig("genny.yaml", strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+	for _, bad := range []parse.GenerateConfig{
+		{In: []string{"a.go"}, Types: "T=int"},
+		{In: []string{"a.go"}, Out: "b.go", TypeSets: []parse.TypeSetConfig{{Types: "T=int", Build: "386"}, {Types: "T=string"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T="},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int", Variants: []parse.VariantConfig{{Tag: "pure go"}}},
+		{In: []string{"a.go"}, Types: "T=int", Variants: []parse.VariantConfig{{Tag: "purego"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int", Routes: []parse.RouteConfig{{Types: "int"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int", Routes: []parse.RouteConfig{{Types: "[", Out: "c.go"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int", Routes: []parse.RouteConfig{{TypeSet: "fast", Out: "c.go"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int", Routes: []parse.RouteConfig{{Types: "int", TypeSet: "fast", Out: "c.go"}}},
+		{In: []string{"a.go"}, Out: "b.go", Types: "T=int,string", Routes: []parse.RouteConfig{{Types: "int", Out: "b.go", Pkg: "other"}}},
+	} {
+		_, err := bad.Outputs(0)
+		assert.Error(t, err, "%v", bad)
+	}
+
+	// the imports of the types are those of the types used
+	config, err = parse.ReadConfig("genny.yaml", strings.NewReader(`
+imports:
+  time.Time: time
+  big.Int: math/big
+  decimal.Decimal: github.com/shopspring/decimal
+generate:
+- in: [list.go]
+  out: list_gen.go
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"math/big", "time"}, config.TypeImports([]map[string]string{
+			{"Elem": "[]*time.Time"},
+			{"Elem": "Big:map[string]big.Int"},
+			{"Elem": "xtime.Timer", "Other": "mydecimal.Decimal"},
+		}))
+		assert.Empty(t, config.TypeImports([]map[string]string{{"Elem": "int"}}))
+	}
+
+	// conversions go into files of their own
+	config, err = parse.ReadConfig("genny.yaml", strings.NewReader(`
+generate:
+- in: [list.go]
+  out: list_gen.go
+  types: Elem=int,string
+  conversions:
+  - from: Elem=int
+    to: Elem=string
+    out: convert.go
+  - from: Elem=string
+    to: Elem=int
+    out: convert.go
+`))
+	if !assert.NoError(t, err) || !assert.Len(t, config.Generate, 1) {
+		return
+	}
+	conversions, err := config.Generate[0].ConversionOutputs()
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.ConversionOutput{{Out: "convert.go", Conversions: []parse.Conversion{
+		{From: map[string]string{"Elem": "int"}, To: map[string]string{"Elem": "string"}},
+		{From: map[string]string{"Elem": "string"}, To: map[string]string{"Elem": "int"}},
+	}}}, conversions)
+	for _, bad := range []parse.ConversionConfig{
+		{From: "Elem=int", To: "Elem=string"},
+		{From: "Elem=int,uint", To: "Elem=string", Out: "convert.go"},
+	} {
+		_, err := parse.GenerateConfig{In: []string{"list.go"}, Conversions: []parse.ConversionConfig{bad}}.ConversionOutputs()
+		assert.Error(t, err, "%v", bad)
+	}
+}
+
+func TestTOMLConfig(t *testing.T) {
+	// a TOML config has the tables and keys of the YAML
+	config, err := parse.ReadConfig("genny.toml", strings.NewReader(`# the config of the project
+renames = { IntQueue = "IntFIFO" }
+
+[imports]
+"time.Time" = "time"
+
+[values.int]
+verb = "%d"
+
+[[generate]]
+in = [
+  "bitset.go", # the template
+]
+out = 'bitset_gen.go'
+types = "Elem=string"
+
+[[generate.typesets]]
+types = "Word=uint32"
+build = "386 || arm"
+out = "bitset_32.go"
+
+[[generate.typesets]]
+types = """
+Word=uint64"""
+
+[[generate]]
+in = ["list.go"]
+out = "list_gen.go"
+
+# the type sets are of the last generate
+[[generate.typesets]]
+types = '''
+Elem=int'''
+
+[[generate.typesets]]
+types = """
+Elem=\
+    string"""
+`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &parse.Config{
+			Generate: []parse.GenerateConfig{
+				{In: []string{"bitset.go"}, Out: "bitset_gen.go", Types: "Elem=string", TypeSets: []parse.TypeSetConfig{{Types: "Word=uint32", Build: "386 || arm", Out: "bitset_32.go"}, {Types: "Word=uint64"}}},
+				{In: []string{"list.go"}, Out: "list_gen.go", TypeSets: []parse.TypeSetConfig{{Types: "Elem=int"}, {Types: "Elem=string"}}},
+			},
+			Imports: map[string]string{"time.Time": "time"},
+			Values:  map[string]map[string]string{"int": {"verb": "%d"}},
+			Renames: map[string]string{"IntQueue": "IntFIFO"},
+		}, config)
+	}
+
+	for bad, message := range map[string]string{
+		"[[generate]]\nin = [\"a.go\"]\ntypo = \"x\"\n":         "Bad config 'genny.toml': yaml: unmarshal errors:\n  field typo not found in type parse.GenerateConfig",
+		"[[generate]]\nin = [\"a.go\"\nout = \"b.go\"\n":        "Bad config 'genny.toml': line 3 (last key \"generate.in\"): expected a comma (',') or array terminator (']'), but got 'o'",
+		"[imports]\nx = \"a\"\n[imports]\ny = \"b\"\n":          "Bad config 'genny.toml': line 3: Key 'imports' has already been defined.",
+		"renames = { A = \"B\", A = \"C\" }\n":                  "Bad config 'genny.toml': line 1 (last key \"renames.A\"): Key 'renames.A' has already been defined.",
+		"[[generate]]\nin = [\"a.go\"]\nout = \"b.go\" extra\n": "Bad config 'genny.toml': line 3 (last key \"generate\"): expected a top-level item to end with a newline, comment, or EOF, but got 'e' instead",
+		"[[generate]]\nin = [\"a.go\"]\nout = 1979-05-27\n":     "Bad config 'genny.toml': the value of 'generate.out' is a date or time, which configs don't have",
+		"[[generate]]\nout = \"b.go\"\n":                        "Bad config 'genny.toml': generate 1 has no templates in 'in'",
+		"[[generate]]\nin = [\"a.go\"]\nout = \"\"\"b.go\n":     "Bad config 'genny.toml': line 3 (last key \"generate.out\"): unexpected EOF; expected '\"\"\"'",
+	} {
+		_, err := parse.ReadConfig("genny.toml", strings.NewReader(bad))
+		assert.EqualError(t, err, message, bad)
+	}
+}
+
+func TestGenericsConfig(t *testing.T) {
+	config, err := parse.ReadConfig("genny.toml", strings.NewReader(`
+[[generate]]
+in = ["generic_queue.go"]
+out = "int_queue.go"
+types = "Something=int"
+
+[[generate]]
+in = ["generic_queue.go"]
+out = "other.go"
+
+[[generate.typesets]]
+types = "Something=float32"
+out = "float32_queue.go"
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	files, err := parse.GenericsConfig(config, "test/queue", parse.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.OutputFile{
+		{Filename: "int_queue.go", Code: []byte(contents("test/queue/int_queue.go"))},
+		{Filename: "float32_queue.go", Code: []byte(contents("test/queue/float32_queue.go"))},
+	}, files)
+
+	// -pkg wins over the config
+	files, err = parse.GenericsConfig(config, "test/queue", parse.Options{PkgName: "changed"})
+	if assert.NoError(t, err) && assert.Len(t, files, 2) {
+		assert.Equal(t, contents("test/queue/changed/int_queue_newpkg.go"), string(files[0].Code))
+	}
+
+	_, err = parse.GenericsConfig(&parse.Config{Generate: []parse.GenerateConfig{{In: []string{"missing.go"}, Out: "a.go", Types: "T=int"}}}, "test/queue", parse.Options{})
+	assert.Error(t, err)
+}
+
+func TestGraph(t *testing.T) {
+	g := parse.NewGraph()
+	assert.NoError(t, g.Add([]string{"test/include/list.go"}, []map[string]string{{"Elem": "int"}}, "list_int.go"))
+	var dot strings.Builder
+	assert.NoError(t, g.WriteDOT(&dot))
+	assert.Equal(t, `digraph genny {
+	rankdir=LR;
+	"template:test/include/list.go" [label="test/include/list.go", shape=note];
+	"template:test/include/iterator.go" [label="test/include/iterator.go", shape=note];
+	"file:list_int.go" [label="list_int.go", shape=box];
+	"typeset:list_int.go:Elem=int" [label="Elem=int", shape=ellipse];
+	"template:test/include/iterator.go" -> "template:test/include/list.go" [label="include", style=dashed];
+	"template:test/include/list.go" -> "typeset:list_int.go:Elem=int";
+	"typeset:list_int.go:Elem=int" -> "file:list_int.go";
+}
+`, dot.String())
+
+	assert.Error(t, g.Add([]string{"test/missing.go"}, []map[string]string{{"Elem": "int"}}, "x.go"))
+}
+
+func TestStats(t *testing.T) {
+	typeSets := []map[string]string{{"Elem": "int"}, {"Elem": "string"}}
+	expectedOut := contents("test/include/list_expected.go")
+	phaseNames := func(stats *parse.Stats) []string {
+		var names []string
+		for _, p := range stats.Phases {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	stats := &parse.Stats{}
+	_, err := parse.GenericsTemplates([]parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}, typeSets, parse.Options{Stats: stats})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Templates)
+	assert.Equal(t, 2, stats.Instantiations)
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, strings.Count(expectedOut, "\n"), stats.OutputLines)
+	assert.Equal(t, len(expectedOut), stats.OutputBytes)
+	assert.Equal(t, []string{"read", "generate", "format"}, phaseNames(stats))
+
+	// the stats add up, and streaming has phases of its own
+	var out strings.Builder
+	err = parse.GenericsTo(&out, []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}, typeSets, parse.Options{Stats: stats})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Templates)
+	assert.Equal(t, 4, stats.Instantiations)
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, 2*len(expectedOut), stats.OutputBytes)
+	assert.Equal(t, []string{"read", "generate", "format", "imports"}, phaseNames(stats))
+
+	var report strings.Builder
+	stats.WriteTo(&report)
+	assert.Contains(t, report.String(), "instantiations:  4\n")
+}
+
+func TestTransforms(t *testing.T) {
+	typeSets := []map[string]string{{"Elem": "int"}, {"Elem": "string"}}
+	templates := []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}
+
+	var stages []string
+	record := parse.TransformFunc(func(code []byte, info parse.TransformInfo) ([]byte, error) {
+		stage := info.Stage.String()
+		if info.TypeSet != nil {
+			stage += ":" + info.TypeSet["Elem"]
+		}
+		stages = append(stages, stage)
+		return code, nil
+	})
+	opts := parse.Options{Transforms: map[parse.Stage][]parse.Transformer{
+		parse.StageRead:       {record},
+		parse.StageSubstitute: {record},
+		parse.StageMerge:      {record},
+		parse.StagePackage:    {record},
+		parse.StageFormat: {record, parse.TransformFunc(func(code []byte, info parse.TransformInfo) ([]byte, error) {
+			return append(code, "\n// transformed\n"...), nil
+		})},
+	}}
+	out, err := parse.GenericsTemplates(templates, typeSets, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, contents("test/include/list_expected.go")+"\n// transformed\n", string(out))
+	assert.Equal(t, []string{"read", "substitute:int", "substitute:string", "merge", "package", "format"}, stages)
+
+	// transforms of the whole output are run when streaming too
+	var streamed strings.Builder
+	assert.NoError(t, parse.GenericsTo(&streamed, templates, typeSets, opts))
+	assert.Equal(t, string(out), streamed.String())
+
+	failing := parse.TransformFunc(func(code []byte, info parse.TransformInfo) ([]byte, error) {
+		return nil, fmt.Errorf("no %s", info.TypeSet["Elem"])
+	})
+	_, err = parse.GenericsTemplates(templates, typeSets, parse.Options{Transforms: map[parse.Stage][]parse.Transformer{parse.StageSubstitute: {failing}}})
+	assert.EqualError(t, err, "Failed to transform 'test/include/list.go' after the substitute stage: no int")
+}
+
+// TestMain runs the test binary as a plugin if GENNY_TEST_PLUGIN is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("GENNY_TEST_PLUGIN") != "" {
+		var request parse.PluginRequest
+		if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+			log.Fatal(err)
+		}
+		if request.Parameter == "fail" {
+			fmt.Fprintln(os.Stderr, "failed on purpose")
+			os.Exit(1)
+		}
+		if request.Parameter == "hang" {
+			time.Sleep(time.Minute)
+		}
+		if request.Stage == "naming" {
+			if request.Specific == "string" {
+				fmt.Println(request.Parameter)
+			}
+			return
+		}
+		fmt.Printf("%s\n// %s %s: Elem=%s\n", request.Source, request.Stage, request.Parameter, request.TypeSet["Elem"])
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func TestPlugin(t *testing.T) {
+	os.Setenv("GENNY_TEST_PLUGIN", "1")
+	defer os.Unsetenv("GENNY_TEST_PLUGIN")
+
+	plugin, err := parse.ParsePlugin("trace=shim")
+	assert.NoError(t, err)
+	assert.Equal(t, parse.Plugin{Name: "trace", Parameter: "shim"}, plugin)
+	_, err = parse.ParsePlugin("=shim")
+	assert.Error(t, err)
+
+	plugin.Path = os.Args[0]
+	templates := []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}
+	typeSets := []map[string]string{{"Elem": "int"}, {"Elem": "string"}}
+	opts := parse.Options{Transforms: map[parse.Stage][]parse.Transformer{parse.StageSubstitute: {plugin}}}
+	out, err := parse.GenericsTemplates(templates, typeSets, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "// substitute shim: Elem=int\n")
+	assert.Contains(t, string(out), "// substitute shim: Elem=string\n")
+
+	plugin.Parameter = "fail"
+	opts.Transforms[parse.StageSubstitute] = []parse.Transformer{plugin}
+	_, err = parse.GenericsTemplates(templates, typeSets, opts)
+	assert.EqualError(t, err, "Failed to transform 'test/include/list.go' after the substitute stage: Plugin 'trace' failed: exit status 1: failed on purpose")
+
+	plugin.Parameter = "hang"
+	plugin.Deadline = time.Now().Add(100 * time.Millisecond)
+	_, err = plugin.Transform(nil, parse.TransformInfo{})
+	assert.EqualError(t, err, "Plugin 'trace' failed: context deadline exceeded")
+
+	_, err = parse.Plugin{Name: "missing-plugin"}.Transform(nil, parse.TransformInfo{})
+	assert.Error(t, err)
+}
+
+func TestNamer(t *testing.T) {
+	templates := []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}
+	namer := parse.NamerFunc(func(generic, specific string) (string, error) {
+		switch specific {
+		case "int":
+			return "Integer", nil
+		case "error":
+			return "", fmt.Errorf("no name")
+		case "bad":
+			return "not a name", nil
+		}
+		return "", nil
+	})
+
+	out, err := parse.GenericsTemplates(templates, []map[string]string{{"Elem": "int"}, {"Elem": "Num:float64"}, {"Elem": "string"}}, parse.Options{Namer: namer})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntegerList struct {\n")
+	assert.Contains(t, string(out), "type NumList struct {\n")
+	assert.Contains(t, string(out), "type StringList struct {\n")
+
+	_, err = parse.GenericsTemplates(templates, []map[string]string{{"Elem": "error"}}, parse.Options{Namer: namer})
+	assert.EqualError(t, err, "Failed to name specific type 'error' for 'Elem': no name")
+	_, err = parse.GenericsTemplates(templates, []map[string]string{{"Elem": "bad"}}, parse.Options{Namer: namer})
+	assert.EqualError(t, err, "Failed to name specific type 'bad' for 'Elem': 'not a name' is not an identifier")
+
+	// the plugin names string Text, and leaves int to the namer after it
+	os.Setenv("GENNY_TEST_PLUGIN", "1")
+	defer os.Unsetenv("GENNY_TEST_PLUGIN")
+	plugin := parse.Plugin{Name: "naming", Path: os.Args[0], Parameter: "Text"}
+	out, err = parse.GenericsTemplates(templates, []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{Namer: parse.Namers(plugin.Namer(), namer)})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntegerList struct {\n")
+	assert.Contains(t, string(out), "type TextList struct {\n")
+}
+
+func TestDebugDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-debug")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	templates := []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(contents("test/include/list.go"))}}
+	out, err := parse.GenericsTemplates(templates, []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{DebugDir: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, contents("test/include/list_expected.go"), string(out))
+	assert.Equal(t, "0 Elem=int\n1 Elem=string\n", read("typesets.txt"))
+	assert.Contains(t, read("substitute/0_list_0.go"), "type IntList struct {")
+	assert.NotContains(t, read("substitute/0_list_0.go"), "StringList")
+	assert.Contains(t, read("substitute/0_list_1.go"), "type StringList struct {")
+	// goimports removes the generic import later
+	assert.Contains(t, read("imports.txt"), `"github.com/mauricelam/genny/generic"`)
+	assert.Contains(t, read("merged.go"), `"github.com/mauricelam/genny/generic"`)
+	assert.Contains(t, read("merged.go"), "type StringList struct {")
+}
+
+func TestGenericsStream(t *testing.T) {
+	// more type sets than are generated at a time, with one that is
+	// generated again in a later batch
+	lines := []string{"# the arrays", ""}
+	var typeSets []map[string]string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("Elem=Array%d:[%d]int", i, i))
+		typeSets = append(typeSets, map[string]string{"Elem": fmt.Sprintf("Array%d:[%d]int", i, i)})
+	}
+	lines = append(lines, "Elem=Array0:[0]int,time.Duration")
+	typeSets = append(typeSets, map[string]string{"Elem": "Array0:[0]int"}, map[string]string{"Elem": "time.Duration"})
+
+	source := contents("test/include/list.go")
+	var expected strings.Builder
+	assert.NoError(t, parse.GenericsTo(&expected, []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(source)}}, typeSets, parse.Options{}))
+	var streamed strings.Builder
+	stats := &parse.Stats{}
+	err := parse.GenericsStream(&streamed, []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(source)}}, strings.NewReader(strings.Join(lines, "\n")), parse.Options{Stats: stats})
+	assert.NoError(t, err)
+	assert.Equal(t, expected.String(), streamed.String())
+	assert.Contains(t, streamed.String(), "type Array99List struct {")
+	assert.Contains(t, streamed.String(), "\t\"time\"\n")
+	assert.Equal(t, 102, stats.Instantiations)
+
+	err = parse.GenericsStream(ioutil.Discard, []parse.Template{{Filename: "test/include/list.go", Source: strings.NewReader(source)}}, strings.NewReader("Elem=int\nElem\n"), parse.Options{})
+	assert.EqualError(t, err, `Bad type sets at line 2: "Elem" is bad: unexpected end, expected '=' at position 4`)
+}
+
+func TestReport(t *testing.T) {
+	report := parse.NewReport()
+	templates := []parse.Template{{Filename: "test/queue/generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	assert.NoError(t, report.Add(templates, []map[string]string{{"Something": "int"}}, "int_queue.go", parse.Options{}))
+	assert.NoError(t, report.Add(templates, []map[string]string{{"Something": "float32"}}, "float32_queue.go", parse.Options{}))
+	var md strings.Builder
+	assert.NoError(t, report.WriteMarkdown(&md))
+	assert.Equal(t, "# Generated code\n"+
+		"\n## test/queue/generic_queue.go\n\n"+
+		"Generic types: `Something`\n\n"+
+		"| Types | File | Declarations |\n"+
+		"| --- | --- | --- |\n"+
+		"| `Something=int` | `int_queue.go` | `IntQueue`, `NewIntQueue`, `IntQueue.Push`, `IntQueue.Pop` |\n"+
+		"| `Something=float32` | `float32_queue.go` | `Float32Queue`, `NewFloat32Queue`, `Float32Queue.Push`, `Float32Queue.Pop` |\n",
+		md.String())
+}
+
+func stripTags(tag string, tags []string) []string {
+	if tag != "" {
+		return append([]string{tag}, tags...)
+	}
+	return tags
+}
+
 func contents(s string) string {
 	if strings.HasSuffix(s, "go") || strings.HasSuffix(s, "go.nobuild") {
 		file, err := ioutil.ReadFile(s)
@@ -234,3 +1227,1003 @@ func contents(s string) string {
 	}
 	return s
 }
+
+func TestDiagnose(t *testing.T) {
+	generate := func(src string, opts parse.Options) error {
+		_, err := parse.GenericsTemplates([]parse.Template{{Filename: "list.go", Source: strings.NewReader(src)}}, []map[string]string{{"Elem": "int"}}, opts)
+		return err
+	}
+	header := "package list\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype Elem generic.Type\n\n"
+
+	for _, test := range []struct {
+		src      string
+		opts     parse.Options
+		expected parse.Diagnostic
+	}{
+		{header + "func f( {\n", parse.Options{}, parse.Diagnostic{Rule: "source", Filename: "list.go", Line: 7, Column: 9}},
+		{header + "const Verb = generic.FormatVerb\n", parse.Options{}, parse.Diagnostic{Rule: "placeholder", Filename: "list.go", Line: 7}},
+		{header + "/*{{.Other}}*/\n", parse.Options{Preprocess: true}, parse.Diagnostic{Rule: "preprocess", Filename: "list.go", Line: 7, Column: 5}},
+	} {
+		err := generate(test.src, test.opts)
+		if !assert.Error(t, err, test.src) {
+			continue
+		}
+		d := parse.Diagnose(err)
+		test.expected.Message = err.Error()
+		assert.Equal(t, test.expected, d, test.src)
+	}
+	assert.Equal(t, parse.Diagnostic{Rule: "error", Message: "oops"}, parse.Diagnose(errors.New("oops")))
+
+	var sarif bytes.Buffer
+	assert.NoError(t, parse.WriteSARIF(&sarif, []parse.Diagnostic{
+		{Rule: "source", Filename: "dir/list.go", Line: 7, Column: 9, Message: "bad"},
+		{Rule: "error", Message: "oops"},
+	}))
+	var log struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				RuleID    string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn int }
+					}
+				}
+			}
+		}
+	}
+	if assert.NoError(t, json.Unmarshal(sarif.Bytes(), &log)) && assert.Len(t, log.Runs, 1) && assert.Len(t, log.Runs[0].Results, 2) {
+		assert.Equal(t, "2.1.0", log.Version)
+		results := log.Runs[0].Results
+		assert.Equal(t, "source", results[0].RuleID)
+		assert.Equal(t, "bad", results[0].Message.Text)
+		if assert.Len(t, results[0].Locations, 1) {
+			location := results[0].Locations[0].PhysicalLocation
+			assert.Equal(t, "dir/list.go", location.ArtifactLocation.URI)
+			assert.Equal(t, 7, location.Region.StartLine)
+			assert.Equal(t, 9, location.Region.StartColumn)
+		}
+		assert.Empty(t, results[1].Locations)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-stamp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"list.go", "iterator.go"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents("test/include/"+name)), 0644))
+	}
+	filename := filepath.Join(dir, "list.go")
+	generate := func(opts parse.Options) []byte {
+		src, err := ioutil.ReadFile(filename)
+		assert.NoError(t, err)
+		out, err := parse.GenericsTemplates([]parse.Template{{Filename: filename, Source: bytes.NewReader(src)}}, []map[string]string{{"Elem": "int"}}, opts)
+		assert.NoError(t, err)
+		return out
+	}
+
+	// the stamp is only written with a StampDir
+	_, ok := parse.FindStamp(generate(parse.Options{}))
+	assert.False(t, ok)
+	out := generate(parse.Options{StampDir: filepath.Join(dir, "gen")})
+	assert.Contains(t, string(out), "// see https://github.com/mauricelam/genny\n// genny:templates sha256:")
+	stamp, ok := parse.FindStamp(out)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, []string{"../list.go"}, stamp.Templates)
+	current, err := stamp.Current(filepath.Join(dir, "gen"))
+	assert.NoError(t, err)
+	assert.True(t, current)
+
+	// a change to an included template makes it out of date
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "iterator.go"), []byte(contents("test/include/iterator.go")+"\n// changed\n"), 0644))
+	current, err = stamp.Current(filepath.Join(dir, "gen"))
+	assert.NoError(t, err)
+	assert.False(t, current)
+
+	_, err = parse.Stamp{Hash: "sha256:00", Templates: []string{"missing.go"}}.Current(dir)
+	assert.Error(t, err)
+}
+
+func TestCheckTemplate(t *testing.T) {
+	rules := func(src string) []string {
+		var rules []string
+		for _, d := range parse.CheckTemplate("list.go", []byte(src)) {
+			rules = append(rules, fmt.Sprintf("%s:%d", d.Rule, d.Line))
+		}
+		return rules
+	}
+	header := "package list\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype Elem generic.Type\n\n"
+
+	assert.Empty(t, parse.CheckTemplate("test/queue/generic_queue.go", []byte(contents("test/queue/generic_queue.go"))))
+	assert.Empty(t, parse.CheckTemplate("test/include/list.go", []byte(contents("test/include/list.go"))))
+	assert.Equal(t, []string{"syntax:7"}, rules(header+"func f( {\n"))
+	assert.Equal(t, []string{"type:7"}, rules(header+"func f() Elem { return missing }\n"))
+	assert.Equal(t, []string{"generic-use:7"}, rules(header+"func f() generic.Type { return nil }\n"))
+	assert.Equal(t, []string{"overlapping-generics:7"}, rules(header+"type ElemList generic.Type\n"))
+	assert.Equal(t, []string{"placeholder:7"}, rules(header+"const Verb = generic.FormatVerb\n"))
+	assert.Equal(t, []string{"no-generics:1"}, rules("package list\n\nfunc f() {}\n"))
+}
+
+func TestDefaultTypeSets(t *testing.T) {
+	src := "package list\n\nimport \"github.com/mauricelam/genny/generic\"\n\n// genny:defaults Elem=int,string\n// genny:defaults Elem=float64\n\ntype Elem generic.Type\n\ntype ElemList []Elem\n"
+	typeSets, err := parse.DefaultTypeSets("list.go", []byte(src), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"Elem": "int"}, {"Elem": "string"}, {"Elem": "float64"}}, typeSets)
+
+	// the directives aren't generated
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "list.go", Source: strings.NewReader(src)}}, typeSets, parse.Options{})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "genny:defaults")
+	assert.Contains(t, string(out), "type Float64List []float64")
+
+	typeSets, err = parse.DefaultTypeSets("list.go", []byte("package list\n"), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, typeSets)
+	_, err = parse.DefaultTypeSets("list.go", []byte(src), 2)
+	assert.EqualError(t, err, "the genny:defaults of 'list.go' make at least 3 type sets, which is over the limit of 2")
+	_, err = parse.DefaultTypeSets("list.go", []byte("// genny:defaults Elem\n"), 0)
+	assert.Error(t, err)
+	assert.Equal(t, "defaults", parse.Diagnose(err).Rule)
+
+	// a genny:default is a single specific type
+	for _, directive := range []string{"Elem=int,string", "Elem=int Other=string", "Elem"} {
+		_, err = parse.GenericsTemplates([]parse.Template{{Filename: "list.go", Source: strings.NewReader("package list\n\n// genny:default " + directive + "\n")}}, []map[string]string{{"Elem": "int"}}, parse.Options{})
+		assert.Error(t, err, directive)
+	}
+}
+
+func TestSkipExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-existing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		// a hand-written version of the type set Something=bool
+		"bool_queue.go": "package queue\n\ntype BoolQueue struct{ bits []uint64 }\n\nfunc NewBoolQueue() *BoolQueue { return &BoolQueue{} }\n",
+		// the test files and the code genny generated don't count
+		"queue_test.go": "package queue\n\nfunc NewStringQueue() {}\n",
+		"gen-queue.go":  "// Code generated by genny. DO NOT EDIT.\n\npackage queue\n\ntype IntQueue struct{}\n",
+	}
+	for name, src := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	typeSets, err := parse.TypeSet("Something=int,bool,string")
+	assert.NoError(t, err)
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}, typeSets, parse.Options{SkipExistingDir: dir})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntQueue struct")
+	assert.Contains(t, string(out), "func NewStringQueue() *StringQueue")
+	// the methods of the existing type go along with it
+	assert.NotContains(t, string(out), "BoolQueue")
+
+	_, err = parse.GenericsTemplates([]parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}, typeSets, parse.Options{SkipExistingDir: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
+
+func TestRenames(t *testing.T) {
+	template := func() []parse.Template {
+		return []parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	}
+	typeSets := []map[string]string{{"Something": "int"}}
+	opts := parse.Options{Renames: map[string]string{"IntQueue": "IntFIFO", "NewIntQueue": "MakeIntFIFO"}}
+
+	out, err := parse.GenericsTemplates(template(), typeSets, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "// IntFIFO is a queue of Ints.\ntype IntFIFO struct {")
+	assert.Contains(t, string(out), "func MakeIntFIFO() *IntFIFO {\n\treturn &IntFIFO{items: make([]int, 0)}")
+	assert.Contains(t, string(out), "func (q *IntFIFO) Push(item int) {")
+	assert.NotContains(t, string(out), "IntQueue")
+
+	// the registry refers to the renamed constructor
+	registry, err := parse.GenericsRegistry(template(), typeSets, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(registry), "MakeIntFIFO")
+	assert.NotContains(t, string(registry), "NewIntQueue")
+
+	_, err = parse.GenericsTemplates(template(), typeSets, parse.Options{Renames: map[string]string{"IntQueue": "int queue"}})
+	assert.EqualError(t, err, "Can't rename 'IntQueue' to 'int queue': both must be identifiers")
+}
+
+func TestExportPolicy(t *testing.T) {
+	policy, err := parse.ParseExportPolicy("types=unexported, methods=unexported,funcs=keep")
+	assert.NoError(t, err)
+	assert.Equal(t, parse.ExportPolicy{Types: parse.Unexported, Methods: parse.Unexported}, policy)
+	for _, bad := range []string{"structs=exported", "types", "types=public"} {
+		_, err := parse.ParseExportPolicy(bad)
+		assert.Error(t, err, bad)
+	}
+
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}, []map[string]string{{"Something": "int"}}, parse.Options{
+		Export:  policy,
+		Renames: map[string]string{"NewIntQueue": "newQueue"},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "// intQueue is a queue of Ints.\ntype intQueue struct {")
+	assert.Contains(t, string(out), "func newQueue() *intQueue {")
+	assert.Contains(t, string(out), "func (q *intQueue) push(item int) {")
+	assert.Contains(t, string(out), "func (q *intQueue) pop() int {")
+}
+
+func TestTestedImport(t *testing.T) {
+	template := []parse.Template{{Filename: "queue_test.go", Source: strings.NewReader(contents("test/testpkg/queue_test.go"))}}
+	_, err := parse.GenericsTemplates(template, []map[string]string{{"Something": "int"}}, parse.Options{PkgName: "testpkg_test"})
+	assert.EqualError(t, err, "The code generated into package 'testpkg_test' refers to package 'testpkg', but its import path isn't known")
+}
+
+func TestInternalImports(t *testing.T) {
+	code := []byte("package gen\n\nimport (\n\t\"a/internal/x\"\n\t\"b/internal\"\n\t\"c/d\"\n\t\"internal/race\"\n)\n")
+	disallowed, err := parse.DisallowedImports(code, "a/gen")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b/internal", "internal/race"}, disallowed)
+	disallowed, err = parse.DisallowedImports(code, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/internal/x", "internal/race"}, disallowed)
+
+	root, err := ioutil.TempDir("", "genny-internal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	for name, src := range map[string]string{
+		"a/a.go":             "package a\n\nimport _ \"m/a/internal/gen\"\n",
+		"a/sub/sub.go":       "package sub\n\nimport _ \"m/a/internal/gen\"\n",
+		"b/b.go":             "package b\n\nimport _ \"m/a/internal/gen\"\n",
+		"b/testdata/data.go": "package data\n\nimport _ \"m/a/internal/gen\"\n",
+		"c/c.go":             "package c\n",
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	}
+	importers, err := parse.InternalImporters(root, "m", "m/a/internal/gen")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"m/b"}, importers)
+	importers, err = parse.InternalImporters(root, "m", "m/c")
+	assert.NoError(t, err)
+	assert.Empty(t, importers)
+}
+
+func TestCommand(t *testing.T) {
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}, []map[string]string{{"Something": "int"}}, parse.Options{Command: "cd .. && genny -in=generic_queue.go\ngen Something=int"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "// see https://github.com/mauricelam/genny\n// genny:command cd .. && genny -in=generic_queue.go gen Something=int\n\npackage queue\n")
+}
+
+func TestSharedGenerics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-shared")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for name, src := range map[string]string{
+		"types.go": "package coll\n\nimport \"github.com/mauricelam/genny/generic\"\n\n// genny:default Value=bool\ntype Value generic.Type\n\ntype Elem generic.Type\n",
+		"list.go":  "package coll\n\nimport \"fmt\"\n\n// ElemList is a list.\ntype ElemList []Elem\n\nfunc (l ElemList) String() string { return fmt.Sprint([]Elem(l)) }\n",
+		"map.go":   "package coll\n\ntype ElemMap map[Elem]Value\n",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+	generate := func(name string, types map[string]string) ([]byte, error) {
+		filename := filepath.Join(dir, name)
+		src, err := ioutil.ReadFile(filename)
+		assert.NoError(t, err)
+		return parse.GenericsTemplates([]parse.Template{{Filename: filename, Source: bytes.NewReader(src)}}, []map[string]string{types}, parse.Options{})
+	}
+
+	out, err := generate("list.go", map[string]string{"Elem": "string"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type StringList []string\n")
+	assert.NotContains(t, string(out), "generic")
+	// the defaults of the shared generic types count
+	out, err = generate("map.go", map[string]string{"Elem": "int"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntMap map[int]bool\n")
+	_, err = generate("list.go", map[string]string{"Value": "int"})
+	assert.EqualError(t, err, "Missing specific type for 'Elem' generic type")
+	src, err := ioutil.ReadFile(filepath.Join(dir, "list.go"))
+	assert.NoError(t, err)
+	assert.Empty(t, parse.CheckTemplate(filepath.Join(dir, "list.go"), src))
+
+	// the shared generic types are declared alike
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.go"), []byte("package coll\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype Elem generic.Number\n"), 0644))
+	_, err = generate("list.go", map[string]string{"Elem": "int"})
+	assert.EqualError(t, err, "The generic type 'Elem' of '"+filepath.Join(dir, "list.go")+"' is shared, but '"+filepath.Join(dir, "other.go")+"' and '"+filepath.Join(dir, "types.go")+"' declare it differently")
+}
+
+func TestMapKeysComparable(t *testing.T) {
+	src := "package cache\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype Key generic.Type\n\ntype Value generic.Type\n\ntype KeyValueCache struct {\n\tentries map[[2]Key]Value\n\tvalues  map[*Value]bool\n}\n"
+	generate := func(types string) error {
+		typeSets, err := parse.TypeSet(types)
+		assert.NoError(t, err)
+		_, err = parse.GenericsTemplates([]parse.Template{{Filename: "cache.go", Source: strings.NewReader(src)}}, typeSets, parse.Options{})
+		return err
+	}
+	assert.NoError(t, generate("Key=string,time.Time Value=Bytes:[]byte"))
+	assert.EqualError(t, generate("Key=string,Bytes:[]byte Value=int"), "The specific type '[]byte' of 'Key' is a slice, which isn't comparable, but cache.go:10 uses 'Key' in a map key")
+	assert.EqualError(t, generate("Key=Handler:'struct{ f func() }' Value=int"), "The specific type 'struct{ f func() }' of 'Key' is a struct with a func, which isn't comparable, but cache.go:10 uses 'Key' in a map key")
+}
+
+func TestWorkspace(t *testing.T) {
+	root, err := ioutil.TempDir("", "genny-workspace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	for name, src := range map[string]string{
+		"go.work":              "go 1.18\n\nuse (\n\t./a // the library\n\t\"./b\"\n)\n",
+		"a/go.mod":             "module example.com/a\n",
+		"a/lib/lib.go":         "package lib\n\ntype Thing struct{}\n",
+		"a/time/time.go":       "package time\n",
+		"a/cmd/main.go":        "package main\n",
+		"a/testdata/x/x.go":    "package x\n",
+		"a/nested/go.mod":      "module example.com/nested\n",
+		"a/nested/x/x.go":      "package x\n",
+		"b/go.mod":             "module example.com/b\n",
+		"b/one/util/util.go":   "package util\n",
+		"b/two/util/util.go":   "package util\n",
+		"b/gen/gen_test.go":    "package gen\n",
+		"b/x/x.go":             "package x\n",
+		"b/ignored/ignored.go": "package lib\n",
+	} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	}
+	ws, err := parse.FindWorkspace(filepath.Join(root, "b", "gen"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, ws) {
+		assert.Equal(t, filepath.Join(root, "go.work"), ws.Filename)
+		assert.Equal(t, []parse.Module{{Dir: filepath.Join(root, "a"), Path: "example.com/a"}, {Dir: filepath.Join(root, "b"), Path: "example.com/b"}}, ws.Modules)
+	}
+
+	// lib and util each name two packages, time is standard and x is
+	// imported already
+	typeSets := []map[string]string{{"A": "lib.Thing", "B": "[]*time.Time", "C": "util.T", "D": "map[x.K]int"}}
+	assert.Empty(t, ws.TypeImports(typeSets, []string{"example.com/b/x"}))
+	assert.NoError(t, os.Remove(filepath.Join(root, "b", "ignored", "ignored.go")))
+	ws, err = parse.ReadWorkspace(filepath.Join(root, "go.work"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com/a/lib"}, ws.TypeImports(typeSets, []string{"example.com/b/x"}))
+	assert.Equal(t, []string{"example.com/a/lib", "example.com/b/x"}, ws.TypeImports(typeSets, nil))
+	// with an import path of its own, the type is left alone
+	assert.Empty(t, ws.TypeImports([]map[string]string{{"A": "lib.Thing@example.com/other/lib"}}, nil))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "go.work"), []byte("use ./a ./b\n"), 0644))
+	_, err = parse.ReadWorkspace(filepath.Join(root, "go.work"))
+	assert.Error(t, err)
+	assert.Equal(t, "workspace", parse.Diagnose(err).Rule)
+	assert.Equal(t, 1, parse.Diagnose(err).Line)
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genny-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	template := func() []parse.Template {
+		return []parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	}
+	typeSets := []map[string]string{{"Something": "int"}}
+	opts := parse.Options{CacheDir: dir}
+
+	out, err := parse.GenericsTemplates(template(), typeSets, opts)
+	assert.NoError(t, err)
+	cached, err := filepath.Glob(filepath.Join(dir, "*", "*.go"))
+	assert.NoError(t, err)
+	if assert.Len(t, cached, 1) {
+		assert.Equal(t, string(out), contents(cached[0]))
+		// the code is read from the cache from now on
+		assert.NoError(t, ioutil.WriteFile(cached[0], []byte("cached"), 0644))
+	}
+	out, err = parse.GenericsTemplates(template(), typeSets, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", string(out))
+
+	// other types, options or templates are generated again
+	out, err = parse.GenericsTemplates(template(), []map[string]string{{"Something": "string"}}, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type StringQueue struct")
+	out, err = parse.GenericsTemplates(template(), typeSets, parse.Options{CacheDir: dir, PkgName: "other"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "package other")
+	changed := []parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go") + "\nvar _ = 1\n")}}
+	out, err = parse.GenericsTemplates(changed, typeSets, opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntQueue struct")
+
+	// the code of a namer isn't cached
+	namer := parse.NamerFunc(func(generic, specific string) (string, error) { return "", nil })
+	out, err = parse.GenericsTemplates(template(), typeSets, parse.Options{CacheDir: dir, Namer: namer})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntQueue struct")
+	cached, err = filepath.Glob(filepath.Join(dir, "*", "*.go"))
+	assert.NoError(t, err)
+	assert.Len(t, cached, 4)
+}
+
+func TestRemoteCache(t *testing.T) {
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if code, ok := objects[r.URL.Path]; ok {
+				w.Write(code)
+				return
+			}
+			http.NotFound(w, r)
+		case http.MethodPut:
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			objects[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+		}
+	}))
+	defer server.Close()
+
+	cache, err := parse.OpenCache(server.URL + "/genny/")
+	assert.NoError(t, err)
+	template := func() []parse.Template {
+		return []parse.Template{{Filename: "generic_queue.go", Source: strings.NewReader(contents("test/queue/generic_queue.go"))}}
+	}
+	typeSets := []map[string]string{{"Something": "int"}}
+
+	// without the token, the code isn't cached but is still generated
+	out, err := parse.GenericsTemplates(template(), typeSets, parse.Options{Cache: cache})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type IntQueue struct")
+	assert.Empty(t, objects)
+	cache.(*parse.HTTPCache).Header = http.Header{"Authorization": {"Bearer token"}}
+	_, err = parse.GenericsTemplates(template(), typeSets, parse.Options{Cache: cache})
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 1) {
+		for path := range objects {
+			assert.True(t, strings.HasPrefix(path, "/genny/"), path)
+			objects[path] = []byte("cached")
+		}
+	}
+	out, err = parse.GenericsTemplates(template(), typeSets, parse.Options{Cache: cache})
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", string(out))
+
+	cache, err = parse.OpenCache("some/dir")
+	assert.NoError(t, err)
+	assert.Equal(t, parse.DirCache("some/dir"), cache)
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	_, err = parse.OpenCache("s3://bucket/prefix")
+	assert.EqualError(t, err, "the S3 cache needs a region, in AWS_REGION")
+
+	// the code comes over https, or from this machine
+	_, err = parse.OpenCache("http://cache.example.com/genny")
+	assert.EqualError(t, err, "the HTTP cache needs https:// unless it is on this machine, not http://cache.example.com/genny")
+	for _, location := range []string{"https://cache.example.com/genny", "http://localhost:8080", "http://[::1]:8080"} {
+		_, err = parse.OpenCache(location)
+		assert.NoError(t, err, location)
+	}
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	defer os.Setenv("AWS_ACCESS_KEY_ID", os.Getenv("AWS_ACCESS_KEY_ID"))
+	defer os.Setenv("AWS_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	defer os.Setenv("AWS_ENDPOINT_URL_S3", os.Getenv("AWS_ENDPOINT_URL_S3"))
+	os.Setenv("AWS_REGION", "us-east-1")
+	os.Setenv("AWS_ACCESS_KEY_ID", "id")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_ENDPOINT_URL_S3", "http://minio.example.com")
+	_, err = parse.OpenCache("s3://bucket/prefix")
+	assert.EqualError(t, err, "the S3 cache needs an https:// endpoint unless it is on this machine, not http://minio.example.com")
+	os.Setenv("AWS_ENDPOINT_URL_S3", "http://127.0.0.1:9000")
+	_, err = parse.OpenCache("s3://bucket/prefix")
+	assert.NoError(t, err)
+}
+
+func TestSplitCode(t *testing.T) {
+	code := []byte(`// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IntQueue is a queue.
+type IntQueue []int
+
+func (q IntQueue) String() string { return fmt.Sprint([]int(q)) }
+
+// StringQueue is a queue.
+type StringQueue []string
+
+func (q StringQueue) String() string { return strings.Join(q, ",") }
+`)
+
+	files, err := parse.SplitCode("gen/queue.go", code, parse.SplitLimits{MaxLines: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.OutputFile{{Filename: "queue.go", Code: code}}, files)
+
+	files, err = parse.SplitCode("gen/queue.go", code, parse.SplitLimits{MaxLines: 16})
+	assert.NoError(t, err)
+	if assert.Len(t, files, 2) {
+		assert.Equal(t, "queue_1.go", files[0].Filename)
+		assert.Equal(t, `// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+import (
+	"fmt"
+)
+
+// IntQueue is a queue.
+type IntQueue []int
+
+func (q IntQueue) String() string { return fmt.Sprint([]int(q)) }
+`, string(files[0].Code))
+		assert.Equal(t, "queue_2.go", files[1].Filename)
+		assert.Equal(t, `// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+import (
+	"strings"
+)
+
+// StringQueue is a queue.
+type StringQueue []string
+
+func (q StringQueue) String() string { return strings.Join(q, ",") }
+`, string(files[1].Code))
+	}
+
+	// a declaration over the limits gets a part of its own
+	files, err = parse.SplitCode("queue.go", code, parse.SplitLimits{MaxBytes: 10})
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	assert.Equal(t, "gen_2.go", parse.PartFilename("gen.go", 2))
+	assert.Equal(t, "gen_1_linux_amd64_test.go", parse.PartFilename("gen_linux_amd64_test.go", 1))
+}
+
+func TestKeepOrder(t *testing.T) {
+	existing := []byte(`// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+// StringQueue is a queue.
+type StringQueue []string
+
+// IntQueue is a queue.
+type IntQueue []int
+
+func init() {}
+
+// BoolQueue is a queue.
+type BoolQueue []bool
+`)
+	code := []byte(`// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+import "fmt"
+
+// IntQueue is a queue.
+type IntQueue []int
+
+// Float64Queue is a queue.
+type Float64Queue []float64
+
+func init() {}
+
+// StringQueue is a queue.
+type StringQueue []string
+
+func (q StringQueue) String() string { return fmt.Sprint([]string(q)) }
+`)
+
+	output, err := parse.KeepOrder(code, existing)
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated by genny. DO NOT EDIT.
+
+package queue
+
+import "fmt"
+
+// StringQueue is a queue.
+type StringQueue []string
+
+func (q StringQueue) String() string { return fmt.Sprint([]string(q)) }
+
+// IntQueue is a queue.
+type IntQueue []int
+
+// Float64Queue is a queue.
+type Float64Queue []float64
+
+func init() {}
+`, string(output))
+
+	// split existing code is taken in the order of its parts
+	output, err = parse.KeepOrder(code, existing[:bytes.Index(existing, []byte("// IntQueue"))], []byte("package queue\n\n"+string(existing[bytes.Index(existing, []byte("// IntQueue")):])))
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "type StringQueue []string\n\nfunc (q StringQueue) String() string { return fmt.Sprint([]string(q)) }\n\n// IntQueue is a queue.\n")
+
+	output, err = parse.KeepOrder(code)
+	assert.NoError(t, err)
+	assert.Equal(t, string(code), string(output))
+}
+
+func TestAnnotate(t *testing.T) {
+	template := `package list
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list.
+type ElemList []Elem
+
+func (l ElemList) Len() int { return len(l) }
+`
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "dir/list.go", Source: strings.NewReader(template)}}, []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{Annotate: true})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "// IntList is a list.\n// genny: list.go:8 Elem=int\ntype IntList []int\n")
+	assert.Contains(t, string(out), "// genny: list.go:10 Elem=int\nfunc (l IntList) Len() int")
+	assert.Contains(t, string(out), "// StringList is a list.\n// genny: list.go:8 Elem=string\ntype StringList []string\n")
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "func (l StringList) Len()") {
+			annotation, ok := parse.FindAnnotation(out, i+1)
+			assert.True(t, ok)
+			assert.Equal(t, "list.go:10 Elem=string", annotation)
+		}
+	}
+	_, ok := parse.FindAnnotation(out, 1)
+	assert.False(t, ok)
+
+	out, err = parse.GenericsTemplates([]parse.Template{{Filename: "dir/list.go", Source: strings.NewReader(template)}}, []map[string]string{{"Elem": "int"}}, parse.Options{})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "// genny: ")
+}
+
+func TestExplain(t *testing.T) {
+	template := func() []parse.Template {
+		return []parse.Template{{Filename: "list.go", Source: strings.NewReader(`package list
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list.
+type ElemList []Elem
+
+func (l ElemList) Len() int { return len(l) }
+`)}}
+	}
+
+	explanations, err := parse.Explain(template(), []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{}, "StringList")
+	assert.NoError(t, err)
+	assert.Equal(t, []parse.Explanation{{
+		Name:       "StringList",
+		Identifier: "ElemList",
+		Template:   "list.go",
+		Line:       8,
+		TypeSet:    "Elem=string",
+		Steps:      []string{"Elem in ElemList is string, which is named String from the type, starting with an upper case letter as Elem is exported, so ElemList becomes StringList"},
+	}}, explanations)
+
+	explanations, err = parse.Explain(template(), []map[string]string{{"Elem": "Person:github.com/me/people.Person"}}, parse.Options{
+		Export:  parse.ExportPolicy{Types: parse.Unexported},
+		Renames: map[string]string{"personList": "people"},
+	}, "people")
+	assert.NoError(t, err)
+	if assert.Len(t, explanations, 1) {
+		assert.Equal(t, []string{
+			"Elem in ElemList is people.Person, which is named Person from the title Person given with it, starting with an upper case letter as Elem is exported, so ElemList becomes PersonList",
+			"the export policy has it unexported, so PersonList becomes personList",
+			"the rename personList=people renames it to people",
+		}, explanations[0].Steps)
+	}
+
+	explanations, err = parse.Explain(template(), []map[string]string{{"Elem": "int"}, {"Elem": "string"}}, parse.Options{}, "Len")
+	assert.NoError(t, err)
+	if assert.Len(t, explanations, 1) {
+		assert.Equal(t, 10, explanations[0].Line)
+		assert.Equal(t, []string{"Len has none of the generic types in it, so it is the same for every type set"}, explanations[0].Steps)
+	}
+
+	explanations, err = parse.Explain(template(), []map[string]string{{"Elem": "int"}}, parse.Options{}, "StringList")
+	assert.NoError(t, err)
+	assert.Empty(t, explanations)
+}
+
+func TestRaw(t *testing.T) {
+	template := "# ElemList\n\nA list of elem values, like `ElemList{}`.\n\n\tid    Elem\n"
+	out, err := parse.GenericsTemplates([]parse.Template{{Filename: "list.md", Source: strings.NewReader(template)}}, []map[string]string{{"Elem": "int"}, {"Elem": "Person:github.com/me/people.Person"}}, parse.Options{Raw: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "# IntList\n\nA list of int values, like `IntList{}`.\n\n\tid    int\n"+
+		"# PersonList\n\nA list of person values, like `PersonList{}`.\n\n\tid    people.Person\n", string(out))
+
+	var buf bytes.Buffer
+	err = parse.GenericsTo(&buf, []parse.Template{{Filename: "list.md", Source: strings.NewReader(template)}}, []map[string]string{{"Elem": "int"}}, parse.Options{Raw: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "# IntList\n\nA list of int values, like `IntList{}`.\n\n\tid    int\n", buf.String())
+}
+
+func TestAstSubstitution(t *testing.T) {
+	template := `package box
+
+import (
+	"strings"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// ElemBox holds an Elem.
+type ElemBox struct {
+	Elem  Elem
+	Label string
+}
+
+func NewElemBox(e Elem) ElemBox { box := ElemBox{Elem: e, Label: strings.ToUpper("box")}; return box }
+
+func (b ElemBox) Get() (Elem, bool) { elems := []Elem{b.Elem}; return elems[0], len(elems) > 0 }
+`
+	generate := func(useAst bool) ([]byte, error) {
+		return parse.GenericsTemplates([]parse.Template{{Filename: "box.go", Source: strings.NewReader(template)}}, []map[string]string{{"Elem": "*big.Int"}}, parse.Options{UseAst: useAst})
+	}
+
+	out, err := generate(true)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `// BigIntBox holds an *big.Int.
+type BigIntBox struct {
+	BigInt *big.Int
+	Label  string
+}
+
+func NewBigIntBox(e *big.Int) BigIntBox {
+	box := BigIntBox{BigInt: e, Label: strings.ToUpper("box")}
+	return box
+}
+
+func (b BigIntBox) Get() (*big.Int, bool) {
+	bigInts := []*big.Int{b.BigInt}
+	return bigInts[0], len(bigInts) > 0
+}
+`)
+
+	// the words of the lines make the fields types
+	_, err = generate(false)
+	assert.Error(t, err)
+}
+
+func TestTypeParams(t *testing.T) {
+	template := `package list
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type (
+	// Key is a key.
+	Key   generic.Type
+	Value generic.Number
+)
+
+// KeyValueMap maps a Key to its Value.
+type KeyValueMap map[Key]Value
+
+// NewKeyValueMap makes a KeyValueMap.
+func NewKeyValueMap() KeyValueMap { return make(KeyValueMap) }
+
+// Sum adds up the values of m.
+func (m KeyValueMap) Sum() Value {
+	var total Value
+	for _, v := range m {
+		total += v
+	}
+	return total
+}
+
+func (m *KeyValueMap) String() string { return fmt.Sprint(sumKeyValueMap(*m)) }
+
+func sumKeyValueMap(m KeyValueMap) Value { return m.Sum() }
+
+type Elem generic.Type
+
+type ElemList []Elem
+
+func (l ElemList) Has(e Elem) bool { return len(l) > 0 && l[0] == e }
+`
+	generate := func(src string, opts parse.Options) ([]byte, error) {
+		opts.Mode = parse.ModeTypeParams
+		return parse.GenericsTemplates([]parse.Template{{Filename: "list.go", Source: strings.NewReader(src)}}, nil, opts)
+	}
+
+	out, err := generate(template, parse.Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `// Code generated by genny. DO NOT EDIT.
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/mauricelam/genny
+
+package list
+
+import (
+	"fmt"
+)
+
+// Map maps a Key to its Value.
+type Map[Key comparable, Value Number] map[Key]Value
+
+// NewMap makes a Map.
+func NewMap[Key comparable, Value Number]() Map[Key, Value] { return make(Map[Key, Value]) }
+
+// Sum adds up the values of m.
+func (m Map[Key, Value]) Sum() Value {
+	var total Value
+	for _, v := range m {
+		total += v
+	}
+	return total
+}
+
+func (m *Map[Key, Value]) String() string { return fmt.Sprint(sumMap[Key, Value](*m)) }
+
+func sumMap[Key comparable, Value Number](m Map[Key, Value]) Value { return m.Sum() }
+
+type List[Elem comparable] []Elem
+
+func (l List[Elem]) Has(e Elem) bool { return len(l) > 0 && l[0] == e }
+
+// Number is the constraint of the type parameters of generic.Number.
+type Number interface {
+	~float32 | ~float64 | ~int | ~int16 | ~int32 | ~int64 | ~int8 | ~uint | ~uint16 | ~uint32 | ~uint64 | ~uint8
+}
+`, string(out))
+
+	// the renames are of the names without the generic types
+	out, err = generate(template, parse.Options{Renames: map[string]string{"List": "Elems"}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "type Elems[Elem comparable] []Elem\n\nfunc (l Elems[Elem]) Has(")
+
+	for src, message := range map[string]string{
+		"package p\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype T generic.Type\n\nvar zeroT T\n":                                "Can't convert 'list.go:7' to type parameters: the variable zeroT uses the generic types T, which it can't have as type parameters",
+		"package p\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype T generic.Type\n\ntype P struct{}\n\nfunc (P) Print(t T) {}\n":  "Can't convert 'list.go:9' to type parameters: the method P.Print uses the generic types T, which its receiver doesn't have all of, and methods can't have type parameters of their own",
+		"package p\n\nimport \"github.com/mauricelam/genny/generic\"\n\ntype T generic.Type\n\nvar TLess = generic.Less\n\nfunc f(a T) {}\n": "Can't convert 'list.go:7' to type parameters: generic.Less has no form with type parameters",
+		"package p\n\nfunc f() {}\n": "Can't convert 'list.go:1' to type parameters: it has no generic types",
+	} {
+		_, err := generate(src, parse.Options{})
+		assert.EqualError(t, err, message)
+	}
+
+	_, err = parse.ParseMode("dynamic")
+	assert.EqualError(t, err, "Unknown mode 'dynamic' (expected copies or typeparams)")
+}
+
+func TestDegenerify(t *testing.T) {
+	code := `package list
+
+import "golang.org/x/exp/constraints"
+
+// Number is a number.
+type Number interface {
+	~float32 | ~float64 | ~int | ~int16 | ~int32 | ~int64 | ~int8 | ~uint | ~uint16 | ~uint32 | ~uint64 | ~uint8
+}
+
+// List is a list of E.
+type List[E comparable] []E
+
+// NewList makes a List.
+func NewList[E comparable]() List[E] { return List[E]{} }
+
+func (l List[E]) Has(e E) bool { return len(l) > 0 && l[0] == e }
+
+func (l List[_]) Len() int { return len(l) }
+
+func sum[N Number](xs []N) N {
+	var s N
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func Max[O constraints.Ordered](a, b O) O {
+	if a > b {
+		return a
+	}
+	return b
+}
+`
+	out, err := parse.Degenerify("list.go", []byte(code), map[string]string{"E": "Elem", "N": "Num", "O": "Key"}, `//go:generate genny -in=$GOFILE -out=gen-$GOFILE gen "Elem=int"`)
+	assert.NoError(t, err)
+	assert.Equal(t, `package list
+
+import (
+	"github.com/mauricelam/genny/generic"
+)
+
+//go:generate genny -in=$GOFILE -out=gen-$GOFILE gen "Elem=int"
+
+// Elem is a generic type, replaced with the specific types of genny gen.
+type Elem generic.Type
+
+// Num is a generic type, replaced with the specific types of genny gen.
+type Num generic.Number
+
+// Key is a generic type, replaced with the specific types of genny gen.
+type Key generic.Ordered
+
+// ElemList is a list of Elem.
+type ElemList []Elem
+
+// NewElemList makes a ElemList.
+func NewElemList() ElemList { return ElemList{} }
+
+func (l ElemList) Has(e Elem) bool { return len(l) > 0 && l[0] == e }
+
+func (l ElemList) Len() int { return len(l) }
+
+func sumNum(xs []Num) Num {
+	var s Num
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func KeyMax(a, b Key) Key {
+	if a > b {
+		return a
+	}
+	return b
+}
+`, string(out))
+
+	// the template gens for the types
+	gen, err := parse.GenericsTemplates([]parse.Template{{Filename: "list.go", Source: bytes.NewReader(out)}}, []map[string]string{{"Elem": "int", "Num": "float64", "Key": "string"}}, parse.Options{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(gen), "func (l IntList) Has(e int) bool")
+	assert.Contains(t, string(gen), "func sumFloat64(xs []float64) float64")
+
+	// the template and its copies are for every Go version
+	for src, expected := range map[string]string{
+		"//go:build go1.18\n\npackage p\n\nfunc F[T any](v T) {}\n":                                                  "package p\n",
+		"//go:build go1.18\n// +build go1.18\n\npackage p\n\nfunc F[T any](v T) {}\n":                                "package p\n",
+		"// Copyright\n\n//go:build go1.18 && linux\n// +build go1.18,linux\n\npackage p\n\nfunc F[T any](v T) {}\n": "// Copyright\n\n//go:build linux\n// +build linux\n\npackage p\n",
+		"//go:build linux\n\npackage p\n\nfunc F[T any](v T) {}\n":                                                   "//go:build linux\n\npackage p\n",
+	} {
+		out, err := parse.Degenerify("list.go", []byte(src), nil, "")
+		if assert.NoError(t, err, src) {
+			assert.True(t, strings.HasPrefix(string(out), expected), "%q", out)
+			assert.NotContains(t, string(out), "go1.18", src)
+		}
+	}
+
+	// the code genny generated becomes a template that it didn't
+	for src, expected := range map[string]string{
+		"// Code generated by genny. DO NOT EDIT.\n// This file was automatically generated by genny.\n// Any changes will be lost if this file is regenerated.\n// see https://github.com/mauricelam/genny\n\npackage p\n\nfunc F[T any](v T) {}\n":                               "package p\n",
+		"// Code generated by genny. DO NOT EDIT.\n// This file was automatically generated by genny.\n// Any changes will be lost if this file is regenerated.\n// see https://github.com/mauricelam/genny\n\n//go:build go1.18 && linux\n\npackage p\n\nfunc F[T any](v T) {}\n": "//go:build linux\n\npackage p\n",
+	} {
+		out, err := parse.Degenerify("list.go", []byte(src), nil, "")
+		if assert.NoError(t, err, src) {
+			assert.True(t, strings.HasPrefix(string(out), expected), "%q", out)
+			assert.False(t, parse.IsGenerated(out), src)
+		}
+	}
+
+	for src, message := range map[string]string{
+		"package p\n\nfunc f() {}\n": "Can't convert 'list.go:1' to a template: it has no type parameters",
+		"package p\n\nfunc F[T any](t T) {}\n\nfunc G[T ~int | ~string](t T) {}\n":                                   "Can't convert 'list.go:5' to a template: the constraint ~int | ~string of G has no placeholder type in the generic package, which has them for any, comparable and the unions of the built-in types of generic.Number, Ordered, Integer, Unsigned and Float",
+		"package p\n\nimport \"cmp\"\n\nfunc F[T any](t T) {}\n\nfunc G[T cmp.Ordered](t T) {}\n":                    "Can't convert 'list.go:7' to a template: the type parameter T of G is generic.Ordered, but that of F is generic.Type, and a generic type has one placeholder type",
+		"package p\n\ntype List[T any] []T\n\nvar ints List[int]\n":                                                  "Can't convert 'list.go:5' to a template: List[int] gives List other types than its type parameters T, which a template can't, as it names the copy of List that it uses after its generic types",
+		"package p\n\nimport \"strings\"\n\nfunc Upper[T any](s string, _ T) string { return strings.ToUpper(s) }\n": "Can't convert 'list.go:5' to a template: genny would substitute the specific types of the generic type T into ToUpper too, which a longer name for T, given with a rename, keeps apart",
+		"package p\n\nimport \"fmt\"\n\nfunc Print[T any](t T) { fmt.Println(t) }\n":                                 "Can't convert 'list.go:5' to a template: genny would substitute the specific types of the generic type T into t too, which a longer name for T, given with a rename, keeps apart",
+		"package p\n\ntype Set[T comparable] map[T]bool\n\nfunc (s Set[E]) Add(e E) { s[e] = true }\n":               "Can't convert 'list.go:5' to a template: the receiver of Set.Add names the type parameters of Set other than T, as Set does, which a template can't",
+	} {
+		_, err := parse.Degenerify("list.go", []byte(src), nil, "")
+		assert.EqualError(t, err, message)
+	}
+}
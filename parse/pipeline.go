@@ -0,0 +1,87 @@
+package parse
+
+// Stage is a stage of the code generation, after which custom transforms
+// can run. The stages run in this order:
+//
+//	read → substitute → merge → package → format
+type Stage int
+
+const (
+	// StageRead reads each template and resolves its includes and base.
+	// Its transforms get the source of the template.
+	StageRead Stage = iota
+	// StageSubstitute substitutes the specific types of a type set into a
+	// template. Its transforms get the code of one template for one type
+	// set, before the declarations of earlier type sets are removed.
+	StageSubstitute
+	// StageMerge merges the code of all of the templates and type sets
+	// into one file.
+	StageMerge
+	// StagePackage renames the package, if Options.PkgName is set.
+	StagePackage
+	// StageFormat fixes the imports, formats the code and sorts the
+	// declarations. Its transforms get the code that would be output.
+	StageFormat
+)
+
+var stageNames = []string{"read", "substitute", "merge", "package", "format"}
+
+// String gets the name of the stage.
+func (s Stage) String() string {
+	if s < 0 || int(s) >= len(stageNames) {
+		return "unknown"
+	}
+	return stageNames[s]
+}
+
+// Transformer is a custom stage of the code generation, see
+// Options.Transforms.
+type Transformer interface {
+	// Transform gets the code transformed. The code given to a transform
+	// must not be changed in place.
+	Transform(code []byte, info TransformInfo) ([]byte, error)
+}
+
+// TransformFunc is a function that is a Transformer.
+type TransformFunc func(code []byte, info TransformInfo) ([]byte, error)
+
+// Transform calls f.
+func (f TransformFunc) Transform(code []byte, info TransformInfo) ([]byte, error) {
+	return f(code, info)
+}
+
+// TransformInfo describes the code given to a transform.
+type TransformInfo struct {
+	// Stage is the stage that the transform runs after.
+	Stage Stage
+	// Filename is the name of the template of the code, or of the first
+	// template after the code of the templates was merged.
+	Filename string
+	// TypeSet is the type set of the code, as it was given, after
+	// StageSubstitute. It is nil for the other stages.
+	TypeSet map[string]string
+}
+
+// transform runs the transforms of the stage on the code.
+func (opts Options) transform(stage Stage, code []byte, filename string, typeSet map[string]string) ([]byte, error) {
+	info := TransformInfo{Stage: stage, Filename: filename, TypeSet: typeSet}
+	for _, t := range opts.Transforms[stage] {
+		var err error
+		code, err = t.Transform(code, info)
+		if err != nil {
+			return nil, &errTransform{Stage: stage, Filename: filename, Err: err}
+		}
+	}
+	return code, nil
+}
+
+// transformsOutput tells whether there are transforms of the stages after
+// StageSubstitute, which need the whole output.
+func (opts Options) transformsOutput() bool {
+	for stage, transforms := range opts.Transforms {
+		if stage > StageSubstitute && len(transforms) > 0 {
+			return true
+		}
+	}
+	return false
+}
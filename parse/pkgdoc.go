@@ -0,0 +1,111 @@
+package parse
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// packageDoc gets the lines of the package doc comment of the template,
+// exactly as they were written.
+func packageDoc(filename string, src []byte) ([]string, error) {
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	if file.Doc == nil {
+		return nil, nil
+	}
+	doc := string(src[fs.Position(file.Doc.Pos()).Offset:fs.Position(file.Doc.End()).Offset])
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		lines = append(lines, makeLine(line))
+	}
+	return lines, nil
+}
+
+// packageName gets the package name of the template.
+func packageName(filename string, src []byte) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.PackageClauseOnly)
+	if err != nil {
+		return "", &errSource{Err: err}
+	}
+	return file.Name.Name, nil
+}
+
+// trailingComment gets the index into lines, no lower than start, where the
+// comment that the lines end with begins. This is len(lines) if they don't
+// end with a comment.
+func trailingComment(lines []string, start int) int {
+	i := len(lines)
+	inBlock := false
+	for i > start {
+		line := strings.TrimSpace(lines[i-1])
+		switch {
+		case inBlock:
+			inBlock = !strings.HasPrefix(line, "/*")
+		case strings.HasSuffix(line, "*/"):
+			inBlock = !strings.HasPrefix(line, "/*")
+		case !strings.HasPrefix(line, "//") || isConstraintLine(line):
+			return i
+		}
+		i--
+	}
+	return i
+}
+
+// packageClauseFinder finds the package clause of a file that is scanned
+// line by line, skipping over the comments that may come before it.
+type packageClauseFinder struct {
+	inBlock bool
+}
+
+// find gets the offset of the package keyword in line, or -1 if the line
+// is not the package clause. It must be called for every line before the
+// package clause, in order.
+func (f *packageClauseFinder) find(line string) int {
+	offset := 0
+	for offset < len(line) {
+		rest := line[offset:]
+		if f.inBlock {
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				return -1
+			}
+			f.inBlock = false
+			offset += end + 2
+			continue
+		}
+		trimmed := strings.TrimLeft(rest, " \t")
+		offset += len(rest) - len(trimmed)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, "//"):
+			return -1
+		case strings.HasPrefix(trimmed, "/*"):
+			f.inBlock = true
+			offset += 2
+			continue
+		}
+		if strings.HasPrefix(trimmed, string(packageKeyword)) &&
+			(len(trimmed) == len(packageKeyword) || !isAlphaNumeric(rune(trimmed[len(packageKeyword)]))) {
+			return offset
+		}
+		return -1
+	}
+	return -1
+}
+
+// renamePackage replaces the package name in the package clause line, which
+// has the package keyword at offset.
+func renamePackage(line string, offset int, pkgName string) string {
+	start := offset + len(packageKeyword)
+	for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+		start++
+	}
+	end := start
+	for end < len(line) && isAlphaNumeric(rune(line[end])) {
+		end++
+	}
+	return line[:start] + pkgName + line[end:]
+}
@@ -0,0 +1,117 @@
+package parse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// genericFormatVerb is the placeholder of the fmt verb of a specific type.
+// The others, generic.Hash, generic.Less and generic.Equal, are of
+// functions that are given with the specific type.
+const genericFormatVerb = "generic.FormatVerb"
+
+// placeholderDecl matches a constant or variable set to a placeholder,
+// like ElemVerb = generic.FormatVerb, alone or in a block.
+var placeholderDecl = regexp.MustCompile(`^(\s*(?:const\s+|var\s+)?)(\w+)(\s*=\s*)(generic\.(?:FormatVerb|Hash|Less|Equal))\b`)
+
+// formatVerb gets the fmt verb that prints a value of the specific type
+// like it is usually printed.
+func formatVerb(specific string) string {
+	switch typify(specific) {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+		return "%d"
+	case "float32", "float64":
+		return "%f"
+	case "string", "rune":
+		return "%q"
+	case "bool":
+		return "%t"
+	}
+	return "%v"
+}
+
+// splitFuncs takes the functions off the specific types of the type sets,
+// which are written after the type, like Title:Type:hashFunc:lessFunc, and
+// gets them by generic type.
+func splitFuncs(typeSets []map[string]string) ([]map[string]string, []map[string][]string) {
+	stripped := make([]map[string]string, 0, len(typeSets))
+	funcs := make([]map[string][]string, 0, len(typeSets))
+	for _, typeSet := range typeSets {
+		s := make(map[string]string, len(typeSet))
+		f := make(map[string][]string)
+		for generic, specific := range typeSet {
+			s[generic] = specific
+			title := strings.Index(specific, titleSep)
+			if title < 0 {
+				continue
+			}
+			sep := strings.Index(specific[title+1:], titleSep)
+			if sep < 0 {
+				continue
+			}
+			start := title + 1 + sep
+			end := len(specific)
+			if i := strings.IndexAny(specific[start:], importSep+namingSep); i >= 0 {
+				end = start + i
+			}
+			f[generic] = strings.Split(specific[start+1:end], titleSep)
+			s[generic] = specific[:start] + specific[end:]
+		}
+		stripped = append(stripped, s)
+		funcs = append(funcs, f)
+	}
+	return stripped, funcs
+}
+
+// placeholderFunc gets the function of a specific type for a placeholder,
+// which is the first whose name has the word of the placeholder in it, like
+// hash in hashString, or else the only one.
+func placeholderFunc(placeholder string, funcs []string) string {
+	word := strings.ToLower(strings.TrimPrefix(placeholder, genericPackage+"."))
+	for _, f := range funcs {
+		if strings.Contains(strings.ToLower(f), word) {
+			return f
+		}
+	}
+	if len(funcs) == 1 {
+		return funcs[0]
+	}
+	return ""
+}
+
+// expandPlaceholders replaces the placeholder of every constant or variable
+// that is named after a generic type, like ElemVerb, with its value for the
+// specific type, before the types are substituted. funcs are the functions
+// of the specific types, by generic type.
+func expandPlaceholders(filename string, src []byte, typeSet map[string]string, funcs map[string][]string) ([]byte, error) {
+	if !strings.Contains(string(src), genericPackage+".") {
+		return src, nil
+	}
+	lines := strings.Split(string(src), "\n")
+	for i, line := range lines {
+		m := placeholderDecl.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		name, placeholder := line[m[4]:m[5]], line[m[8]:m[9]]
+		generic := ""
+		for t := range typeSet {
+			if strings.Contains(name, t) && len(t) > len(generic) {
+				generic = t
+			}
+		}
+		if generic == "" {
+			return nil, &errPlaceholder{Filename: filename, Line: i + 1, Name: name, Placeholder: placeholder}
+		}
+		value := strconv.Quote(formatVerb(typeSet[generic]))
+		if placeholder != genericFormatVerb {
+			value = placeholderFunc(placeholder, funcs[generic])
+			if value == "" {
+				return nil, &errNoFunc{Placeholder: placeholder, GenericType: generic, SpecificType: typeSet[generic]}
+			}
+		}
+		lines[i] = line[:m[8]] + value + line[m[9]:]
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
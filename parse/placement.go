@@ -0,0 +1,96 @@
+package parse
+
+import "strings"
+
+// Placement is a type set argument of genny gen with the file and package
+// that the pkg= and out= arguments after it place its type sets in, like
+//
+//	genny gen "Elem=string" "Elem=int" pkg=fastints out=ints/gen.go
+//
+// which generates string into -out and int into ints/gen.go, in package
+// fastints, within one run.
+type Placement struct {
+	// Types are the gen types, written like the argument of TypeSet.
+	Types string
+	// Out is the file the type sets are written to, or "" for -out.
+	Out string
+	// Pkg is the package name of the code written to Out, or "" for that
+	// of -pkg or the templates.
+	Pkg string
+}
+
+// placementKeys are the arguments that place the type set argument before
+// them, which are lower case so that they aren't taken for generic types.
+var placementKeys = []string{"out=", "pkg="}
+
+// isPlacement tells whether an argument of genny gen places the type set
+// argument before it, rather than being one.
+func isPlacement(arg string) bool {
+	for _, key := range placementKeys {
+		if strings.HasPrefix(arg, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePlacements parses the arguments of genny gen into the type set
+// arguments, each placed by the pkg= and out= arguments that follow it.
+func ParsePlacements(args []string) ([]Placement, error) {
+	var placements []Placement
+	for _, arg := range args {
+		if !isPlacement(arg) {
+			placements = append(placements, Placement{Types: arg})
+			continue
+		}
+		if len(placements) == 0 {
+			return nil, &errBadPlacement{Arg: arg, Message: "it must come after the types it places"}
+		}
+		p := &placements[len(placements)-1]
+		value := arg[len("out="):]
+		field := &p.Out
+		if strings.HasPrefix(arg, "pkg=") {
+			field = &p.Pkg
+		}
+		switch {
+		case value == "":
+			return nil, &errBadPlacement{Arg: arg, Message: "it has no value"}
+		case *field != "":
+			return nil, &errBadPlacement{Arg: arg, Message: "the types '" + p.Types + "' are placed twice"}
+		}
+		*field = value
+	}
+	for _, p := range placements {
+		if p.Pkg != "" && p.Out == "" {
+			return nil, &errBadPlacement{Arg: "pkg=" + p.Pkg, Message: "a package needs a file of its own, given with out="}
+		}
+	}
+	return placements, nil
+}
+
+// PlacedOutputs gets the files that the placements are generated into,
+// with the type sets of each of them, in the order they are first placed.
+// The type sets of the placements with the same Out are generated into one
+// file, which must have one package. The output with an Out of "" is that
+// of -out. maxTypeSets limits the type sets of each placement like in
+// TypeSetLimit.
+func PlacedOutputs(placements []Placement, maxTypeSets int) ([]ConfigOutput, error) {
+	var outputs []ConfigOutput
+	index := make(map[string]int)
+	for _, p := range placements {
+		typeSets, err := TypeSetLimit(p.Types, maxTypeSets)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[p.Out]
+		if !ok {
+			i = len(outputs)
+			index[p.Out] = i
+			outputs = append(outputs, ConfigOutput{Out: p.Out, Pkg: p.Pkg})
+		} else if outputs[i].Pkg != p.Pkg {
+			return nil, &errBadPlacement{Arg: "out=" + p.Out, Message: "the type sets written to it are in different packages"}
+		}
+		outputs[i].TypeSets = append(outputs[i].TypeSets, typeSets...)
+	}
+	return outputs, nil
+}
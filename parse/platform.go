@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"strings"
+)
+
+// knownOS and knownArch are the values of GOOS and GOARCH that the go tool
+// recognizes in file name suffixes.
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+		"js": true, "linux": true, "nacl": true, "netbsd": true,
+		"openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true,
+		"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+		"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+		"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+		"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// Platform is an operating system and architecture that generated code is
+// restricted to. Either of them may be empty to mean any.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+}
+
+// ParsePlatform parses a platform written like "linux/amd64", or just
+// "linux" or "amd64".
+func ParsePlatform(s string) (Platform, error) {
+	var p Platform
+	if i := strings.Index(s, "/"); i >= 0 {
+		p = Platform{GOOS: s[:i], GOARCH: s[i+1:]}
+		if !knownOS[p.GOOS] || !knownArch[p.GOARCH] {
+			return Platform{}, &errBadPlatform{Name: s}
+		}
+		return p, nil
+	}
+	switch {
+	case knownOS[s]:
+		p.GOOS = s
+	case knownArch[s]:
+		p.GOARCH = s
+	default:
+		return Platform{}, &errBadPlatform{Name: s}
+	}
+	return p, nil
+}
+
+// String gets the platform as it is written for ParsePlatform.
+func (p Platform) String() string {
+	if p.GOOS != "" && p.GOARCH != "" {
+		return p.GOOS + "/" + p.GOARCH
+	}
+	return p.GOOS + p.GOARCH
+}
+
+// Constraint gets the build constraint expression of the platform, like
+// "linux && amd64", for Options.BuildConstraint.
+func (p Platform) Constraint() string {
+	if p.GOOS != "" && p.GOARCH != "" {
+		return p.GOOS + " && " + p.GOARCH
+	}
+	return p.GOOS + p.GOARCH
+}
+
+// Filename gets the name of the file for the platform, by adding a
+// _GOOS_GOARCH suffix to name, so gen.go becomes gen_linux_amd64.go and
+// gen_test.go becomes gen_linux_amd64_test.go.
+func (p Platform) Filename(name string) string {
+	suffix := ""
+	if p.GOOS != "" {
+		suffix += "_" + p.GOOS
+	}
+	if p.GOARCH != "" {
+		suffix += "_" + p.GOARCH
+	}
+	ext := ""
+	if i := strings.LastIndex(name, "."); i > strings.LastIndexAny(name, `/\`) {
+		name, ext = name[:i], name[i:]
+	}
+	if strings.HasSuffix(name, "_test") {
+		return strings.TrimSuffix(name, "_test") + suffix + "_test" + ext
+	}
+	return name + suffix + ext
+}
@@ -0,0 +1,124 @@
+package parse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// PluginPrefix is the prefix of the name of a plugin binary, which is
+// looked up in the PATH: the plugin "metrics" is run as genny-gen-metrics.
+const PluginPrefix = "genny-gen-"
+
+// PluginRequest is written to the stdin of a plugin as JSON. The plugin
+// writes the transformed code to its stdout, or the name of the specific
+// type if the stage is "naming", and fails by writing a message to its
+// stderr and exiting with a non-zero status.
+type PluginRequest struct {
+	// Stage is the name of the stage the plugin runs after, or "naming"
+	// if the plugin names a specific type.
+	Stage string `json:"stage"`
+	// Generic and Specific are the generic type and the specific type to
+	// name, for "naming".
+	Generic  string `json:"generic,omitempty"`
+	Specific string `json:"specific,omitempty"`
+	// Filename is the name of the template of the code.
+	Filename string `json:"filename"`
+	// TypeSet is the type set substituted into the code, if it was
+	// substituted for a single type set.
+	TypeSet map[string]string `json:"typeSet,omitempty"`
+	// Parameter is the parameter of the plugin, see Plugin.
+	Parameter string `json:"parameter,omitempty"`
+	// Source is the code to transform.
+	Source string `json:"source,omitempty"`
+}
+
+// Plugin is a Transformer that runs an external binary, so that code can be
+// transformed without changes to genny. It is usually run after
+// StageSubstitute, where it gets the source of one template with one type
+// set substituted into it.
+type Plugin struct {
+	// Name is the name of the plugin.
+	Name string
+	// Path is the path of the binary, if it is not PluginPrefix+Name in
+	// the PATH.
+	Path string
+	// Parameter is passed to the plugin in the request.
+	Parameter string
+	// Deadline, if it is set, is when the plugin is killed if it is still
+	// running.
+	Deadline time.Time
+}
+
+// ParsePlugin parses a plugin given as name or name=parameter.
+func ParsePlugin(arg string) (Plugin, error) {
+	name, parameter := arg, ""
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		name, parameter = arg[:i], arg[i+1:]
+	}
+	if name == "" {
+		return Plugin{}, &errBadPlugin{Arg: arg}
+	}
+	return Plugin{Name: name, Parameter: parameter}, nil
+}
+
+// Transform runs the plugin on the code.
+func (p Plugin) Transform(code []byte, info TransformInfo) ([]byte, error) {
+	return p.run(PluginRequest{
+		Stage:    info.Stage.String(),
+		Filename: info.Filename,
+		TypeSet:  info.TypeSet,
+		Source:   string(code),
+	})
+}
+
+// Namer gets a Namer that runs the plugin to name the specific types. An
+// empty output leaves it to the naming policy.
+func (p Plugin) Namer() Namer {
+	return NamerFunc(func(generic, specific string) (string, error) {
+		out, err := p.run(PluginRequest{Stage: "naming", Generic: generic, Specific: specific})
+		if err != nil {
+			return "", err
+		}
+		return string(bytes.TrimSpace(out)), nil
+	})
+}
+
+// run writes the request to the stdin of the plugin and gets its stdout.
+func (p Plugin) run(request PluginRequest) ([]byte, error) {
+	path := p.Path
+	if path == "" {
+		var err error
+		path, err = exec.LookPath(PluginPrefix + p.Name)
+		if err != nil {
+			return nil, &errPlugin{Name: p.Name, Err: err}
+		}
+	}
+	request.Parameter = p.Parameter
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, &errPlugin{Name: p.Name, Err: err}
+	}
+
+	ctx := context.Background()
+	if !p.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, p.Deadline)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, &errPlugin{Name: p.Name, Err: err, Stderr: string(bytes.TrimSpace(stderr.Bytes()))}
+	}
+	return stdout.Bytes(), nil
+}
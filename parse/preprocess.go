@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"text/template"
+)
+
+// The delimiters of the actions of templates that are preprocessed, which
+// keep them valid Go code, like
+//
+//	/*{{if eq .Elem "string"}}*/
+//	func (l ElemList) Join() string { ... }
+//	/*{{end}}*/
+const (
+	preprocessLeft  = "/*{{"
+	preprocessRight = "}}*/"
+)
+
+// preprocessor runs the source of a template as a text/template with each
+// type set, before the types are substituted.
+type preprocessor struct {
+	tmpl *template.Template
+}
+
+// newPreprocessor parses the source of a template. values are those of
+// Options.Values, which the template gets with the values function, like
+// {{(values .Elem).verb}}.
+func newPreprocessor(filename string, src []byte, values map[string]map[string]string) (*preprocessor, error) {
+	funcs := NamingFuncs()
+	funcs["values"] = func(specific string) (map[string]string, error) {
+		v, ok := values[specific]
+		if !ok {
+			return nil, errors.New("no values for the type '" + specific + "'")
+		}
+		return v, nil
+	}
+	t, err := template.New(filename).Delims(preprocessLeft, preprocessRight).Funcs(funcs).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return nil, &errPreprocess{Filename: filename, Err: err}
+	}
+	return &preprocessor{tmpl: t}, nil
+}
+
+// run runs the template with the specific types of the type set as its
+// data, so that {{.Elem}} is the specific type of Elem. The template can use
+// NamingFuncs.
+func (p *preprocessor) run(typeSet map[string]string) ([]byte, error) {
+	data := make(map[string]string, len(typeSet))
+	for generic, specific := range typeSet {
+		data[generic] = typify(specific)
+	}
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return nil, &errPreprocess{Filename: p.tmpl.Name(), Err: err}
+	}
+	return buf.Bytes(), nil
+}
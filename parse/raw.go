@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// genericsRaw generates the text of every template for every type set in
+// turn, for Options.Raw. The generic types are replaced in the words of the
+// text like in Go code, and nothing else is done to it: it isn't parsed,
+// formatted or given a header, and has no imports or package clause.
+func genericsRaw(templates []Template, typeSets []map[string]string, opts Options) ([]byte, error) {
+	start := time.Now()
+	if err := opts.Limits.checkInstantiations(len(templates) * len(typeSets)); err != nil {
+		return nil, err
+	}
+	opts.Stats.addTemplates(len(templates), len(typeSets))
+	// the functions of the specific types are for the placeholders of Go
+	// code, which the text has none of
+	named, _ := splitFuncs(typeSets)
+	named, err := namedTypeSets(named, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	for _, template := range templates {
+		src, err := opts.Limits.readSource(template)
+		if err != nil {
+			return nil, err
+		}
+		src = normalizeEOL(src)
+		var p *preprocessor
+		if opts.Preprocess {
+			if p, err = newPreprocessor(template.Filename, src, opts.Values); err != nil {
+				return nil, err
+			}
+		}
+		for _, typeSet := range named {
+			text := src
+			if p != nil {
+				if text, err = p.run(typeSet); err != nil {
+					return nil, err
+				}
+			}
+			lines := strings.SplitAfter(string(text), "\n")
+			if lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			for _, line := range lines {
+				eol := ""
+				if strings.HasSuffix(line, "\n") {
+					line, eol = strings.TrimSuffix(line, "\n"), "\n"
+				}
+				for _, generic := range sortedKeys(typeSet) {
+					line = subTypeIntoWords(line, generic, typeSet[generic])
+				}
+				output.WriteString(line + eol)
+			}
+			if output.Len() > 0 && output.Bytes()[output.Len()-1] != '\n' {
+				output.WriteString("\n")
+			}
+			if err := opts.Limits.checkOutputSize(output.Len()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	opts.Stats.phase("generate", start)
+	opts.Stats.addFile()
+	opts.Stats.addOutput(output.Bytes())
+	return output.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// registryConstructor is a constructor of a template, a function like
+// NewElemList that takes nothing and returns a value, along with the
+// generic types in its name.
+type registryConstructor struct {
+	name     string
+	generics []string
+}
+
+// GenericsRegistry generates a file with a map from the specific types to
+// the constructors generated for them, for every constructor of the
+// templates, so that the generated types can be made without reflection:
+//
+//	var NewListFor = map[string]func() interface{}{
+//		"int":    func() interface{} { return NewIntList() },
+//		"string": func() interface{} { return NewStringList() },
+//	}
+//
+// A constructor is a function that takes nothing, returns one value and
+// has a generic type in its name, like NewElemList. The map is named after
+// it without the generic types and with For appended. The keys are the
+// specific types of the generic types in the name, separated by commas.
+func GenericsRegistry(templates []Template, typeSets []map[string]string, opts Options) ([]byte, error) {
+	opts.Stats = nil
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	genericSet := make(map[string]string)
+	for _, typeSet := range typeSets {
+		for generic := range typeSet {
+			genericSet[generic] = ""
+		}
+	}
+	generics := sortedKeys(genericSet)
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	for i, template := range g.templates {
+		src := g.sources[i]
+		name, constructors, err := templateConstructors(template.Filename, src, generics)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			if opts.PkgName != "" {
+				name = opts.PkgName
+			}
+			buf.WriteString(makeLine("package " + name))
+		}
+		for _, c := range constructors {
+			writeRegistry(&buf, c, g.argTypeSets, g.typeSets)
+		}
+	}
+	return formatOutput(templates[0].Filename, renameSymbols(buf.Bytes(), opts.Renames))
+}
+
+// writeRegistry writes the map of a constructor.
+func writeRegistry(buf *bytes.Buffer, c registryConstructor, argTypeSets, typeSets []map[string]string) {
+	varName := c.name
+	for _, generic := range c.generics {
+		varName = strings.Replace(varName, generic, "", -1)
+	}
+
+	fmt.Fprintf(buf, "\n// %sFor maps the specific types to the %s constructors generated for them.\n", varName, varName)
+	fmt.Fprintf(buf, "var %sFor = map[string]func() interface{}{\n", varName)
+	seen := make(map[string]bool)
+	for i, typeSet := range typeSets {
+		var specifics []string
+		for _, generic := range c.generics {
+			specifics = append(specifics, parseSpecificArg(argTypeSets[i][generic]).Type)
+		}
+		key := strings.Join(specifics, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		name := c.name
+		for _, generic := range sortedKeys(typeSet) {
+			name = subIntoLiteral(name, generic, typeSet[generic])
+		}
+		fmt.Fprintf(buf, "\t%s: func() interface{} { return %s() },\n", strconv.Quote(key), name)
+	}
+	buf.WriteString("}\n")
+}
+
+// templateConstructors gets the package name and the constructors of a
+// template.
+func templateConstructors(filename string, src []byte, generics []string) (string, []registryConstructor, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if err != nil {
+		return "", nil, &errSource{Err: err}
+	}
+	var constructors []registryConstructor
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+			continue
+		}
+		if fn.Type.Params.NumFields() != 0 || fn.Type.Results.NumFields() != 1 {
+			continue
+		}
+		c := registryConstructor{name: fn.Name.Name}
+		for _, generic := range generics {
+			if strings.Contains(c.name, generic) {
+				c.generics = append(c.generics, generic)
+			}
+		}
+		if len(c.generics) > 0 {
+			sort.Strings(c.generics)
+			constructors = append(constructors, c)
+		}
+	}
+	return file.Name.Name, constructors, nil
+}
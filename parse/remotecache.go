@@ -0,0 +1,325 @@
+package parse
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenCache gets the cache at the location, which is a directory, an
+// https:// URL of an HTTPCache, or an http:// one on this machine, or
+// s3://bucket/prefix for an S3Cache with the credentials of the AWS
+// environment variables. It gets nil for "".
+func OpenCache(location string) (Cache, error) {
+	switch {
+	case location == "":
+		return nil, nil
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		if !secureURL(location) {
+			// the generated code it serves is compiled, so nobody on the
+			// way may change it
+			return nil, fmt.Errorf("the HTTP cache needs https:// unless it is on this machine, not %s", location)
+		}
+		return &HTTPCache{URL: strings.TrimSuffix(location, "/")}, nil
+	case strings.HasPrefix(location, "s3://"):
+		return s3CacheFromEnv(strings.TrimPrefix(location, "s3://"))
+	}
+	return DirCache(location), nil
+}
+
+// cacheTimeout is how long a remote cache has to answer, after which the
+// code is generated instead.
+const cacheTimeout = 30 * time.Second
+
+// HTTPCache is a Cache on an HTTP server, which has the code of a key at
+// URL/<key>.go for GET and PUT, like the remote caches of build systems, so
+// that CI machines and laptops share the code they generated.
+type HTTPCache struct {
+	URL string
+	// Header is added to the requests, like an Authorization.
+	Header http.Header
+	// Client does the requests, or one with a timeout if it is nil.
+	Client *http.Client
+}
+
+// Get downloads the code of the key.
+func (c *HTTPCache) Get(key string) ([]byte, bool) {
+	req, err := http.NewRequest(http.MethodGet, c.URL+"/"+key+".go", nil)
+	if err != nil {
+		return nil, false
+	}
+	return doCacheGet(c.client(), req, c.Header)
+}
+
+// Put uploads the code of the key.
+func (c *HTTPCache) Put(key string, code []byte) error {
+	req, err := http.NewRequest(http.MethodPut, c.URL+"/"+key+".go", bytes.NewReader(code))
+	if err != nil {
+		return err
+	}
+	return doCachePut(c.client(), req, c.Header)
+}
+
+func (c *HTTPCache) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: cacheTimeout}
+}
+
+// doCacheGet does the GET request of a remote cache, for the code in the
+// body of a 200 response.
+func doCacheGet(client *http.Client, req *http.Request, header http.Header) ([]byte, bool) {
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	code, err := ioutil.ReadAll(resp.Body)
+	return code, err == nil
+}
+
+// doCachePut does the PUT request of a remote cache.
+func doCachePut(client *http.Client, req *http.Request, header http.Header) error {
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "text/x-go; charset=utf-8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return nil
+}
+
+// S3Cache is a Cache in an S3 bucket, or in a bucket of a service
+// compatible with S3, with the code of a key in the object
+// <Prefix><key>.go. The requests are signed with AWS Signature Version 4.
+type S3Cache struct {
+	Bucket string
+	// Prefix starts the names of the objects, like genny/.
+	Prefix string
+	Region string
+	// Endpoint is the URL of a service compatible with S3, whose objects
+	// are at Endpoint/Bucket/name, instead of AWS.
+	Endpoint string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is for temporary credentials.
+	SessionToken string
+
+	// Client does the requests, or one with a timeout if it is nil.
+	Client *http.Client
+	// now is the time of the signatures, time.Now if it is nil.
+	now func() time.Time
+}
+
+// s3CacheFromEnv gets the cache in bucket/prefix with the credentials,
+// region and endpoint of the AWS environment variables.
+func s3CacheFromEnv(location string) (*S3Cache, error) {
+	parts := strings.SplitN(location, "/", 2)
+	c := &S3Cache{
+		Bucket:          parts[0],
+		Region:          os.Getenv("AWS_REGION"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL_S3"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		c.Prefix = strings.TrimSuffix(parts[1], "/") + "/"
+	}
+	if c.Region == "" {
+		c.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	switch {
+	case c.Bucket == "":
+		return nil, errors.New("the S3 cache needs a bucket, like s3://bucket/prefix")
+	case c.Region == "":
+		return nil, errors.New("the S3 cache needs a region, in AWS_REGION")
+	case c.AccessKeyID == "" || c.SecretAccessKey == "":
+		return nil, errors.New("the S3 cache needs credentials, in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	case c.Endpoint != "" && !secureURL(c.Endpoint):
+		return nil, fmt.Errorf("the S3 cache needs an https:// endpoint unless it is on this machine, not %s", c.Endpoint)
+	}
+	return c, nil
+}
+
+// secureURL tells whether a URL is https://, or http:// on a loopback
+// address, where nobody can read or change the requests on the way.
+func secureURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return u.Scheme == "http" && ip.IsLoopback()
+	}
+	return u.Scheme == "http" && host == "localhost"
+}
+
+// Get downloads the code of the key from its object.
+func (c *S3Cache) Get(key string) ([]byte, bool) {
+	req, err := c.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false
+	}
+	return doCacheGet(c.client(), req, nil)
+}
+
+// Put uploads the code of the key to its object.
+func (c *S3Cache) Put(key string, code []byte) error {
+	req, err := c.request(http.MethodPut, key, code)
+	if err != nil {
+		return err
+	}
+	return doCachePut(c.client(), req, nil)
+}
+
+func (c *S3Cache) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: cacheTimeout}
+}
+
+// request makes the signed request for the object of the key.
+func (c *S3Cache) request(method, key string, body []byte) (*http.Request, error) {
+	name := awsEscape(c.Prefix+key+".go", false)
+	rawURL := "https://" + c.Bucket + ".s3." + c.Region + ".amazonaws.com/" + name
+	if c.Endpoint != "" {
+		rawURL = strings.TrimSuffix(c.Endpoint, "/") + "/" + awsEscape(c.Bucket, true) + "/" + name
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	c.sign(req, u.EscapedPath(), body, now().UTC())
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 of the request for the escaped
+// path to its headers, signing its query and the headers it already has
+// too. See
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (c *S3Cache) sign(req *http.Request, path string, body []byte, t time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := t.Format("20060102T150405Z")
+	headers := make(map[string]string)
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	headers["host"] = req.URL.Host
+	headers["x-amz-content-sha256"] = hex.EncodeToString(payloadHash[:])
+	headers["x-amz-date"] = amzDate
+	if c.SessionToken != "" {
+		headers["x-amz-security-token"] = c.SessionToken
+	}
+	var names []string
+	for name, value := range headers {
+		names = append(names, name)
+		if strings.HasPrefix(name, "x-amz-") {
+			req.Header.Set(name, value)
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{req.Method, path, awsQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, headers["x-amz-content-sha256"]}, "\n")
+
+	scope := t.Format("20060102") + "/" + c.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + c.SecretAccessKey)
+	for _, part := range []string{t.Format("20060102"), c.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsQuery gets the canonical query string of the signatures, with the
+// escaped parameters sorted by name.
+func awsQuery(query url.Values) string {
+	var params [][2]string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, [2]string{awsEscape(name, true), awsEscape(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	joined := make([]string, len(params))
+	for i, param := range params {
+		joined[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(joined, "&")
+}
+
+// awsEscape escapes a name for a URI like AWS does, escaping everything but
+// the unreserved characters, and the slashes unless escapeSlash.
+func awsEscape(name string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && !escapeSlash:
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reIdentifier matches a Go identifier.
+var reIdentifier = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+
+// checkRenames makes sure that the renames are from and to identifiers.
+func checkRenames(renames map[string]string) error {
+	for _, from := range sortedKeys(renames) {
+		if !reIdentifier.MatchString(from) || !reIdentifier.MatchString(renames[from]) {
+			return &errBadRename{From: from, To: renames[from]}
+		}
+	}
+	return nil
+}
+
+// renameSymbols renames the identifiers of the generated code, and the
+// words of its comments, that are keys of renames to their values. The
+// rest of the code is left as it is.
+func renameSymbols(code []byte, renames map[string]string) []byte {
+	if len(renames) == 0 {
+		return code
+	}
+	froms := sortedKeys(renames)
+	// the longest names first, so that none is renamed within another
+	sort.SliceStable(froms, func(i, j int) bool { return len(froms[i]) > len(froms[j]) })
+	quoted := make([]string, len(froms))
+	for i, from := range froms {
+		quoted[i] = regexp.QuoteMeta(from)
+	}
+	reWord := regexp.MustCompile(`\b(?:` + strings.Join(quoted, "|") + `)\b`)
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(code))
+	var s scanner.Scanner
+	s.Init(file, code, nil, scanner.ScanComments)
+	var out []byte
+	start := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var renamed string
+		switch tok {
+		case token.IDENT:
+			to, ok := renames[lit]
+			if !ok {
+				continue
+			}
+			renamed = to
+		case token.COMMENT:
+			renamed = reWord.ReplaceAllStringFunc(lit, func(from string) string { return renames[from] })
+			if renamed == lit {
+				continue
+			}
+		default:
+			continue
+		}
+		offset := file.Offset(pos)
+		out = append(out, code[start:offset]...)
+		out = append(out, renamed...)
+		start = offset + len(lit)
+	}
+	if start == 0 {
+		return code
+	}
+	return append(out, code[start:]...)
+}
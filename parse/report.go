@@ -0,0 +1,184 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+)
+
+// Report lists the templates, their generic types and the code generated
+// from them for every type set, to write it as Markdown for reviewers.
+type Report struct {
+	templates []*reportTemplate
+}
+
+type reportTemplate struct {
+	filename       string
+	generics       []string
+	instantiations []reportInstantiation
+}
+
+type reportInstantiation struct {
+	typeSet string
+	out     string
+	decls   []string
+}
+
+// NewReport gets an empty report.
+func NewReport() *Report {
+	return &Report{}
+}
+
+// Add adds the code generated from the templates for the type sets into
+// the file out, generating it like GenericsTemplates does with opts to find
+// the exported declarations of each type set.
+func (r *Report) Add(templates []Template, typeSets []map[string]string, out string, opts Options) error {
+	opts.Stats = nil
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return err
+	}
+	entries := make([]*reportTemplate, len(g.templates))
+	for i, template := range g.templates {
+		src := g.sources[i]
+		generics, err := genericTypes(template.Filename, src)
+		if err != nil {
+			return err
+		}
+		entries[i] = r.template(template.Filename, generics)
+	}
+
+	// every type set has a row, even if its declarations were all
+	// generated for an earlier one
+	decls := make([][][]string, len(g.templates))
+	for i := range decls {
+		decls[i] = make([][]string, len(g.typeSets))
+	}
+	err = g.each(func(file generatedFile) error {
+		names, err := exportedDecls(g.templates[file.template].Filename, file.code)
+		decls[file.template][file.typeSet] = names
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		for j, typeSet := range typeSets {
+			entry.instantiations = append(entry.instantiations, reportInstantiation{
+				typeSet: typeSetClause(typeSet),
+				out:     out,
+				decls:   decls[i][j],
+			})
+		}
+	}
+	return nil
+}
+
+// template gets the entry of a template, adding it if it is new.
+func (r *Report) template(filename string, generics []string) *reportTemplate {
+	for _, t := range r.templates {
+		if t.filename == filename {
+			return t
+		}
+	}
+	t := &reportTemplate{filename: filename, generics: generics}
+	r.templates = append(r.templates, t)
+	return t
+}
+
+// WriteMarkdown writes the report in Markdown.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated code\n")
+	for _, t := range r.templates {
+		fmt.Fprintf(&buf, "\n## %s\n\n", t.filename)
+		if len(t.generics) > 0 {
+			fmt.Fprintf(&buf, "Generic types: %s\n\n", codeList(t.generics))
+		}
+		buf.WriteString("| Types | File | Declarations |\n| --- | --- | --- |\n")
+		for _, inst := range t.instantiations {
+			fmt.Fprintf(&buf, "| %s | %s | %s |\n", codeList([]string{inst.typeSet}), codeList([]string{inst.out}), codeList(inst.decls))
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// codeList writes the strings as a list of code spans for a table cell.
+func codeList(list []string) string {
+	var spans []string
+	for _, s := range list {
+		spans = append(spans, "`"+strings.Replace(s, "|", "\\|", -1)+"`")
+	}
+	return strings.Join(spans, ", ")
+}
+
+// genericTypes gets the generic types that a template declares.
+func genericTypes(filename string, src []byte) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	var generics []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if sel, ok := ts.Type.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == genericPackage {
+					generics = append(generics, ts.Name.Name)
+				}
+			}
+		}
+	}
+	return generics, nil
+}
+
+// exportedDecls gets the names of the exported declarations of the code,
+// with methods named after their receiver, like IntList.Add.
+func exportedDecls(filename string, code []byte) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filename, code, 0)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverName(d.Recv.List[0].Type)
+				if ast.IsExported(recv) {
+					names = append(names, recv+"."+d.Name.Name)
+				}
+				continue
+			}
+			names = append(names, d.Name.Name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						names = append(names, s.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							names = append(names, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,164 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/scanner"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is an error of the code generation, with the place in the
+// templates it is at, as far as it is known.
+type Diagnostic struct {
+	// Rule names the kind of error, like "source" or "placeholder".
+	Rule string
+	// Filename is the template that the error is in, if it is known.
+	Filename string
+	// Line and Column are 1-based, and 0 if they aren't known.
+	Line, Column int
+	Message      string
+}
+
+// reTemplateErrorPos matches the place at the start of a text/template
+// error, like "template: list.go:15:26: ".
+var reTemplateErrorPos = regexp.MustCompile(`^template: (.+?):(\d+):(?:(\d+):)? `)
+
+// Diagnose gets the diagnostic of an error returned by the code
+// generation, taking the place from the errors it wraps.
+func Diagnose(err error) Diagnostic {
+	d := Diagnostic{Rule: diagnosticRule(err), Message: err.Error()}
+	d.locate(err)
+	return d
+}
+
+// locate sets the place of the diagnostic to that of the error.
+func (d *Diagnostic) locate(err error) {
+	switch e := err.(type) {
+	case *errSource:
+		d.locate(e.Err)
+	case *errTransform:
+		d.Filename = e.Filename
+		d.locate(e.Err)
+	case *errPreprocess:
+		d.Filename = e.Filename
+		if m := reTemplateErrorPos.FindStringSubmatch(e.Err.Error()); m != nil {
+			d.Line, _ = strconv.Atoi(m[2])
+			// the column of text/template is the bytes in front of it
+			if col, err := strconv.Atoi(m[3]); err == nil {
+				d.Column = col + 1
+			}
+		}
+	case scanner.ErrorList:
+		if len(e) > 0 {
+			d.Filename, d.Line, d.Column = e[0].Pos.Filename, e[0].Pos.Line, e[0].Pos.Column
+		}
+	case *errPlaceholder:
+		d.Filename, d.Line = e.Filename, e.Line
+	case *errPackageMismatch:
+		d.Filename = e.Filename
+	case *errInclude:
+		d.Filename = e.Filename
+	case *errBase:
+		d.Filename = e.Filename
+	case *errDefaults:
+		d.Filename = e.Filename
+	case *errBadConfig:
+		d.Filename = e.Filename
+	case *errNotComparable:
+		d.Filename, d.Line = e.Filename, e.Line
+	case *errSharedGeneric:
+		d.Filename = e.Filename
+	case *errLeftoverGeneric:
+		d.Filename = e.Filename
+	case *errWorkspace:
+		d.Filename, d.Line = e.Filename, e.Line
+	}
+}
+
+// diagnosticRule names the kind of an error after its type, so errSource is
+// "source", and the errors of other packages are "error".
+func diagnosticRule(err error) string {
+	name := fmt.Sprintf("%T", err)
+	if !strings.HasPrefix(name, "*parse.err") {
+		return "error"
+	}
+	return joinWords(strings.TrimPrefix(name, "*parse.err"), "-")
+}
+
+// The parts of a SARIF log that genny writes. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string `json:"name"`
+		InformationURI string `json:"informationUri"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations,omitempty"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *sarifRegion          `json:"region,omitempty"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+)
+
+// WriteSARIF writes the diagnostics to w as a SARIF 2.1.0 log, for the code
+// scanning tools and editors that show them on the templates.
+func WriteSARIF(w io.Writer, diagnostics []Diagnostic) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "genny", InformationURI: "https://github.com/mauricelam/genny"}},
+		Results: []sarifResult{},
+	}
+	for _, d := range diagnostics {
+		result := sarifResult{RuleID: d.Rule, Level: "error", Message: sarifMessage{Text: d.Message}}
+		if d.Filename != "" {
+			location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.Filename)}}
+			if d.Line > 0 {
+				location.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		run.Results = append(run.Results, result)
+	}
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,173 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// genericImport is the import path of the generic package.
+const genericImport = "github.com/mauricelam/genny/generic"
+
+// sharedGeneric is a generic type declared by a sibling of a template.
+type sharedGeneric struct {
+	filename string
+	// decl is the declaration, like "type Elem generic.Type".
+	decl string
+	// defaults are the genny:default directives of the generic type.
+	defaults []string
+}
+
+// resolveShared adds the declarations of the generic types that a template
+// uses but doesn't declare to it, from the other files of its package that
+// declare them, like a types.go of the generic types of every template of
+// the package, along with their genny:default directives. The files must
+// declare a generic type alike. They are added at the end, and the import
+// of the generic package on the line of the last import, so the lines of
+// the template stay where they are.
+func resolveShared(filename string, src []byte) ([]byte, error) {
+	if info, err := os.Stat(filename); err != nil || !info.Mode().IsRegular() {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		// leave it to the generation to report the syntax error
+		return src, nil
+	}
+	var used []string
+	seen := make(map[string]bool)
+	for _, ident := range file.Unresolved {
+		if !seen[ident.Name] && ident.Name != "_" {
+			seen[ident.Name] = true
+			used = append(used, ident.Name)
+		}
+	}
+	if len(used) == 0 {
+		return src, nil
+	}
+	shared, err := siblingGenerics(filename, file.Name.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls bytes.Buffer
+	sort.Strings(used)
+	for _, name := range used {
+		declared := shared[name]
+		if len(declared) == 0 {
+			continue
+		}
+		for _, other := range declared[1:] {
+			if other.decl != declared[0].decl || strings.Join(other.defaults, "\n") != strings.Join(declared[0].defaults, "\n") {
+				return nil, &errSharedGeneric{Filename: filename, Generic: name, Message: "'" + declared[0].filename + "' and '" + other.filename + "' declare it differently"}
+			}
+		}
+		decls.WriteString("\n")
+		for _, directive := range declared[0].defaults {
+			decls.WriteString(directive + "\n")
+		}
+		decls.WriteString(declared[0].decl + "\n")
+	}
+	if decls.Len() == 0 {
+		return src, nil
+	}
+
+	imports := ""
+	if !importsGeneric(file) {
+		imports = "; import " + strconv.Quote(genericImport)
+	}
+	end, _, err := importsRange(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.Write(src[:end])
+	out.WriteString(imports)
+	out.Write(src[end:])
+	if !bytes.HasSuffix(src, []byte("\n")) {
+		out.WriteString("\n")
+	}
+	out.Write(decls.Bytes())
+	return out.Bytes(), nil
+}
+
+// importsGeneric tells whether the file imports the generic package under
+// its own name.
+func importsGeneric(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == genericImport && spec.Name == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// siblingGenerics gets the generic types that the other files of the
+// package pkgName in the directory of the template declare, by name. The
+// test files and the files generated by genny don't count.
+func siblingGenerics(filename, pkgName string) (map[string][]sharedGeneric, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	generics := make(map[string][]sharedGeneric)
+	for _, info := range files {
+		name := info.Name()
+		if !info.Mode().IsRegular() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || filepath.Join(dir, name) == abs {
+			continue
+		}
+		sibling := filepath.Join(filepath.Dir(filename), name)
+		src, err := ioutil.ReadFile(sibling)
+		if err != nil {
+			return nil, err
+		}
+		src = normalizeEOL(src)
+		if IsGenerated(src) {
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, sibling, src, 0)
+		if err != nil || file.Name.Name != pkgName {
+			continue
+		}
+		defaults := make(map[string][]string)
+		for _, line := range strings.Split(string(src), "\n") {
+			if arg, ok := directiveArg(line, defaultDirective); ok {
+				generic := strings.TrimSpace(strings.SplitN(arg, "=", 2)[0])
+				defaults[generic] = append(defaults[generic], strings.TrimSpace(line))
+			}
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				sel, ok := ts.Type.(*ast.SelectorExpr)
+				if !ok || !isGenericTypeSelector(sel) {
+					continue
+				}
+				generics[ts.Name.Name] = append(generics[ts.Name.Name], sharedGeneric{
+					filename: sibling,
+					decl:     "type " + ts.Name.Name + " " + genericPackage + "." + sel.Sel.Name,
+					defaults: defaults[ts.Name.Name],
+				})
+			}
+		}
+	}
+	return generics, nil
+}
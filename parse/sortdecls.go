@@ -0,0 +1,190 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DeclOrder controls the order of the declarations in the generated code.
+type DeclOrder int
+
+const (
+	// DeclOrderNone keeps the declarations in the order they are generated,
+	// type set after type set. This is the default.
+	DeclOrderNone DeclOrder = iota
+	// DeclOrderAlpha groups the declarations by kind (types, constants,
+	// variables, then functions) and sorts each group by name.
+	DeclOrderAlpha
+	// DeclOrderTemplate groups the declarations by kind and keeps the order
+	// of the template within each group, with the instantiations of a
+	// declaration sorted by name.
+	DeclOrderTemplate
+)
+
+var declOrders = map[string]DeclOrder{
+	"none":     DeclOrderNone,
+	"alpha":    DeclOrderAlpha,
+	"template": DeclOrderTemplate,
+}
+
+// ParseDeclOrder returns the DeclOrder with the given name. Valid names are
+// "none", "alpha" and "template".
+func ParseDeclOrder(name string) (DeclOrder, error) {
+	if name == "" {
+		return DeclOrderNone, nil
+	}
+	order, ok := declOrders[name]
+	if !ok {
+		return DeclOrderNone, &errBadDeclOrder{Name: name}
+	}
+	return order, nil
+}
+
+// typeSetMarker is written in front of the code of every type set when the
+// declarations are sorted, so that sortDecls knows where each declaration
+// came from.
+const typeSetMarker = "//genny:typeset "
+
+func makeTypeSetMarker(index int) string {
+	return makeLine(typeSetMarker+strconv.Itoa(index)) + makeLine("")
+}
+
+// sortableDecl is a declaration along with the comments in front of it.
+type sortableDecl struct {
+	text     string
+	kind     int
+	name     string
+	typeSet  int
+	position int
+}
+
+// sortDecls reorders the declarations of the formatted source src, and
+// removes the type set markers from it.
+func sortDecls(filename string, src []byte, order DeclOrder) ([]byte, error) {
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	offset := func(pos token.Pos) int {
+		return fs.Position(pos).Offset
+	}
+
+	// the markers that each declaration follows
+	var markers []int
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, typeSetMarker) {
+				markers = append(markers, offset(c.Pos()))
+			}
+		}
+	}
+
+	// everything up to the imports stays in place
+	headEnd := offset(file.Name.End())
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			headEnd = offset(decl.End())
+		}
+	}
+
+	start := headEnd
+	var decls []sortableDecl
+	positions := make(map[int]int)
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		end := offset(decl.End())
+		typeSet := sort.SearchInts(markers, offset(decl.Pos())) - 1
+		kind, name := declKind(decl)
+		decls = append(decls, sortableDecl{
+			text:     stripTypeSetMarkers(string(src[start:end])),
+			kind:     kind,
+			name:     name,
+			typeSet:  typeSet,
+			position: positions[typeSet],
+		})
+		positions[typeSet]++
+		start = end
+	}
+	head := stripTypeSetMarkers(string(src[:headEnd]))
+	tail := stripTypeSetMarkers(string(src[start:]))
+
+	sort.SliceStable(decls, func(i, j int) bool {
+		a, b := decls[i], decls[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if order == DeclOrderTemplate && a.position != b.position {
+			return a.position < b.position
+		}
+		return a.name < b.name
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(head)
+	for _, decl := range decls {
+		buf.WriteString("\n\n")
+		buf.WriteString(decl.text)
+	}
+	buf.WriteString("\n")
+	if tail != "" {
+		buf.WriteString("\n" + tail + "\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// declKind gets the sort group and the name of a declaration. Methods are
+// named after their receiver type, so they are sorted along with it.
+func declKind(decl ast.Decl) (int, string) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return 3, receiverName(d.Recv.List[0].Type) + "." + d.Name.Name
+		}
+		return 3, d.Name.Name
+	case *ast.GenDecl:
+		kind := map[token.Token]int{token.TYPE: 0, token.CONST: 1, token.VAR: 2}[d.Tok]
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				return kind, s.Name.Name
+			case *ast.ValueSpec:
+				if len(s.Names) > 0 {
+					return kind, s.Names[0].Name
+				}
+			}
+		}
+		return kind, ""
+	}
+	return 4, ""
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// stripTypeSetMarkers removes the type set marker lines from src and trims
+// the surrounding whitespace.
+func stripTypeSetMarkers(src string) string {
+	var lines []string
+	for _, line := range strings.Split(src, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), typeSetMarker) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
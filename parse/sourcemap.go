@@ -0,0 +1,181 @@
+package parse
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strings"
+)
+
+// SourceMap relates the declarations of a generated file to the
+// declarations of the templates and the type sets they were generated
+// from, for tools that navigate from generated code back to the templates.
+type SourceMap struct {
+	// Version is the version of the format, 1.
+	Version int `json:"version"`
+	// File is the name of the generated file.
+	File         string          `json:"file"`
+	Declarations []SourceMapping `json:"declarations"`
+}
+
+// SourceMapping relates a declaration of the generated code to the
+// declaration of the template it was generated from. The lines are 1-based
+// and inclusive, and leave out the doc comments. Where the ranges are as
+// long, which they are unless the code was reformatted, every line of the
+// declaration comes from the line as far into the template declaration.
+type SourceMapping struct {
+	// Name is the name of the declaration, with methods named after their
+	// receiver, like IntList.Add.
+	Name    string `json:"name"`
+	Line    int    `json:"line"`
+	EndLine int    `json:"endLine"`
+	// Template is the file of the template declaration, which is an
+	// included or base template if it was declared there.
+	Template        string `json:"template"`
+	TemplateLine    int    `json:"templateLine"`
+	TemplateEndLine int    `json:"templateEndLine"`
+	// TypeSet is the type set that the declaration was generated for, like
+	// "Elem=int".
+	TypeSet string `json:"typeSet"`
+}
+
+// sourceDecl is a named top level declaration of a file, and its lines.
+type sourceDecl struct {
+	name          string
+	filename      string
+	line, endLine int
+}
+
+// GenericsSourceMap generates the code of the templates for the type sets
+// like GenericsTemplates does, and gets its source map as JSON. out is the
+// name of the generated file.
+func GenericsSourceMap(templates []Template, typeSets []map[string]string, opts Options, out string) ([]byte, error) {
+	opts.Stats, opts.DebugDir = nil, ""
+	code, err := GenericsTemplates(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// the declarations of every template, with those it includes or derives
+	// from after its own
+	templateDecls := make([][]sourceDecl, len(g.templates))
+	for i, template := range g.templates {
+		src, err := readSource(template)
+		if err != nil {
+			return nil, err
+		}
+		templateDecls[i], err = templateSourceDecls(template.Filename, normalizeEOL(src))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the template and type set that generated each declaration first
+	type origin struct{ template, typeSet int }
+	origins := make(map[string]origin)
+	err = g.each(func(file generatedFile) error {
+		decls, err := sourceDecls(g.templates[file.template].Filename, file.code)
+		for _, decl := range decls {
+			if _, ok := origins[decl.name]; !ok {
+				origins[decl.name] = origin{file.template, file.typeSet}
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sourceMap := SourceMap{Version: 1, File: out, Declarations: []SourceMapping{}}
+	decls, err := sourceDecls(out, code)
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range decls {
+		o, ok := origins[decl.name]
+		if !ok {
+			continue
+		}
+		for _, t := range templateDecls[o.template] {
+			if subIntoType(t.name, g.typeSets[o.typeSet]) != decl.name {
+				continue
+			}
+			sourceMap.Declarations = append(sourceMap.Declarations, SourceMapping{
+				Name:            decl.name,
+				Line:            decl.line,
+				EndLine:         decl.endLine,
+				Template:        t.filename,
+				TemplateLine:    t.line,
+				TemplateEndLine: t.endLine,
+				TypeSet:         typeSetClause(typeSets[o.typeSet]),
+			})
+			break
+		}
+	}
+	data, err := json.MarshalIndent(sourceMap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// templateSourceDecls gets the declarations of a template and of the
+// templates it includes or derives from, following its directives.
+func templateSourceDecls(filename string, src []byte) ([]sourceDecl, error) {
+	decls, err := sourceDecls(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(src), "\n") {
+		arg, ok := directiveArg(line, includeDirective)
+		if !ok {
+			arg, ok = directiveArg(line, baseDirective)
+		}
+		if !ok {
+			continue
+		}
+		path := templatePath(filename, arg)
+		other, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		otherDecls, err := templateSourceDecls(path, normalizeEOL(other))
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, otherDecls...)
+	}
+	return decls, nil
+}
+
+// sourceDecls gets the named declarations of the code, other than imports.
+func sourceDecls(filename string, code []byte) ([]sourceDecl, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, code, 0)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+	var decls []sourceDecl
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		_, name := declKind(decl)
+		if name == "" || name == "_" {
+			continue
+		}
+		decls = append(decls, sourceDecl{
+			name:     name,
+			filename: filename,
+			line:     fset.Position(decl.Pos()).Line,
+			endLine:  fset.Position(decl.End()).Line,
+		})
+	}
+	return decls, nil
+}
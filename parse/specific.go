@@ -0,0 +1,52 @@
+package parse
+
+import "strings"
+
+const (
+	titleSep  = ":"
+	importSep = "@"
+	namingSep = "#"
+)
+
+// specificArg is a specific type argument split into its parts. The full
+// syntax of an argument is
+//
+//	[Title:]Type[@ImportPath][#naming]
+//
+// e.g. Dog:pet.Dog@github.com/me/zoo/pet#type.
+type specificArg struct {
+	Title      string
+	Type       string
+	ImportPath string
+	Naming     string
+}
+
+func parseSpecificArg(s string) specificArg {
+	var arg specificArg
+	if sepIdx := strings.Index(s, titleSep); sepIdx >= 0 {
+		arg.Title, s = s[:sepIdx], s[sepIdx+1:]
+	}
+	if sepIdx := strings.LastIndex(s, namingSep); sepIdx >= 0 {
+		s, arg.Naming = s[:sepIdx], s[sepIdx+1:]
+	}
+	if sepIdx := strings.Index(s, importSep); sepIdx >= 0 {
+		s, arg.ImportPath = s[:sepIdx], s[sepIdx+1:]
+	}
+	arg.Type = s
+	return arg
+}
+
+// String gets the argument back in its string form.
+func (arg specificArg) String() string {
+	s := arg.Type
+	if arg.Title != "" {
+		s = arg.Title + titleSep + s
+	}
+	if arg.ImportPath != "" {
+		s += importSep + arg.ImportPath
+	}
+	if arg.Naming != "" {
+		s += namingSep + arg.Naming
+	}
+	return s
+}
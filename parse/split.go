@@ -0,0 +1,127 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SplitLimits bound the size of a generated file, beyond which SplitCode
+// splits it into parts. A zero limit is not enforced.
+type SplitLimits struct {
+	// MaxBytes is the maximum size of a file in bytes.
+	MaxBytes int
+	// MaxLines is the maximum number of lines of a file.
+	MaxLines int
+}
+
+// over tells whether code of the size and number of lines is over the
+// limits.
+func (l SplitLimits) over(size, lines int) bool {
+	return l.MaxBytes > 0 && size > l.MaxBytes || l.MaxLines > 0 && lines > l.MaxLines
+}
+
+// SplitCode splits the generated code of the file filename into numbered
+// parts of the same package when it is over the limits, so gen.go becomes
+// gen_1.go, gen_2.go and so on. The code is split between its top-level
+// declarations, and every part has the header, the build constraints, the
+// package clause and the imports of the code, less those that the part
+// doesn't use. A declaration that is over the limits on its own gets a part
+// of its own. Code within the limits is returned as the one file filename.
+func SplitCode(filename string, code []byte, limits SplitLimits) ([]OutputFile, error) {
+	name := filepath.Base(filename)
+	if !limits.over(len(code), lineCount(code)) {
+		return []OutputFile{{Filename: name, Code: code}}, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, code, parser.ParseComments)
+	if err != nil {
+		return nil, &errSource{Err: err}
+	}
+
+	// the head is everything up to the end of the last import, and every
+	// declaration after it starts at the line of its doc comment
+	tf := fset.File(file.Pos())
+	headEnd := tf.Offset(file.Name.End())
+	var starts []int
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && len(starts) == 0 {
+			headEnd = tf.Offset(decl.End())
+			continue
+		}
+		pos := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			pos = doc.Pos()
+		}
+		starts = append(starts, tf.Offset(tf.LineStart(tf.Line(pos))))
+	}
+	if len(starts) == 0 {
+		return []OutputFile{{Filename: name, Code: code}}, nil
+	}
+	head := append([]byte(nil), code[:headEnd]...)
+	head = append(head, '\n')
+	starts[0] = headEnd
+	starts = append(starts, len(code))
+
+	var parts [][]byte
+	part := append([]byte(nil), head...)
+	empty := true
+	for i := 0; i+1 < len(starts); i++ {
+		decl := code[starts[i]:starts[i+1]]
+		if !empty && limits.over(len(part)+len(decl), lineCount(part)+lineCount(decl)) {
+			parts = append(parts, part)
+			part, empty = append([]byte(nil), head...), true
+		}
+		part = append(part, decl...)
+		empty = false
+	}
+	parts = append(parts, part)
+	if len(parts) == 1 {
+		return []OutputFile{{Filename: name, Code: code}}, nil
+	}
+
+	files := make([]OutputFile, len(parts))
+	for i, part := range parts {
+		partName := PartFilename(name, i+1)
+		output, err := formatOutput(partName, part)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = OutputFile{Filename: partName, Code: output}
+	}
+	return files, nil
+}
+
+// PartFilename gets the name of the nth part of a file that SplitCode
+// splits, by adding _n to name before its _test, _GOOS and _GOARCH
+// suffixes, so gen.go becomes gen_1.go and gen_linux_test.go becomes
+// gen_1_linux_test.go, which keeps their build constraints.
+func PartFilename(name string, n int) string {
+	ext := ""
+	if i := strings.LastIndex(name, "."); i > strings.LastIndexAny(name, `/\`) {
+		name, ext = name[:i], name[i:]
+	}
+	suffix := ""
+	if strings.HasSuffix(name, "_test") {
+		name, suffix = strings.TrimSuffix(name, "_test"), "_test"
+	}
+	for _, known := range []map[string]bool{knownArch, knownOS} {
+		if i := strings.LastIndex(name, "_"); i > 0 && known[name[i+1:]] {
+			name, suffix = name[:i], name[i:]+suffix
+		}
+	}
+	return name + "_" + strconv.Itoa(n) + suffix + ext
+}
+
+// lineCount gets the number of lines of the code.
+func lineCount(code []byte) int {
+	n := bytes.Count(code, []byte("\n"))
+	if len(code) > 0 && code[len(code)-1] != '\n' {
+		n++
+	}
+	return n
+}
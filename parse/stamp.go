@@ -0,0 +1,132 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stampPrefix starts the line under the header that records the templates
+// a file was generated from, like
+//
+//	// genny:templates sha256:9f86d0... list.go ../set.go
+const stampPrefix = "// genny:templates "
+
+// commandPrefix starts the line under the header that records the command
+// that generates the file again, like
+//
+//	// genny:command cd .. && genny -in=list.go -out=gen/list.go gen Elem=int
+const commandPrefix = "// genny:command "
+
+// commandLine gets the line of the command, with any line breaks in it
+// made spaces.
+func commandLine(command string) string {
+	return makeLine(commandPrefix + strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(command))
+}
+
+// Stamp is the record of the templates that a file was generated from,
+// which genny writes under the header when Options.StampDir is set.
+type Stamp struct {
+	// Hash is the hash of the templates, with their includes and bases, as
+	// they were when the file was generated.
+	Hash string
+	// Templates are the paths of the templates, relative to the directory
+	// of the generated file, with forward slashes.
+	Templates []string
+}
+
+// FindStamp gets the stamp of the generated code, if it has one.
+func FindStamp(code []byte) (Stamp, bool) {
+	for _, line := range strings.SplitN(string(code), "\n", 10) {
+		line = strings.TrimRight(line, linefeed)
+		if !strings.HasPrefix(line, stampPrefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, stampPrefix))
+		if len(fields) < 2 {
+			return Stamp{}, false
+		}
+		return Stamp{Hash: fields[0], Templates: fields[1:]}, true
+	}
+	return Stamp{}, false
+}
+
+// Current tells whether the templates still hash to the stamp. dir is the
+// directory of the generated file.
+func (s Stamp) Current(dir string) (bool, error) {
+	var sources [][]byte
+	for _, template := range s.Templates {
+		filename := filepath.Join(dir, filepath.FromSlash(template))
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return false, err
+		}
+		resolved, err := resolveTemplate(filename, normalizeEOL(src))
+		if err != nil {
+			return false, err
+		}
+		sources = append(sources, resolved)
+	}
+	return templatesHash(sources) == s.Hash, nil
+}
+
+// ResolveTemplate gets the source of a template with its includes, base and
+// shared generic types resolved, which is a template that stands on its own, like for
+// publishing it.
+func ResolveTemplate(filename string, src []byte) ([]byte, error) {
+	return resolveTemplate(filename, normalizeEOL(src))
+}
+
+// resolveTemplate resolves the includes, the base and the shared generic
+// types of a template.
+func resolveTemplate(filename string, src []byte) ([]byte, error) {
+	included, err := resolveIncludes(filename, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	based, err := resolveBase(filename, included, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resolveShared(filename, based)
+}
+
+// templatesHash hashes the sources of the templates, with their includes
+// and bases resolved.
+func templatesHash(sources [][]byte) string {
+	h := sha256.New()
+	for _, src := range sources {
+		h.Write([]byte(strconv.Itoa(len(src)) + "\n"))
+		h.Write(src)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// stampLine gets the stamp of the templates for a file generated into dir,
+// or "" if a template isn't a file that the stamp can point to.
+func stampLine(dir string, templates []Template, sources [][]byte) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	paths := make([]string, 0, len(templates))
+	for _, template := range templates {
+		if info, err := os.Stat(template.Filename); err != nil || !info.Mode().IsRegular() {
+			return ""
+		}
+		abs, err := filepath.Abs(template.Filename)
+		if err != nil {
+			return ""
+		}
+		rel, err := filepath.Rel(absDir, abs)
+		if err != nil || strings.ContainsAny(rel, " \t") {
+			return ""
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return makeLine(stampPrefix + templatesHash(sources) + " " + strings.Join(paths, " "))
+}
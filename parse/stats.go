@@ -0,0 +1,110 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats are statistics of the code generation, collected when
+// Options.Stats is set. They add up over the calls that share them.
+type Stats struct {
+	// Templates is the number of templates read.
+	Templates int
+	// Instantiations is the number of times a template was generated for a
+	// type set.
+	Instantiations int
+	// Files is the number of files generated.
+	Files int
+	// OutputLines and OutputBytes are the size of the generated code.
+	OutputLines int
+	OutputBytes int
+	// Phases are the wall times spent in each phase, in the order they
+	// first ran.
+	Phases []Phase
+}
+
+// Phase is the wall time spent in a phase of the code generation.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// phase adds the time since start to the phase. It does nothing if the
+// stats are not collected, like the other methods.
+func (s *Stats) phase(name string, start time.Time) {
+	if s == nil {
+		return
+	}
+	d := time.Since(start)
+	for i := range s.Phases {
+		if s.Phases[i].Name == name {
+			s.Phases[i].Duration += d
+			return
+		}
+	}
+	s.Phases = append(s.Phases, Phase{Name: name, Duration: d})
+}
+
+func (s *Stats) addTemplates(templates, typeSets int) {
+	if s != nil {
+		s.Templates += templates
+		s.Instantiations += templates * typeSets
+	}
+}
+
+// addInstantiations counts instantiations of templates that were added
+// without their type sets.
+func (s *Stats) addInstantiations(n int) {
+	if s != nil {
+		s.Instantiations += n
+	}
+}
+
+func (s *Stats) addFile() {
+	if s != nil {
+		s.Files++
+	}
+}
+
+// addOutput counts the generated code.
+func (s *Stats) addOutput(code []byte) {
+	if s != nil {
+		s.OutputLines += bytes.Count(code, []byte("\n"))
+		s.OutputBytes += len(code)
+	}
+}
+
+// WriteTo writes the stats as a table that people can read.
+func (s *Stats) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "templates:\t%d\n", s.Templates)
+	fmt.Fprintf(tw, "instantiations:\t%d\n", s.Instantiations)
+	fmt.Fprintf(tw, "files:\t%d\n", s.Files)
+	fmt.Fprintf(tw, "lines of output:\t%d\n", s.OutputLines)
+	fmt.Fprintf(tw, "bytes of output:\t%d\n", s.OutputBytes)
+	var total time.Duration
+	for _, p := range s.Phases {
+		fmt.Fprintf(tw, "%s time:\t%v\n", p.Name, p.Duration)
+		total += p.Duration
+	}
+	fmt.Fprintf(tw, "total time:\t%v\n", total)
+	tw.Flush()
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// statsWriter counts the code written through it.
+type statsWriter struct {
+	w     io.Writer
+	stats *Stats
+}
+
+func (w statsWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.stats.addOutput(p[:n])
+	return n, err
+}
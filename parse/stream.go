@@ -0,0 +1,196 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"strings"
+	"time"
+)
+
+// stubMarker precedes the references that are added to the head of the
+// streamed output so that goimports knows which packages to import.
+const stubMarker = "//genny:stub"
+
+// GenericsTo is like GenericsTemplates, but writes the code to w one type
+// set at a time instead of building the whole output in memory, which keeps
+// the memory use flat when generating for many type sets. The code is
+// generated twice: first to collect the imports that go at the top and to
+// report errors before anything is written, then to write it out. Sorted
+// declarations, transforms of the stages after StageSubstitute, DebugDir
+// and Raw need the whole output, so they are generated in memory.
+func GenericsTo(w io.Writer, templates []Template, typeSets []map[string]string, opts Options) error {
+	if opts.SortDecls != DeclOrderNone || opts.transformsOutput() || opts.DebugDir != "" || opts.Raw || opts.Mode == ModeTypeParams {
+		output, err := GenericsTemplates(templates, typeSets, opts)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(output)
+		return err
+	}
+
+	start := time.Now()
+	g, err := newGeneration(templates, typeSets, opts)
+	if err != nil {
+		return err
+	}
+	opts.Stats.phase("read", start)
+
+	// collect the imports and the packages that the code refers to
+	start = time.Now()
+	collector := newImportCollector(g)
+	if err := g.each(collector.add); err != nil {
+		return err
+	}
+	importDecls := collector.importDecls(g.importSpecs)
+	opts.Stats.phase("imports", start)
+
+	start = time.Now()
+	writer := newStreamWriter(w, g, importDecls, collector.refs)
+	if err := g.each(writer.add); err != nil {
+		return err
+	}
+	opts.Stats.phase("generate", start)
+	opts.Stats.addFile()
+	return nil
+}
+
+// importCollector collects the imports of the generated files and the
+// packages that they refer to, only keeping the lines of the merged code in
+// front of the package clause.
+type importCollector struct {
+	*codeMerger
+	filenames []string
+	refs      map[string]string
+}
+
+func newImportCollector(g *generation) *importCollector {
+	c := &importCollector{codeMerger: newCodeMerger(g), refs: make(map[string]string)}
+	for _, template := range g.templates {
+		c.filenames = append(c.filenames, template.Filename)
+	}
+	return c
+}
+
+func (c *importCollector) add(file generatedFile) error {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, c.filenames[file.template], file.code, 0)
+	if err != nil {
+		return &errImports{Err: err}
+	}
+	for qualifier, ref := range qualifiedRefs(f) {
+		c.refs[qualifier] = ref
+	}
+	c.codeMerger.add(file)
+	c.lines = c.lines[:c.preambleStart]
+	return nil
+}
+
+// streamWriter merges the generated files and writes the code of each as
+// soon as it is added, with the head and the imports in front of the first.
+type streamWriter struct {
+	w           io.Writer
+	merger      *codeMerger
+	filename    string
+	importDecls []string
+	refs        map[string]string
+	opts        Options
+	headWritten bool
+}
+
+func newStreamWriter(w io.Writer, g *generation, importDecls []string, refs map[string]string) *streamWriter {
+	if g.opts.Stats != nil {
+		w = statsWriter{w: w, stats: g.opts.Stats}
+	}
+	return &streamWriter{
+		w:           w,
+		merger:      newCodeMerger(g),
+		filename:    g.templates[0].Filename,
+		importDecls: importDecls,
+		refs:        refs,
+		opts:        g.opts,
+	}
+}
+
+func (sw *streamWriter) add(file generatedFile) error {
+	sw.merger.add(file)
+	if !sw.headWritten {
+		head, body := sw.merger.split()
+		if err := writeHead(sw.w, sw.filename, head, sw.importDecls, sw.refs, sw.opts); err != nil {
+			return err
+		}
+		sw.merger.lines = body
+		sw.headWritten = true
+	}
+	if err := writeBody(sw.w, sw.merger.lines); err != nil {
+		return err
+	}
+	sw.merger.lines = sw.merger.lines[:0]
+	return nil
+}
+
+// qualifiedRefs gets a reference to an identifier of every package that
+// the code refers to, like fmt.Println, keyed by the package name.
+func qualifiedRefs(file *ast.File) map[string]string {
+	refs := make(map[string]string)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				refs[x.Name] = x.Name + "." + sel.Sel.Name
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// writeHead writes everything down to the imports. The references are
+// added to the code for fixing the imports, and cut off again afterwards.
+func writeHead(w io.Writer, filename string, head, importDecl []string, refs map[string]string, opts Options) error {
+	var buf bytes.Buffer
+	for _, lines := range [][]string{head, importDecl} {
+		for _, line := range lines {
+			buf.WriteString(line)
+		}
+	}
+	buf.WriteString(makeLine(""))
+	buf.WriteString(makeLine(stubMarker))
+	buf.WriteString(makeLine("func _() {"))
+	for _, qualifier := range sortedKeys(refs) {
+		buf.WriteString(makeLine("_ = " + refs[qualifier]))
+	}
+	buf.WriteString(makeLine("}"))
+
+	output := buf.Bytes()
+	if opts.PkgName != "" {
+		output = changePackage(bytes.NewReader(output), opts.PkgName)
+	}
+	output, err := formatOutput(filename, output)
+	if err != nil {
+		return err
+	}
+	if stubIdx := bytes.Index(output, []byte(stubMarker)); stubIdx >= 0 {
+		output = append(bytes.TrimRight(output[:stubIdx], "\n"), '\n')
+	}
+	_, err = w.Write(output)
+	return err
+}
+
+// writeBody formats the lines of a generated file that follow the imports
+// and writes them.
+func writeBody(w io.Writer, lines []string) error {
+	src := "package p\n" + strings.Join(lines, "")
+	output, err := format.Source([]byte(src))
+	if err != nil {
+		return &errImports{Err: err}
+	}
+	body := bytes.TrimSpace(output[bytes.IndexByte(output, '\n')+1:])
+	if len(body) == 0 {
+		return nil
+	}
+	_, err = w.Write(append(append([]byte("\n"), body...), '\n'))
+	return err
+}
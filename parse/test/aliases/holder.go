@@ -0,0 +1,10 @@
+package aliases
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemHolder holds an Elem.
+type ElemHolder struct {
+	Value Elem
+}
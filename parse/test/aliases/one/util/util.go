@@ -0,0 +1,4 @@
+package util
+
+// Value is a value from the one package.
+type Value struct{}
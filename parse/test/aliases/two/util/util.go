@@ -0,0 +1,4 @@
+package util
+
+// Value is a value from the two package.
+type Value struct{}
@@ -0,0 +1,19 @@
+//go:build genny && amd64
+
+#include "textflag.h"
+
+// func ElemSum(items []Elem) Elem
+TEXT ·ElemSum(SB), NOSPLIT, $0-32
+	MOVQ items_base+0(FP), SI
+	MOVQ items_len+8(FP), CX
+	XORQ AX, AX
+loopElem:
+	CMPQ CX, $0
+	JE   doneElem
+	ADDQ (SI), AX
+	ADDQ $8, SI
+	DECQ CX
+	JMP  loopElem
+doneElem:
+	MOVQ AX, ret+24(FP)
+	RET
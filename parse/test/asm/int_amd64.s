@@ -0,0 +1,19 @@
+//go:build amd64
+
+#include "textflag.h"
+
+// func IntSum(items []int) int
+TEXT ·IntSum(SB), NOSPLIT, $0-32
+	MOVQ items_base+0(FP), SI
+	MOVQ items_len+8(FP), CX
+	XORQ AX, AX
+loopInt:
+	CMPQ CX, $0
+	JE   doneInt
+	ADDQ (SI), AX
+	ADDQ $8, SI
+	DECQ CX
+	JMP  loopInt
+doneInt:
+	MOVQ AX, ret+24(FP)
+	RET
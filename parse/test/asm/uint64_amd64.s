@@ -0,0 +1,19 @@
+//go:build amd64
+
+#include "textflag.h"
+
+// func Uint64Sum(items []uint64) uint64
+TEXT ·Uint64Sum(SB), NOSPLIT, $0-32
+	MOVQ items_base+0(FP), SI
+	MOVQ items_len+8(FP), CX
+	XORQ AX, AX
+loopUint64:
+	CMPQ CX, $0
+	JE   doneUint64
+	ADDQ (SI), AX
+	ADDQ $8, SI
+	DECQ CX
+	JMP  loopUint64
+doneUint64:
+	MOVQ AX, ret+24(FP)
+	RET
@@ -3,6 +3,7 @@
 // Any changes will be lost if this file is regenerated.
 // see https://github.com/mauricelam/genny
 
+//go:build (x && y) || z
 // +build x,y z
 
 package buildtags
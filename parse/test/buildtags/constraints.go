@@ -0,0 +1,15 @@
+//go:build genny && (linux || darwin)
+
+package buildtags
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type _t_ generic.Type
+
+func _t_Print(t _t_) {
+	fmt.Println(t)
+}
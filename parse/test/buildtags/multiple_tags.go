@@ -0,0 +1,15 @@
+//go:build genny && ignore
+
+package buildtags
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type _t_ generic.Type
+
+func _t_Describe(t _t_) {
+	fmt.Printf("%T\n", t)
+}
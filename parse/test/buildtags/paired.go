@@ -0,0 +1,16 @@
+//go:build genny && linux
+// +build genny,linux
+
+package buildtags
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type _t_ generic.Type
+
+func _t_Paired(t _t_) {
+	fmt.Println(t)
+}
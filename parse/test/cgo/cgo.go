@@ -0,0 +1,31 @@
+package cgo
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Number
+
+// ElemBuffer is a buffer of Elems allocated by C.
+type ElemBuffer struct {
+	ptr unsafe.Pointer
+	len int
+}
+
+// NewElemBuffer allocates a buffer for n Elems.
+func NewElemBuffer(n int) *ElemBuffer {
+	var zero Elem
+	return &ElemBuffer{ptr: C.malloc(C.size_t(n) * C.size_t(unsafe.Sizeof(zero))), len: n}
+}
+
+// Free frees the buffer.
+func (b *ElemBuffer) Free() {
+	C.free(b.ptr)
+}
@@ -0,0 +1,22 @@
+//go:build genny
+
+package convert
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list of Elems.
+type ElemList []Elem
+
+// ElemsByName are Elems by their names.
+type ElemsByName map[string]Elem
+
+// ElemSet is a set of Elems, whose members can't be converted one by one,
+// as two of them could be converted into one.
+type ElemSet map[Elem]struct{}
+
+// ElemStack is not a slice or a map, so it has no conversion.
+type ElemStack struct {
+	elems ElemList
+}
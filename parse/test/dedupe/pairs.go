@@ -0,0 +1,21 @@
+package dedupe
+
+import "github.com/mauricelam/genny/generic"
+
+type KeyType generic.Type
+type ValueType generic.Type
+
+// KeyTypeSet is a set of KeyTypes.
+type KeyTypeSet map[KeyType]struct{}
+
+// KeyTypeValueTypeMap maps KeyTypes to ValueTypes.
+type KeyTypeValueTypeMap map[KeyType]ValueType
+
+// Keys gets the keys of the map.
+func (m KeyTypeValueTypeMap) Keys() KeyTypeSet {
+	keys := make(KeyTypeSet, len(m))
+	for k := range m {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
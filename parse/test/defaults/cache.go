@@ -0,0 +1,19 @@
+package defaults
+
+import "github.com/mauricelam/genny/generic"
+
+type Key generic.Type
+
+// genny:default Value=string
+type Value generic.Type
+
+// KeyValueCache holds the Value of every Key.
+type KeyValueCache struct {
+	entries map[Key]Value
+}
+
+// Get gets the Value of the Key.
+func (c *KeyValueCache) Get(k Key) (Value, bool) {
+	v, ok := c.entries[k]
+	return v, ok
+}
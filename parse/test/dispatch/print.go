@@ -0,0 +1,31 @@
+//go:build genny
+
+package dispatch
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// FprintElem writes e to w on a line of its own.
+func FprintElem(w io.Writer, e Elem) (int, error) {
+	return fmt.Fprintln(w, e)
+}
+
+// PrintElems prints each of them, so there is no single value to switch on.
+func PrintElems(elems ...Elem) {
+	for _, e := range elems {
+		fmt.Println(e)
+	}
+}
+
+// PrintElemWithPrefix prints e after the prefix and the other arguments.
+func PrintElemWithPrefix(e Elem, prefix string, a ...interface{}) {
+	fmt.Print(prefix)
+	fmt.Print(a...)
+	fmt.Println(e)
+}
@@ -0,0 +1,17 @@
+package equal
+
+import "math"
+
+// equalFloats tells whether a and b are as long, with their items within
+// 1e-9 of each other.
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package equal
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+var ElemEqual = generic.Equal
+
+// IndexElem gets the index of the first item of s that is equal to v, or -1.
+func IndexElem(s []Elem, v Elem) int {
+	for i, item := range s {
+		if ElemEqual(item, v) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,15 @@
+package examples_test
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/parse/test/examples"
+)
+
+func ExampleElemStack_Push() {
+	var s examples.ElemStack
+	s.Push(1)
+	s.Push(2)
+	fmt.Println(s.Pop(), s.Pop())
+	// Output: 2 1
+}
@@ -0,0 +1,22 @@
+package examples
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemStack is a stack of Elem values.
+type ElemStack struct {
+	items []Elem
+}
+
+// Push puts an item on top of the stack.
+func (s *ElemStack) Push(item Elem) {
+	s.items = append(s.items, item)
+}
+
+// Pop takes the item on top of the stack.
+func (s *ElemStack) Pop() Elem {
+	item := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return item
+}
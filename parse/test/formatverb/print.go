@@ -0,0 +1,16 @@
+package formatverb
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+const ElemVerb = generic.FormatVerb
+
+// PrintElem prints v with the verb of its type.
+func PrintElem(v Elem) {
+	fmt.Printf("value: "+ElemVerb+"\n", v)
+}
@@ -0,0 +1,13 @@
+package hash
+
+func hashString(s string) uint64 {
+	var h uint64 = 14695981039346656037
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * 1099511628211
+	}
+	return h
+}
+
+func hashInt(i int) uint64 {
+	return uint64(i)
+}
@@ -0,0 +1,26 @@
+package hash
+
+import "github.com/mauricelam/genny/generic"
+
+type Key generic.Type
+
+var KeyHash = generic.Hash
+
+// KeySet is a set of Key values, bucketed by their hash.
+type KeySet struct {
+	buckets map[uint64][]Key
+}
+
+// Add adds k to the set.
+func (s *KeySet) Add(k Key) {
+	h := KeyHash(k)
+	for _, other := range s.buckets[h] {
+		if other == k {
+			return
+		}
+	}
+	if s.buckets == nil {
+		s.buckets = make(map[uint64][]Key)
+	}
+	s.buckets[h] = append(s.buckets[h], k)
+}
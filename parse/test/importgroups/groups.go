@@ -0,0 +1,16 @@
+package importgroups
+
+import (
+	"github.com/mauricelam/genny/generic"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/mauricelam/genny/parse/test/aliases/one/util"
+	"fmt"
+)
+
+type Elem generic.Type
+
+// AssertElemValue checks that the value of an Elem is the util.Value.
+func AssertElemValue(t *testing.T, v Elem, value util.Value) {
+	assert.Equal(t, fmt.Sprint(value), fmt.Sprint(v))
+}
@@ -0,0 +1,26 @@
+package include
+
+import "fmt"
+
+// ElemIterator iterates over Elem values. It is included by the templates
+// of the containers.
+type ElemIterator struct {
+	items []Elem
+	index int
+}
+
+// Next moves to the next item, and gets whether there is one.
+func (it *ElemIterator) Next() bool {
+	it.index++
+	return it.index <= len(it.items)
+}
+
+// Value gets the current item.
+func (it *ElemIterator) Value() Elem {
+	return it.items[it.index-1]
+}
+
+// String gets the current item as a string.
+func (it *ElemIterator) String() string {
+	return fmt.Sprint(it.Value())
+}
@@ -0,0 +1,22 @@
+package include
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list of Elem values.
+type ElemList struct {
+	items []Elem
+}
+
+// Add adds an item to the list.
+func (l *ElemList) Add(item Elem) {
+	l.items = append(l.items, item)
+}
+
+// genny:include iterator.go
+
+// Iterator gets an iterator over the items of the list.
+func (l *ElemList) Iterator() *ElemIterator {
+	return &ElemIterator{items: l.items}
+}
@@ -0,0 +1,20 @@
+package inherit
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list of Elem values.
+type ElemList struct {
+	items []Elem
+}
+
+// Add adds an item to the list.
+func (l *ElemList) Add(item Elem) {
+	l.items = append(l.items, item)
+}
+
+// Len gets the number of items in the list.
+func (l *ElemList) Len() int {
+	return len(l.items)
+}
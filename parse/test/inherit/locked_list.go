@@ -0,0 +1,28 @@
+//go:build genny
+
+package inherit
+
+// genny:base list.go
+
+import "sync"
+
+// ElemList is a list of Elem values that can be used by several
+// goroutines at once.
+type ElemList struct {
+	mu    sync.Mutex
+	items []Elem
+}
+
+// Add adds an item to the list.
+func (l *ElemList) Add(item Elem) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, item)
+}
+
+// Clear removes all the items from the list.
+func (l *ElemList) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = nil
+}
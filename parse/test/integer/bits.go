@@ -0,0 +1,32 @@
+package integer
+
+import (
+	"math"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Word generic.Unsigned
+
+type Signed generic.Integer
+
+type Ratio generic.Float
+
+// OnesWord counts the bits of w that are set.
+func OnesWord(w Word) Signed {
+	var n Signed
+	for ; w != 0; w &= w - 1 {
+		n++
+	}
+	return n
+}
+
+// EvenSigned tells whether n is even.
+func EvenSigned(n Signed) bool {
+	return n%2 == 0
+}
+
+// RoundRatio rounds r to the nearest whole number.
+func RoundRatio(r Ratio) Ratio {
+	return Ratio(math.Round(float64(r)))
+}
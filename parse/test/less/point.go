@@ -0,0 +1,11 @@
+package less
+
+// Point is a type that can't be compared with <.
+type Point struct {
+	X, Y int
+}
+
+// lessPoint orders points by X, then by Y.
+func lessPoint(a, b Point) bool {
+	return a.X < b.X || a.X == b.X && a.Y < b.Y
+}
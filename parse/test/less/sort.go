@@ -0,0 +1,16 @@
+package less
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+var ElemLess = generic.Less
+
+// SortElems sorts s in place with an insertion sort.
+func SortElems(s []Elem) {
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && ElemLess(s[j], s[j-1]); j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build genny
+
+package merge
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// ElemList is a list of Elems.
+type ElemList []Elem
+
+// Print prints the list.
+func (l ElemList) Print() {
+	fmt.Println(l)
+}
@@ -0,0 +1,24 @@
+//go:build genny
+
+package merge
+
+import (
+	"sort"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// ElemSet is a set of Elems.
+type ElemSet map[Elem]struct{}
+
+// Sorted sorts the items with less.
+func (s ElemSet) Sorted(less func(a, b Elem) bool) []Elem {
+	items := make([]Elem, 0, len(s))
+	for item := range s {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return less(items[i], items[j]) })
+	return items
+}
@@ -0,0 +1,12 @@
+package naming
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// ElemList is a list of Elems.
+type ElemList []Elem
+
+func NewElemList(items []Elem) ElemList {
+	return ElemList(items)
+}
@@ -0,0 +1,12 @@
+package ordered
+
+import "github.com/mauricelam/genny/generic"
+
+type OrderedType generic.Ordered
+
+func OrderedTypeMin(a, b OrderedType) OrderedType {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,13 @@
+// Package pkgdoc provides a ThingBox for holding Things.
+//
+// A ThingBox is not safe for concurrent use.
+package pkgdoc
+
+import "github.com/mauricelam/genny/generic"
+
+type Thing generic.Type
+
+// ThingBox holds a Thing.
+type ThingBox struct {
+	Value Thing
+}
@@ -0,0 +1,30 @@
+//go:build genny
+
+package platform
+
+import (
+	"unsafe"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+// Word is the unsigned integer that is as wide as a pointer.
+type Word generic.Number
+
+const bitsPerWord = uint(8 * unsafe.Sizeof(Word(0)))
+
+// BitSet is a set of small numbers, stored in integers as wide as a pointer.
+type BitSet []Word
+
+// Add adds n to the set.
+func (s *BitSet) Add(n uint) {
+	for uint(len(*s)) <= n/bitsPerWord {
+		*s = append(*s, 0)
+	}
+	(*s)[n/bitsPerWord] |= 1 << (n % bitsPerWord)
+}
+
+// Has gets whether n is in the set.
+func (s BitSet) Has(n uint) bool {
+	return n/bitsPerWord < uint(len(s)) && s[n/bitsPerWord]&(1<<(n%bitsPerWord)) != 0
+}
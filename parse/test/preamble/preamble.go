@@ -0,0 +1,23 @@
+// Copyright 2020 The Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license.
+
+/*
+Block comment
+package notreally
+*/
+
+//go:build genny
+
+//go:generate genny -in=$GOFILE -out=gen-$GOFILE gen "Thing=int"
+
+// Package preamble is a packaged thing.
+package preamble
+
+import "github.com/mauricelam/genny/generic"
+
+type Thing generic.Type
+
+// ThingBox holds a Thing.
+type ThingBox struct {
+	Value Thing
+}
@@ -0,0 +1,35 @@
+//go:build genny
+
+package preprocess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// ElemList is a list of Elem values.
+type ElemList []Elem
+
+/*{{if eq .Elem "string"}}*/
+
+// Join joins the items of the list with sep between them.
+func (l ElemList) Join(sep string) string {
+	return strings.Join(l, sep)
+}
+
+/*{{else}}*/
+
+// Join joins the items of the list with sep between them.
+func (l ElemList) Join(sep string) string {
+	items := make([]string, len(l))
+	for i, item := range l {
+		items[i] = fmt.Sprint(item)
+	}
+	return strings.Join(items, sep)
+}
+
+/*{{end}}*/
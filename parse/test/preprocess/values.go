@@ -0,0 +1,16 @@
+//go:build genny
+
+package preprocess
+
+import (
+	"fmt"
+
+	"github.com/mauricelam/genny/generic"
+)
+
+type Elem generic.Type
+
+// FormatElem formats v like fmt.Sprintf does with its verb.
+func FormatElem(v Elem) string {
+	return fmt.Sprintf("/*{{(values .Elem).verb}}*/", v)
+}
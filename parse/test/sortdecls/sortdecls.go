@@ -0,0 +1,35 @@
+package sortdecls
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// NewElemStack makes an empty stack.
+func NewElemStack() *ElemStack {
+	return &ElemStack{}
+}
+
+// ElemStack is a stack of Elems.
+type ElemStack struct {
+	items []Elem
+}
+
+// Push adds an item to the stack.
+func (s *ElemStack) Push(item Elem) {
+	s.items = append(s.items, item)
+}
+
+// DefaultElemCapacity is the initial capacity of a stack.
+const DefaultElemCapacity = 8
+
+// Pop removes the last item from the stack.
+func (s *ElemStack) Pop() Elem {
+	item := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return item
+}
+
+// ElemPair holds two Elems.
+type ElemPair struct {
+	First, Second Elem
+}
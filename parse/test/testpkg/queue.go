@@ -0,0 +1,24 @@
+package testpkg
+
+import "github.com/mauricelam/genny/generic"
+
+type Something generic.Type
+
+// SomethingQueue is a queue of Somethings.
+type SomethingQueue struct {
+	items []Something
+}
+
+func NewSomethingQueue() *SomethingQueue {
+	return &SomethingQueue{}
+}
+
+func (q *SomethingQueue) Push(item Something) {
+	q.items = append(q.items, item)
+}
+
+func (q *SomethingQueue) Pop() Something {
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item
+}
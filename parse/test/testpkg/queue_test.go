@@ -0,0 +1,26 @@
+package testpkg
+
+import "testing"
+
+// the generic type is declared by the template under test
+
+func TestSomethingQueue(t *testing.T) {
+	var zero Something
+	for _, test := range []struct {
+		name  string
+		items []Something
+	}{
+		{"one", []Something{zero}},
+		{"two", []Something{zero, zero}},
+	} {
+		q := NewSomethingQueue()
+		for _, item := range test.items {
+			q.Push(item)
+		}
+		for range test.items {
+			if got := q.Pop(); got != zero {
+				t.Errorf("%s: got %v", test.name, got)
+			}
+		}
+	}
+}
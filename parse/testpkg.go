@@ -0,0 +1,53 @@
+package parse
+
+import (
+	"go/parser"
+	"go/token"
+)
+
+// testedPackage gets the name of the package under test if the code of the
+// templates of package pkgName is generated into its external test package,
+// like queue for queue_test, or "".
+func testedPackage(pkgName string, opts Options) string {
+	if opts.PkgName != pkgName+"_test" {
+		return ""
+	}
+	return pkgName
+}
+
+// qualifyTested qualifies the exported identifiers that the generated code
+// refers to but doesn't declare with the package under test, so that
+// NewIntQueue() becomes queue.NewIntQueue() in package queue_test. It tells
+// whether there were any.
+func qualifyTested(code []byte, pkg string) ([]byte, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		// leave it to imports.Process to report the syntax error
+		return code, false
+	}
+	var out []byte
+	start := 0
+	for _, ident := range file.Unresolved {
+		if !isExported(ident.Name) {
+			continue
+		}
+		offset := fset.Position(ident.Pos()).Offset
+		out = append(out, code[start:offset]...)
+		out = append(out, pkg+"."...)
+		start = offset
+	}
+	if start == 0 {
+		return code, false
+	}
+	return append(out, code[start:]...), true
+}
+
+// testedImport gets the import of the package under test, with the name of
+// the package if the path doesn't end with it.
+func testedImport(path, pkg string) importSpec {
+	if importBase(path) == pkg {
+		return importSpec{Path: path}
+	}
+	return importSpec{Name: pkg, Path: path}
+}
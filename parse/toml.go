@@ -0,0 +1,61 @@
+package parse
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// readTOML reads the TOML that configs can be written in, into the maps and
+// slices that the YAML of a config is read into. Dates and times are
+// errors, as configs have none.
+func readTOML(src string) (map[string]interface{}, error) {
+	var tables map[string]interface{}
+	if _, err := toml.Decode(src, &tables); err != nil {
+		// the errors have the line they are at
+		return nil, &errBadConfig{Message: strings.TrimPrefix(err.Error(), "toml: ")}
+	}
+	if err := checkTOMLValues("", tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// checkTOMLValues fails for the dates and times in the value at key, which
+// would be read as the strings of fields otherwise.
+func checkTOMLValues(key string, value interface{}) error {
+	switch value := value.(type) {
+	case time.Time:
+		return &errBadConfig{Message: "the value of '" + key + "' is a date or time, which configs don't have"}
+	case map[string]interface{}:
+		var keys []string
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := k
+			if key != "" {
+				name = key + "." + k
+			}
+			if err := checkTOMLValues(name, value[k]); err != nil {
+				return err
+			}
+		}
+	case []map[string]interface{}:
+		for _, table := range value {
+			if err := checkTOMLValues(key, table); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, v := range value {
+			if err := checkTOMLValues(key, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// typeArg is a generic type along with the specific types it is replaced
+// with.
+type typeArg struct {
+	Generic   string
+	Specifics []string
+}
+
+// typeArgsParser splits the type arguments of genny gen, like
+//
+//	KeyType=string ValueType=int,"func(a, b int) bool"
+//
+// into the generic types and their specific types. The pairs are separated
+// by white space and the specific types by commas, except inside brackets
+// and quotes. Double quotes are unquoted like Go strings, single quotes are
+// taken literally.
+type typeArgsParser struct {
+	arg   string
+	runes []rune
+	pos   int
+}
+
+func parseTypeArgs(arg string) ([]typeArg, error) {
+	p := &typeArgsParser{arg: arg, runes: []rune(arg)}
+	var args []typeArg
+	seen := make(map[string]bool)
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+
+		genericPos := p.pos
+		generic := p.readGeneric()
+		if generic == "" {
+			return nil, p.unexpected("a generic type")
+		}
+		if seen[generic] {
+			return nil, p.errorAt(genericPos, "generic type '"+generic+"' is given twice")
+		}
+		seen[generic] = true
+		if p.done() || p.runes[p.pos] != '=' {
+			return nil, p.unexpected("'='")
+		}
+		p.pos++
+
+		specifics, err := p.readSpecifics()
+		if err != nil {
+			return nil, err
+		}
+		// the list can be followed by types to add or remove, like
+		// @numeric - complex64,complex128
+		for {
+			opPos := p.pos
+			p.skipSpace()
+			if p.done() || (p.runes[p.pos] != '+' && p.runes[p.pos] != '-') {
+				p.pos = opPos
+				break
+			}
+			op := p.runes[p.pos]
+			p.pos++
+			p.skipSpace()
+			operand, err := p.readSpecifics()
+			if err != nil {
+				return nil, err
+			}
+			if op == '+' {
+				specifics = addTypes(specifics, operand)
+			} else {
+				specifics = removeTypes(specifics, operand)
+			}
+		}
+		if len(specifics) == 0 {
+			return nil, p.errorAt(genericPos, "no specific types are left for '"+generic+"'")
+		}
+		arg := typeArg{Generic: generic, Specifics: specifics}
+		if !p.done() && !unicode.IsSpace(p.runes[p.pos]) {
+			return nil, p.unexpected("white space")
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return nil, &errBadTypeArgs{Arg: arg, Message: "Generic=Specific expected"}
+	}
+	return args, nil
+}
+
+// readSpecifics reads a comma separated list of specific types, with
+// BUILTINS, NUMBERS and the type classes like @numeric expanded.
+func (p *typeArgsParser) readSpecifics() ([]string, error) {
+	var specifics []string
+	for {
+		specificPos := p.pos
+		specific, quoted, err := p.readSpecific()
+		if err != nil {
+			return nil, err
+		}
+		// they stand for lists of types, unless quoted
+		switch {
+		case specific == builtins && !quoted:
+			specifics = append(specifics, Builtins...)
+		case specific == numbers && !quoted:
+			specifics = append(specifics, Numbers...)
+		case strings.HasPrefix(specific, "@") && !quoted:
+			types, ok := typeClasses[specific]
+			if !ok {
+				return nil, p.errorAt(specificPos, "unknown type class '"+specific+"', expected one of "+strings.Join(typeClassNames(), ", "))
+			}
+			specifics = append(specifics, types...)
+		default:
+			specifics = append(specifics, specific)
+		}
+		if p.done() || p.runes[p.pos] != ',' {
+			return specifics, nil
+		}
+		p.pos++
+	}
+}
+
+// addTypes adds the types that are not in the list yet to its end.
+func addTypes(list, types []string) []string {
+	for _, t := range types {
+		list = stringArraySet(list).append(t)
+	}
+	return list
+}
+
+// removeTypes removes the types from the list.
+func removeTypes(list, types []string) []string {
+	var kept []string
+	for _, t := range list {
+		if !stringArraySet(types).contains(t) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func (p *typeArgsParser) done() bool {
+	return p.pos >= len(p.runes)
+}
+
+func (p *typeArgsParser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.runes[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *typeArgsParser) readGeneric() string {
+	start := p.pos
+	for !p.done() && isAlphaNumeric(p.runes[p.pos]) {
+		p.pos++
+	}
+	return string(p.runes[start:p.pos])
+}
+
+// readSpecific reads a specific type, up to the next comma or white space
+// outside of brackets and quotes. It also gets whether any of it was quoted.
+func (p *typeArgsParser) readSpecific() (string, bool, error) {
+	var specific strings.Builder
+	var brackets []int
+	start := p.pos
+	quoted := false
+	for !p.done() {
+		r := p.runes[p.pos]
+		if len(brackets) == 0 && (r == ',' || unicode.IsSpace(r)) {
+			break
+		}
+		switch r {
+		case '"', '\'':
+			s, err := p.readQuoted()
+			if err != nil {
+				return "", false, err
+			}
+			specific.WriteString(s)
+			quoted = true
+			continue
+		case '(', '[', '{':
+			brackets = append(brackets, p.pos)
+		case ')', ']', '}':
+			if len(brackets) == 0 || p.runes[brackets[len(brackets)-1]] != openingBracket(r) {
+				return "", false, p.unexpected("")
+			}
+			brackets = brackets[:len(brackets)-1]
+		case '=':
+			return "", false, p.unexpected("")
+		}
+		specific.WriteRune(r)
+		p.pos++
+	}
+	if len(brackets) > 0 {
+		open := brackets[len(brackets)-1]
+		return "", false, p.errorAt(open, "'"+string(p.runes[open])+"' is not closed")
+	}
+	if p.pos == start {
+		return "", false, p.unexpected("a specific type")
+	}
+
+	return specific.String(), quoted, nil
+}
+
+// readQuoted reads a quoted string and gets its content.
+func (p *typeArgsParser) readQuoted() (string, error) {
+	start := p.pos
+	quote := p.runes[start]
+	for p.pos++; !p.done(); p.pos++ {
+		switch p.runes[p.pos] {
+		case '\\':
+			if quote == '"' {
+				p.pos++
+			}
+		case quote:
+			p.pos++
+			quoted := string(p.runes[start:p.pos])
+			if quote == '\'' {
+				return quoted[1 : len(quoted)-1], nil
+			}
+			s, err := strconv.Unquote(quoted)
+			if err != nil {
+				return "", p.errorAt(start, "bad quoted string")
+			}
+			return s, nil
+		}
+	}
+	return "", p.errorAt(start, "quote is not closed")
+}
+
+// unexpected gets an error for the rune at the current position, saying
+// what was expected instead if it isn't empty.
+func (p *typeArgsParser) unexpected(expected string) error {
+	var message string
+	if p.done() {
+		message = "unexpected end"
+	} else {
+		message = "unexpected '" + string(p.runes[p.pos]) + "'"
+	}
+	if expected != "" {
+		message += ", expected " + expected
+	}
+	return p.errorAt(p.pos, message)
+}
+
+// errorAt gets an error at a position, which is counted from 1 for the
+// message. A position at the end of the arguments is given as their length.
+func (p *typeArgsParser) errorAt(pos int, message string) error {
+	switch {
+	case pos < len(p.runes):
+		message += " at position " + strconv.Itoa(pos+1)
+	case len(p.runes) > 0:
+		message += " at position " + strconv.Itoa(len(p.runes))
+	}
+	return &errBadTypeArgs{Arg: p.arg, Message: message}
+}
+
+func openingBracket(r rune) rune {
+	switch r {
+	case ')':
+		return '('
+	case ']':
+		return '['
+	}
+	return '{'
+}
+
+// quoteSpecific quotes a specific type if it would not be read back as it
+// is, so that it can be given to genny gen again.
+func quoteSpecific(s string) string {
+	args, err := parseTypeArgs("T=" + s)
+	if err == nil && len(args) == 1 && len(args[0].Specifics) == 1 && args[0].Specifics[0] == s {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,643 @@
+package parse
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mode is how the templates are generated.
+type Mode int
+
+const (
+	// ModeCopies generates a copy of the templates for every type set, with
+	// the specific types substituted into it.
+	ModeCopies Mode = iota
+	// ModeTypeParams converts the templates into one version of them with
+	// the type parameters of Go 1.18 in place of the generic types, which
+	// every type set uses, to migrate templates to the generics of Go.
+	ModeTypeParams
+)
+
+var modes = map[string]Mode{
+	"copies":     ModeCopies,
+	"typeparams": ModeTypeParams,
+}
+
+// ParseMode returns the Mode with the given name. Valid names are "copies"
+// and "typeparams".
+func ParseMode(name string) (Mode, error) {
+	if name == "" {
+		return ModeCopies, nil
+	}
+	mode, ok := modes[name]
+	if !ok {
+		return ModeCopies, &errBadMode{Name: name}
+	}
+	return mode, nil
+}
+
+// typeParamConstraints are the built-in types that the constraints of the
+// placeholder types of the generic package are the union of, in the order
+// the constraints are declared in. generic.Type is any, or comparable.
+var typeParamConstraints = []struct {
+	placeholder string
+	types       []string
+}{
+	{"Number", Numbers},
+	{"Ordered", Ordered},
+	{"Integer", Integers},
+	{"Unsigned", []string{"uint", "uint16", "uint32", "uint64", "uint8"}},
+	{"Float", []string{"float32", "float64"}},
+}
+
+// typeParamsFile is a template converted to type parameters.
+type typeParamsFile struct {
+	pkgName string
+	// imports are the import specs of the template, without the generic
+	// package.
+	imports []string
+	// body is the code after the imports.
+	body []byte
+	// constraints are the placeholder types whose constraints the code
+	// uses.
+	constraints map[string]bool
+}
+
+// genericsTypeParams converts the templates into one version of them with
+// type parameters, for ModeTypeParams. The generic types become the type
+// parameters of the declarations that use them, directly or through other
+// declarations, which are named without them, so ElemList becomes
+// List[Elem any] unless the name is taken. generic.Type becomes any, or
+// comparable if the template uses it in a map key or compares it, and the
+// other placeholder types become constraints that are declared with the
+// code, like Number. Package-level variables and constants can't have type
+// parameters, and neither can methods of their own, so those that use the
+// generic types fail the conversion.
+func genericsTypeParams(templates []Template, opts Options) ([]byte, error) {
+	start := time.Now()
+	if err := checkRenames(opts.Renames); err != nil {
+		return nil, err
+	}
+	opts.Stats.addTemplates(len(templates), 1)
+
+	var resolved, sources [][]byte
+	taken := make(map[string]bool)
+	for _, template := range templates {
+		src, err := opts.Limits.readSource(template)
+		if err != nil {
+			return nil, err
+		}
+		src = normalizeEOL(src)
+		src, err = resolveTemplate(template.Filename, src)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, src)
+		src = dropDefaults(src)
+		sources = append(sources, src)
+		file, err := parser.ParseFile(token.NewFileSet(), template.Filename, src, 0)
+		if err != nil {
+			return nil, &errSource{Err: err}
+		}
+		for name := range file.Scope.Objects {
+			taken[name] = true
+		}
+	}
+	// the constraints are named after the placeholder types, unless the
+	// templates have the names
+	constraintNames := make(map[string]string)
+	for _, c := range typeParamConstraints {
+		name := c.placeholder
+		if taken[name] {
+			name += "Constraint"
+		}
+		constraintNames[c.placeholder] = name
+	}
+
+	pkgName := opts.PkgName
+	var importSpecs []string
+	seenImports := make(map[string]bool)
+	var body bytes.Buffer
+	used := make(map[string]bool)
+	for i, template := range templates {
+		converted, err := convertTypeParams(template.Filename, sources[i], constraintNames, opts.Renames)
+		if err != nil {
+			return nil, err
+		}
+		if pkgName == "" {
+			pkgName = converted.pkgName
+		}
+		for _, spec := range converted.imports {
+			if !seenImports[spec] {
+				seenImports[spec] = true
+				importSpecs = append(importSpecs, spec)
+			}
+		}
+		body.WriteString("\n")
+		body.Write(converted.body)
+		for placeholder := range converted.constraints {
+			used[placeholder] = true
+		}
+	}
+	for _, c := range typeParamConstraints {
+		if !used[c.placeholder] {
+			continue
+		}
+		name := constraintNames[c.placeholder]
+		body.WriteString("\n\n// " + name + " is the constraint of the type parameters of generic." + c.placeholder + ".\n")
+		body.WriteString("type " + name + " interface {\n\t~" + strings.Join(c.types, " | ~") + "\n}\n")
+	}
+
+	var buf bytes.Buffer
+	stamp := ""
+	if opts.StampDir != "" {
+		stamp = stampLine(opts.StampDir, templates, resolved)
+	}
+	if stamp != "" || opts.Command != "" {
+		// the stamp and the command go under the header, like in the copies
+		buf.WriteString(strings.TrimSuffix(header, "\n"))
+		if stamp != "" {
+			buf.WriteString(stamp)
+		}
+		if opts.Command != "" {
+			buf.WriteString(commandLine(opts.Command))
+		}
+		buf.WriteString("\n")
+	} else {
+		buf.WriteString(header)
+	}
+	buf.WriteString("package " + pkgName + "\n")
+	if len(importSpecs) > 0 {
+		buf.WriteString("\nimport (\n\t" + strings.Join(importSpecs, "\n\t") + "\n)\n")
+	}
+	buf.Write(body.Bytes())
+	if err := opts.Limits.checkOutputSize(buf.Len()); err != nil {
+		return nil, err
+	}
+	opts.Stats.phase("generate", start)
+
+	start = time.Now()
+	filename := "stdin"
+	if len(templates) > 0 {
+		filename = templates[0].Filename
+	}
+	output, err := formatOutput(filename, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	opts.Stats.phase("format", start)
+	opts.Stats.addFile()
+	opts.Stats.addOutput(output)
+	return output, nil
+}
+
+// textEdit replaces the text of the source from start to end.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// typeParamDecl is a top-level declaration of a template, and what it
+// needs type parameters for.
+type typeParamDecl struct {
+	node ast.Node
+	// names are the names it declares, or the method for a method.
+	names []*ast.Ident
+	// kind is type, func, method, var or const.
+	kind string
+	// recv is the receiver type of a method.
+	recv string
+	// generics are the generic types it uses directly, and refs the other
+	// top-level declarations.
+	generics map[string]bool
+	refs     map[string]bool
+}
+
+// convertTypeParams converts the resolved source of a template to type
+// parameters, with the constraints of the placeholder types named by
+// constraintNames. renames rename the declarations after they are named
+// without the generic types.
+func convertTypeParams(filename string, src []byte, constraintNames, renames map[string]string) (typeParamsFile, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return typeParamsFile{}, &errSource{Err: err}
+	}
+	tf := fset.File(file.Pos())
+	offset := tf.Offset
+	fail := func(pos token.Pos, message string) error {
+		return &errTypeParams{Filename: filename, Line: fset.Position(pos).Line, Message: message}
+	}
+	result := typeParamsFile{pkgName: file.Name.Name, constraints: make(map[string]bool)}
+
+	bodyStart := offset(file.Name.End())
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		bodyStart = offset(gen.End())
+		for _, spec := range gen.Specs {
+			if s := spec.(*ast.ImportSpec); strings.Trim(s.Path.Value, "`\"") != genericImport {
+				result.imports = append(result.imports, string(src[offset(s.Pos()):offset(s.End())]))
+			}
+		}
+	}
+
+	// the generic types, in the order of the template, which are removed
+	var generics []*ast.TypeSpec
+	isGeneric := make(map[string]bool)
+	var edits []textEdit
+	remove := func(doc *ast.CommentGroup, node ast.Node) {
+		pos := node.Pos()
+		if doc != nil {
+			pos = doc.Pos()
+		}
+		edits = append(edits, textEdit{start: offset(tf.LineStart(tf.Line(pos))), end: offset(node.End())})
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		var specs []*ast.TypeSpec
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); isGenericTypeDefinition(ts) {
+				specs = append(specs, ts)
+			}
+		}
+		if len(specs) == len(gen.Specs) {
+			remove(gen.Doc, gen)
+		} else {
+			for _, ts := range specs {
+				remove(ts.Doc, ts)
+			}
+		}
+		for _, ts := range specs {
+			generics = append(generics, ts)
+			isGeneric[ts.Name.Name] = true
+		}
+	}
+	if len(generics) == 0 {
+		return typeParamsFile{}, fail(file.Name.Pos(), "it has no generic types")
+	}
+
+	// the identifiers that refer to the top-level declarations, less those
+	// that declare them and the keys of composite literals, which may be
+	// fields
+	declaring := make(map[*ast.Ident]bool)
+	keys := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if lit, ok := node.(*ast.CompositeLit); ok {
+			for _, elt := range lit.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						keys[key] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+	refersTop := func(ident *ast.Ident) bool {
+		return ident.Obj != nil && file.Scope.Lookup(ident.Name) == ident.Obj && !declaring[ident] && !keys[ident]
+	}
+
+	var decls []*typeParamDecl
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			declaring[d.Name] = true
+			if d.Recv == nil {
+				decls = append(decls, &typeParamDecl{node: d, names: []*ast.Ident{d.Name}, kind: "func"})
+			} else if len(d.Recv.List) > 0 {
+				decls = append(decls, &typeParamDecl{node: d, names: []*ast.Ident{d.Name}, kind: "method", recv: receiverName(d.Recv.List[0].Type)})
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					declaring[s.Name] = true
+					if !isGeneric[s.Name.Name] {
+						decls = append(decls, &typeParamDecl{node: s, names: []*ast.Ident{s.Name}, kind: "type"})
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						declaring[name] = true
+					}
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					decls = append(decls, &typeParamDecl{node: s, names: s.Names, kind: kind})
+				}
+			}
+		}
+	}
+	for _, d := range decls {
+		d.generics, d.refs = make(map[string]bool), make(map[string]bool)
+		var err error
+		ast.Inspect(d.node, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.SelectorExpr:
+				if x, ok := n.X.(*ast.Ident); ok && x.Name == genericPackage && x.Obj == nil && err == nil {
+					err = fail(n.Pos(), "generic."+n.Sel.Name+" has no form with type parameters")
+				}
+			case *ast.Ident:
+				if !refersTop(n) {
+					break
+				}
+				if isGeneric[n.Name] {
+					d.generics[n.Name] = true
+				} else {
+					d.refs[n.Name] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return typeParamsFile{}, err
+		}
+	}
+
+	// the type parameters of a type or function are the generic types that
+	// it uses, and those of the types and functions it uses
+	byName := make(map[string]*typeParamDecl)
+	for _, d := range decls {
+		if d.kind != "method" {
+			for _, name := range d.names {
+				byName[name.Name] = d
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, d := range decls {
+			for ref := range d.refs {
+				if other, ok := byName[ref]; ok {
+					for generic := range other.generics {
+						if !d.generics[generic] {
+							d.generics[generic], changed = true, true
+						}
+					}
+				}
+			}
+		}
+	}
+	var genericNames []string
+	for _, generic := range generics {
+		genericNames = append(genericNames, generic.Name.Name)
+	}
+	params := func(d *typeParamDecl) []string {
+		var names []string
+		for _, name := range genericNames {
+			if d.generics[name] {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	for _, d := range decls {
+		if len(d.generics) == 0 {
+			continue
+		}
+		uses := strings.Join(params(d), ", ")
+		switch d.kind {
+		case "var", "const":
+			return typeParamsFile{}, fail(d.node.Pos(), "the "+map[string]string{"var": "variable", "const": "constant"}[d.kind]+" "+d.names[0].Name+" uses the generic types "+uses+", which it can't have as type parameters")
+		case "method":
+			recv, ok := byName[d.recv]
+			for generic := range d.generics {
+				if !ok || !recv.generics[generic] {
+					return typeParamsFile{}, fail(d.node.Pos(), "the method "+d.recv+"."+d.names[0].Name+" uses the generic types "+uses+", which its receiver doesn't have all of, and methods can't have type parameters of their own")
+				}
+			}
+		case "type":
+			if s := d.node.(*ast.TypeSpec); s.Assign.IsValid() {
+				return typeParamsFile{}, fail(s.Pos(), "the alias "+s.Name.Name+" uses the generic types "+uses+", which it can't have as type parameters")
+			}
+		}
+	}
+
+	// the constraints of the generic types
+	comparable := make(map[string]bool)
+	for generic := range mapKeyGenerics(filename, src) {
+		comparable[generic] = true
+	}
+	for name := range comparedTypes(file) {
+		if isGeneric[name] {
+			comparable[name] = true
+		} else if d, ok := byName[name]; ok {
+			// a struct or array of them is comparable only if they are
+			for generic := range d.generics {
+				comparable[generic] = true
+			}
+		}
+	}
+	constraints := make(map[string]string)
+	for _, generic := range generics {
+		name := generic.Name.Name
+		constraint := func(sel *ast.SelectorExpr) string {
+			if sel.Sel.Name != "Type" {
+				result.constraints[sel.Sel.Name] = true
+				return constraintNames[sel.Sel.Name]
+			} else if comparable[name] {
+				return "comparable"
+			}
+			return ""
+		}
+		switch t := generic.Type.(type) {
+		case *ast.SelectorExpr:
+			constraints[name] = constraint(t)
+			if constraints[name] == "" {
+				constraints[name] = "any"
+			}
+		case *ast.InterfaceType:
+			// the interface with the placeholder type made its constraint
+			var text strings.Builder
+			at := offset(t.Pos())
+			for _, method := range t.Methods.List {
+				if sel, ok := method.Type.(*ast.SelectorExpr); ok && isGenericTypeSelector(sel) {
+					text.Write(src[at:offset(sel.Pos())])
+					text.WriteString(constraint(sel))
+					at = offset(sel.End())
+				}
+			}
+			text.Write(src[at:offset(t.End())])
+			constraints[name] = text.String()
+		}
+	}
+
+	// the declarations with type parameters are named without the generic
+	// types, unless the names are taken
+	newNames := make(map[string]string)
+	count := make(map[string]int)
+	for _, d := range decls {
+		if len(d.generics) == 0 || d.kind == "method" {
+			continue
+		}
+		for _, name := range d.names {
+			stripped := stripGenerics(name.Name, genericNames)
+			newNames[name.Name] = stripped
+			count[stripped]++
+		}
+	}
+	for name, stripped := range newNames {
+		if stripped == "" || !isIdentifier(stripped) || token.Lookup(stripped).IsKeyword() || file.Scope.Lookup(stripped) != nil || types.Universe.Lookup(stripped) != nil || count[stripped] > 1 {
+			newNames[name] = name
+		}
+		for _, constraint := range constraintNames {
+			if stripped == constraint {
+				newNames[name] = name
+			}
+		}
+	}
+	for name, renamed := range newNames {
+		if to, ok := renames[renamed]; ok {
+			newNames[name] = to
+		}
+	}
+
+	for _, d := range decls {
+		if len(d.generics) == 0 || d.kind == "method" {
+			continue
+		}
+		var list []string
+		for _, name := range params(d) {
+			list = append(list, name+" "+constraints[name])
+		}
+		for _, name := range d.names {
+			edits = append(edits, textEdit{start: offset(name.Pos()), end: offset(name.End()), text: newNames[name.Name] + "[" + strings.Join(list, ", ") + "]"})
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok || !refersTop(ident) {
+			return true
+		}
+		if d, ok := byName[ident.Name]; ok && len(d.generics) > 0 {
+			edits = append(edits, textEdit{start: offset(ident.Pos()), end: offset(ident.End()), text: newNames[ident.Name] + "[" + strings.Join(params(d), ", ") + "]"})
+		}
+		return true
+	})
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if offset(comment.Pos()) < bodyStart {
+				continue
+			}
+			text := comment.Text
+			for name, renamed := range newNames {
+				if renamed != name {
+					text = regexp.MustCompile(`\b`+regexp.QuoteMeta(name)+`\b`).ReplaceAllString(text, renamed)
+				}
+			}
+			if text != comment.Text {
+				edits = append(edits, textEdit{start: offset(comment.Pos()), end: offset(comment.End()), text: text})
+			}
+		}
+	}
+
+	// the edits within the generic types that are removed are dropped
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end > edits[j].end
+	})
+	var body bytes.Buffer
+	at := bodyStart
+	for _, edit := range edits {
+		if edit.start < at {
+			continue
+		}
+		body.Write(src[at:edit.start])
+		body.WriteString(edit.text)
+		at = edit.end
+	}
+	body.Write(src[at:])
+	result.body = body.Bytes()
+	return result, nil
+}
+
+// comparedTypes gets the names of the types of the parameters, fields and
+// variables that the file compares with == or !=, which must be comparable,
+// as far as they are known without type checking.
+func comparedTypes(file *ast.File) map[string]bool {
+	compared := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		expr, ok := node.(*ast.BinaryExpr)
+		if !ok || expr.Op != token.EQL && expr.Op != token.NEQ {
+			return true
+		}
+		for _, operand := range []ast.Expr{expr.X, expr.Y} {
+			ident, ok := operand.(*ast.Ident)
+			if !ok || ident.Obj == nil {
+				continue
+			}
+			var typ ast.Expr
+			switch decl := ident.Obj.Decl.(type) {
+			case *ast.Field:
+				typ = decl.Type
+			case *ast.ValueSpec:
+				typ = decl.Type
+			case *ast.AssignStmt:
+				// a variable of a composite literal
+				for i, lhs := range decl.Lhs {
+					if l, ok := lhs.(*ast.Ident); ok && l.Name == ident.Name && i < len(decl.Rhs) {
+						if lit, ok := decl.Rhs[i].(*ast.CompositeLit); ok {
+							typ = lit.Type
+						}
+					}
+				}
+			}
+			if t, ok := typ.(*ast.Ident); ok {
+				compared[t.Name] = true
+			}
+		}
+		return true
+	})
+	return compared
+}
+
+// stripGenerics names a declaration without the generic types, where they
+// are words of its name, keeping whether it is exported, so ElemList
+// becomes List and newElemList becomes newList.
+func stripGenerics(name string, generics []string) string {
+	stripped := name
+	for _, generic := range generics {
+		lower := strings.ToLower(generic[:1]) + generic[1:]
+		for _, word := range []string{generic, lower} {
+			for i := 0; i+len(word) <= len(stripped); {
+				end := i + len(word)
+				if stripped[i:end] != word || word == lower && i > 0 || end < len(stripped) && !isWordStart(stripped[end:]) {
+					i++
+					continue
+				}
+				stripped = stripped[:i] + stripped[end:]
+			}
+		}
+	}
+	if stripped == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(stripped)
+	if isExported(name) {
+		return string(unicode.ToUpper(first)) + stripped[size:]
+	}
+	return string(unicode.ToLower(first)) + stripped[size:]
+}
+
+// isWordStart tells whether s starts a new word of a camel case name.
+func isWordStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsUpper(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,118 @@
+package parse
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+// typeSetBatch is about how many type sets GenericsStream generates the
+// code of at a time.
+const typeSetBatch = 64
+
+// GenericsStream is like GenericsTo, but reads the type sets from r, which
+// has a line for each, or for a cross product of them, written like the
+// argument of genny gen. Blank lines and lines starting with # are skipped.
+//
+// Only a batch of the type sets is held in memory at a time, so the memory
+// use stays flat for files of thousands of them. r is read twice, like the
+// code is generated twice by GenericsTo. The code of the templates is
+// merged batch by batch, so with several templates the declarations are in
+// another order than GenericsTo writes them in. The options that need the
+// whole output in memory need all of the type sets too.
+func GenericsStream(w io.Writer, templates []Template, r io.ReadSeeker, opts Options) error {
+	if opts.SortDecls != DeclOrderNone || opts.transformsOutput() || opts.DebugDir != "" {
+		var typeSets []map[string]string
+		err := typeSetBatches(r, func(batch []map[string]string) error {
+			typeSets = append(typeSets, batch...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return GenericsTo(w, templates, typeSets, opts)
+	}
+
+	start := time.Now()
+	g, err := newGeneration(templates, nil, opts)
+	if err != nil {
+		return err
+	}
+	opts.Stats.phase("read", start)
+
+	// the imports of the earlier batches come first in those of the next,
+	// so that conflicting package names are aliased the same in all of them
+	start = time.Now()
+	specs := importPaths(opts.ImportPaths)
+	collector := newImportCollector(g)
+	dedupe := newDeclDeduper(nil)
+	count := 0
+	err = typeSetBatches(r, func(batch []map[string]string) error {
+		count += len(batch)
+		if err := opts.Limits.checkInstantiations(len(templates) * count); err != nil {
+			return err
+		}
+		if err := g.setTypeSets(batch, specs); err != nil {
+			return err
+		}
+		specs = g.importSpecs
+		return g.eachDeduped(dedupe, collector.add)
+	})
+	if err != nil {
+		return err
+	}
+	opts.Stats.addInstantiations(len(templates) * count)
+	importDecls := collector.importDecls(specs)
+	opts.Stats.phase("imports", start)
+
+	start = time.Now()
+	writer := newStreamWriter(w, g, importDecls, collector.refs)
+	dedupe = newDeclDeduper(nil)
+	err = typeSetBatches(r, func(batch []map[string]string) error {
+		if err := g.setTypeSets(batch, specs); err != nil {
+			return err
+		}
+		return g.eachDeduped(dedupe, writer.add)
+	})
+	if err != nil {
+		return err
+	}
+	opts.Stats.phase("generate", start)
+	opts.Stats.addFile()
+	return nil
+}
+
+// typeSetBatches reads the type sets of r from its start, and calls f with
+// a batch of them at a time.
+func typeSetBatches(r io.ReadSeeker, f func([]map[string]string) error) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var batch []map[string]string
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		arg := strings.TrimSpace(sc.Text())
+		if arg == "" || strings.HasPrefix(arg, "#") {
+			continue
+		}
+		typeSets, err := TypeSet(arg)
+		if err != nil {
+			return &errTypeSetLine{Line: line, Err: err}
+		}
+		batch = append(batch, typeSets...)
+		if len(batch) >= typeSetBatch {
+			if err := f(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return f(batch)
+	}
+	return nil
+}
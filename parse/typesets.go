@@ -1,13 +1,10 @@
 package parse
 
-import "strings"
+import "strconv"
 
 const (
-	typeSep     = " "
-	keyValueSep = "="
-	valuesSep   = ","
-	builtins    = "BUILTINS"
-	numbers     = "NUMBERS"
+	builtins = "BUILTINS"
+	numbers  = "NUMBERS"
 )
 
 // TypeSet turns a type string into a []map[string]string
@@ -21,26 +18,32 @@ const (
 //     Person=man,woman Animal=dog,cat
 //     Person=man,woman,child Animal=dog,cat Place=london,paris
 //     Place=London:city.London
+//     Less="func(a, b int) bool"
 func TypeSet(arg string) ([]map[string]string, error) {
+	return TypeSetLimit(arg, 0)
+}
+
+// TypeSetLimit is like TypeSet, but fails if there would be more than
+// maxTypeSets type sets, before building any of them. A zero maxTypeSets is
+// not enforced.
+func TypeSetLimit(arg string, maxTypeSets int) ([]map[string]string, error) {
 
+	typeArgs, err := parseTypeArgs(arg)
+	if err != nil {
+		return nil, err
+	}
 	types := make(map[string][]string)
 	var keys []string
-	for _, pair := range strings.Split(arg, typeSep) {
-		segs := strings.Split(pair, keyValueSep)
-		if len(segs) != 2 {
-			return nil, &errBadTypeArgs{Arg: arg, Message: "Generic=Specific expected"}
-		}
-		key := segs[0]
-		keys = append(keys, key)
-		types[key] = make([]string, 0)
-		for _, t := range strings.Split(segs[1], valuesSep) {
-			if t == builtins {
-				types[key] = append(types[key], Builtins...)
-			} else if t == numbers {
-				types[key] = append(types[key], Numbers...)
-			} else {
-				types[key] = append(types[key], t)
-			}
+	for _, typeArg := range typeArgs {
+		keys = append(keys, typeArg.Generic)
+		types[typeArg.Generic] = typeArg.Specifics
+	}
+
+	count := 1
+	for _, key := range keys {
+		count *= len(types[key])
+		if maxTypeSets > 0 && count > maxTypeSets {
+			return nil, &errLimit{What: "\"" + arg + "\" makes at least " + strconv.Itoa(count) + " type sets", Max: maxTypeSets}
 		}
 	}
 
@@ -1,6 +1,7 @@
 package parse_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/mauricelam/genny/parse"
@@ -72,3 +73,113 @@ func TestArgsToTypeset(t *testing.T) {
 	}
 
 }
+
+func TestTypeSetLimit(t *testing.T) {
+
+	ts, err := parse.TypeSetLimit("Person=1,2 Animal=1,2,3", 6)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 6, len(ts))
+	}
+
+	_, err = parse.TypeSetLimit("Person=1,2 Animal=1,2,3,4", 6)
+	assert.EqualError(t, err, `"Person=1,2 Animal=1,2,3,4" makes at least 8 type sets, which is over the limit of 6`)
+
+}
+
+func TestTypeSetSyntax(t *testing.T) {
+
+	ts, err := parse.TypeSet(`  Less=Cmp:"func(a, b int) bool",'chan<- int'	Map=map[string]interface{}  `)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []map[string]string{
+			{"Less": "Cmp:func(a, b int) bool", "Map": "map[string]interface{}"},
+			{"Less": "chan<- int", "Map": "map[string]interface{}"},
+		}, ts)
+	}
+
+	ts, err = parse.TypeSet(`Fn=Fn:func(a,b)(c) Q="BUILTINS","[]\"x\""`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []map[string]string{
+			{"Fn": "Fn:func(a,b)(c)", "Q": "BUILTINS"},
+			{"Fn": "Fn:func(a,b)(c)", "Q": `[]"x"`},
+		}, ts)
+	}
+
+	ts, err = parse.TypeSet(`T=@integer,*Big U=@ordered Q="@numeric"`)
+	if assert.NoError(t, err) {
+		assert.Len(t, ts, (len(parse.Integers)+1)*(len(parse.Numbers)+1))
+		assert.Equal(t, map[string]string{"T": "int", "U": "float32", "Q": "@numeric"}, ts[0])
+		assert.Equal(t, map[string]string{"T": "*Big", "U": "string", "Q": "@numeric"}, ts[len(ts)-1])
+	}
+	ts, err = parse.TypeSet(`T=@numeric - complex64,complex128 U=@ordered + time.Duration,string - @numeric`)
+	if assert.NoError(t, err) {
+		assert.Len(t, ts, len(parse.Numbers)*2)
+		assert.Equal(t, map[string]string{"T": "float32", "U": "string"}, ts[0])
+		assert.Equal(t, map[string]string{"T": "uint8", "U": "time.Duration"}, ts[len(ts)-1])
+	}
+	_, err = parse.TypeSet(`T=@integer-wide`)
+	assert.EqualError(t, err, `"T=@integer-wide" is bad: unknown type class '@integer-wide', expected one of @all-builtin, @integer, @numeric, @ordered at position 3`)
+	_, err = parse.TypeSet(`T=int - int`)
+	assert.EqualError(t, err, `"T=int - int" is bad: no specific types are left for 'T' at position 1`)
+	_, err = parse.TypeSet(`T=int -`)
+	assert.EqualError(t, err, `"T=int -" is bad: unexpected end, expected a specific type at position 7`)
+	ts, err = parse.TypeSet(`T=@all-builtin`)
+	if assert.NoError(t, err) {
+		assert.Len(t, ts, len(parse.Builtins))
+	}
+
+	for arg, message := range map[string]string{
+		"":                 `"" is bad: Generic=Specific expected`,
+		"Person=man=woman": `"Person=man=woman" is bad: unexpected '=' at position 11`,
+		"Person":           `"Person" is bad: unexpected end, expected '=' at position 6`,
+		"Person man":       `"Person man" is bad: unexpected ' ', expected '=' at position 7`,
+		"=man":             `"=man" is bad: unexpected '=', expected a generic type at position 1`,
+		"Person=":          `"Person=" is bad: unexpected end, expected a specific type at position 7`,
+		"Person=man,":      `"Person=man," is bad: unexpected end, expected a specific type at position 11`,
+		"A=x,,y":           `"A=x,,y" is bad: unexpected ',', expected a specific type at position 5`,
+		"A=x A=y":          `"A=x A=y" is bad: generic type 'A' is given twice at position 5`,
+		"A=map[string":     `"A=map[string" is bad: '[' is not closed at position 6`,
+		"A=x B=":           `"A=x B=" is bad: unexpected end, expected a specific type at position 6`,
+		"A=func(int]":      `"A=func(int]" is bad: unexpected ']' at position 11`,
+		`A="int B=x`:       `"A="int B=x" is bad: quote is not closed at position 3`,
+		"T=int,@number":    `"T=int,@number" is bad: unknown type class '@number', expected one of @all-builtin, @integer, @numeric, @ordered at position 7`,
+	} {
+		_, err := parse.TypeSet(arg)
+		assert.EqualError(t, err, message, arg)
+	}
+
+}
+
+func TestPlacedOutputs(t *testing.T) {
+
+	placements, err := parse.ParsePlacements([]string{"Elem=string", "Elem=int,float64", "pkg=fastints", "out=ints/gen.go", "Elem=bool", "Elem=byte", "out=ints/gen.go", "pkg=fastints"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []parse.Placement{
+			{Types: "Elem=string"},
+			{Types: "Elem=int,float64", Out: "ints/gen.go", Pkg: "fastints"},
+			{Types: "Elem=bool"},
+			{Types: "Elem=byte", Out: "ints/gen.go", Pkg: "fastints"},
+		}, placements)
+	}
+	outputs, err := parse.PlacedOutputs(placements, 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []parse.ConfigOutput{
+			{TypeSets: []map[string]string{{"Elem": "string"}, {"Elem": "bool"}}},
+			{Out: "ints/gen.go", Pkg: "fastints", TypeSets: []map[string]string{{"Elem": "int"}, {"Elem": "float64"}, {"Elem": "byte"}}},
+		}, outputs)
+	}
+
+	for args, message := range map[string]string{
+		"out=a.go Elem=int":                          `Bad placement 'out=a.go': it must come after the types it places`,
+		"Elem=int out=":                              `Bad placement 'out=': it has no value`,
+		"Elem=int out=a.go out=b.go":                 `Bad placement 'out=b.go': the types 'Elem=int' are placed twice`,
+		"Elem=int pkg=ints":                          `Bad placement 'pkg=ints': a package needs a file of its own, given with out=`,
+		"Elem=int out=a.go Elem=bool out=a.go pkg=b": `Bad placement 'out=a.go': the type sets written to it are in different packages`,
+	} {
+		placements, err := parse.ParsePlacements(strings.Fields(args))
+		if err == nil {
+			_, err = parse.PlacedOutputs(placements, 0)
+		}
+		assert.EqualError(t, err, message, args)
+	}
+
+}
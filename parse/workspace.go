@@ -0,0 +1,247 @@
+package parse
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Workspace is a go.work file and the modules it uses, whose packages the
+// generated code may import.
+type Workspace struct {
+	// Filename is the go.work file.
+	Filename string
+	// Modules are the modules of its use directives.
+	Modules []Module
+
+	// packages are the import paths of the packages of the modules by their
+	// names, once they are found.
+	packages map[string][]string
+}
+
+// Module is a module of a workspace.
+type Module struct {
+	// Dir is the absolute directory of its go.mod.
+	Dir string
+	// Path is the module path of its go.mod.
+	Path string
+}
+
+// FindWorkspace finds the workspace of the directory like the go command
+// does: the go.work file of GOWORK, or else the first one in dir or above
+// it. It gets nil if there is none, or with GOWORK=off.
+func FindWorkspace(dir string) (*Workspace, error) {
+	if gowork := os.Getenv("GOWORK"); gowork == "off" {
+		return nil, nil
+	} else if gowork != "" {
+		return ReadWorkspace(gowork)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for ; ; dir = filepath.Dir(dir) {
+		filename := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(filename); err == nil && info.Mode().IsRegular() {
+			return ReadWorkspace(filename)
+		}
+		if filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+// ReadWorkspace reads a go.work file and the go.mod files of the modules
+// it uses.
+func ReadWorkspace(filename string) (*Workspace, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ws := &Workspace{Filename: filename}
+	inUse := false
+	for i, line := range strings.Split(string(data), "\n") {
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		var dirs []string
+		switch {
+		case len(fields) == 0:
+			continue
+		case inUse && fields[0] == ")":
+			inUse = false
+		case inUse:
+			dirs = fields
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inUse = true
+		case fields[0] == "use":
+			dirs = fields[1:]
+		}
+		if len(dirs) == 0 {
+			continue
+		}
+		if len(dirs) != 1 {
+			return nil, &errWorkspace{Filename: filename, Line: i + 1, Message: "expected a directory to use"}
+		}
+		dir, err := strconv.Unquote(dirs[0])
+		if err != nil {
+			dir = dirs[0]
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(filename), filepath.FromSlash(dir))
+		}
+		modPath, err := modulePath(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, &errWorkspace{Filename: filename, Line: i + 1, Message: err.Error()}
+		}
+		ws.Modules = append(ws.Modules, Module{Dir: dir, Path: modPath})
+	}
+	return ws, nil
+}
+
+// modulePath gets the path of the module clause of a go.mod file.
+func modulePath(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`"), nil
+		}
+	}
+	return "", &errWorkspace{Filename: filename, Line: 1, Message: "no module clause"}
+}
+
+// TypeImports gets the import paths of the packages of the workspace that
+// the types of the type sets refer to, like lib for lib.Thing, as goimports
+// doesn't look for them in the other modules of a workspace. The types with
+// an import path of their own, the packages named like standard packages or
+// imports, and the names of more than one package are left to goimports.
+func (w *Workspace) TypeImports(typeSets []map[string]string, imports []string) []string {
+	imported := make(map[string]bool)
+	for _, path := range imports {
+		imported[importBase(path)] = true
+	}
+	paths := make(map[string]bool)
+	for _, typeSet := range typeSets {
+		for _, specific := range typeSet {
+			arg := parseSpecificArg(specific)
+			if arg.ImportPath != "" {
+				continue
+			}
+			for _, name := range typeQualifiers(arg.Type) {
+				if imported[name] || isStandardPackage(name) {
+					continue
+				}
+				if found := w.findPackages(name); len(found) == 1 {
+					paths[found[0]] = true
+				}
+			}
+		}
+	}
+	var typeImports []string
+	for path := range paths {
+		typeImports = append(typeImports, path)
+	}
+	sort.Strings(typeImports)
+	return typeImports
+}
+
+// typeQualifiers gets the package names that qualify the type names of a
+// type expression, like time for []*time.Time.
+func typeQualifiers(typ string) []string {
+	var names []string
+	for i := 0; i < len(typ); {
+		start := i
+		for i < len(typ) && isAlphaNumeric(rune(typ[i])) {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		if i < len(typ) && typ[i] == '.' && (start == 0 || typ[start-1] != '.') {
+			names = append(names, typ[start:i])
+		}
+	}
+	return names
+}
+
+// isStandardPackage tells whether there is a standard package with the
+// import path.
+func isStandardPackage(path string) bool {
+	info, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	return err == nil && info.IsDir()
+}
+
+// findPackages gets the import paths of the packages of the workspace with
+// the name. The vendor and testdata directories, those starting with . or
+// _, and nested modules are skipped like by the go tool.
+func (w *Workspace) findPackages(name string) []string {
+	if w.packages != nil {
+		return w.packages[name]
+	}
+	w.packages = make(map[string][]string)
+	for _, module := range w.Modules {
+		root := module.Dir
+		filepath.Walk(root, func(filename string, info os.FileInfo, err error) error {
+			if err != nil {
+				// the go tool reports it
+				return nil
+			}
+			base := info.Name()
+			if !info.IsDir() {
+				return nil
+			}
+			if filename != root {
+				if base == "vendor" || base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(filename, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			pkgName := dirPackageName(filename)
+			if pkgName == "" || pkgName == "main" {
+				return nil
+			}
+			rel, err := filepath.Rel(root, filename)
+			if err != nil {
+				return nil
+			}
+			w.packages[pkgName] = append(w.packages[pkgName], path.Join(module.Path, filepath.ToSlash(rel)))
+			return nil
+		})
+	}
+	return w.packages[name]
+}
+
+// dirPackageName gets the name of the package of the Go files in the
+// directory, not counting the tests, or "" if there are none.
+func dirPackageName(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The policies of -allow-plugins besides a list of plugin names. No policy
+// allows no plugin, like allowNone.
+const (
+	allowAll  = "all"
+	allowNone = "none"
+)
+
+// pluginPolicies gets the policies that a plugin must be allowed by: that of
+// -allow-plugins and that of GENNY_ALLOW_PLUGINS, so a command line, like a
+// //go:generate line of a template bundle, can narrow the policy of the
+// environment but not widen it.
+func pluginPolicies(allowPlugins string) []string {
+	policies := []string{allowPlugins}
+	if env, ok := os.LookupEnv(envName("allow-plugins")); ok {
+		policies = append(policies, env)
+	}
+	return policies
+}
+
+// checkPlugin fails unless a policy is set and every policy that is set
+// allows the plugin, which is what keeps a template or its //go:generate
+// lines from running plugins that the one who runs genny didn't choose.
+func checkPlugin(name string, policies []string) error {
+	set := false
+	for _, policy := range policies {
+		if policy == "" {
+			continue
+		}
+		set = true
+		if !pluginAllowed(name, policy) {
+			return fmt.Errorf("the plugin %s isn't allowed by the plugin policy %q, allow it with -allow-plugins or %s", name, policy, envName("allow-plugins"))
+		}
+	}
+	if !set {
+		return fmt.Errorf("the plugin %s isn't allowed, as no plugin policy is set, allow it with -allow-plugins or %s", name, envName("allow-plugins"))
+	}
+	return nil
+}
+
+// pluginAllowed tells whether the policy allows the plugin: all allows every
+// plugin, none allows none and otherwise the policy lists the names of the
+// allowed plugins, separated by commas.
+func pluginAllowed(name, policy string) bool {
+	switch policy {
+	case allowAll:
+		return true
+	case allowNone:
+		return false
+	}
+	for _, allowed := range strings.Split(policy, ",") {
+		if strings.TrimSpace(allowed) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runPolicy gets the plugin policy of the lines that genny run runs, which
+// is what the policies allow together, and allows no plugins if none of them
+// is set, as the lines may be in files that the one running it didn't
+// write.
+func runPolicy(policies []string) string {
+	var allowed []string
+	limited, all := false, false
+	for _, policy := range policies {
+		if policy == allowAll {
+			all = true
+		}
+		if policy == "" || policy == allowAll {
+			continue
+		}
+		var names []string
+		for _, name := range strings.Split(policy, ",") {
+			name = strings.TrimSpace(name)
+			if policy != allowNone && (!limited || contains(allowed, name)) {
+				names = append(names, name)
+			}
+		}
+		allowed, limited = names, true
+	}
+	if all && !limited {
+		return allowAll
+	}
+	if len(allowed) == 0 {
+		return allowNone
+	}
+	return strings.Join(allowed, ",")
+}
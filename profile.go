@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profiler records the profiles that were asked for with the -cpuprofile,
+// -memprofile and -trace flags.
+type profiler struct {
+	cpuFile   *os.File
+	traceFile *os.File
+	memPath   string
+}
+
+// startProfiling starts the CPU profile and the execution trace. Empty paths
+// are not recorded.
+func startProfiling(cpuPath, memPath, tracePath string) (*profiler, error) {
+	p := &profiler{memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		p.cpuFile = f
+	}
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			p.stop()
+			return nil, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			p.stop()
+			return nil, err
+		}
+		p.traceFile = f
+	}
+	return p, nil
+}
+
+// stop stops the profiles and writes the memory profile.
+func (p *profiler) stop() error {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		p.cpuFile.Close()
+	}
+	if p.traceFile != nil {
+		trace.Stop()
+		p.traceFile.Close()
+	}
+	if p.memPath == "" {
+		return nil
+	}
+	f, err := os.Create(p.memPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// get up-to-date statistics
+	runtime.GC()
+	return pprof.WriteHeapProfile(f)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mauricelam/genny/catalog"
+	"github.com/mauricelam/genny/parse"
+)
+
+// defaultPackage is the package that publish writes without -out.
+const defaultPackage = "genny-templates.tar.gz"
+
+// publish checks the templates and packages them for a registry or a
+// release: a tarball at outFile of the templates, with their includes and
+// bases resolved, and of their index, which is also written next to it.
+// With a signKey, every template has its detached signature next to it in
+// the tarball.
+func publish(templates []string, outFile, signKey, diagFormat string) (int, error) {
+	if len(templates) == 0 {
+		return exitcodeInvalidArgs, fmt.Errorf("publish takes the templates to publish")
+	}
+	if code, err := check(templates, diagFormat); code != 0 {
+		return code, err
+	}
+	if outFile == "" {
+		outFile = defaultPackage
+	}
+	var privateKey ed25519.PrivateKey
+	if signKey != "" {
+		var err error
+		if privateKey, err = readPrivateKey(signKey); err != nil {
+			return exitcodeInvalidArgs, err
+		}
+	}
+
+	var index catalog.Index
+	sources := make(map[string][]byte)
+	for _, filename := range templates {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		resolved, err := parse.ResolveTemplate(filename, src)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		name := publishedName(filename)
+		if _, ok := sources[name]; ok {
+			return exitcodeInvalidArgs, fmt.Errorf("two templates are published as %s", name)
+		}
+		sources[name] = resolved
+		entry, err := describe(name, resolved)
+		if err != nil {
+			return exitcodeSourceFileInvalid, err
+		}
+		index.Templates = append(index.Templates, entry)
+	}
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return exitcodeInternalError, err
+	}
+	indexJSON = append(indexJSON, '\n')
+
+	file, err := os.Create(outFile)
+	if err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := add("index.json", indexJSON); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	for _, entry := range index.Templates {
+		if err := add(entry.Name, sources[entry.Name]); err != nil {
+			return exitcodeDestFileFailed, err
+		}
+		if privateKey != nil {
+			if err := add(entry.Name+signatureExt, ed25519.Sign(privateKey, sources[entry.Name])); err != nil {
+				return exitcodeDestFileFailed, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	if err := gz.Close(); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	if err := file.Close(); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+
+	indexFile := outFile
+	for _, ext := range []string{".tgz", ".gz", ".tar"} {
+		indexFile = strings.TrimSuffix(indexFile, ext)
+	}
+	indexFile += ".json"
+	if err := ioutil.WriteFile(indexFile, indexJSON, 0644); err != nil {
+		return exitcodeDestFileFailed, err
+	}
+	fmt.Printf("published %d templates to %s, indexed in %s\n", len(index.Templates), outFile, indexFile)
+	return 0, nil
+}
+
+// publishedName gets the name that a template is published as, which is
+// its path with forward slashes, or its base name if the path is absolute
+// or outside of the current directory.
+func publishedName(filename string) string {
+	name := path.Clean(filepath.ToSlash(filename))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") || filepath.VolumeName(filename) != "" {
+		return path.Base(name)
+	}
+	return name
+}
+
+// describe gets the index entry of a template: its generic types, and the
+// first sentence of its package doc, or else of the doc of its first
+// exported declaration that isn't a generic type, as its description.
+func describe(name string, src []byte) (catalog.Entry, error) {
+	entry := catalog.Entry{Name: name, Generics: catalog.Template{Source: string(src)}.Generics()}
+	file, err := parser.ParseFile(token.NewFileSet(), name, src, parser.ParseComments)
+	if err != nil {
+		return entry, err
+	}
+	doc := file.Doc
+	for _, decl := range file.Decls {
+		if doc != nil {
+			break
+		}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Name.IsExported() {
+				doc = decl.Doc
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.IsExported() && !contains(entry.Generics, ts.Name.Name) {
+					doc = ts.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					break
+				}
+			}
+		}
+	}
+	if doc != nil {
+		entry.Description = firstSentence(doc.Text())
+	}
+	return entry, nil
+}
+
+// firstSentence gets the first sentence of a doc comment, on one line.
+func firstSentence(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if end := strings.Index(text, ". "); end >= 0 {
+		return text[:end+1]
+	}
+	return text
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// signatureExt is added to the name of a template for its detached
+// signature, so list.go is signed by list.go.sig.
+const signatureExt = ".sig"
+
+// readPublicKeys reads the Ed25519 public keys that the signatures of the
+// fetched templates are verified with. Each is a file of a PEM public key, as
+// openssl pkey -pubout writes it, or the key itself in base64.
+func readPublicKeys(keys []string) ([]ed25519.PublicKey, error) {
+	var publicKeys []ed25519.PublicKey
+	for _, key := range keys {
+		data, err := ioutil.ReadFile(key)
+		if err != nil {
+			data = []byte(key)
+		}
+		if block, _ := pem.Decode(data); block != nil {
+			parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("bad key %s: %v", key, err)
+			}
+			publicKey, ok := parsed.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("bad key %s: only Ed25519 keys are supported, not %T", key, parsed)
+			}
+			publicKeys = append(publicKeys, publicKey)
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("bad key %s: it is neither a PEM public key nor %d bytes in base64", key, ed25519.PublicKeySize)
+		}
+		publicKeys = append(publicKeys, ed25519.PublicKey(raw))
+	}
+	return publicKeys, nil
+}
+
+// readPrivateKey reads the Ed25519 private key that publish signs the
+// templates with, a PEM PKCS #8 key as openssl genpkey -algorithm ed25519
+// writes it.
+func readPrivateKey(filename string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("bad key %s: it isn't a PEM private key", filename)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("bad key %s: %v", filename, err)
+	}
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("bad key %s: only Ed25519 keys are supported, not %T", filename, parsed)
+	}
+	return privateKey, nil
+}
+
+// verifySignature verifies the detached signature of a fetched template
+// with the keys, of which one must have signed it. The signature is the 64
+// bytes of openssl pkeyutl -sign -rawin, or them in base64. A template
+// without a signature is fine unless it is required, but one with a bad
+// signature never is.
+func verifySignature(name string, src, sig []byte, keys []ed25519.PublicKey, required bool) error {
+	if sig == nil {
+		if required {
+			return fmt.Errorf("%s has no signature %s%s, and -require-signature is set", name, name, signatureExt)
+		}
+		return nil
+	}
+	if len(keys) == 0 {
+		// there is nothing to verify it with
+		fmt.Fprintf(os.Stderr, "warning: the signature of %s isn't verified, as no -verify-key is given\n", name)
+		return nil
+	}
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return fmt.Errorf("bad signature of %s: it is neither %d bytes nor them in base64", name, ed25519.SignatureSize)
+		}
+		sig = decoded
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, src, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the signature of %s doesn't verify with any -verify-key, so it was changed or signed by someone else", name)
+}
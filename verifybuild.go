@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mauricelam/genny/parse"
+)
+
+// problemLine matches a line of the go command that tells of a problem at
+// a line of a file, like "./gen.go:12:3: undefined: Foo", or one of a test
+// failure, which is indented.
+var problemLine = regexp.MustCompile(`^(\s*)([^\s:]+\.go):(\d+)(:\d+)?:`)
+
+// verifyBuild builds and vets the packages of the files, the generated
+// ones, and tests them too if test is set, so that generated code that
+// doesn't compile fails the run that generated it rather than the build
+// that comes after. The problems that the go command has with a generated
+// file are told with what it was generated from.
+func verifyBuild(files []string, test bool) (int, error) {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, filename := range files {
+		dir, err := filepath.Abs(filepath.Dir(filename))
+		if err != nil {
+			return exitcodeInternalError, err
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return 0, nil
+	}
+	sort.Strings(dirs)
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		return exitcodeInvalidArgs, errors.New("the go command isn't in the PATH, which -verify-build and -verify-test need")
+	}
+
+	steps := []string{"build", "vet"}
+	if test {
+		steps = append(steps, "test")
+	}
+	codes := make(map[string][]byte)
+	for _, step := range steps {
+		cmd := exec.Command(goCmd, append([]string{step}, dirs...)...)
+		var output bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &output, &output
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return exitcodeInternalError, err
+			}
+			for _, line := range strings.SplitAfter(output.String(), "\n") {
+				fmt.Fprint(os.Stderr, withOrigin(line, codes))
+			}
+			return exitcodeGenFailed, fmt.Errorf("go %s failed for the packages of the generated files", step)
+		}
+	}
+	return 0, nil
+}
+
+// withOrigin adds what the generated file of a problem line of the go
+// command was generated from to the line: the template line and type set
+// of the annotation of the declaration, with -annotate, or else the
+// templates of the stamp. Other lines are left as they are. The code of the
+// files is read into codes the first time.
+func withOrigin(line string, codes map[string][]byte) string {
+	m := problemLine.FindStringSubmatch(line)
+	if m == nil {
+		return line
+	}
+	filename := m[2]
+	code, ok := codes[filename]
+	if !ok {
+		code, _ = ioutil.ReadFile(filename)
+		codes[filename] = code
+	}
+	if !parse.IsGenerated(code) {
+		return line
+	}
+	lineNum, _ := strconv.Atoi(m[3])
+	var origin string
+	if annotation, ok := parse.FindAnnotation(code, lineNum); ok {
+		origin = annotation
+	} else if stamp, ok := parse.FindStamp(code); ok {
+		var templates []string
+		for _, template := range stamp.Templates {
+			templates = append(templates, filepath.Join(filepath.Dir(filename), filepath.FromSlash(template)))
+		}
+		origin = strings.Join(templates, ", ")
+	} else {
+		return line
+	}
+	end := strings.TrimRight(line, "\r\n")
+	return end + " (generated from " + origin + ")" + line[len(end):]
+}
@@ -0,0 +1,4 @@
+package stale
+
+// Plain is written by hand, and has no stamp.
+func Plain() {}
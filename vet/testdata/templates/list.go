@@ -0,0 +1,10 @@
+package templates
+
+import "github.com/mauricelam/genny/generic"
+
+type Elem generic.Type
+
+// FirstElem gets the first one in the list.
+func FirstElem(list []Elem) Elem {
+	return list[0]
+}
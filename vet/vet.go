@@ -0,0 +1,55 @@
+// Package vet has an analyzer that reports the files generated by genny
+// that are out of date with their templates.
+//
+// genny records the templates under the header of the files it writes with
+// -out -stamp, with a hash of them. The analyzer hashes the templates again
+// and reports the files whose templates changed since they were generated.
+// The types and flags of the files aren't hashed, so a file is not reported
+// when it should be generated for other types.
+package vet
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mauricelam/genny/parse"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports the generated files that are out of date with their
+// templates.
+var Analyzer = &analysis.Analyzer{
+	Name: "gennystale",
+	Doc:  "report files generated by genny that are out of date with their templates\n\nThe files are regenerated with go generate.",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		filename := pass.Fset.File(file.Pos()).Name()
+		code, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		stamp, ok := parse.FindStamp(code)
+		if !ok {
+			continue
+		}
+		pos := file.Package
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if strings.HasPrefix(comment.Text, "// genny:templates ") {
+					pos = comment.Pos()
+				}
+			}
+		}
+		current, err := stamp.Current(filepath.Dir(filename))
+		if err != nil {
+			pass.Reportf(pos, "can't tell whether %s is up to date with its templates: %v", filepath.Base(filename), err)
+		} else if !current {
+			pass.Reportf(pos, "%s is out of date with its templates %s; run go generate", filepath.Base(filename), strings.Join(stamp.Templates, ", "))
+		}
+	}
+	return nil, nil
+}
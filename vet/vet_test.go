@@ -0,0 +1,55 @@
+package vet_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mauricelam/genny/vet"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/analysis"
+)
+
+// run runs the analyzer on the files of the package in dir, and gets its
+// diagnostics as file:line: message, in order.
+func run(t *testing.T, dir string) []string {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+	if !assert.NoError(t, err) || !assert.Len(t, pkgs, 1) {
+		return nil
+	}
+	var files []*ast.File
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+	var messages []string
+	pass := &analysis.Pass{
+		Analyzer: vet.Analyzer,
+		Fset:     fset,
+		Files:    files,
+		Report: func(d analysis.Diagnostic) {
+			posn := fset.Position(d.Pos)
+			messages = append(messages, filepath.Base(posn.Filename)+":"+strconv.Itoa(posn.Line)+": "+d.Message)
+		},
+	}
+	_, err = vet.Analyzer.Run(pass)
+	assert.NoError(t, err)
+	sort.Strings(messages)
+	return messages
+}
+
+func TestAnalyzer(t *testing.T) {
+	messages := run(t, filepath.Join("testdata", "stale"))
+	// the current and the hand-written files are not reported
+	if assert.Len(t, messages, 2, "%v", messages) {
+		assert.True(t, strings.HasPrefix(messages[0], "missing_gen.go:5: can't tell whether missing_gen.go is up to date with its templates: "), messages[0])
+		assert.Equal(t, "old_gen.go:5: old_gen.go is out of date with its templates ../templates/list.go; run go generate", messages[1])
+	}
+}